@@ -0,0 +1,81 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"fmt"
+	"time"
+)
+
+// 证书链中某个环节的校验错误
+type ChainError struct {
+	// 出错的证书: cabundle[i] 或签名证书
+	Certificate string
+	Subject     string
+	Reason      string
+}
+
+func (e *ChainError) Error() string {
+	return fmt.Sprintf("%s (%s) 校验失败: %s", e.Certificate, e.Subject, e.Reason)
+}
+
+// 逐个环节校验 [cabundle..., 签名证书] 构成有序的链:
+// cabundle[0] 为受信任的根 (或由其签发)，之后每个证书由前一个签发；
+// CA 证书须有 basicConstraints CA=true、keyCertSign 且满足 pathLenConstraint，签名证书不得为 CA
+func verifyChainOrder(cabundle []*x509.Certificate, leaf *x509.Certificate, roots *x509.CertPool, now time.Time) error {
+	if now.IsZero() {
+		now = time.Now()
+	}
+	chain := append(append([]*x509.Certificate{}, cabundle...), leaf)
+	name := func(i int) string {
+		if i == len(chain)-1 {
+			return "签名证书"
+		}
+		return fmt.Sprintf("cabundle[%d]", i)
+	}
+	fail := func(i int, format string, args ...interface{}) error {
+		return &ChainError{Certificate: name(i), Subject: chain[i].Subject.String(), Reason: fmt.Sprintf(format, args...)}
+	}
+
+	for i, cert := range chain {
+		if now.Before(cert.NotBefore) || now.After(cert.NotAfter) {
+			return fail(i, "不在有效期内 (%s ~ %s)", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+		}
+
+		if i == len(chain)-1 {
+			if cert.BasicConstraintsValid && cert.IsCA {
+				return fail(i, "签名证书不应为 CA")
+			}
+			if cert.KeyUsage != 0 && cert.KeyUsage&x509.KeyUsageDigitalSignature == 0 {
+				return fail(i, "keyUsage 不含 digitalSignature")
+			}
+		} else {
+			if !cert.BasicConstraintsValid || !cert.IsCA {
+				return fail(i, "不是 CA 证书 (basicConstraints CA=false)")
+			}
+			if cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+				return fail(i, "keyUsage 不含 keyCertSign")
+			}
+			// 其下方 (不含签名证书) 的中间证书数量不得超过 pathLenConstraint
+			below := len(chain) - 2 - i
+			if (cert.MaxPathLen > 0 || cert.MaxPathLenZero) && below > cert.MaxPathLen {
+				return fail(i, "pathLenConstraint 为 %d，但其下还有 %d 个中间证书", cert.MaxPathLen, below)
+			}
+		}
+
+		if i == 0 {
+			if _, err := cert.Verify(x509.VerifyOptions{Roots: roots, CurrentTime: now, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}); err != nil {
+				return fail(i, "不是受信任的根证书，也不由其签发: %v", err)
+			}
+			continue
+		}
+		parent := chain[i-1]
+		if !bytes.Equal(cert.RawIssuer, parent.RawSubject) {
+			return fail(i, "颁发者 %s 与前一个证书 %s 的主体不符 (cabundle 顺序应为根 → 中间证书 → 签名证书)", cert.Issuer, parent.Subject)
+		}
+		if err := cert.CheckSignatureFrom(parent); err != nil {
+			return fail(i, "不是由 %s 签发: %v", name(i-1), err)
+		}
+	}
+	return nil
+}
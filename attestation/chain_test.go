@@ -0,0 +1,114 @@
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// 生成测试证书，parent 为空时自签名，modify 在签发前修改模板
+func issueCert(t *testing.T, name string, parent *testCA, ca bool, modify func(*x509.Certificate)) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+	}
+	if ca {
+		template.KeyUsage |= x509.KeyUsageCertSign
+	}
+	if modify != nil {
+		modify(template)
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+// 链中出错的环节应被准确指出
+func TestVerifyChainOrder(t *testing.T) {
+	root := issueCert(t, "root", nil, true, nil)
+	intermediate := issueCert(t, "intermediate", root, true, nil)
+	leaf := issueCert(t, "leaf", intermediate, false, nil)
+
+	notCA := issueCert(t, "not-ca", root, false, nil)
+	underNotCA := issueCert(t, "leaf", notCA, false, nil)
+
+	pathLenZero := issueCert(t, "root-pathlen-0", nil, true, func(c *x509.Certificate) { c.MaxPathLenZero = true })
+	pathLenIntermediate := issueCert(t, "intermediate", pathLenZero, true, nil)
+	pathLenLeaf := issueCert(t, "leaf", pathLenIntermediate, false, nil)
+
+	caLeaf := issueCert(t, "ca-leaf", intermediate, true, nil)
+	expired := issueCert(t, "expired", intermediate, false, func(c *x509.Certificate) { c.NotAfter = time.Now().Add(-time.Minute) })
+	other := issueCert(t, "other-root", nil, true, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+	roots.AddCert(pathLenZero.cert)
+
+	tests := []struct {
+		name     string
+		bundle   []*testCA
+		leaf     *testCA
+		wantCert string
+	}{
+		{"有序的链", []*testCA{root, intermediate}, leaf, ""},
+		{"顺序颠倒", []*testCA{intermediate, root}, leaf, "cabundle[1]"},
+		{"中间证书不是 CA", []*testCA{root, notCA}, underNotCA, "cabundle[1]"},
+		{"违反 pathLenConstraint", []*testCA{pathLenZero, pathLenIntermediate}, pathLenLeaf, "cabundle[0]"},
+		{"签名证书为 CA", []*testCA{root, intermediate}, caLeaf, "签名证书"},
+		{"签名证书已过期", []*testCA{root, intermediate}, expired, "签名证书"},
+		{"根证书不受信任", []*testCA{other, intermediate}, leaf, "cabundle[0]"},
+		{"缺少中间证书", []*testCA{root}, leaf, "签名证书"},
+	}
+	for _, tt := range tests {
+		bundle := make([]*x509.Certificate, len(tt.bundle))
+		for i, ca := range tt.bundle {
+			bundle[i] = ca.cert
+		}
+		err := verifyChainOrder(bundle, tt.leaf.cert, roots, time.Time{})
+		if tt.wantCert == "" {
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+			}
+			continue
+		}
+		var chainErr *ChainError
+		if !errors.As(err, &chainErr) {
+			t.Errorf("%s: 期望 ChainError，得到 %v", tt.name, err)
+			continue
+		}
+		if chainErr.Certificate != tt.wantCert {
+			t.Errorf("%s: 出错的证书为 %s，期望 %s (%v)", tt.name, chainErr.Certificate, tt.wantCert, err)
+		}
+	}
+}
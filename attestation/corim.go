@@ -0,0 +1,243 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// CoRIM (draft-ietf-rats-corim) 中用到的 CBOR 标签
+const (
+	tagCOSESign1   = 18
+	tagCoRIM       = 501
+	tagCoMID       = 506
+	hashAlgSHA384  = 7 // named-information 哈希算法注册表
+	hashAlgSHA256  = 1
+	hashAlgSHA512  = 8
+	corimTagsKey   = 1
+	comidTriples   = 4
+	triplesRefVals = 0
+	measurementKey = 0
+	measurementVal = 1
+	mvalDigests    = 2
+)
+
+// 参考值: PCR 索引 → 可接受的值，同一 PCR 有多个值时匹配任一即可
+type ReferenceValues map[int][][]byte
+
+// 加入一个 PCR 参考值
+func (r ReferenceValues) Add(index int, value []byte) {
+	for _, existing := range r[index] {
+		if bytes.Equal(existing, value) {
+			return
+		}
+	}
+	r[index] = append(r[index], value)
+}
+
+// 合并另一组参考值
+func (r ReferenceValues) Merge(other ReferenceValues) {
+	for index, values := range other {
+		for _, value := range values {
+			r.Add(index, value)
+		}
+	}
+}
+
+// 检查度量值: 每个有参考值的 PCR 都必须存在且等于其中一个参考值
+func (r ReferenceValues) Check(measurements map[int][]byte) error {
+	indexes := make([]int, 0, len(r))
+	for index := range r {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		actual, ok := measurements[index]
+		if !ok {
+			return fmt.Errorf("证明文档中没有 PCR%d", index)
+		}
+		matched := false
+		for _, want := range r[index] {
+			if bytes.Equal(actual, want) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("PCR%d 为 %s，与参考值不符", index, hex.EncodeToString(actual))
+		}
+	}
+	return nil
+}
+
+// 从 CoRIM 文件加载 PCR 参考值
+func LoadCoRIM(path string) (ReferenceValues, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 CoRIM 失败: %v", err)
+	}
+	refs, err := ParseCoRIM(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return refs, nil
+}
+
+// 解析 CoRIM (标签 501，或以 COSE_Sign1 签名的 CoRIM) 中各 CoMID 的参考值三元组，
+// 度量键为 PCR 索引 (整数或 "PCRn")，值为 SHA-384/256/512 摘要；签名不在此校验
+// 也接受单独的 CoMID (标签 506)
+func ParseCoRIM(data []byte) (ReferenceValues, error) {
+	var top interface{}
+	if err := cbor.Unmarshal(data, &top); err != nil {
+		return nil, fmt.Errorf("解析 CoRIM 失败: %v", err)
+	}
+	refs := ReferenceValues{}
+	if err := collectCoRIM(top, refs); err != nil {
+		return nil, err
+	}
+	if len(refs) == 0 {
+		return nil, fmt.Errorf("CoRIM 中没有 PCR 参考值")
+	}
+	return refs, nil
+}
+
+func collectCoRIM(item interface{}, refs ReferenceValues) error {
+	tag, ok := item.(cbor.Tag)
+	if !ok {
+		return fmt.Errorf("不是 CoRIM 或 CoMID (缺少 CBOR 标签)")
+	}
+	content, err := unwrapBytes(tag.Content)
+	if err != nil {
+		return err
+	}
+	switch tag.Number {
+	case tagCOSESign1:
+		sign1, ok := content.([]interface{})
+		if !ok || len(sign1) != 4 {
+			return fmt.Errorf("无效的 COSE_Sign1")
+		}
+		payload, ok := sign1[2].([]byte)
+		if !ok {
+			return fmt.Errorf("签名的 CoRIM 缺少载荷")
+		}
+		var inner interface{}
+		if err := cbor.Unmarshal(payload, &inner); err != nil {
+			return fmt.Errorf("解析签名的 CoRIM 载荷失败: %v", err)
+		}
+		return collectCoRIM(inner, refs)
+	case tagCoRIM:
+		corim, ok := content.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("无效的 corim-map")
+		}
+		tags, _ := corim[uint64(corimTagsKey)].([]interface{})
+		for _, t := range tags {
+			if inner, ok := t.(cbor.Tag); ok && inner.Number != tagCoMID {
+				continue // CoSWID、CoTL 等其他标签
+			}
+			if err := collectCoRIM(t, refs); err != nil {
+				return err
+			}
+		}
+		return nil
+	case tagCoMID:
+		comid, ok := content.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("无效的 concise-mid-tag")
+		}
+		triples, _ := comid[uint64(comidTriples)].(map[interface{}]interface{})
+		records, _ := triples[uint64(triplesRefVals)].([]interface{})
+		for _, record := range records {
+			if err := collectReferenceTriple(record, refs); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("不支持的 CBOR 标签 %d", tag.Number)
+	}
+}
+
+// 早期草案中 CoMID 以 bstr 包装，解码后再处理
+func unwrapBytes(content interface{}) (interface{}, error) {
+	b, ok := content.([]byte)
+	if !ok {
+		return content, nil
+	}
+	var inner interface{}
+	if err := cbor.Unmarshal(b, &inner); err != nil {
+		return nil, fmt.Errorf("解析 bstr 包装的标签内容失败: %v", err)
+	}
+	return inner, nil
+}
+
+// reference-triple-record = [environment-map, [+ measurement-map]]
+func collectReferenceTriple(record interface{}, refs ReferenceValues) error {
+	fields, ok := record.([]interface{})
+	if !ok || len(fields) != 2 {
+		return fmt.Errorf("无效的参考值三元组")
+	}
+	measurements, ok := fields[1].([]interface{})
+	if !ok {
+		// 部分实现以单个 measurement-map 表示
+		measurements = []interface{}{fields[1]}
+	}
+	for _, m := range measurements {
+		measurement, ok := m.(map[interface{}]interface{})
+		if !ok {
+			return fmt.Errorf("无效的 measurement-map")
+		}
+		index, ok := pcrIndex(measurement[uint64(measurementKey)])
+		if !ok {
+			continue // 不是 PCR 的度量值
+		}
+		mval, _ := measurement[uint64(measurementVal)].(map[interface{}]interface{})
+		digests, _ := mval[uint64(mvalDigests)].([]interface{})
+		for _, d := range digests {
+			digest, ok := d.([]interface{})
+			if !ok || len(digest) != 2 {
+				return fmt.Errorf("PCR%d 的摘要格式无效", index)
+			}
+			value, ok := digest[1].([]byte)
+			if !ok || !validDigest(digest[0], len(value)) {
+				return fmt.Errorf("PCR%d 的摘要算法或长度无效", index)
+			}
+			refs.Add(index, value)
+		}
+	}
+	return nil
+}
+
+// 度量键: 整数 PCR 索引，或 "PCR0"、"0" 形式的文本
+func pcrIndex(key interface{}) (int, bool) {
+	switch k := key.(type) {
+	case uint64:
+		if k < 32 {
+			return int(k), true
+		}
+	case string:
+		n, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(k), "PCR"))
+		if err == nil && n >= 0 && n < 32 {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func validDigest(alg interface{}, size int) bool {
+	switch alg {
+	case uint64(hashAlgSHA256), "sha-256":
+		return size == 32
+	case uint64(hashAlgSHA384), "sha-384":
+		return size == 48
+	case uint64(hashAlgSHA512), "sha-512":
+		return size == 64
+	}
+	return false
+}
@@ -0,0 +1,175 @@
+// Package attestation 解析并校验 AWS Nitro Enclaves 证明文档 (COSE_Sign1 / CBOR)。
+package attestation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// COSE_Sign1 结构: [protected_header, unprotected_header, payload, signature]
+type coseSign1 struct {
+	_           struct{} `cbor:",toarray"`
+	Protected   []byte
+	Unprotected cbor.RawMessage
+	Payload     []byte
+	Signature   []byte
+}
+
+// 证明文档载荷
+type Document struct {
+	ModuleID    string         `cbor:"module_id" json:"module_id"`
+	Timestamp   uint64         `cbor:"timestamp" json:"timestamp"`
+	Digest      string         `cbor:"digest" json:"digest"`
+	PCRs        map[int][]byte `cbor:"pcrs" json:"pcrs"`
+	Certificate []byte         `cbor:"certificate" json:"certificate"`
+	CABundle    [][]byte       `cbor:"cabundle" json:"cabundle"`
+	PublicKey   []byte         `cbor:"public_key" json:"public_key,omitempty"`
+	UserData    []byte         `cbor:"user_data" json:"user_data,omitempty"`
+	Nonce       []byte         `cbor:"nonce" json:"nonce,omitempty"`
+}
+
+// 解析后的 COSE_Sign1 证明文档
+type SignedDocument struct {
+	Document
+
+	// 受保护头部原文及其中的签名算法
+	Protected []byte
+	Algorithm int64
+
+	// 载荷原文和签名
+	Payload   []byte
+	Signature []byte
+}
+
+// 文档生成时间 (timestamp 为 UTC 毫秒)
+func (d *Document) Time() time.Time {
+	return time.UnixMilli(int64(d.Timestamp)).UTC()
+}
+
+// 是否由调试模式的 Enclave 生成: 调试模式下 PCR0、PCR1、PCR2 全为零
+func (d *Document) IsDebug() bool {
+	for _, index := range []int{0, 1, 2} {
+		value, ok := d.PCRs[index]
+		if !ok {
+			return false
+		}
+		for _, b := range value {
+			if b != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// 检查文档时间戳相对 now 的新鲜度: 早于 maxAge 时过期 (maxAge 为 0 时不检查)，
+// 晚于 now 超过 skew 时视为来自未来
+func (d *Document) CheckAge(now time.Time, maxAge, skew time.Duration) error {
+	return checkAge(d.Time(), now, maxAge, skew)
+}
+
+func checkAge(issued, now time.Time, maxAge, skew time.Duration) error {
+	if issued.After(now.Add(skew)) {
+		return fmt.Errorf("证明文档时间 %s 晚于当前时间超过 %s", issued.Format(time.RFC3339), skew)
+	}
+	if maxAge > 0 && now.Sub(issued) > maxAge {
+		return fmt.Errorf("证明文档生成于 %s，已超过最长有效期 %s", issued.Format(time.RFC3339), maxAge)
+	}
+	return nil
+}
+
+// 解码 Enclave 返回或磁盘保存的文档，PEM 块、JWS 形式的 JSON 和 base64 文本会先被解码
+func Decode(data []byte) []byte {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		if decoded, err := DecodeJWS(data); err == nil {
+			return decoded
+		}
+	}
+	if bytes.Contains(data, []byte("-----BEGIN "+PEMBlockType+"-----")) {
+		if decoded, err := DecodePEM(data); err == nil {
+			return decoded
+		}
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return data
+	}
+	return decoded
+}
+
+// 解析 COSE_Sign1 证明文档，不校验签名和证书链
+func Parse(data []byte) (*SignedDocument, error) {
+	var sign1 coseSign1
+	if err := cbor.Unmarshal(data, &sign1); err != nil {
+		return nil, fmt.Errorf("解析 COSE_Sign1 失败: %v", err)
+	}
+
+	var header map[int64]interface{}
+	if err := cbor.Unmarshal(sign1.Protected, &header); err != nil {
+		return nil, fmt.Errorf("解析受保护头部失败: %v", err)
+	}
+	alg, ok := header[1].(int64)
+	if !ok {
+		return nil, fmt.Errorf("受保护头部缺少签名算法")
+	}
+
+	doc := &SignedDocument{
+		Protected: sign1.Protected,
+		Algorithm: alg,
+		Payload:   sign1.Payload,
+		Signature: sign1.Signature,
+	}
+	if err := cbor.Unmarshal(sign1.Payload, &doc.Document); err != nil {
+		return nil, fmt.Errorf("解析证明文档载荷失败: %v", err)
+	}
+	if err := doc.Document.validate(); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// 按 AWS Nitro Enclaves 文档规范检查必填字段和长度
+func (d *Document) validate() error {
+	if d.ModuleID == "" {
+		return fmt.Errorf("证明文档缺少 module_id")
+	}
+	if d.Digest != "SHA384" {
+		return fmt.Errorf("不支持的摘要算法: %q", d.Digest)
+	}
+	if d.Timestamp == 0 {
+		return fmt.Errorf("证明文档缺少 timestamp")
+	}
+	if len(d.PCRs) == 0 || len(d.PCRs) > 32 {
+		return fmt.Errorf("PCR 数量 %d 无效", len(d.PCRs))
+	}
+	for index, value := range d.PCRs {
+		if index < 0 || index >= 32 {
+			return fmt.Errorf("PCR 索引 %d 无效", index)
+		}
+		if n := len(value); n != 32 && n != 48 && n != 64 {
+			return fmt.Errorf("PCR%d 长度 %d 无效", index, n)
+		}
+	}
+	if len(d.Certificate) == 0 {
+		return fmt.Errorf("证明文档缺少 certificate")
+	}
+	if len(d.CABundle) == 0 {
+		return fmt.Errorf("证明文档缺少 cabundle")
+	}
+	if len(d.PublicKey) > 1024 {
+		return fmt.Errorf("public_key 长度 %d 超过 1024 字节", len(d.PublicKey))
+	}
+	if len(d.UserData) > 512 {
+		return fmt.Errorf("user_data 长度 %d 超过 512 字节", len(d.UserData))
+	}
+	if len(d.Nonce) > 512 {
+		return fmt.Errorf("nonce 长度 %d 超过 512 字节", len(d.Nonce))
+	}
+	return nil
+}
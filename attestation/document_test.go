@@ -0,0 +1,116 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 构造结构合法的证明文档 (签名和证书不可校验)，作为模糊测试的种子
+func seedDocument(t testing.TB) []byte {
+	protected, err := cbor.Marshal(map[int64]interface{}{1: int64(-35)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := cbor.Marshal(Document{
+		ModuleID:    "i-0123456789abcdef0-enc0123456789abcdef",
+		Timestamp:   1700000000000,
+		Digest:      "SHA384",
+		PCRs:        map[int][]byte{0: make([]byte, 48), 1: make([]byte, 48), 2: make([]byte, 48)},
+		Certificate: []byte("certificate"),
+		CABundle:    [][]byte{[]byte("root")},
+		UserData:    []byte("user data"),
+		Nonce:       []byte{0, 1, 2, 3},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := cbor.Marshal(coseSign1{
+		Protected:   protected,
+		Unprotected: cbor.RawMessage{0xa0},
+		Payload:     payload,
+		Signature:   make([]byte, 96),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestParseSeedDocument(t *testing.T) {
+	doc, err := Parse(seedDocument(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Algorithm != -35 || doc.AlgorithmName() != "ES384" || !doc.IsDebug() {
+		t.Fatalf("解析结果不符: alg=%d debug=%v", doc.Algorithm, doc.IsDebug())
+	}
+}
+
+// 文档解析器 (Decode、Parse 及 JSON 输出) 处理任意输入都不 panic，解析成功的文档满足字段检查
+func FuzzParse(f *testing.F) {
+	seed := seedDocument(f)
+	f.Add(seed)
+	f.Add([]byte(base64.StdEncoding.EncodeToString(seed)))
+	f.Add(EncodePEM(seed))
+	f.Add([]byte(`{"typ":"COSE_Sign1","protected":"oQE4Ig","payload":"","signature":""}`))
+	f.Add([]byte{0x84, 0x40, 0xa0, 0x40, 0x40})
+	f.Add([]byte{0x84, 0x43, 0xa1, 0x01, 0x26, 0xa0, 0x41, 0xa0, 0x40})
+	f.Add([]byte("-----BEGIN " + PEMBlockType + "-----\n!!\n-----END " + PEMBlockType + "-----\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		doc, err := Parse(Decode(data))
+		if err != nil {
+			if doc != nil {
+				t.Fatalf("出错时返回了文档: %v", err)
+			}
+			return
+		}
+		if err := doc.Document.validate(); err != nil {
+			t.Fatalf("解析成功的文档未通过字段检查: %v", err)
+		}
+		if _, err := json.Marshal(doc); err != nil {
+			t.Fatalf("输出 JSON 失败: %v", err)
+		}
+		doc.IsDebug()
+		doc.AlgorithmName()
+	})
+}
+
+// PEM 解码器处理任意输入都不 panic，编码后可还原
+func FuzzDecodePEM(f *testing.F) {
+	f.Add([]byte("document"))
+	f.Add(seedDocument(f))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		decoded, err := DecodePEM(EncodePEM(data))
+		if err != nil {
+			t.Fatalf("解码失败: %v", err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("PEM 编码后无法还原")
+		}
+		DecodePEM(data)
+	})
+}
+
+// JWS 形式可逐字节还原为原始 COSE_Sign1 文档
+func TestJWSRoundTrip(t *testing.T) {
+	seed := seedDocument(t)
+	jws, err := EncodeJWS(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jws.Algorithm != "ES384" || jws.Header != "" {
+		t.Fatalf("JWS 形式不符: %+v", jws)
+	}
+	data, err := json.Marshal(jws)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Decode(data), seed) {
+		t.Fatalf("JWS 形式无法还原为原始文档")
+	}
+}
@@ -0,0 +1,125 @@
+package attestation
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// EAT 配置文件标识，接收方据此解释下列私有声明
+const EATProfile = "urn:aws-enclave-attestation:eat:1"
+
+// CWT (RFC 8392) 和 EAT (RFC 9711) 的声明键
+const (
+	claimIssuer      = 1
+	claimIssuedAt    = 6
+	claimNonce       = 10
+	claimDebugStatus = 263
+	claimProfile     = 265
+)
+
+// 未受保护的 CWT 声明集 (UCCS) 的 CBOR 标签
+const uccsTag = 601
+
+// dbgstat: 调试模式的 Enclave 为 enabled，否则自启动起即禁用
+const (
+	debugEnabled           = 0
+	debugDisabledSinceBoot = 2
+)
+
+// EAT 中 eat_nonce 的长度范围，超出时以私有声明 nonce 携带
+const (
+	minEATNonce = 8
+	maxEATNonce = 64
+)
+
+// 生成 EAT 的选项
+type EATOptions struct {
+	// iss 声明，为空时不包含
+	Issuer string
+
+	// 附带的原始证据 (如 COSE_Sign1 证明文档)，接收方可据此重新校验签名
+	Evidence []byte
+}
+
+// 声明名及其 CWT 整数键，CBOR 使用整数键，JSON 使用名称
+type eatClaim struct {
+	key   interface{}
+	name  string
+	value interface{}
+}
+
+// 按 EAT 声明排列，二进制值在 JSON 中由调用方编码
+func (c *Claims) eatClaims(opts EATOptions) []eatClaim {
+	claims := []eatClaim{
+		{claimProfile, "eat_profile", EATProfile},
+		{claimIssuedAt, "iat", c.Time.Unix()},
+	}
+	if opts.Issuer != "" {
+		claims = append(claims, eatClaim{claimIssuer, "iss", opts.Issuer})
+	}
+	if len(c.Nonce) >= minEATNonce && len(c.Nonce) <= maxEATNonce {
+		claims = append(claims, eatClaim{claimNonce, "eat_nonce", c.Nonce})
+	} else if len(c.Nonce) > 0 {
+		claims = append(claims, eatClaim{"nonce", "nonce", c.Nonce})
+	}
+	debug := debugDisabledSinceBoot
+	if c.Debug {
+		debug = debugEnabled
+	}
+	claims = append(claims, eatClaim{claimDebugStatus, "dbgstat", debug})
+
+	// 私有声明
+	claims = append(claims,
+		eatClaim{"evidence_type", "evidence_type", string(c.Type)},
+		eatClaim{"module_id", "module_id", c.ModuleID},
+		eatClaim{"measurements", "measurements", c.Measurements},
+	)
+	if len(c.UserData) > 0 {
+		claims = append(claims, eatClaim{"user_data", "user_data", c.UserData})
+	}
+	if len(c.PublicKey) > 0 {
+		claims = append(claims, eatClaim{"public_key", "public_key", c.PublicKey})
+	}
+	if len(opts.Evidence) > 0 {
+		claims = append(claims, eatClaim{"evidence", "evidence", opts.Evidence})
+	}
+	return claims
+}
+
+// 以 UCCS (标签 601 的 CWT 声明集) 表示声明，不另行签名：
+// 只应对已校验的证据生成，或通过 Evidence 附带原始证据供接收方校验
+func (c *Claims) EAT(opts EATOptions) ([]byte, error) {
+	claims := make(map[interface{}]interface{})
+	for _, claim := range c.eatClaims(opts) {
+		claims[claim.key] = claim.value
+	}
+	mode, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+	return mode.Marshal(cbor.Tag{Number: uccsTag, Content: claims})
+}
+
+// 以 JSON 形式的 EAT 声明集表示声明，二进制值使用无填充的 base64url
+func (c *Claims) EATJSON(opts EATOptions) ([]byte, error) {
+	encode := base64.RawURLEncoding.EncodeToString
+	claims := make(map[string]interface{})
+	for _, claim := range c.eatClaims(opts) {
+		switch value := claim.value.(type) {
+		case []byte:
+			claims[claim.name] = encode(value)
+		case map[int][]byte:
+			measurements := make(map[string]string, len(value))
+			for index, digest := range value {
+				measurements[strconv.Itoa(index)] = encode(digest)
+			}
+			claims[claim.name] = measurements
+		default:
+			claims[claim.name] = value
+		}
+	}
+	return json.Marshal(claims)
+}
@@ -0,0 +1,163 @@
+package attestation
+
+import (
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// EIF (Enclave Image File) 格式，与 aws-nitro-enclaves-image-format 相同，字段均为大端
+const (
+	eifMagic         = ".eif"
+	eifMaxSections   = 32
+	eifHeaderSize    = 4 + 2 + 2 + 8 + 8 + 2 + 2 + eifMaxSections*8*2 + 4 + 4
+	eifSectionHeader = 2 + 2 + 8
+)
+
+// EIF 段类型
+const (
+	eifSectionKernel    = 1
+	eifSectionCmdline   = 2
+	eifSectionRamdisk   = 3
+	eifSectionSignature = 4
+	eifSectionMetadata  = 5
+)
+
+// EIF 计算出的度量值，与 nitro-cli build-enclave / describe-eif 输出的 PCR 相同
+type EIFMeasurements struct {
+	Version uint16
+	// PCR 索引 → 值: PCR0 (镜像)、PCR1 (内核及引导 ramdisk)、PCR2 (应用 ramdisk)，
+	// 已签名的镜像另有 PCR8 (签名证书)
+	PCRs map[int][]byte
+}
+
+// 计算 EIF 文件的 PCR
+func MeasureEIFFile(path string) (*EIFMeasurements, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开 EIF 失败: %v", err)
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	return MeasureEIF(file, info.Size())
+}
+
+// 计算 EIF 的 PCR: 每个 PCR 为 SHA384(48 字节零 || SHA384(相关段的数据))，
+// 即从全零扩展一次所度量内容的摘要；第一个 ramdisk 为引导 ramdisk，其余为应用 ramdisk
+func MeasureEIF(r io.ReaderAt, size int64) (*EIFMeasurements, error) {
+	header := make([]byte, eifHeaderSize)
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("读取 EIF 头部失败: %v", err)
+	}
+	if string(header[:4]) != eifMagic {
+		return nil, fmt.Errorf("不是 EIF 文件 (magic 不符)")
+	}
+	version := binary.BigEndian.Uint16(header[4:])
+	numSections := int(binary.BigEndian.Uint16(header[26:]))
+	if numSections > eifMaxSections {
+		return nil, fmt.Errorf("无效的 EIF: 段数 %d 超过 %d", numSections, eifMaxSections)
+	}
+
+	image, bootstrap, application := sha512.New384(), sha512.New384(), sha512.New384()
+	var certificate []byte
+	ramdisks := 0
+	for i := 0; i < numSections; i++ {
+		offset := int64(binary.BigEndian.Uint64(header[28+i*8:]))
+		if offset < 0 || offset+eifSectionHeader > size {
+			return nil, fmt.Errorf("无效的 EIF: 第 %d 段超出文件范围", i)
+		}
+		sectionHeader := make([]byte, eifSectionHeader)
+		if _, err := r.ReadAt(sectionHeader, offset); err != nil {
+			return nil, fmt.Errorf("读取 EIF 段头失败: %v", err)
+		}
+		kind := binary.BigEndian.Uint16(sectionHeader)
+		length := int64(binary.BigEndian.Uint64(sectionHeader[4:]))
+		start := offset + eifSectionHeader
+		if length < 0 || length > size-start {
+			return nil, fmt.Errorf("无效的 EIF: 第 %d 段超出文件范围", i)
+		}
+		data := io.NewSectionReader(r, start, length)
+
+		var targets []hash.Hash
+		switch kind {
+		case eifSectionKernel, eifSectionCmdline:
+			targets = []hash.Hash{image, bootstrap}
+		case eifSectionRamdisk:
+			if ramdisks == 0 {
+				targets = []hash.Hash{image, bootstrap}
+			} else {
+				targets = []hash.Hash{image, application}
+			}
+			ramdisks++
+		case eifSectionSignature:
+			signature := make([]byte, length)
+			if _, err := io.ReadFull(data, signature); err != nil {
+				return nil, fmt.Errorf("读取 EIF 签名段失败: %v", err)
+			}
+			cert, err := eifSigningCertificate(signature)
+			if err != nil {
+				return nil, err
+			}
+			certificate = cert
+			continue
+		case eifSectionMetadata:
+			continue
+		default:
+			return nil, fmt.Errorf("无效的 EIF: 未知的段类型 %d", kind)
+		}
+		writers := make([]io.Writer, len(targets))
+		for j, h := range targets {
+			writers[j] = h
+		}
+		if _, err := io.Copy(io.MultiWriter(writers...), data); err != nil {
+			return nil, fmt.Errorf("读取 EIF 段失败: %v", err)
+		}
+	}
+	if ramdisks == 0 {
+		return nil, fmt.Errorf("无效的 EIF: 没有 ramdisk 段")
+	}
+
+	zero := make([]byte, sha512.Size384)
+	pcrs := map[int][]byte{
+		PCRImage:       ExtendPCR(zero, image.Sum(nil)),
+		PCRKernel:      ExtendPCR(zero, bootstrap.Sum(nil)),
+		PCRApplication: ExtendPCR(zero, application.Sum(nil)),
+	}
+	if certificate != nil {
+		sum := sha512.Sum384(certificate)
+		pcrs[PCRSigningCert] = ExtendPCR(zero, sum[:])
+	}
+	return &EIFMeasurements{Version: version, PCRs: pcrs}, nil
+}
+
+// 签名段为 CBOR 编码的 [{signing_certificate, signature}] (字节串或整数数组)，证书为 PEM，取第一项的 DER 证书
+func eifSigningCertificate(section []byte) ([]byte, error) {
+	var signatures []struct {
+		SigningCertificate []byte `cbor:"signing_certificate"`
+		Signature          []byte `cbor:"signature"`
+	}
+	if err := cbor.Unmarshal(section, &signatures); err != nil {
+		return nil, fmt.Errorf("解析 EIF 签名段失败: %v", err)
+	}
+	if len(signatures) == 0 {
+		return nil, fmt.Errorf("EIF 签名段为空")
+	}
+	der := signatures[0].SigningCertificate
+	if block, _ := pem.Decode(der); block != nil {
+		der = block.Bytes
+	}
+	if _, err := x509.ParseCertificate(der); err != nil {
+		return nil, fmt.Errorf("解析 EIF 签名证书失败: %v", err)
+	}
+	return der, nil
+}
@@ -0,0 +1,140 @@
+package attestation
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 证据类型，与 Enclave attest 响应中的 evidence_type 相同
+type EvidenceType string
+
+const (
+	// AWS Nitro Enclaves 证明文档 (COSE_Sign1)，未标明类型的证据按此处理
+	EvidenceNitro EvidenceType = "aws-nitro"
+)
+
+// 校验器从证据中取出的声明，各后端统一的表示，策略只检查这些字段
+type Claims struct {
+	Type EvidenceType
+
+	// 实例标识，Nitro 为 module_id
+	ModuleID string
+	// 证据生成时间
+	Time time.Time
+	// 度量值: Nitro 为 PCR 索引 → 值
+	Measurements map[int][]byte
+
+	PublicKey []byte
+	UserData  []byte
+	Nonce     []byte
+
+	// 是否来自调试模式的 TEE，调试模式下度量值不可信
+	Debug bool
+
+	// 后端解析出的证据，Nitro 为 *SignedDocument
+	Evidence interface{}
+}
+
+// 检查证据时间相对 now 的新鲜度，规则与 Document.CheckAge 相同
+func (c *Claims) CheckAge(now time.Time, maxAge, skew time.Duration) error {
+	return checkAge(c.Time, now, maxAge, skew)
+}
+
+// 证据校验器，每种证据类型一个，与 Enclave 端的 Attester 后端对应
+type Verifier interface {
+	Type() EvidenceType
+	// 解析证据，不校验签名和信任链
+	Parse(evidence []byte) (*Claims, error)
+	// 校验签名和信任链，返回证据中的声明
+	Verify(evidence []byte, opts VerifyOptions) (*Claims, error)
+}
+
+var (
+	verifiersMu sync.RWMutex
+	verifiers   = map[EvidenceType]Verifier{
+		EvidenceNitro: nitroVerifier{},
+	}
+)
+
+// 注册证据校验器，同一类型的校验器会被替换
+func RegisterVerifier(v Verifier) {
+	verifiersMu.Lock()
+	defer verifiersMu.Unlock()
+	verifiers[v.Type()] = v
+}
+
+// 证据类型对应的校验器，类型为空时使用 Nitro 校验器
+func LookupVerifier(t EvidenceType) (Verifier, error) {
+	if t == "" {
+		t = EvidenceNitro
+	}
+	verifiersMu.RLock()
+	defer verifiersMu.RUnlock()
+	v, ok := verifiers[t]
+	if !ok {
+		names := make([]string, 0, len(verifiers))
+		for name := range verifiers {
+			names = append(names, string(name))
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("不支持的证据类型: %s (可选 %s)", t, strings.Join(names, "、"))
+	}
+	return v, nil
+}
+
+// 以证据类型对应的校验器校验证据
+func VerifyEvidence(t EvidenceType, evidence []byte, opts VerifyOptions) (*Claims, error) {
+	v, err := LookupVerifier(t)
+	if err != nil {
+		return nil, err
+	}
+	return v.Verify(evidence, opts)
+}
+
+// 以证据类型对应的校验器解析证据，不校验签名
+func ParseEvidence(t EvidenceType, evidence []byte) (*Claims, error) {
+	v, err := LookupVerifier(t)
+	if err != nil {
+		return nil, err
+	}
+	return v.Parse(evidence)
+}
+
+// Nitro 证明文档校验器
+type nitroVerifier struct{}
+
+func (nitroVerifier) Type() EvidenceType { return EvidenceNitro }
+
+func (nitroVerifier) Parse(evidence []byte) (*Claims, error) {
+	doc, err := Parse(evidence)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Claims(), nil
+}
+
+func (nitroVerifier) Verify(evidence []byte, opts VerifyOptions) (*Claims, error) {
+	doc, err := Verify(evidence, opts)
+	if err != nil {
+		return nil, err
+	}
+	return doc.Claims(), nil
+}
+
+// 文档的统一声明表示
+func (d *SignedDocument) Claims() *Claims {
+	return &Claims{
+		Type:         EvidenceNitro,
+		ModuleID:     d.ModuleID,
+		Time:         d.Time(),
+		Measurements: d.PCRs,
+		PublicKey:    d.PublicKey,
+		UserData:     d.UserData,
+		Nonce:        d.Nonce,
+		Debug:        d.IsDebug(),
+		Evidence:     d,
+	}
+}
@@ -0,0 +1,215 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// go test ./attestation -update 以当前解析结果重新生成 testdata/*.json
+var update = flag.Bool("update", false, "重新生成 testdata 中的期望 JSON")
+
+// testdata 中的文档由模拟 NSM (enclave --mock-nsm) 生成，以 mock-root.pem 签发:
+//
+//	minimal.bin  不含 user_data、nonce、public_key
+//	full.bin     含 user_data、nonce 和 P-384 public_key
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func mockRoots(t *testing.T) VerifyOptions {
+	t.Helper()
+	roots, err := LoadRoots(filepath.Join("testdata", "mock-root.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return VerifyOptions{Roots: roots}
+}
+
+// 重新编码 COSE_Sign1，mutate 修改解析出的结构
+func resign(t *testing.T, data []byte, mutate func(*coseSign1)) []byte {
+	t.Helper()
+	var sign1 coseSign1
+	if err := cbor.Unmarshal(data, &sign1); err != nil {
+		t.Fatal(err)
+	}
+	mutate(&sign1)
+	out, err := cbor.Marshal(sign1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// 解析结果与 testdata 中的期望 JSON 一致，raw、base64 和 PEM 格式解析结果相同
+func TestParseGolden(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  func(raw []byte) []byte
+		golden string
+	}{
+		{"minimal.bin", nil, "minimal.json"},
+		{"full.bin", nil, "full.json"},
+		{"full.bin", func(raw []byte) []byte { return []byte(base64.StdEncoding.EncodeToString(raw) + "\n") }, "full.json"},
+		{"full.bin", EncodePEM, "full.json"},
+		{"full.bin", func(raw []byte) []byte { return append([]byte("工单附件:\n"), EncodePEM(raw)...) }, "full.json"},
+	}
+	for _, tt := range tests {
+		data := readFixture(t, tt.name)
+		if tt.input != nil {
+			data = tt.input(data)
+		}
+		doc, err := Parse(Decode(data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, '\n')
+
+		golden := filepath.Join("testdata", tt.golden)
+		if *update {
+			if err := os.WriteFile(golden, got, 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if want := readFixture(t, tt.golden); !bytes.Equal(got, want) {
+			t.Errorf("%s: 解析结果与 %s 不一致:\n%s", tt.name, tt.golden, got)
+		}
+	}
+}
+
+// 校验器对合法文档、篡改文档和错误信任配置的结论保持不变
+func TestVerifyGolden(t *testing.T) {
+	full := readFixture(t, "full.bin")
+	doc, err := Parse(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issued := doc.Time()
+
+	tests := []struct {
+		name    string
+		data    []byte
+		opts    func(VerifyOptions) VerifyOptions
+		wantErr string
+	}{
+		{
+			name: "minimal",
+			data: readFixture(t, "minimal.bin"),
+		},
+		{
+			name: "full",
+			data: full,
+		},
+		{
+			name:    "AWS 根证书",
+			data:    full,
+			opts:    func(VerifyOptions) VerifyOptions { return VerifyOptions{Time: issued} },
+			wantErr: "证书链校验失败",
+		},
+		{
+			name: "根证书指纹不符",
+			data: full,
+			opts: func(o VerifyOptions) VerifyOptions {
+				pin, _ := ParseFingerprint(AWSNitroRootFingerprint)
+				o.RootFingerprints = [][]byte{pin}
+				return o
+			},
+			wantErr: "不在固定的指纹中",
+		},
+		{
+			name:    "签名证书已过期",
+			data:    full,
+			opts:    func(o VerifyOptions) VerifyOptions { o.Time = issued.Add(10 * 365 * 24 * time.Hour); return o },
+			wantErr: "证书链校验失败",
+		},
+		{
+			name:    "签名被篡改",
+			data:    resign(t, full, func(s *coseSign1) { s.Signature[0] ^= 0xff }),
+			wantErr: "COSE 签名校验失败",
+		},
+		{
+			name: "载荷被篡改",
+			data: resign(t, full, func(s *coseSign1) {
+				var d Document
+				if err := cbor.Unmarshal(s.Payload, &d); err != nil {
+					t.Fatal(err)
+				}
+				d.UserData = []byte("tampered user data")
+				payload, err := cbor.Marshal(d)
+				if err != nil {
+					t.Fatal(err)
+				}
+				s.Payload = payload
+			}),
+			wantErr: "COSE 签名校验失败",
+		},
+		{
+			name:    "签名长度错误",
+			data:    resign(t, full, func(s *coseSign1) { s.Signature = s.Signature[:10] }),
+			wantErr: "签名长度 10 无效",
+		},
+		{
+			name:    "文档被截断",
+			data:    full[:len(full)/2],
+			wantErr: "解析 COSE_Sign1 失败",
+		},
+	}
+	for _, tt := range tests {
+		opts := mockRoots(t)
+		opts.Time = issued
+		if tt.opts != nil {
+			opts = tt.opts(opts)
+		}
+		_, err := Verify(tt.data, opts)
+		switch {
+		case tt.wantErr == "" && err != nil:
+			t.Errorf("%s: %v", tt.name, err)
+		case tt.wantErr != "" && err == nil:
+			t.Errorf("%s: 期望错误 %q，校验却通过", tt.name, tt.wantErr)
+		case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+			t.Errorf("%s: 期望错误包含 %q，实际为 %v", tt.name, tt.wantErr, err)
+		}
+	}
+}
+
+// 文档的时间、调试模式和时效检查
+func TestDocumentGolden(t *testing.T) {
+	doc, err := Parse(readFixture(t, "full.bin"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.IsDebug() {
+		t.Error("模拟 NSM 生成的文档 PCR0-2 非零，不应视为调试模式")
+	}
+	if string(doc.UserData) != "golden user data" || string(doc.Nonce) != "golden-nonce-0001" || len(doc.PublicKey) == 0 {
+		t.Errorf("user_data/nonce/public_key 不符: %q %q %d", doc.UserData, doc.Nonce, len(doc.PublicKey))
+	}
+	issued := doc.Time()
+	if err := doc.CheckAge(issued.Add(time.Minute), 5*time.Minute, 0); err != nil {
+		t.Error(err)
+	}
+	if err := doc.CheckAge(issued.Add(time.Hour), 5*time.Minute, 0); err == nil {
+		t.Error("超过 max-age 的文档应被拒绝")
+	}
+	if err := doc.CheckAge(issued.Add(-time.Hour), 0, time.Minute); err == nil {
+		t.Error("来自未来的文档应被拒绝")
+	}
+}
@@ -0,0 +1,49 @@
+package attestation
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// 证明文档的 JSON 表示，PCR 和签名使用十六进制，其余二进制字段使用 base64
+type documentJSON struct {
+	ModuleID    string            `json:"module_id"`
+	Timestamp   uint64            `json:"timestamp"`
+	Time        string            `json:"time"`
+	Digest      string            `json:"digest"`
+	Algorithm   string            `json:"algorithm"`
+	PCRs        map[string]string `json:"pcrs"`
+	Certificate []byte            `json:"certificate"`
+	CABundle    [][]byte          `json:"cabundle"`
+	PublicKey   []byte            `json:"public_key,omitempty"`
+	UserData    []byte            `json:"user_data,omitempty"`
+	Nonce       []byte            `json:"nonce,omitempty"`
+	Signature   string            `json:"signature"`
+	Debug       bool              `json:"debug"`
+}
+
+// 以便于 jq 等工具处理的结构输出
+func (d *SignedDocument) MarshalJSON() ([]byte, error) {
+	pcrs := make(map[string]string, len(d.PCRs))
+	for index, value := range d.PCRs {
+		pcrs[strconv.Itoa(index)] = hex.EncodeToString(value)
+	}
+
+	return json.Marshal(documentJSON{
+		ModuleID:    d.ModuleID,
+		Timestamp:   d.Timestamp,
+		Time:        d.Time().Format(time.RFC3339Nano),
+		Digest:      d.Digest,
+		Algorithm:   d.AlgorithmName(),
+		PCRs:        pcrs,
+		Certificate: d.Certificate,
+		CABundle:    d.CABundle,
+		PublicKey:   d.PublicKey,
+		UserData:    d.UserData,
+		Nonce:       d.Nonce,
+		Signature:   hex.EncodeToString(d.Signature),
+		Debug:       d.IsDebug(),
+	})
+}
@@ -0,0 +1,84 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// JWS 形式中标明 COSE 结构的 typ
+const JWSType = "COSE_Sign1"
+
+// 以 JWS Flattened JSON 的形式表示的 COSE_Sign1 文档: 各部分为 base64url (无填充) 编码的原始字节，
+// protected/header 仍为 CBOR，只是便于 Web 后端以 JSON 转发证据，不是可按 JWS 校验的签名
+type JWSDocument struct {
+	Type      string `json:"typ"`
+	Algorithm string `json:"alg"`
+	Protected string `json:"protected"`
+	Header    string `json:"header,omitempty"`
+	Payload   string `json:"payload"`
+	Signature string `json:"signature"`
+}
+
+// 将 COSE_Sign1 文档拆分为 JWS 形式
+func EncodeJWS(data []byte) (*JWSDocument, error) {
+	var sign1 coseSign1
+	if err := cbor.Unmarshal(data, &sign1); err != nil {
+		return nil, fmt.Errorf("解析 COSE_Sign1 失败: %v", err)
+	}
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	jws := &JWSDocument{
+		Type:      JWSType,
+		Algorithm: doc.AlgorithmName(),
+		Protected: base64.RawURLEncoding.EncodeToString(sign1.Protected),
+		Payload:   base64.RawURLEncoding.EncodeToString(sign1.Payload),
+		Signature: base64.RawURLEncoding.EncodeToString(sign1.Signature),
+	}
+	// Nitro 文档的非受保护头部为空 map，省略
+	if len(sign1.Unprotected) > 0 && !bytes.Equal(sign1.Unprotected, []byte{0xa0}) {
+		jws.Header = base64.RawURLEncoding.EncodeToString(sign1.Unprotected)
+	}
+	return jws, nil
+}
+
+// 由 JWS 形式还原 COSE_Sign1 文档，Nitro 文档可逐字节还原
+func (j *JWSDocument) COSE() ([]byte, error) {
+	if j.Type != JWSType {
+		return nil, fmt.Errorf("不支持的 typ: %q", j.Type)
+	}
+	var (
+		sign1 coseSign1
+		err   error
+	)
+	if sign1.Protected, err = base64.RawURLEncoding.DecodeString(j.Protected); err != nil {
+		return nil, fmt.Errorf("解码 protected 失败: %v", err)
+	}
+	sign1.Unprotected = cbor.RawMessage{0xa0}
+	if j.Header != "" {
+		if sign1.Unprotected, err = base64.RawURLEncoding.DecodeString(j.Header); err != nil {
+			return nil, fmt.Errorf("解码 header 失败: %v", err)
+		}
+	}
+	if sign1.Payload, err = base64.RawURLEncoding.DecodeString(j.Payload); err != nil {
+		return nil, fmt.Errorf("解码 payload 失败: %v", err)
+	}
+	if sign1.Signature, err = base64.RawURLEncoding.DecodeString(j.Signature); err != nil {
+		return nil, fmt.Errorf("解码 signature 失败: %v", err)
+	}
+	return cbor.Marshal(sign1)
+}
+
+// 解析 JWS 形式的文档并还原为 COSE_Sign1
+func DecodeJWS(data []byte) ([]byte, error) {
+	var jws JWSDocument
+	if err := json.Unmarshal(data, &jws); err != nil {
+		return nil, fmt.Errorf("解析 JWS 形式的文档失败: %v", err)
+	}
+	return jws.COSE()
+}
@@ -0,0 +1,91 @@
+package attestation
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 生成 JWT 的选项
+type JWTOptions struct {
+	// iss、aud 声明，为空时不包含
+	Issuer   string
+	Audience string
+
+	// 有效期，iat/nbf 为签发时间 (而不是证据生成时间)
+	TTL time.Duration
+
+	// JWS 头中的 kid
+	KeyID string
+
+	// 签发时间，零值时为当前时间
+	Now time.Time
+}
+
+// 将校验通过的证据重新表达为由校验方密钥签名的 JWT，供只能处理 JWT 的服务使用:
+// sub 为 module_id，PCR 为 pcrN 声明 (十六进制，与 OIDC Broker 相同)，nonce 为十六进制，
+// user_data 只给出 SHA-256 摘要，att_time 为证据生成时间
+func (c *Claims) JWT(key *ecdsa.PrivateKey, opts JWTOptions) (string, error) {
+	method, err := jwtSigningMethod(key)
+	if err != nil {
+		return "", err
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	claims := jwt.MapClaims{
+		"sub":           c.ModuleID,
+		"iat":           now.Unix(),
+		"nbf":           now.Unix(),
+		"att_time":      c.Time.Unix(),
+		"evidence_type": string(c.Type),
+		"module_id":     c.ModuleID,
+		"debug":         c.Debug,
+	}
+	if opts.TTL > 0 {
+		claims["exp"] = now.Add(opts.TTL).Unix()
+	}
+	if opts.Issuer != "" {
+		claims["iss"] = opts.Issuer
+	}
+	if opts.Audience != "" {
+		claims["aud"] = opts.Audience
+	}
+	for index, value := range c.Measurements {
+		claims["pcr"+strconv.Itoa(index)] = hex.EncodeToString(value)
+	}
+	if len(c.Nonce) > 0 {
+		claims["nonce"] = hex.EncodeToString(c.Nonce)
+	}
+	if len(c.UserData) > 0 {
+		digest := sha256.Sum256(c.UserData)
+		claims["user_data_sha256"] = hex.EncodeToString(digest[:])
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	if opts.KeyID != "" {
+		token.Header["kid"] = opts.KeyID
+	}
+	return token.SignedString(key)
+}
+
+// 按私钥曲线选择 JWS 算法
+func jwtSigningMethod(key *ecdsa.PrivateKey) (jwt.SigningMethod, error) {
+	switch key.Curve {
+	case elliptic.P256():
+		return jwt.SigningMethodES256, nil
+	case elliptic.P384():
+		return jwt.SigningMethodES384, nil
+	case elliptic.P521():
+		return jwt.SigningMethodES512, nil
+	}
+	return nil, fmt.Errorf("不支持的曲线: %s", key.Curve.Params().Name)
+}
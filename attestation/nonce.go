@@ -0,0 +1,53 @@
+package attestation
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// 一次性随机数存储，用于确认证明文档是针对本次请求新生成的
+type NonceStore struct {
+	// 随机数有效期
+	TTL time.Duration
+
+	mu     sync.Mutex
+	nonces map[string]time.Time
+}
+
+// 生成新的随机数 (32 字节十六进制)，同时清理已过期的随机数
+func (s *NonceStore) Issue() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	nonce := hex.EncodeToString(buf)
+
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.nonces == nil {
+		s.nonces = make(map[string]time.Time)
+	}
+	for n, expiry := range s.nonces {
+		if now.After(expiry) {
+			delete(s.nonces, n)
+		}
+	}
+	s.nonces[nonce] = now.Add(s.TTL)
+	return nonce, nil
+}
+
+// 消耗随机数，每个随机数只能使用一次
+func (s *NonceStore) Consume(nonce string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.nonces[nonce]
+	if !ok {
+		return false
+	}
+	delete(s.nonces, nonce)
+	return time.Now().Before(expiry)
+}
@@ -0,0 +1,55 @@
+package attestation
+
+import (
+	"crypto/sha512"
+	"fmt"
+	"path"
+)
+
+// Nitro Enclaves 各 PCR 的含义
+const (
+	// Enclave 镜像文件
+	PCRImage = 0
+	// Linux 内核及引导
+	PCRKernel = 1
+	// 应用
+	PCRApplication = 2
+	// 父实例的 IAM 角色
+	PCRParentRole = 3
+	// 父实例 ID
+	PCRInstanceID = 4
+	// Enclave 镜像签名证书
+	PCRSigningCert = 8
+)
+
+// 与 NSM 相同的扩展运算: SHA384(pcr || data)
+func ExtendPCR(pcr, data []byte) []byte {
+	h := sha512.New384()
+	h.Write(pcr)
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// 由父实例 IAM 角色 ARN 计算期望的 PCR3 (从全零扩展一次角色 ARN)
+func ParentRolePCR(roleARN string) []byte {
+	return ExtendPCR(make([]byte, sha512.Size384), []byte(roleARN))
+}
+
+// 由父实例 ID 计算期望的 PCR4 (从全零扩展一次实例 ID)
+func InstanceIDPCR(instanceID string) []byte {
+	return ExtendPCR(make([]byte, sha512.Size384), []byte(instanceID))
+}
+
+// 检查 module_id 是否匹配任一通配符模式 (path.Match 语法，如 i-0abc*-enc*)
+func MatchModuleID(moduleID string, patterns ...string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, moduleID)
+		if err != nil {
+			return false, fmt.Errorf("无效的 module_id 模式 %q: %v", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,28 @@
+package attestation
+
+import (
+	"encoding/pem"
+	"fmt"
+)
+
+// PEM 格式证明文档的块类型
+const PEMBlockType = "ATTESTATION DOCUMENT"
+
+// 将证明文档编码为 -----BEGIN ATTESTATION DOCUMENT----- PEM 块
+func EncodePEM(doc []byte) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: PEMBlockType, Bytes: doc})
+}
+
+// 从 PEM 数据中取出第一个证明文档块，忽略其前后的其他内容
+func DecodePEM(data []byte) ([]byte, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("未找到 %s PEM 块", PEMBlockType)
+		}
+		if block.Type == PEMBlockType {
+			return block.Bytes, nil
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// 吊销检查模式
+type RevocationMode string
+
+const (
+	// 不检查吊销状态
+	RevocationOff RevocationMode = ""
+	// 尽力检查: 证书已吊销时拒绝，无法获取吊销状态时放行
+	RevocationSoftFail RevocationMode = "soft"
+	// 严格检查: 无法获取任一证书的吊销状态时同样拒绝
+	RevocationHardFail RevocationMode = "hard"
+)
+
+// 吊销检查选项
+type RevocationOptions struct {
+	Mode RevocationMode
+
+	// 本地 CRL (隔离网络中的校验方预先下载)，优先于在线查询
+	CRLs []*x509.RevocationList
+
+	// 不在线查询 OCSP 和 CRL 分发点，只使用本地 CRL
+	Offline bool
+
+	// 在线查询所用的 HTTP 客户端，为空时使用 10 秒超时的默认客户端
+	HTTPClient *http.Client
+}
+
+// 从 PEM (X509 CRL) 或 DER 文件加载 CRL
+func LoadCRLs(paths ...string) ([]*x509.RevocationList, error) {
+	var crls []*x509.RevocationList
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取 CRL 失败: %v", err)
+		}
+		ders := [][]byte{data}
+		if block, _ := pem.Decode(data); block != nil {
+			ders = nil
+			for rest := data; ; {
+				block, rest = pem.Decode(rest)
+				if block == nil {
+					break
+				}
+				if block.Type == "X509 CRL" {
+					ders = append(ders, block.Bytes)
+				}
+			}
+		}
+		if len(ders) == 0 {
+			return nil, fmt.Errorf("%s 中没有 CRL", path)
+		}
+		for _, der := range ders {
+			crl, err := x509.ParseRevocationList(der)
+			if err != nil {
+				return nil, fmt.Errorf("解析 %s 失败: %v", path, err)
+			}
+			crls = append(crls, crl)
+		}
+	}
+	return crls, nil
+}
+
+// 吊销状态
+type revocationStatus int
+
+const (
+	statusUnknown revocationStatus = iota
+	statusGood
+	statusRevoked
+)
+
+// 检查链中除根证书外每个证书的吊销状态，chain 按 [签名证书, ..., 根] 排列
+func checkRevocation(chain []*x509.Certificate, opts RevocationOptions, now time.Time) error {
+	if opts.Mode == RevocationOff {
+		return nil
+	}
+	if now.IsZero() {
+		now = time.Now()
+	}
+	client := opts.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	for i := 0; i < len(chain)-1; i++ {
+		cert, issuer := chain[i], chain[i+1]
+		status, source, reason := statusUnknown, "", "没有覆盖该证书的本地 CRL"
+
+		for _, crl := range opts.CRLs {
+			if s, err := crlStatus(crl, cert, issuer, now); err == nil {
+				status, source = s, "本地 CRL"
+				break
+			}
+		}
+		if status == statusUnknown && !opts.Offline {
+			status, source, reason = onlineStatus(client, cert, issuer, now)
+		}
+
+		switch status {
+		case statusRevoked:
+			return fmt.Errorf("证书 %s (序列号 %s) 已被吊销 (%s)", cert.Subject, cert.SerialNumber.Text(16), source)
+		case statusUnknown:
+			if opts.Mode == RevocationHardFail {
+				return fmt.Errorf("无法获取证书 %s 的吊销状态: %s", cert.Subject, reason)
+			}
+		}
+	}
+	return nil
+}
+
+// CRL 须由 issuer 签发且在 now 时有效，否则返回错误 (不适用)
+func crlStatus(crl *x509.RevocationList, cert, issuer *x509.Certificate, now time.Time) (revocationStatus, error) {
+	if !bytes.Equal(crl.RawIssuer, issuer.RawSubject) {
+		return statusUnknown, fmt.Errorf("CRL 颁发者不符")
+	}
+	if err := crl.CheckSignatureFrom(issuer); err != nil {
+		return statusUnknown, fmt.Errorf("CRL 签名无效: %v", err)
+	}
+	if now.Before(crl.ThisUpdate) || (!crl.NextUpdate.IsZero() && now.After(crl.NextUpdate)) {
+		return statusUnknown, fmt.Errorf("CRL 不在有效期内 (%s ~ %s)", crl.ThisUpdate.Format(time.RFC3339), crl.NextUpdate.Format(time.RFC3339))
+	}
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 && !entry.RevocationTime.After(now) {
+			return statusRevoked, nil
+		}
+	}
+	return statusGood, nil
+}
+
+// 依次查询 OCSP 和 CRL 分发点，返回状态、来源及无法获取时的原因
+func onlineStatus(client *http.Client, cert, issuer *x509.Certificate, now time.Time) (revocationStatus, string, string) {
+	reason := "证书中没有 OCSP 地址或 CRL 分发点"
+	for _, server := range cert.OCSPServer {
+		status, err := ocspStatus(client, server, cert, issuer, now)
+		if err == nil {
+			return status, "OCSP " + server, ""
+		}
+		reason = fmt.Sprintf("OCSP %s: %v", server, err)
+	}
+	for _, url := range cert.CRLDistributionPoints {
+		crl, err := fetchCRL(client, url)
+		if err == nil {
+			var status revocationStatus
+			if status, err = crlStatus(crl, cert, issuer, now); err == nil {
+				return status, "CRL " + url, ""
+			}
+		}
+		reason = fmt.Sprintf("CRL %s: %v", url, err)
+	}
+	return statusUnknown, "", reason
+}
+
+// OCSP 响应须在 now 时有效 (与 CRL 相同的校验时间)，否则返回错误 (不适用)
+func ocspStatus(client *http.Client, server string, cert, issuer *x509.Certificate, now time.Time) (revocationStatus, error) {
+	request, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return statusUnknown, err
+	}
+	resp, err := client.Post(server, "application/ocsp-request", bytes.NewReader(request))
+	if err != nil {
+		return statusUnknown, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return statusUnknown, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return statusUnknown, err
+	}
+	response, err := ocsp.ParseResponseForCert(body, cert, issuer)
+	if err != nil {
+		return statusUnknown, err
+	}
+	if now.Before(response.ThisUpdate) || (!response.NextUpdate.IsZero() && now.After(response.NextUpdate)) {
+		return statusUnknown, fmt.Errorf("OCSP 响应不在有效期内 (%s ~ %s)", response.ThisUpdate.Format(time.RFC3339), response.NextUpdate.Format(time.RFC3339))
+	}
+	switch response.Status {
+	case ocsp.Good:
+		return statusGood, nil
+	case ocsp.Revoked:
+		return statusRevoked, nil
+	default:
+		return statusUnknown, fmt.Errorf("OCSP 响应状态未知")
+	}
+}
+
+func fetchCRL(client *http.Client, url string) (*x509.RevocationList, error) {
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("HTTP %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 32<<20))
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(body); block != nil {
+		body = block.Bytes
+	}
+	return x509.ParseRevocationList(body)
+}
@@ -0,0 +1,75 @@
+package attestation
+
+import (
+	"crypto/x509"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// 过期或尚未生效的 OCSP 响应不能证明证书未被吊销
+func TestOCSPResponseValidity(t *testing.T) {
+	var thisUpdate, nextUpdate time.Time
+	var root, leaf *testCA
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		request, err := ocsp.ParseRequest(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		response, err := ocsp.CreateResponse(root.cert, root.cert, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: request.SerialNumber,
+			ThisUpdate:   thisUpdate,
+			NextUpdate:   nextUpdate,
+		}, root.key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Write(response)
+	}))
+	defer server.Close()
+
+	root = issueCert(t, "root", nil, true, nil)
+	leaf = issueCert(t, "leaf", root, false, func(c *x509.Certificate) { c.OCSPServer = []string{server.URL} })
+	chain := []*x509.Certificate{leaf.cert, root.cert}
+	now := time.Now()
+
+	tests := []struct {
+		name       string
+		thisUpdate time.Time
+		nextUpdate time.Time
+		wantErr    string
+	}{
+		{"有效", now.Add(-time.Hour), now.Add(time.Hour), ""},
+		{"未指定 nextUpdate", now.Add(-time.Hour), time.Time{}, ""},
+		{"已过期", now.Add(-2 * time.Hour), now.Add(-time.Hour), "OCSP 响应不在有效期内"},
+		{"尚未生效", now.Add(time.Hour), now.Add(2 * time.Hour), "OCSP 响应不在有效期内"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thisUpdate, nextUpdate = tt.thisUpdate, tt.nextUpdate
+			err := checkRevocation(chain, RevocationOptions{Mode: RevocationHardFail}, now)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("有效的 OCSP 响应应被接受: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("期望错误包含 %q，实际: %v", tt.wantErr, err)
+			}
+			// 宽松模式下无法确认状态时放行
+			if err := checkRevocation(chain, RevocationOptions{Mode: RevocationSoftFail}, now); err != nil {
+				t.Fatalf("宽松模式下应放行: %v", err)
+			}
+		})
+	}
+}
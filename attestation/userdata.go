@@ -0,0 +1,160 @@
+package attestation
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// user_data 声明的常用键
+const (
+	ClaimAppVersion = "app_version"
+	ClaimGitSHA     = "git_sha"
+	ClaimConfigHash = "config_hash"
+	// 过期时间 (Unix 秒)，校验方在其之后拒绝
+	ClaimExpiry = "exp"
+)
+
+// user_data 声明的编码格式
+const (
+	ClaimsCBOR = "cbor"
+	ClaimsJSON = "json"
+)
+
+// 写入 user_data 的声明集: 值为字符串，exp 为 Unix 秒
+type UserDataClaims map[string]interface{}
+
+// 解析 key=value 形式的声明并加入声明集；exp 的值可为 RFC3339 时间、相对 now 的时长 (如 24h) 或 Unix 秒
+func (c UserDataClaims) Set(claim string, now time.Time) error {
+	key, value, ok := strings.Cut(claim, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("无效的声明 %q: 格式应为 key=value", claim)
+	}
+	if _, exists := c[key]; exists {
+		return fmt.Errorf("声明 %s 重复", key)
+	}
+	if key != ClaimExpiry {
+		c[key] = value
+		return nil
+	}
+	expiry, err := parseExpiry(value, now)
+	if err != nil {
+		return fmt.Errorf("无效的声明 %q: %v", claim, err)
+	}
+	c[key] = expiry.Unix()
+	return nil
+}
+
+func parseExpiry(value string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(d), nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("exp 应为 RFC3339 时间、时长或 Unix 秒")
+}
+
+// 按格式编码声明集，同一声明集总是得到相同的字节:
+// CBOR 使用 RFC 8949 核心确定性编码，JSON 按键排序且无多余空白
+func (c UserDataClaims) Encode(format string) ([]byte, error) {
+	switch format {
+	case ClaimsCBOR:
+		mode, err := cbor.CoreDetEncOptions().EncMode()
+		if err != nil {
+			return nil, err
+		}
+		return mode.Marshal(map[string]interface{}(c))
+	case ClaimsJSON:
+		return json.Marshal(map[string]interface{}(c))
+	default:
+		return nil, fmt.Errorf("不支持的声明格式: %s (可选 cbor、json)", format)
+	}
+}
+
+// 解析 user_data 中的声明集，按首字节识别 CBOR map 或 JSON 对象；整数值统一为 int64
+func DecodeUserDataClaims(data []byte) (UserDataClaims, error) {
+	raw := make(map[string]interface{})
+	switch {
+	case len(data) > 0 && data[0]>>5 == 5:
+		if err := cbor.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("解析 CBOR 声明失败: %v", err)
+		}
+	case bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")):
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("解析 JSON 声明失败: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("user_data 不是 CBOR map 或 JSON 对象")
+	}
+
+	claims := make(UserDataClaims, len(raw))
+	for key, value := range raw {
+		switch v := value.(type) {
+		case uint64:
+			claims[key] = int64(v)
+		case json.Number:
+			if n, err := v.Int64(); err == nil {
+				claims[key] = n
+			} else {
+				claims[key] = v.String()
+			}
+		default:
+			claims[key] = v
+		}
+	}
+	return claims, nil
+}
+
+// 过期时间，未声明 exp 时 ok 为 false
+func (c UserDataClaims) Expiry() (expiry time.Time, ok bool) {
+	seconds, ok := c[ClaimExpiry].(int64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(seconds, 0), true
+}
+
+// 检查声明: expect 中每个键的值须等于所列值之一，声明了 exp 时 now 不得晚于它
+func (c UserDataClaims) Check(expect map[string][]string, now time.Time) error {
+	if expiry, ok := c.Expiry(); ok && now.After(expiry) {
+		return fmt.Errorf("user_data 声明已于 %s 过期", expiry.UTC().Format(time.RFC3339))
+	} else if _, declared := c[ClaimExpiry]; declared && !ok {
+		return fmt.Errorf("user_data 声明的 exp 不是整数")
+	}
+
+	keys := make([]string, 0, len(expect))
+	for key := range expect {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value, ok := c[key]
+		if !ok {
+			return fmt.Errorf("user_data 缺少声明 %s", key)
+		}
+		actual := fmt.Sprint(value)
+		matched := false
+		for _, want := range expect[key] {
+			if actual == want {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("user_data 声明 %s=%s 不在期望值 %s 中", key, actual, strings.Join(expect[key], "、"))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package attestation
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// 声明集编码与 --claim 的顺序无关，两种格式均可还原并通过检查
+func TestUserDataClaims(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	build := func(pairs ...string) UserDataClaims {
+		claims := make(UserDataClaims)
+		for _, pair := range pairs {
+			if err := claims.Set(pair, now); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return claims
+	}
+	a := build("app_version=1.2.3", "git_sha=abc123", "exp=1h")
+	b := build("exp=1h", "git_sha=abc123", "app_version=1.2.3")
+
+	for _, format := range []string{ClaimsCBOR, ClaimsJSON} {
+		encodedA, err := a.Encode(format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		encodedB, _ := b.Encode(format)
+		if !bytes.Equal(encodedA, encodedB) {
+			t.Fatalf("%s 编码不确定: %x != %x", format, encodedA, encodedB)
+		}
+
+		decoded, err := DecodeUserDataClaims(encodedA)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expiry, ok := decoded.Expiry(); !ok || !expiry.Equal(now.Add(time.Hour)) {
+			t.Fatalf("%s: exp 不符: %v", format, decoded[ClaimExpiry])
+		}
+		expect := map[string][]string{ClaimAppVersion: {"1.2.2", "1.2.3"}, ClaimGitSHA: {"abc123"}}
+		if err := decoded.Check(expect, now); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+		if err := decoded.Check(map[string][]string{ClaimGitSHA: {"def456"}}, now); err == nil {
+			t.Fatalf("%s: 值不符时未拒绝", format)
+		}
+		if err := decoded.Check(map[string][]string{ClaimConfigHash: {"x"}}, now); err == nil {
+			t.Fatalf("%s: 缺少声明时未拒绝", format)
+		}
+		if err := decoded.Check(nil, now.Add(2*time.Hour)); err == nil {
+			t.Fatalf("%s: 过期时未拒绝", format)
+		}
+	}
+
+	if err := make(UserDataClaims).Set("novalue", now); err == nil {
+		t.Fatal("无效的声明未报错")
+	}
+	if _, err := DecodeUserDataClaims([]byte("plain text")); err == nil {
+		t.Fatal("非声明集的 user_data 未报错")
+	}
+}
@@ -0,0 +1,217 @@
+package attestation
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	_ "embed"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// AWS Nitro Enclaves 根证书 (G1)
+// SHA-256 指纹: 641A0321A3E244EFE456463195D606317ED7CDCC3C1756E09893F3C68F79BB5B
+//
+//go:embed aws_nitro_enclaves_root_g1.pem
+var awsNitroRootPEM []byte
+
+// 内置 AWS 根证书的指纹，可用于 VerifyOptions.RootFingerprints
+const AWSNitroRootFingerprint = "sha256:641A0321A3E244EFE456463195D606317ED7CDCC3C1756E09893F3C68F79BB5B"
+
+// COSE 签名算法
+const (
+	algES256 = -7
+	algES384 = -35
+	algES512 = -36
+)
+
+// 签名算法名称
+func (d *SignedDocument) AlgorithmName() string {
+	switch d.Algorithm {
+	case algES256:
+		return "ES256"
+	case algES384:
+		return "ES384"
+	case algES512:
+		return "ES512"
+	default:
+		return fmt.Sprintf("未知 (%d)", d.Algorithm)
+	}
+}
+
+// 校验选项
+type VerifyOptions struct {
+	// 信任的根证书，为空时使用内置的 AWS Nitro Enclaves 根证书
+	Roots *x509.CertPool
+
+	// 校验证书有效期所用的时间，为零时使用当前时间
+	// 签名证书有效期很短，审计归档文档时可设为文档的生成时间
+	Time time.Time
+
+	// 固定的根证书 SHA-256 指纹，非空时校验所用的根证书必须是其中之一
+	RootFingerprints [][]byte
+
+	// 证书链的吊销检查，默认不检查
+	Revocation RevocationOptions
+}
+
+// 内置的 AWS Nitro Enclaves 根证书池
+func DefaultRoots() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(awsNitroRootPEM)
+	return pool
+}
+
+// 从 PEM 文件加载根证书，每个文件可包含多个证书
+// 用于测试环境的模拟 NSM 或内部 PKI
+func LoadRoots(paths ...string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("读取根证书失败: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("%s 中没有有效的 PEM 证书", path)
+		}
+	}
+	return pool, nil
+}
+
+// 解析证明文档并校验 COSE 签名和证书链
+func Verify(data []byte, opts VerifyOptions) (*SignedDocument, error) {
+	doc, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, err := x509.ParseCertificate(doc.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("解析签名证书失败: %v", err)
+	}
+
+	if err := verifyChain(doc, leaf, opts); err != nil {
+		return nil, err
+	}
+	if err := verifySignature(doc, leaf); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// 校验签名证书经 cabundle 链接到受信任的根证书
+func verifyChain(doc *SignedDocument, leaf *x509.Certificate, opts VerifyOptions) error {
+	roots := opts.Roots
+	if roots == nil {
+		roots = DefaultRoots()
+	}
+
+	// cabundle 按 [根, 中间证书..., 最接近签名证书的中间证书] 排列，根证书以本地信任为准
+	intermediates := x509.NewCertPool()
+	bundle := make([]*x509.Certificate, 0, len(doc.CABundle))
+	for i, der := range doc.CABundle {
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return fmt.Errorf("解析 cabundle[%d] 失败: %v", i, err)
+		}
+		intermediates.AddCert(cert)
+		bundle = append(bundle, cert)
+	}
+	if len(bundle) > 0 {
+		if err := verifyChainOrder(bundle, leaf, roots, opts.Time); err != nil {
+			return fmt.Errorf("证书链校验失败: %w", err)
+		}
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		CurrentTime:   opts.Time,
+	})
+	if err != nil {
+		return fmt.Errorf("证书链校验失败: %v", err)
+	}
+	if err := checkRootFingerprint(chains, opts.RootFingerprints); err != nil {
+		return err
+	}
+	return checkRevocation(chains[0], opts.Revocation, opts.Time)
+}
+
+// 任一校验通过的链以固定指纹的根证书结尾即可
+func checkRootFingerprint(chains [][]*x509.Certificate, fingerprints [][]byte) error {
+	if len(fingerprints) == 0 {
+		return nil
+	}
+	var root *x509.Certificate
+	for _, chain := range chains {
+		root = chain[len(chain)-1]
+		sum := sha256.Sum256(root.Raw)
+		for _, fingerprint := range fingerprints {
+			if bytes.Equal(sum[:], fingerprint) {
+				return nil
+			}
+		}
+	}
+	sum := sha256.Sum256(root.Raw)
+	return fmt.Errorf("根证书 %s 的 SHA-256 指纹 %s 不在固定的指纹中", root.Subject, strings.ToUpper(hex.EncodeToString(sum[:])))
+}
+
+// 解析根证书指纹，格式为 sha256:HEX，十六进制可含冒号 (如 openssl x509 -fingerprint 的输出)
+func ParseFingerprint(s string) ([]byte, error) {
+	value := s
+	if algorithm, rest, ok := strings.Cut(s, ":"); ok && strings.EqualFold(algorithm, "sha256") {
+		value = rest
+	}
+	fingerprint, err := hex.DecodeString(strings.ReplaceAll(value, ":", ""))
+	if err != nil || len(fingerprint) != sha256.Size {
+		return nil, fmt.Errorf("无效的根证书指纹 %q: 应为 sha256:<64 位十六进制>", s)
+	}
+	return fingerprint, nil
+}
+
+// 按 RFC 8152 构造 Sig_structure 并校验 ECDSA 签名
+func verifySignature(doc *SignedDocument, leaf *x509.Certificate) error {
+	var hash crypto.Hash
+	var size int
+	switch doc.Algorithm {
+	case algES256:
+		hash, size = crypto.SHA256, 32
+	case algES384:
+		hash, size = crypto.SHA384, 48
+	case algES512:
+		hash, size = crypto.SHA512, 66
+	default:
+		return fmt.Errorf("不支持的 COSE 签名算法: %d", doc.Algorithm)
+	}
+
+	key, ok := leaf.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("签名证书公钥不是 ECDSA")
+	}
+	if len(doc.Signature) != 2*size {
+		return fmt.Errorf("签名长度 %d 无效", len(doc.Signature))
+	}
+
+	sigStructure, err := cbor.Marshal([]interface{}{"Signature1", doc.Protected, []byte{}, doc.Payload})
+	if err != nil {
+		return err
+	}
+
+	h := hash.New()
+	h.Write(sigStructure)
+	r := new(big.Int).SetBytes(doc.Signature[:size])
+	s := new(big.Int).SetBytes(doc.Signature[size:])
+	if !ecdsa.Verify(key, h.Sum(nil), r, s) {
+		return fmt.Errorf("COSE 签名校验失败")
+	}
+	return nil
+}
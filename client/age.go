@@ -0,0 +1,31 @@
+package client
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+)
+
+// age 插件包装文件密钥时 HPKE 的 info，与 Seal 的消息区分 - 与 enclave 端匹配
+const ageSealInfo = "aws-enclave-attestation age file key v1"
+
+// 以 Enclave 解密公钥 (32 字节的 X25519 公钥) 包装 age 文件密钥，只能由该 Enclave 的 age-unwrap 方法解出
+func SealAgeFileKey(publicKey, fileKey []byte) (*SealedMessage, error) {
+	key, err := ecdh.X25519().NewPublicKey(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 X25519 公钥: %v", err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return seal(spki, fileKey, ageSealInfo)
+}
+
+// 请求 Enclave 解出 SealAgeFileKey 包装的 age 文件密钥 (SealedMessage 的 JSON)，响应的 FileKey 为文件密钥；
+// Enclave 需以 --allow-age-unwrap 启动
+func (c *Client) AgeUnwrap(ctx context.Context, sealed []byte) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodAgeUnwrap, DataB64: base64.StdEncoding.EncodeToString(sealed)})
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+)
+
+const (
+	// HMAC-SHA256 标签长度 - 与 enclave 端匹配
+	hmacTagSize = sha256.Size
+
+	// 帧方向 - 与 enclave 端匹配
+	directionRequest  = 'Q'
+	directionResponse = 'R'
+)
+
+// 响应帧的 HMAC 校验失败
+var ErrBadFrameMAC = errors.New("响应帧 HMAC 校验失败")
+
+// 客户端随机数长度 - 与 enclave 端的挑战长度匹配
+const hmacNonceSize = 16
+
+// 解析 HMAC 密钥文件的内容: 除末尾换行外都是可打印 ASCII 时视为文本并去除末尾换行，否则按原始字节使用 - 与 enclave 端匹配
+func ParseHMACKey(data []byte) []byte {
+	text := strings.TrimRight(string(data), "\r\n")
+	for i := 0; i < len(text); i++ {
+		if text[i] < 0x20 || text[i] > 0x7e {
+			return data
+		}
+	}
+	return []byte(text)
+}
+
+// 握手记录的摘要: 握手 (含客户端随机数) 和握手响应 (含 Enclave 的挑战) 原文，各带 4 字节长度前缀 - 与 enclave 端匹配
+func hmacTranscript(hello, ack []byte) []byte {
+	h := sha256.New()
+	for _, part := range [][]byte{hello, ack} {
+		binary.Write(h, binary.BigEndian, uint32(len(part)))
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// 按帧收发数据，启用 HMAC 时每帧前附加认证标签，标签覆盖握手记录摘要 - 与 enclave 端匹配
+type frameConn struct {
+	rw io.ReadWriter
+
+	key        []byte
+	transcript []byte
+	channel    uint32
+	recvSeq    uint64
+	sendSeq    uint64
+}
+
+func newFrameConn(rw io.ReadWriter, key []byte, transcript []byte, channel uint32) *frameConn {
+	return &frameConn{rw: rw, key: key, transcript: transcript, channel: channel}
+}
+
+// 读取一帧并校验响应方向的 HMAC
+func (c *frameConn) ReadFrame() ([]byte, error) {
+	frame, err := readFrame(c.rw)
+	if err != nil || c.key == nil {
+		return frame, err
+	}
+	if len(frame) < hmacTagSize {
+		return nil, ErrBadFrameMAC
+	}
+
+	tag, payload := frame[:hmacTagSize], frame[hmacTagSize:]
+	if !hmac.Equal(tag, c.mac(directionResponse, c.recvSeq, payload)) {
+		return nil, ErrBadFrameMAC
+	}
+	c.recvSeq++
+	return payload, nil
+}
+
+// 写出一帧，启用 HMAC 时附加请求方向的标签
+func (c *frameConn) WriteFrame(payload []byte) error {
+	if c.key == nil {
+		return writeFrame(c.rw, payload)
+	}
+
+	frame := append(c.mac(directionRequest, c.sendSeq, payload), payload...)
+	c.sendSeq++
+	return writeFrame(c.rw, frame)
+}
+
+func (c *frameConn) mac(direction byte, seq uint64, payload []byte) []byte {
+	var header [13]byte
+	binary.BigEndian.PutUint32(header[0:4], c.channel)
+	header[4] = direction
+	binary.BigEndian.PutUint64(header[5:13], seq)
+
+	m := hmac.New(sha256.New, c.key)
+	m.Write(c.transcript)
+	m.Write(header[:])
+	m.Write(payload)
+	return m.Sum(nil)
+}
@@ -0,0 +1,910 @@
+// Package client 实现与 Enclave 中 vsock 服务器通信的客户端协议。
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"github.com/klauspost/compress/zstd"
+	"github.com/mdlayher/vsock"
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// 单帧最大长度 - 与 enclave 端匹配
+	maxFrameSize = 16 << 20
+
+	// 请求/响应的 schema 版本及可降级到的最低版本 - 与 enclave 端匹配
+	SchemaVersion    = 2
+	minSchemaVersion = 1
+
+	// 请求/响应编码
+	CodecJSON     = "json"
+	CodecCBOR     = "cbor"
+	CodecProtobuf = "protobuf"
+
+	// 响应压缩算法
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+
+	// 流式响应中每个分块帧的首字节 - 与 enclave 端匹配
+	chunkMore  = 0x01
+	chunkFinal = 0x00
+)
+
+// 命令行参数结构 - 与 enclave 端匹配
+type CommandArgs struct {
+	// 请求使用的 schema 版本，为 0 时使用 SchemaVersion
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// 请求方法，为空时等同于 attest
+	Method    string `json:"method,omitempty"`
+	UserData  string `json:"user_data"`
+	PublicKey string `json:"public_key,omitempty"`
+	Nonce     string `json:"nonce,omitempty"`
+	// base64 编码的二进制 user_data，与 UserData 互斥
+	UserDataB64 string `json:"user_data_b64,omitempty"`
+	// base64 编码的二进制 nonce，与 Nonce 互斥
+	NonceB64 string `json:"nonce_b64,omitempty"`
+	// token 方法: JWT 的 aud 和有效期 (秒)
+	Audience string `json:"audience,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
+	// attest 方法: 跳过 Enclave 的证明文档缓存
+	Fresh bool `json:"fresh,omitempty"`
+	// W3C traceparent，为空时使用 ctx 中的 span
+	TraceParent string `json:"traceparent,omitempty"`
+	// get-random 方法: 随机字节数
+	Length int `json:"length,omitempty"`
+	// extend-pcr 方法: PCR 索引及 base64 编码的扩展数据
+	PCRIndex uint16 `json:"pcr_index,omitempty"`
+	DataB64  string `json:"data_b64,omitempty"`
+	// describe-pcr 方法: PCR 索引，为空时读取全部 PCR
+	PCRIndices []uint16 `json:"pcr_indices,omitempty"`
+	// lock-pcrs 方法: 锁定 PCR0 到 PCR(pcr_range-1)；lock-pcr 方法使用 pcr_index
+	PCRRange uint16 `json:"pcr_range,omitempty"`
+	// attest-batch 方法: 在同一请求中处理的多个 attest 请求
+	Batch []CommandArgs `json:"batch,omitempty"`
+	// file-push、file-pull 方法: --file-root 下的相对路径及本块的偏移；
+	// file-push 的数据在 data_b64 中，最后一块 final 为 true 并携带整个文件的 SHA-256 (十六进制)，
+	// file-pull 每块最多读取 length 字节
+	Path   string `json:"path,omitempty"`
+	Offset int64  `json:"offset,omitempty"`
+	Final  bool   `json:"final,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// set-time 方法: 主机的当前时间 (Unix 纳秒) 及可选的时间证明 (时间机构签名的 JWT)
+	TimeUnixNano int64  `json:"time_unix_nano,omitempty"`
+	TimeProof    string `json:"time_proof,omitempty"`
+	// get-secret 方法: Secrets Manager 密钥 ID (名称或 ARN)、要求的 KMS 密钥、区域及 Enclave 内的句柄 (默认为 secret_id)
+	SecretID string `json:"secret_id,omitempty"`
+	KeyID    string `json:"key_id,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Handle   string `json:"handle,omitempty"`
+	// get-parameter 方法: SSM SecureString 参数名称或 ARN，key_id、region、handle 与 get-secret 相同
+	Parameter string `json:"parameter,omitempty"`
+	// kms-sign 方法: 签名算法 (如 ECDSA_SHA_256)，data_b64 为消息，digest 为 true 时 data_b64 已是摘要；key_id、region 与 get-secret 相同
+	SigningAlgorithm string `json:"signing_algorithm,omitempty"`
+	Digest           bool   `json:"digest,omitempty"`
+	// decrypt-object 方法: 由 Enclave 读取的对象 (s3://BUCKET/KEY)；或由主机以 handle、offset、data_b64、final 分块上传密文，
+	// 第一块的 envelope 为对象元数据 (x-amz-key-v2 等) 的 JSON；region 与 get-secret 相同
+	S3URI    string `json:"s3_uri,omitempty"`
+	Envelope string `json:"envelope,omitempty"`
+	// session 方法: session-open 返回的会话 ID 及本请求的序号 (从 0 开始逐个递增)，data_b64 为加密的请求
+	Session  string `json:"session,omitempty"`
+	Sequence uint64 `json:"sequence,omitempty"`
+}
+
+// 请求方法 - 与 enclave 端匹配
+const (
+	MethodAttest        = "attest"
+	MethodToken         = "token"
+	MethodSigningKey    = "signing-key"
+	MethodHealth        = "health"
+	MethodDescribeNSM   = "describe-nsm"
+	MethodGetRandom     = "get-random"
+	MethodDescribePCR   = "describe-pcr"
+	MethodExtendPCR     = "extend-pcr"
+	MethodLockPCR       = "lock-pcr"
+	MethodLockPCRs      = "lock-pcrs"
+	MethodAttestBatch   = "attest-batch"
+	MethodFilePush      = "file-push"
+	MethodFilePull      = "file-pull"
+	MethodSetTime       = "set-time"
+	MethodGetSecret     = "get-secret"
+	MethodGetParameter  = "get-parameter"
+	MethodKMSSign       = "kms-sign"
+	MethodDecryptObject = "decrypt-object"
+	MethodDecryptKey    = "decrypt-key"
+	MethodDecrypt       = "decrypt"
+	MethodAgeUnwrap     = "age-unwrap"
+	MethodSessionOpen   = "session-open"
+	MethodSession       = "session"
+	MethodSessionRekey  = "session-rekey"
+)
+
+// 响应结构 - 与 enclave 端匹配
+type Response struct {
+	Success      bool   `json:"success"`
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+	Document     string `json:"document,omitempty"`
+	Token        string `json:"token,omitempty"`
+	Version      string `json:"version,omitempty"`
+	// 响应使用的 schema 版本，与请求的版本相同；旧版 Enclave 不返回
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// 请求带 traceparent 时 Enclave 内记录的 span
+	Trace []TraceSpan `json:"trace,omitempty"`
+	// describe-nsm 方法的结果
+	NSM *NSMDescription `json:"nsm,omitempty"`
+	// get-random 方法的随机数
+	Random []byte `json:"random,omitempty"`
+	// describe-pcr、extend-pcr、lock-pcr(s) 方法的结果: PCR 索引 → 锁定状态及值
+	PCRs map[uint16]PCRState `json:"pcrs,omitempty"`
+	// attest-batch 方法的结果，与请求中的 batch 一一对应
+	Batch []Response `json:"batch,omitempty"`
+	// attest 方法: 证据类型 (如 EvidenceNitro)；旧版 Enclave 不返回，此时为 Nitro 证明文档
+	EvidenceType string `json:"evidence_type,omitempty"`
+	// file-push、file-pull 方法的结果
+	File *FileChunk `json:"file,omitempty"`
+	// set-time 方法的结果
+	Time *TimeStatus `json:"time,omitempty"`
+	// get-secret、get-parameter 方法的结果
+	Secret *SecretHandle `json:"secret,omitempty"`
+	// kms-sign 方法的结果，document 为绑定签名的证明文档
+	Signature *KMSSignature `json:"signature,omitempty"`
+	// decrypt-object 方法的结果
+	Object *ObjectResult `json:"object,omitempty"`
+	// age-unwrap 方法解出的 age 文件密钥
+	FileKey []byte `json:"file_key,omitempty"`
+	// session-open 方法建立的会话，document 为绑定会话公钥的证明文档
+	Session *SessionInfo `json:"session,omitempty"`
+	// session 方法加密的响应
+	Sealed []byte `json:"sealed,omitempty"`
+}
+
+// 证据类型 - 与 enclave 端匹配
+const (
+	// AWS Nitro Enclaves 证明文档 (COSE_Sign1)
+	EvidenceNitro = "aws-nitro"
+)
+
+// 一个 PCR 的锁定状态和值 (十六进制) - 与 enclave 端匹配
+type PCRState struct {
+	Locked bool   `json:"locked" cbor:"locked"`
+	Value  string `json:"value" cbor:"value"`
+}
+
+// NSM 的版本、模块 ID、PCR 数量及已锁定的 PCR - 与 enclave 端匹配
+type NSMDescription struct {
+	ModuleID     string   `json:"module_id" cbor:"module_id"`
+	VersionMajor uint16   `json:"version_major" cbor:"version_major"`
+	VersionMinor uint16   `json:"version_minor" cbor:"version_minor"`
+	VersionPatch uint16   `json:"version_patch" cbor:"version_patch"`
+	MaxPCRs      uint16   `json:"max_pcrs" cbor:"max_pcrs"`
+	LockedPCRs   []uint16 `json:"locked_pcrs" cbor:"locked_pcrs"`
+	Digest       string   `json:"digest" cbor:"digest"`
+}
+
+// set-time 方法的结果 - 与 enclave 端匹配
+type TimeStatus struct {
+	// 同步后 Enclave 使用的当前时间 (Unix 纳秒)
+	TimeUnixNano int64 `json:"time_unix_nano" cbor:"time_unix_nano"`
+	// 相对 Enclave 系统时钟的修正量 (纳秒)
+	OffsetNS int64 `json:"offset_ns" cbor:"offset_ns"`
+	// 时间由 Enclave 信任的时间机构签名
+	Authenticated bool   `json:"authenticated,omitempty" cbor:"authenticated,omitempty"`
+	NTPServer     string `json:"ntp_server,omitempty" cbor:"ntp_server,omitempty"`
+}
+
+// get-secret 方法的结果: 只有句柄和元数据，明文不离开 Enclave - 与 enclave 端匹配
+type SecretHandle struct {
+	// Enclave 内的进程以该句柄读取明文 (本地 HTTP 接口的 GET /secrets/<handle>)
+	Handle string `json:"handle" cbor:"handle"`
+	// 密钥的 ARN 及版本
+	ARN       string `json:"arn,omitempty" cbor:"arn,omitempty"`
+	VersionID string `json:"version_id,omitempty" cbor:"version_id,omitempty"`
+	// 解密所用的 KMS 密钥 ARN
+	KeyID string `json:"key_id,omitempty" cbor:"key_id,omitempty"`
+	// 明文字节数
+	Size int `json:"size" cbor:"size"`
+}
+
+// kms-sign 方法的结果 - 与 enclave 端匹配
+type KMSSignature struct {
+	// 签名所用的 KMS 密钥 ARN 及签名算法
+	KeyID            string `json:"key_id" cbor:"key_id"`
+	SigningAlgorithm string `json:"signing_algorithm" cbor:"signing_algorithm"`
+	Signature        []byte `json:"signature" cbor:"signature"`
+}
+
+// 握手请求 - 与 enclave 端匹配
+type hello struct {
+	Mux         bool     `json:"mux,omitempty"`
+	Codec       string   `json:"codec,omitempty"`
+	Compression []string `json:"compression,omitempty"`
+	ChunkSize   int      `json:"chunk_size,omitempty"`
+	HMAC        bool     `json:"hmac,omitempty"`
+	HMACNonce   []byte   `json:"hmac_nonce,omitempty"`
+	Noise       string   `json:"noise,omitempty"`
+	NoiseNonce  string   `json:"noise_nonce,omitempty"`
+}
+
+// 握手响应 - 与 enclave 端匹配
+type helloAck struct {
+	Mux          bool   `json:"mux"`
+	Codec        string `json:"codec"`
+	Compression  string `json:"compression,omitempty"`
+	ChunkSize    int    `json:"chunk_size,omitempty"`
+	HMAC         bool   `json:"hmac,omitempty"`
+	Challenge    []byte `json:"challenge,omitempty"`
+	Noise        string `json:"noise,omitempty"`
+	NoiseStatic  []byte `json:"noise_static,omitempty"`
+	Attestation  string `json:"attestation,omitempty"`
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// 连接选项
+type Options struct {
+	// 在一条 vsock 连接上使用 yamux 多路复用，允许并发请求
+	Mux bool
+
+	// 请求/响应编码 (CodecJSON、CodecCBOR、CodecProtobuf 或通过 RegisterCodec 注册的编码)，默认 JSON
+	Codec string
+
+	// 可接受的响应压缩算法，按优先级排列，为空时不压缩
+	Compression []string
+
+	// 非 0 时请求 Enclave 按该大小分块流式返回响应，响应大小不再受单帧上限约束
+	ChunkSize int
+
+	// 与 Enclave 共享的 HMAC 密钥，设置后每个请求/响应帧都携带并校验 HMAC
+	HMACKey []byte
+
+	// 建立 Noise 加密通道 (NoiseNK 或 NoiseXX)，Enclave 静态公钥由证明文档证明
+	Noise string
+
+	// Noise XX 模式下客户端的 32 字节 X25519 静态私钥
+	NoiseClientKey []byte
+
+	// 通过 RA-TLS 连接 Enclave 的 RA-TLS 端口，信任来自证书中嵌入的证明文档
+	RATLS bool
+
+	// 握手后以 session-open 建立加密会话，之后的请求都加密后经 session 方法发送
+	Session bool
+
+	// 校验 Enclave 在 Noise 握手、RA-TLS 证书或 session-open 中提供的证明文档 (签名、证书链、PCR 等)，
+	// 为空时以内置的 AWS Nitro Enclaves 根证书校验签名和证书链；公钥和随机数绑定总是检查
+	VerifyAttestation func(document []byte) error
+}
+
+// 校验通道或会话证明文档所用的函数
+func (o *Options) verifier() func(document []byte) error {
+	if o.VerifyAttestation != nil {
+		return o.VerifyAttestation
+	}
+	return verifyAttestation
+}
+
+// 以内置的 AWS Nitro Enclaves 根证书校验证明文档的 COSE 签名和证书链
+func verifyAttestation(document []byte) error {
+	_, err := attestation.Verify(document, attestation.VerifyOptions{})
+	return err
+}
+
+// 与 Enclave 的长连接
+type Client struct {
+	conn    net.Conn
+	session *yamux.Session
+
+	// 请求帧经过的传输层，启用 Noise 时为加密连接
+	transport net.Conn
+
+	// Noise 握手、RA-TLS 握手或建立加密会话时收到的证明文档
+	attestation []byte
+
+	// 启用会话模式时请求经该会话加密发送，会话超过最长有效期后以 verify 校验重新建立的会话
+	secureMu sync.Mutex
+	secure   *Session
+	verify   func(document []byte) error
+
+	codec Codec
+
+	// 握手协商出的响应压缩算法
+	compression string
+
+	// 握手协商出的分块大小，0 表示单帧响应
+	chunkSize int
+
+	// HMAC 认证参数，hmacKey 为空表示未启用
+	hmacKey    []byte
+	transcript []byte
+
+	// 非多路复用模式下整条连接共用一个帧序列
+	fc *frameConn
+
+	// 非多路复用模式下请求必须串行
+	mu sync.Mutex
+}
+
+// 连接到 Enclave 并完成握手
+func Dial(cid uint32, port uint32, opts *Options) (*Client, error) {
+	return DialContext(context.Background(), cid, port, opts)
+}
+
+// 同 Dial，连接及握手记录为 ctx 中 span 的子 span
+func DialContext(ctx context.Context, cid uint32, port uint32, opts *Options) (*Client, error) {
+	_, span := startSpan(ctx, "enclave.dial", attribute.String("enclave.address", fmt.Sprintf("vsock://%d:%d", cid, port)))
+	c, err := dialVsock(cid, port, opts)
+	endSpan(span, err)
+	return c, err
+}
+
+func dialVsock(cid uint32, port uint32, opts *Options) (*Client, error) {
+	conn, err := vsock.Dial(cid, port, nil)
+	if err != nil {
+		return nil, netError("连接到 Enclave 失败", err)
+	}
+	return handshake(conn, opts)
+}
+
+// 按地址连接到 Enclave 并完成握手，地址格式为 vsock://CID:PORT、tcp://HOST:PORT 或 unix:///PATH
+// tcp 和 unix 用于没有 vsock 的 CI 及本地集成测试
+func DialAddress(address string, opts *Options) (*Client, error) {
+	return DialAddressContext(context.Background(), address, opts)
+}
+
+// 同 DialAddress，连接及握手记录为 ctx 中 span 的子 span
+func DialAddressContext(ctx context.Context, address string, opts *Options) (*Client, error) {
+	network, addr, err := ParseAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := startSpan(ctx, "enclave.dial", attribute.String("enclave.address", address))
+	c, err := dialNetwork(ctx, network, addr, opts)
+	endSpan(span, err)
+	return c, err
+}
+
+func dialNetwork(ctx context.Context, network string, addr string, opts *Options) (*Client, error) {
+	if network == "vsock" {
+		cid, port, err := parseVsockAddress(addr)
+		if err != nil {
+			return nil, err
+		}
+		return dialVsock(cid, port, opts)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, netError("连接到 Enclave 失败", err)
+	}
+	return handshake(conn, opts)
+}
+
+// 解析 scheme://address 形式的地址，返回网络类型和地址
+func ParseAddress(address string) (network string, addr string, err error) {
+	scheme, addr, ok := strings.Cut(address, "://")
+	if !ok || addr == "" {
+		return "", "", fmt.Errorf("无效的地址: %s (格式为 vsock://CID:PORT、tcp://HOST:PORT 或 unix:///PATH)", address)
+	}
+	switch scheme {
+	case "vsock", "tcp", "unix":
+		return scheme, addr, nil
+	default:
+		return "", "", fmt.Errorf("不支持的地址类型: %s (可选 vsock、tcp、unix)", scheme)
+	}
+}
+
+func parseVsockAddress(addr string) (uint32, uint32, error) {
+	cidText, portText, ok := strings.Cut(addr, ":")
+	if !ok {
+		return 0, 0, fmt.Errorf("无效的 vsock 地址: %s (格式为 CID:PORT)", addr)
+	}
+	cid, err := strconv.ParseUint(cidText, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("无效的 vsock CID: %s", cidText)
+	}
+	port, err := strconv.ParseUint(portText, 10, 32)
+	if err != nil {
+		return 0, 0, fmt.Errorf("无效的 vsock 端口: %s", portText)
+	}
+	return uint32(cid), uint32(port), nil
+}
+
+// 在已建立的连接上完成 (可选的) RA-TLS 及协议握手
+func handshake(conn net.Conn, opts *Options) (*Client, error) {
+	var attestation []byte
+	if opts != nil && opts.RATLS {
+		tlsConn, doc, err := ratlsHandshake(conn, opts.verifier())
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		conn = tlsConn
+		attestation = doc
+	}
+
+	c, err := newClient(conn, opts)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if attestation != nil {
+		c.attestation = attestation
+	}
+	return c, nil
+}
+
+func newClient(conn net.Conn, opts *Options) (*Client, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if opts.Codec != "" {
+		if _, ok := lookupCodec(opts.Codec); !ok {
+			return nil, fmt.Errorf("不支持的编码: %s", opts.Codec)
+		}
+	}
+
+	h := hello{
+		Mux:         opts.Mux,
+		Codec:       opts.Codec,
+		Compression: opts.Compression,
+		ChunkSize:   opts.ChunkSize,
+		HMAC:        opts.HMACKey != nil,
+		Noise:       opts.Noise,
+	}
+	if opts.HMACKey != nil {
+		h.HMACNonce = make([]byte, hmacNonceSize)
+		if _, err := rand.Read(h.HMACNonce); err != nil {
+			return nil, fmt.Errorf("生成握手随机数失败: %v", err)
+		}
+	}
+	if opts.Noise != "" {
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("生成握手随机数失败: %v", err)
+		}
+		h.NoiseNonce = hex.EncodeToString(nonce)
+	}
+
+	helloPayload, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFrame(conn, helloPayload); err != nil {
+		return nil, netError("发送握手失败", err)
+	}
+
+	ackPayload, err := readFrame(conn)
+	if err != nil {
+		return nil, netError("读取握手响应失败", err)
+	}
+	var ack helloAck
+	if err := json.Unmarshal(ackPayload, &ack); err != nil {
+		return nil, fmt.Errorf("解析握手响应失败: %v", err)
+	}
+	if ack.ErrorMessage != "" {
+		return nil, fmt.Errorf("握手被拒绝: %w", &EnclaveError{Code: ack.ErrorCode, Message: ack.ErrorMessage})
+	}
+
+	if ack.Codec == "" {
+		ack.Codec = CodecJSON
+	}
+	if opts.Codec != "" && ack.Codec != opts.Codec {
+		return nil, fmt.Errorf("Enclave 未接受编码 %s", opts.Codec)
+	}
+	codec, ok := lookupCodec(ack.Codec)
+	if !ok {
+		return nil, fmt.Errorf("不支持的编码: %s", ack.Codec)
+	}
+
+	if opts.HMACKey != nil && !ack.HMAC {
+		return nil, fmt.Errorf("Enclave 未启用 HMAC 认证")
+	}
+
+	c := &Client{conn: conn, codec: codec, compression: ack.Compression, chunkSize: ack.ChunkSize}
+
+	// 后续数据经过的传输层，启用 Noise 时为加密连接
+	transport := conn
+	if opts.Noise != "" {
+		if ack.Noise != opts.Noise {
+			return nil, fmt.Errorf("Enclave 未接受 Noise %s 握手", opts.Noise)
+		}
+
+		doc := attestation.Decode([]byte(ack.Attestation))
+		if err := checkNoiseBinding(doc, ack.NoiseStatic, h.NoiseNonce); err != nil {
+			return nil, err
+		}
+		if err := opts.verifier()(doc); err != nil {
+			return nil, fmt.Errorf("证明文档校验失败: %v", err)
+		}
+
+		nc, err := noiseClientHandshake(conn, opts.Noise, ack.NoiseStatic, opts.NoiseClientKey, append(helloPayload, ackPayload...))
+		if err != nil {
+			return nil, err
+		}
+		transport = nc
+		c.attestation = doc
+	}
+
+	if ack.HMAC {
+		c.hmacKey = opts.HMACKey
+		c.transcript = hmacTranscript(helloPayload, ackPayload)
+	}
+	c.transport = transport
+	c.fc = newFrameConn(transport, c.hmacKey, c.transcript, 0)
+	if ack.Mux {
+		session, err := yamux.Client(transport, nil)
+		if err != nil {
+			return nil, fmt.Errorf("创建 yamux 会话失败: %v", err)
+		}
+		c.session = session
+	}
+
+	if opts.Session {
+		secure, err := c.OpenSession(context.Background(), opts.verifier())
+		if err != nil {
+			return nil, fmt.Errorf("建立加密会话失败: %w", err)
+		}
+		c.secure, c.verify = secure, opts.verifier()
+		if c.attestation == nil {
+			c.attestation = secure.Attestation()
+		}
+	}
+	return c, nil
+}
+
+// 建立 Noise 通道、RA-TLS 连接或加密会话时 Enclave 提供的证明文档，其 public_key 为通道或会话公钥
+func (c *Client) Attestation() []byte {
+	return c.attestation
+}
+
+// 请求 Enclave 生成证明文档
+func (c *Client) Attest(ctx context.Context, args CommandArgs) (*Response, error) {
+	return c.call(ctx, args)
+}
+
+// Enclave 在一个 attest-batch 请求中最多处理的 attest 请求数 - 与 enclave 端匹配
+const MaxBatchSize = 64
+
+// 在一个请求中发送多个 attest 请求 (各自的 nonce、user_data 等)，分摊连接和往返的开销
+// 返回的响应与 items 一一对应，单个请求失败时只有对应响应的 Success 为 false
+func (c *Client) AttestBatch(ctx context.Context, items []CommandArgs) ([]*Response, error) {
+	response, err := c.call(ctx, CommandArgs{Method: MethodAttestBatch, Batch: items})
+	if err != nil {
+		return nil, err
+	}
+	if err := response.Err(); err != nil {
+		return nil, err
+	}
+	if len(response.Batch) != len(items) {
+		return nil, fmt.Errorf("批量响应包含 %d 个结果，请求了 %d 个", len(response.Batch), len(items))
+	}
+	responses := make([]*Response, len(items))
+	for i := range response.Batch {
+		responses[i] = &response.Batch[i]
+	}
+	return responses, nil
+}
+
+// 请求 Enclave 签发 JWT，响应的 Document 为签名公钥的证明文档
+// ttl 为 0 时使用 Enclave 的默认有效期
+func (c *Client) Token(ctx context.Context, audience string, ttl time.Duration) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodToken, Audience: audience, TTL: int(ttl / time.Second)})
+}
+
+// 请求 Enclave 为 JWT 签名公钥生成新的证明文档，nonce 可为空
+func (c *Client) SigningKey(ctx context.Context, nonce string) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodSigningKey, Nonce: nonce})
+}
+
+// 检查 Enclave 是否可用，响应的 Version 为服务器版本
+func (c *Client) Health(ctx context.Context) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodHealth})
+}
+
+// 查询 Enclave 中 NSM 的描述，结果在响应的 NSM 中
+func (c *Client) DescribeNSM(ctx context.Context) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodDescribeNSM})
+}
+
+// 从 Enclave 的 NSM 获取 length 字节随机数，结果在响应的 Random 中
+func (c *Client) GetRandom(ctx context.Context, length int) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodGetRandom, Length: length})
+}
+
+// 读取 Enclave 的 PCR，indices 为空时读取全部 PCR，结果在响应的 PCRs 中
+func (c *Client) DescribePCR(ctx context.Context, indices []uint16) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodDescribePCR, PCRIndices: indices})
+}
+
+// 以 data 扩展 Enclave 的用户 PCR (16 及以上)，新值在响应的 PCRs 中
+// Enclave 需以 --allow-extend-pcr 启动
+func (c *Client) ExtendPCR(ctx context.Context, index uint16, data []byte) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodExtendPCR, PCRIndex: index, DataB64: base64.StdEncoding.EncodeToString(data)})
+}
+
+// 锁定 Enclave 的一个 PCR，锁定后的状态在响应的 PCRs 中
+// Enclave 需以 --allow-lock-pcr 启动
+func (c *Client) LockPCR(ctx context.Context, index uint16) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodLockPCR, PCRIndex: index})
+}
+
+// 锁定 Enclave 的 PCR0 到 PCR(pcrRange-1)
+func (c *Client) LockPCRs(ctx context.Context, pcrRange uint16) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodLockPCRs, PCRRange: pcrRange})
+}
+
+// 以主机时间 t 同步 Enclave 的时钟，proof 为时间机构签名的时间证明 (JWT)，可为空
+// Enclave 需以 --allow-set-time 或 --time-authority-key 启动，同步结果在响应的 Time 中
+func (c *Client) SetTime(ctx context.Context, t time.Time, proof string) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodSetTime, TimeUnixNano: t.UnixNano(), TimeProof: proof})
+}
+
+// 让 Enclave 从 Secrets Manager 读取 args 中的 SecretID 并以证明文档经 KMS 解密，
+// 明文只保存在 Enclave 内，响应的 Secret 中只有句柄和元数据；Enclave 需以 --allow-get-secret 启动
+func (c *Client) GetSecret(ctx context.Context, args CommandArgs) (*Response, error) {
+	args.Method = MethodGetSecret
+	return c.call(ctx, args)
+}
+
+// 让 Enclave 读取 args 中的 SSM SecureString 参数 Parameter 并以证明文档经 KMS 解密，
+// 与 GetSecret 相同只返回句柄；Enclave 需以 --allow-get-secret 启动
+func (c *Client) GetParameter(ctx context.Context, args CommandArgs) (*Response, error) {
+	args.Method = MethodGetParameter
+	return c.call(ctx, args)
+}
+
+// 让 Enclave 以 args 中的 KMS 密钥 KeyID 和 SigningAlgorithm 对 message 签名 (args.Digest 为 true 时 message 已是摘要)，
+// 响应的 Document 为 user_data 绑定该签名的证明文档；Enclave 需以 --kms-sign-key 允许该密钥
+func (c *Client) KMSSign(ctx context.Context, args CommandArgs, message []byte) (*Response, error) {
+	args.Method = MethodKMSSign
+	args.DataB64 = base64.StdEncoding.EncodeToString(message)
+	return c.call(ctx, args)
+}
+
+// 发送一个请求并解析响应，ctx 中有 span 时请求记录为其子 span，并通过 traceparent 传播到 Enclave
+func (c *Client) call(ctx context.Context, args CommandArgs) (response *Response, err error) {
+	method := args.Method
+	if method == "" {
+		method = MethodAttest
+	}
+	ctx, span := startSpan(ctx, "enclave.call "+method, attribute.String("enclave.method", method))
+	defer func() {
+		if err == nil && !response.Success {
+			span.SetAttributes(attribute.String("enclave.error_code", response.ErrorCode))
+			endSpan(span, response.Err())
+			return
+		}
+		endSpan(span, err)
+	}()
+	if args.TraceParent == "" {
+		args.TraceParent = traceParent(ctx)
+	}
+	if args.SchemaVersion == 0 {
+		args.SchemaVersion = SchemaVersion
+	}
+
+	response, err = c.send(ctx, args)
+	// Enclave 较旧、不支持请求的 schema 版本时，按其支持的最高版本降级重试一次
+	if err == nil && response.ErrorCode == ErrorCodeUnsupportedSchema &&
+		response.SchemaVersion >= minSchemaVersion && response.SchemaVersion < args.SchemaVersion {
+		args.SchemaVersion = response.SchemaVersion
+		response, err = c.send(ctx, args)
+	}
+	return response, err
+}
+
+// 启用会话模式时经会话加密发送请求，否则直接发送
+func (c *Client) send(ctx context.Context, args CommandArgs) (*Response, error) {
+	if args.Method == MethodSessionOpen || args.Method == MethodSession {
+		return c.exchange(ctx, args)
+	}
+	secure, err := c.currentSession(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if secure == nil {
+		return c.exchange(ctx, args)
+	}
+	return secure.Call(ctx, c, args)
+}
+
+// 会话模式下的当前会话，超过最长有效期时重新建立
+func (c *Client) currentSession(ctx context.Context) (*Session, error) {
+	c.secureMu.Lock()
+	defer c.secureMu.Unlock()
+	if c.secure == nil || !c.secure.Expired() {
+		return c.secure, nil
+	}
+	secure, err := c.OpenSession(ctx, c.verify)
+	if err != nil {
+		return nil, fmt.Errorf("重新建立加密会话失败: %w", err)
+	}
+	c.secure = secure
+	return secure, nil
+}
+
+// 编码请求、完成一次收发并解析响应
+func (c *Client) exchange(ctx context.Context, args CommandArgs) (*Response, error) {
+	payload, err := c.codec.MarshalRequest(args)
+	if err != nil {
+		return nil, fmt.Errorf("序列化参数失败: %v", err)
+	}
+
+	responsePayload, err := c.roundTrip(ctx, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.compression != "" {
+		// 流式模式下响应大小不设上限
+		limit := int64(maxFrameSize)
+		if c.chunkSize > 0 {
+			limit = -1
+		}
+		responsePayload, err = decompressPayload(c.compression, responsePayload, limit)
+		if err != nil {
+			return nil, fmt.Errorf("解压响应失败: %v", err)
+		}
+	}
+
+	response, err := c.codec.UnmarshalResponse(responsePayload)
+	if err != nil {
+		return nil, fmt.Errorf("解析响应失败: %v", err)
+	}
+	return response, nil
+}
+
+// 发送一个请求帧并读取对应的响应帧
+func (c *Client) roundTrip(ctx context.Context, request []byte) ([]byte, error) {
+	var stream net.Conn
+	var fc *frameConn
+	if c.session != nil {
+		s, err := c.session.OpenStream()
+		if err != nil {
+			return nil, fmt.Errorf("打开 yamux 流失败: %v", err)
+		}
+		defer s.Close()
+		stream = s
+		fc = newFrameConn(s, c.hmacKey, c.transcript, s.StreamID())
+	} else {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		stream = c.transport
+		fc = c.fc
+		defer stream.SetDeadline(time.Time{})
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		stream.SetDeadline(deadline)
+	}
+
+	if err := fc.WriteFrame(request); err != nil {
+		return nil, netError("发送参数失败", err)
+	}
+
+	var response []byte
+	var err error
+	if c.chunkSize > 0 {
+		response, err = readChunked(fc)
+	} else {
+		response, err = fc.ReadFrame()
+	}
+	if err != nil {
+		return nil, netError("读取响应失败", err)
+	}
+	return response, nil
+}
+
+// 关闭连接
+func (c *Client) Close() error {
+	if c.session != nil {
+		c.session.Close()
+	}
+	return c.conn.Close()
+}
+
+// 按协商的算法解压响应负载，limit 小于 0 时不限制解压后大小
+func decompressPayload(compression string, payload []byte, limit int64) ([]byte, error) {
+	var r io.Reader
+	switch compression {
+	case CompressionGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer gr.Close()
+		r = gr
+	case CompressionZstd:
+		zr, err := zstd.NewReader(bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法: %s", compression)
+	}
+
+	if limit < 0 {
+		return io.ReadAll(r)
+	}
+	// 多读一个字节以区分恰好达到上限和超过上限，超过时报错而不是截断
+	data, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("解压后的响应超过 %d 字节上限", limit)
+	}
+	return data, nil
+}
+
+// 读取分块流式响应并重新拼装
+func readChunked(fc *frameConn) ([]byte, error) {
+	var buf bytes.Buffer
+	for {
+		chunk, err := fc.ReadFrame()
+		if err != nil {
+			return nil, err
+		}
+		if len(chunk) == 0 {
+			return nil, fmt.Errorf("收到空的分块帧")
+		}
+
+		buf.Write(chunk[1:])
+		if chunk[0] == chunkFinal {
+			return buf.Bytes(), nil
+		}
+	}
+}
+
+// 写入一帧: 4 字节大端长度 + 负载
+func writeFrame(w io.Writer, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("帧长度 %d 超过上限 %d", len(payload), maxFrameSize)
+	}
+
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+// 读取一帧
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxFrameSize {
+		return nil, fmt.Errorf("帧长度 %d 超过上限 %d", size, maxFrameSize)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
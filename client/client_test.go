@@ -0,0 +1,117 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestDecompressPayloadLimit(t *testing.T) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	w.Write(bytes.Repeat([]byte{'a'}, 100))
+	w.Close()
+
+	if data, err := decompressPayload(CompressionGzip, buf.Bytes(), 100); err != nil || len(data) != 100 {
+		t.Fatalf("恰好达到上限的响应应能解压: %d %v", len(data), err)
+	}
+	if _, err := decompressPayload(CompressionGzip, buf.Bytes(), 99); err == nil || !strings.Contains(err.Error(), "上限") {
+		t.Fatalf("超过上限的响应应报错而不是截断: %v", err)
+	}
+}
+
+func TestHMACRejectsDowngrade(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	// 未启用 HMAC 的 Enclave (或篡改握手的中间人) 应答握手
+	go func() {
+		defer server.Close()
+		if _, err := readFrame(server); err != nil {
+			return
+		}
+		ack, _ := json.Marshal(helloAck{Codec: CodecJSON})
+		writeFrame(server, ack)
+	}()
+
+	if _, err := newClient(client, &Options{HMACKey: []byte("secret")}); err == nil || !strings.Contains(err.Error(), "HMAC") {
+		t.Fatalf("Enclave 未启用 HMAC 时应拒绝连接: %v", err)
+	}
+}
+
+func TestHMACRejectsForgedResponse(t *testing.T) {
+	key, transcript := []byte("secret"), hmacTranscript([]byte("hello"), []byte("ack"))
+	payload := []byte(`{"success":true}`)
+	enclave := newFrameConn(nil, key, transcript, 0)
+
+	var buf bytes.Buffer
+	writeFrame(&buf, append(enclave.mac(directionResponse, 0, payload), payload...))
+	if got, err := newFrameConn(&buf, key, transcript, 0).ReadFrame(); err != nil || !bytes.Equal(got, payload) {
+		t.Fatalf("HMAC 正确的响应应被接受: %q %v", got, err)
+	}
+
+	forged := append(enclave.mac(directionResponse, 0, payload), []byte(`{"success":false}`)...)
+	buf.Reset()
+	writeFrame(&buf, forged)
+	if _, err := newFrameConn(&buf, key, transcript, 0).ReadFrame(); !errors.Is(err, ErrBadFrameMAC) {
+		t.Fatalf("篡改的响应应被拒绝: %v", err)
+	}
+	buf.Reset()
+	writeFrame(&buf, payload)
+	if _, err := newFrameConn(&buf, key, transcript, 0).ReadFrame(); !errors.Is(err, ErrBadFrameMAC) {
+		t.Fatalf("没有 HMAC 的响应应被拒绝: %v", err)
+	}
+}
+
+func TestHMACRejectsReplayedSession(t *testing.T) {
+	key := []byte("secret")
+	client, server := net.Pipe()
+	defer client.Close()
+
+	// 中间人重放旧会话的握手响应及其上的 HMAC 响应帧
+	oldHello, _ := json.Marshal(hello{HMAC: true, HMACNonce: []byte("old-client-nonce")})
+	oldAck, _ := json.Marshal(helloAck{Codec: CodecJSON, HMAC: true, Challenge: []byte("enclave-challenge")})
+	old := newFrameConn(nil, key, hmacTranscript(oldHello, oldAck), 0)
+	response := []byte(`{"success":true}`)
+
+	go func() {
+		defer server.Close()
+		payload, err := readFrame(server)
+		if err != nil {
+			return
+		}
+		var h hello
+		json.Unmarshal(payload, &h)
+		if len(h.HMACNonce) != hmacNonceSize {
+			t.Errorf("启用 HMAC 的握手应携带客户端随机数: %x", h.HMACNonce)
+		}
+		writeFrame(server, oldAck)
+		if _, err := readFrame(server); err != nil {
+			return
+		}
+		writeFrame(server, append(old.mac(directionResponse, 0, response), response...))
+	}()
+
+	c, err := newClient(client, &Options{HMACKey: key})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Health(context.Background()); err == nil || !strings.Contains(err.Error(), ErrBadFrameMAC.Error()) {
+		t.Fatalf("重放的响应应被拒绝: %v", err)
+	}
+}
+
+func TestParseHMACKey(t *testing.T) {
+	if got := ParseHMACKey([]byte("c2VjcmV0\r\n")); string(got) != "c2VjcmV0" {
+		t.Fatalf("文本密钥应去除末尾换行: %q", got)
+	}
+	raw := []byte{0x01, 0xff, 0x0a}
+	if got := ParseHMACKey(raw); !bytes.Equal(got, raw) {
+		t.Fatalf("二进制密钥应原样使用: %x", got)
+	}
+}
@@ -0,0 +1,138 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 请求/响应编码，握手时按名称与 Enclave 协商
+// 新的编码实现该接口并通过 RegisterCodec 注册即可使用，Enclave 端需支持同名编码
+type Codec interface {
+	// 握手中使用的名称
+	Name() string
+	MarshalRequest(args CommandArgs) ([]byte, error)
+	// 解析响应，证明文档统一转换为 base64 文本
+	UnmarshalResponse(payload []byte) (*Response, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		CodecJSON:     jsonCodec{},
+		CodecCBOR:     cborCodec{},
+		CodecProtobuf: protobufCodec{},
+	}
+)
+
+// 注册编码，同名编码会被替换
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+func lookupCodec(name string) (Codec, bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[name]
+	return c, ok
+}
+
+// 二进制编码中的原始证明文档转换为 base64 文本
+func documentText(document []byte) string {
+	if len(document) == 0 {
+		return ""
+	}
+	return base64.StdEncoding.EncodeToString(document)
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return CodecJSON }
+
+func (jsonCodec) MarshalRequest(args CommandArgs) ([]byte, error) {
+	return json.Marshal(args)
+}
+
+func (jsonCodec) UnmarshalResponse(payload []byte) (*Response, error) {
+	var response Response
+	if err := json.Unmarshal(payload, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// CBOR 编码的响应 - 与 enclave 端匹配
+type cborResponse struct {
+	Success       bool                `cbor:"success"`
+	ErrorCode     string              `cbor:"error_code,omitempty"`
+	ErrorMessage  string              `cbor:"error_message,omitempty"`
+	Document      []byte              `cbor:"document,omitempty"`
+	Token         string              `cbor:"token,omitempty"`
+	Version       string              `cbor:"version,omitempty"`
+	SchemaVersion int                 `cbor:"schema_version,omitempty"`
+	Trace         []TraceSpan         `cbor:"trace,omitempty"`
+	NSM           *NSMDescription     `cbor:"nsm,omitempty"`
+	Random        []byte              `cbor:"random,omitempty"`
+	PCRs          map[uint16]PCRState `cbor:"pcrs,omitempty"`
+	Batch         []cborResponse      `cbor:"batch,omitempty"`
+	EvidenceType  string              `cbor:"evidence_type,omitempty"`
+	File          *FileChunk          `cbor:"file,omitempty"`
+	Time          *TimeStatus         `cbor:"time,omitempty"`
+	Secret        *SecretHandle       `cbor:"secret,omitempty"`
+	Signature     *KMSSignature       `cbor:"signature,omitempty"`
+	Object        *ObjectResult       `cbor:"object,omitempty"`
+	FileKey       []byte              `cbor:"file_key,omitempty"`
+	Session       *SessionInfo        `cbor:"session,omitempty"`
+	Sealed        []byte              `cbor:"sealed,omitempty"`
+}
+
+type cborCodec struct{}
+
+func (cborCodec) Name() string { return CodecCBOR }
+
+func (cborCodec) MarshalRequest(args CommandArgs) ([]byte, error) {
+	return cbor.Marshal(args)
+}
+
+func (cborCodec) UnmarshalResponse(payload []byte) (*Response, error) {
+	var raw cborResponse
+	if err := cbor.Unmarshal(payload, &raw); err != nil {
+		return nil, err
+	}
+	response := raw.response()
+	return &response, nil
+}
+
+func (raw cborResponse) response() Response {
+	var batch []Response
+	for _, item := range raw.Batch {
+		batch = append(batch, item.response())
+	}
+	return Response{
+		Success:       raw.Success,
+		ErrorCode:     raw.ErrorCode,
+		ErrorMessage:  raw.ErrorMessage,
+		Document:      documentText(raw.Document),
+		Token:         raw.Token,
+		Version:       raw.Version,
+		SchemaVersion: raw.SchemaVersion,
+		Trace:         raw.Trace,
+		NSM:           raw.NSM,
+		Random:        raw.Random,
+		PCRs:          raw.PCRs,
+		Batch:         batch,
+		EvidenceType:  raw.EvidenceType,
+		File:          raw.File,
+		Time:          raw.Time,
+		Secret:        raw.Secret,
+		Signature:     raw.Signature,
+		Object:        raw.Object,
+		FileKey:       raw.FileKey,
+		Session:       raw.Session,
+		Sealed:        raw.Sealed,
+	}
+}
@@ -0,0 +1,102 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Enclave 返回的错误码 - 与 enclave 端匹配
+const (
+	ErrorCodeRequestTooLarge   = "REQUEST_TOO_LARGE"
+	ErrorCodeParseError        = "PARSE_ERROR"
+	ErrorCodeBadRequest        = "BAD_REQUEST"
+	ErrorCodeUnsupportedMethod = "UNSUPPORTED_METHOD"
+	ErrorCodeInvalidPublicKey  = "INVALID_PUBLIC_KEY"
+	ErrorCodeUnauthorized      = "UNAUTHORIZED"
+	ErrorCodeNSMUnavailable    = "NSM_UNAVAILABLE"
+	ErrorCodeNSMError          = "NSM_ERROR"
+	ErrorCodeTimeout           = "TIMEOUT"
+	ErrorCodeInternal          = "INTERNAL_ERROR"
+	ErrorCodeUnsupportedSchema = "UNSUPPORTED_SCHEMA_VERSION"
+)
+
+// 与错误码对应的哨兵错误，可通过 errors.Is 判断 Enclave 返回的错误类别
+var (
+	ErrRequestTooLarge   = errors.New("请求过大")
+	ErrParse             = errors.New("请求无法解析")
+	ErrBadRequest        = errors.New("请求参数无效")
+	ErrUnsupportedMethod = errors.New("不支持的请求方法")
+	ErrInvalidPublicKey  = errors.New("公钥无效")
+	ErrUnauthorized      = errors.New("未授权")
+	ErrNSMUnavailable    = errors.New("NSM 不可用")
+	ErrNSM               = errors.New("NSM 返回错误")
+	ErrTimeout           = errors.New("请求超时")
+	ErrInternal          = errors.New("Enclave 内部错误")
+	ErrUnsupportedSchema = errors.New("Enclave 不支持请求的 schema 版本")
+)
+
+// 连接、握手或收发请求失败 (非 Enclave 返回的错误)，可通过 errors.Is 判断
+var ErrConnection = errors.New("与 Enclave 通信失败")
+
+var codeErrors = map[string]error{
+	ErrorCodeRequestTooLarge:   ErrRequestTooLarge,
+	ErrorCodeParseError:        ErrParse,
+	ErrorCodeBadRequest:        ErrBadRequest,
+	ErrorCodeUnsupportedMethod: ErrUnsupportedMethod,
+	ErrorCodeInvalidPublicKey:  ErrInvalidPublicKey,
+	ErrorCodeUnauthorized:      ErrUnauthorized,
+	ErrorCodeNSMUnavailable:    ErrNSMUnavailable,
+	ErrorCodeNSMError:          ErrNSM,
+	ErrorCodeTimeout:           ErrTimeout,
+	ErrorCodeInternal:          ErrInternal,
+	ErrorCodeUnsupportedSchema: ErrUnsupportedSchema,
+}
+
+// Enclave 在响应或握手中返回的错误
+type EnclaveError struct {
+	Code    string
+	Message string
+}
+
+func (e *EnclaveError) Error() string {
+	if e.Code == "" {
+		return "Enclave 返回错误: " + e.Message
+	}
+	return fmt.Sprintf("Enclave 返回错误 [%s]: %s", e.Code, e.Message)
+}
+
+// 错误码对应的哨兵错误，未知错误码时为 nil
+func (e *EnclaveError) Unwrap() error {
+	return codeErrors[e.Code]
+}
+
+// 响应失败时返回 *EnclaveError，成功时返回 nil
+func (r *Response) Err() error {
+	if r.Success {
+		return nil
+	}
+	return &EnclaveError{Code: r.ErrorCode, Message: r.ErrorMessage}
+}
+
+// 网络错误，错误信息不变，errors.Is 可匹配 ErrConnection
+type connectionError struct {
+	err error
+}
+
+func (e *connectionError) Error() string {
+	return e.err.Error()
+}
+
+func (e *connectionError) Unwrap() []error {
+	return []error{ErrConnection, e.err}
+}
+
+// 以 prefix 包装网络错误，读写超时时可通过 errors.Is(err, ErrTimeout) 判断
+func netError(prefix string, err error) error {
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		return &connectionError{fmt.Errorf("%s: %w: %v", prefix, ErrTimeout, err)}
+	}
+	return &connectionError{fmt.Errorf("%s: %v", prefix, err)}
+}
@@ -0,0 +1,112 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PushFile 默认的分块大小，base64 编码后仍小于 Enclave 默认的 64KB 请求上限
+const DefaultPushChunkSize = 32 << 10
+
+// PullFile 默认的分块大小
+const DefaultPullChunkSize = 1 << 20
+
+// 文件完整性校验失败
+var ErrFileDigest = errors.New("文件 SHA-256 校验失败")
+
+// file-push、file-pull 方法的结果 - 与 enclave 端匹配
+type FileChunk struct {
+	Path   string `json:"path" cbor:"path"`
+	Size   int64  `json:"size" cbor:"size"`
+	Offset int64  `json:"offset,omitempty" cbor:"offset,omitempty"`
+	Data   []byte `json:"data,omitempty" cbor:"data,omitempty"`
+	SHA256 string `json:"sha256,omitempty" cbor:"sha256,omitempty"`
+	EOF    bool   `json:"eof,omitempty" cbor:"eof,omitempty"`
+}
+
+// 将 r 的内容分块上传到 Enclave --file-root 下的 path，chunkSize 为 0 时使用 DefaultPushChunkSize
+// Enclave 校验整个文件的 SHA-256 后才替换目标文件，返回的 FileChunk 包含大小和摘要
+func (c *Client) PushFile(ctx context.Context, path string, r io.Reader, chunkSize int) (*FileChunk, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultPushChunkSize
+	}
+	hash := sha256.New()
+	buf := make([]byte, chunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return nil, fmt.Errorf("读取文件失败: %v", err)
+		}
+		hash.Write(buf[:n])
+
+		args := CommandArgs{Method: MethodFilePush, Path: path, Offset: offset, DataB64: base64.StdEncoding.EncodeToString(buf[:n]), Final: final}
+		if final {
+			args.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		}
+		response, err := c.call(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		if err := response.Err(); err != nil {
+			return nil, err
+		}
+		if response.File == nil {
+			return nil, fmt.Errorf("file-push 响应中没有 file")
+		}
+		offset += int64(n)
+		if final {
+			if response.File.SHA256 != args.SHA256 {
+				return nil, fmt.Errorf("%w: Enclave 返回 %s，期望 %s", ErrFileDigest, response.File.SHA256, args.SHA256)
+			}
+			return response.File, nil
+		}
+	}
+}
+
+// 从 Enclave --file-root 下的 path 分块下载文件写入 w，chunkSize 为 0 时使用 DefaultPullChunkSize
+// 收到的内容与 Enclave 返回的 SHA-256 不一致 (如传输中文件被修改) 时返回 ErrFileDigest，w 中的内容不可用
+func (c *Client) PullFile(ctx context.Context, path string, w io.Writer, chunkSize int) (*FileChunk, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultPullChunkSize
+	}
+	hash := sha256.New()
+	var offset int64
+	for {
+		response, err := c.call(ctx, CommandArgs{Method: MethodFilePull, Path: path, Offset: offset, Length: chunkSize})
+		if err != nil {
+			return nil, err
+		}
+		if err := response.Err(); err != nil {
+			return nil, err
+		}
+		chunk := response.File
+		if chunk == nil {
+			return nil, fmt.Errorf("file-pull 响应中没有 file")
+		}
+		if chunk.Offset != offset {
+			return nil, fmt.Errorf("file-pull 返回偏移 %d，请求 %d", chunk.Offset, offset)
+		}
+		if !chunk.EOF && len(chunk.Data) == 0 {
+			return nil, fmt.Errorf("file-pull 在偏移 %d 返回空数据", offset)
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return nil, fmt.Errorf("写入文件失败: %v", err)
+		}
+		hash.Write(chunk.Data)
+		offset += int64(len(chunk.Data))
+		if chunk.EOF {
+			digest := hex.EncodeToString(hash.Sum(nil))
+			if digest != chunk.SHA256 || offset != chunk.Size {
+				return nil, fmt.Errorf("%w: 收到 %d 字节 (%s)，Enclave 文件为 %d 字节 (%s)", ErrFileDigest, offset, digest, chunk.Size, chunk.SHA256)
+			}
+			return &FileChunk{Path: chunk.Path, Size: chunk.Size, SHA256: digest}, nil
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+
+	"github.com/flynn/noise"
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+const (
+	// Noise 握手模式
+	NoiseNK = "NK"
+	NoiseXX = "XX"
+
+	// 单条 Noise 消息最大长度 (含 16 字节认证标签) - 与 enclave 端匹配
+	noiseMaxMessageSize = 65535
+	noiseTagSize        = 16
+
+	// 握手前言前缀 - 与 enclave 端匹配
+	noisePrologue = "aws-enclave-attestation/noise/v1"
+)
+
+var noiseCipherSuite = noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, noise.HashSHA256)
+
+// 检查证明文档是否绑定了 Enclave 声明的 Noise 静态公钥和本次握手的随机数
+func checkNoiseBinding(doc []byte, static []byte, nonce string) error {
+	binding, err := attestation.Parse(doc)
+	if err != nil {
+		return err
+	}
+
+	key, err := ecdh.X25519().NewPublicKey(static)
+	if err != nil {
+		return fmt.Errorf("无效的 Noise 静态公钥: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(binding.PublicKey, der) {
+		return fmt.Errorf("证明文档中的 public_key 与 Noise 静态公钥不一致")
+	}
+	if !bytes.Equal(binding.Nonce, []byte(nonce)) {
+		return fmt.Errorf("证明文档中的 nonce 与握手随机数不一致")
+	}
+	return nil
+}
+
+// 由 32 字节私钥构造 Noise 静态密钥对
+func noiseKeypair(private []byte) (noise.DHKey, error) {
+	key, err := ecdh.X25519().NewPrivateKey(private)
+	if err != nil {
+		return noise.DHKey{}, fmt.Errorf("无效的 Noise 私钥: %v", err)
+	}
+	return noise.DHKey{Private: key.Bytes(), Public: key.PublicKey().Bytes()}, nil
+}
+
+// 作为发起方完成 Noise 握手，返回加密后的连接
+func noiseClientHandshake(conn net.Conn, pattern string, static []byte, clientKey []byte, prologue []byte) (*noiseConn, error) {
+	cfg := noise.Config{
+		CipherSuite: noiseCipherSuite,
+		Random:      rand.Reader,
+		Initiator:   true,
+		Prologue:    append([]byte(noisePrologue), prologue...),
+	}
+
+	switch pattern {
+	case NoiseNK:
+		cfg.Pattern = noise.HandshakeNK
+		cfg.PeerStatic = static
+	case NoiseXX:
+		if clientKey == nil {
+			return nil, fmt.Errorf("Noise XX 模式需要客户端静态私钥")
+		}
+		keypair, err := noiseKeypair(clientKey)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Pattern = noise.HandshakeXX
+		cfg.StaticKeypair = keypair
+	default:
+		return nil, fmt.Errorf("不支持的 Noise 握手模式: %s", pattern)
+	}
+
+	hs, err := noise.NewHandshakeState(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	msg, _, _, err := hs.WriteMessage(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Noise 握手失败: %v", err)
+	}
+	if err := writeFrame(conn, msg); err != nil {
+		return nil, fmt.Errorf("发送 Noise 握手消息失败: %v", err)
+	}
+
+	msg, err = readFrame(conn)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Noise 握手消息失败: %v", err)
+	}
+	_, cs1, cs2, err := hs.ReadMessage(nil, msg)
+	if err != nil {
+		return nil, fmt.Errorf("Noise 握手失败: %v", err)
+	}
+
+	if pattern == NoiseXX {
+		// XX 模式下静态公钥在握手中传输，必须与证明文档中的一致
+		if !bytes.Equal(hs.PeerStatic(), static) {
+			return nil, fmt.Errorf("Noise 握手中的 Enclave 公钥与证明文档不一致")
+		}
+
+		msg, cs1, cs2, err = hs.WriteMessage(nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Noise 握手失败: %v", err)
+		}
+		if err := writeFrame(conn, msg); err != nil {
+			return nil, fmt.Errorf("发送 Noise 握手消息失败: %v", err)
+		}
+	}
+
+	// cs1 用于发起方到响应方，cs2 用于响应方到发起方
+	return newNoiseConn(conn, cs1, cs2), nil
+}
+
+// Noise 加密的连接，每条记录为 2 字节长度 + 密文 - 与 enclave 端匹配
+type noiseConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	enc *noise.CipherState
+	dec *noise.CipherState
+
+	writeMu sync.Mutex
+	pending []byte
+}
+
+func newNoiseConn(conn net.Conn, enc *noise.CipherState, dec *noise.CipherState) *noiseConn {
+	return &noiseConn{Conn: conn, reader: bufio.NewReader(conn), enc: enc, dec: dec}
+}
+
+func (c *noiseConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		var header [2]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return 0, err
+		}
+
+		ciphertext := make([]byte, binary.BigEndian.Uint16(header[:]))
+		if _, err := io.ReadFull(c.reader, ciphertext); err != nil {
+			return 0, err
+		}
+
+		plaintext, err := c.dec.Decrypt(nil, nil, ciphertext)
+		if err != nil {
+			return 0, fmt.Errorf("Noise 解密失败: %v", err)
+		}
+		c.pending = plaintext
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *noiseConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > noiseMaxMessageSize-noiseTagSize {
+			n = noiseMaxMessageSize - noiseTagSize
+		}
+
+		record := make([]byte, 2, 2+n+noiseTagSize)
+		record, err := c.enc.Encrypt(record, nil, p[:n])
+		if err != nil {
+			return written, err
+		}
+		binary.BigEndian.PutUint16(record, uint16(len(record)-2))
+		if _, err := c.Conn.Write(record); err != nil {
+			return written, err
+		}
+
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
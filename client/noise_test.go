@@ -0,0 +1,86 @@
+package client
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 构造结构合法但未经签名的证明文档，public_key 和 nonce 按调用方给定的值绑定
+func forgedDocument(t *testing.T, publicKey, nonce []byte) []byte {
+	t.Helper()
+	protected, err := cbor.Marshal(map[int64]interface{}{1: int64(-35)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := cbor.Marshal(attestation.Document{
+		ModuleID:    "i-0123456789abcdef0-enc0123456789abcdef",
+		Timestamp:   uint64(time.Now().UnixMilli()),
+		Digest:      "SHA384",
+		PCRs:        map[int][]byte{0: make([]byte, 48)},
+		Certificate: []byte("certificate"),
+		CABundle:    [][]byte{[]byte("root")},
+		PublicKey:   publicKey,
+		Nonce:       nonce,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := cbor.Marshal([]interface{}{protected, map[int64]interface{}{}, payload, make([]byte, 96)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// 以 X25519 公钥的 SubjectPublicKeyInfo 构造伪造的证明文档
+func forgedKeyDocument(t *testing.T, public *ecdh.PublicKey, nonce []byte) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return forgedDocument(t, der, nonce)
+}
+
+func TestNoiseRejectsForgedAttestation(t *testing.T) {
+	static, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	client, server := net.Pipe()
+	defer client.Close()
+
+	// 伪造的 Enclave: 以未签名的证明文档声明自己的 Noise 静态公钥
+	go func() {
+		defer server.Close()
+		payload, err := readFrame(server)
+		if err != nil {
+			return
+		}
+		var h hello
+		json.Unmarshal(payload, &h)
+		doc := forgedKeyDocument(t, static.PublicKey(), []byte(h.NoiseNonce))
+		if err := checkNoiseBinding(doc, static.PublicKey().Bytes(), h.NoiseNonce); err != nil {
+			t.Errorf("伪造文档应能通过绑定检查: %v", err)
+		}
+		ack, _ := json.Marshal(helloAck{
+			Codec:       CodecJSON,
+			Noise:       h.Noise,
+			NoiseStatic: static.PublicKey().Bytes(),
+			Attestation: base64.StdEncoding.EncodeToString(doc),
+		})
+		writeFrame(server, ack)
+	}()
+
+	_, err := newClient(client, &Options{Noise: NoiseNK})
+	if err == nil || !strings.Contains(err.Error(), "证明文档校验失败") {
+		t.Fatalf("未签名的证明文档应被拒绝: %v", err)
+	}
+}
@@ -0,0 +1,74 @@
+package client
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// decrypt-object 方法的结果: 只有由明文导出的结果，明文不离开 Enclave - 与 enclave 端匹配
+type ObjectResult struct {
+	// 已接收的密文字节数 (分块上传未完成时)，或明文字节数
+	Size int64 `json:"size" cbor:"size"`
+	// 明文的 SHA-256 (十六进制) 及行数，完成时返回
+	SHA256 string `json:"sha256,omitempty" cbor:"sha256,omitempty"`
+	Lines  int64  `json:"lines,omitempty" cbor:"lines,omitempty"`
+	// 解密数据密钥所用的 KMS 密钥 ARN
+	KeyID string `json:"key_id,omitempty" cbor:"key_id,omitempty"`
+	// Enclave --object-processor 的标准输出
+	Output []byte `json:"output,omitempty" cbor:"output,omitempty"`
+}
+
+// 让 Enclave 经 --egress 读取 args 中的 S3URI (S3 加密客户端 v2 加密的对象)，以证明文档经 KMS 解密数据密钥后解密处理，
+// 响应的 Object 中只有明文的大小、摘要及处理结果；Enclave 需以 --allow-decrypt-object 启动
+func (c *Client) DecryptObject(ctx context.Context, args CommandArgs) (*Response, error) {
+	args.Method = MethodDecryptObject
+	return c.call(ctx, args)
+}
+
+// 将 r 中的对象密文分块上传到 Enclave 解密处理，envelope 为对象元数据 (x-amz-key-v2、x-amz-iv、x-amz-matdesc 等)，
+// 用于 Enclave 无法直接访问 S3 的情况；chunkSize 为 0 时使用 DefaultPushChunkSize，返回最后一块的响应
+func (c *Client) DecryptObjectStream(ctx context.Context, args CommandArgs, envelope map[string]string, r io.Reader, chunkSize int) (*Response, error) {
+	if chunkSize <= 0 {
+		chunkSize = DefaultPushChunkSize
+	}
+	metadata, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	handle := make([]byte, 16)
+	if _, err := rand.Read(handle); err != nil {
+		return nil, err
+	}
+	args.Method = MethodDecryptObject
+	args.S3URI = ""
+	args.Handle = hex.EncodeToString(handle)
+
+	buf := make([]byte, chunkSize)
+	var offset int64
+	for {
+		n, err := io.ReadFull(r, buf)
+		final := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !final {
+			return nil, fmt.Errorf("读取对象失败: %v", err)
+		}
+		args.Offset, args.Final = offset, final
+		args.DataB64 = base64.StdEncoding.EncodeToString(buf[:n])
+		args.Envelope = ""
+		if offset == 0 {
+			args.Envelope = string(metadata)
+		}
+		response, err := c.call(ctx, args)
+		if err != nil {
+			return nil, err
+		}
+		if final || !response.Success {
+			return response, nil
+		}
+		offset += int64(n)
+	}
+}
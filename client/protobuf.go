@@ -0,0 +1,516 @@
+package client
+
+import (
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protobuf 编码，消息定义见 proto/attestation.proto
+// 不依赖生成代码，直接按字段号读写 - 与 enclave 端匹配
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return CodecProtobuf }
+
+func (protobufCodec) MarshalRequest(args CommandArgs) ([]byte, error) {
+	var w protoWriter
+	w.varint(1, uint64(int64(args.SchemaVersion)))
+	w.string(2, args.Method)
+	w.string(3, args.UserData)
+	w.string(4, args.PublicKey)
+	w.string(5, args.Nonce)
+	w.string(6, args.UserDataB64)
+	w.string(7, args.NonceB64)
+	w.string(8, args.Audience)
+	w.varint(9, uint64(int64(args.TTL)))
+	w.bool(10, args.Fresh)
+	w.string(11, args.TraceParent)
+	w.varint(12, uint64(int64(args.Length)))
+	w.varint(13, uint64(args.PCRIndex))
+	w.string(14, args.DataB64)
+	w.uint16s(15, args.PCRIndices)
+	w.varint(16, uint64(args.PCRRange))
+	for _, item := range args.Batch {
+		encoded, _ := protobufCodec{}.MarshalRequest(item)
+		w.message(17, encoded)
+	}
+	w.string(18, args.Path)
+	w.varint(19, uint64(args.Offset))
+	w.bool(20, args.Final)
+	w.string(21, args.SHA256)
+	w.varint(22, uint64(args.TimeUnixNano))
+	w.string(23, args.TimeProof)
+	w.string(24, args.SecretID)
+	w.string(25, args.KeyID)
+	w.string(26, args.Region)
+	w.string(27, args.Handle)
+	w.string(28, args.Parameter)
+	w.string(29, args.SigningAlgorithm)
+	w.bool(30, args.Digest)
+	w.string(31, args.S3URI)
+	w.string(32, args.Envelope)
+	w.string(33, args.Session)
+	w.varint(34, args.Sequence)
+	return w, nil
+}
+
+func (protobufCodec) UnmarshalResponse(payload []byte) (*Response, error) {
+	response := &Response{}
+	r := protoReader{b: payload}
+	for r.next() {
+		switch r.num {
+		case 1:
+			response.Success = r.varint() != 0
+		case 2:
+			response.ErrorCode = r.string()
+		case 3:
+			response.ErrorMessage = r.string()
+		case 4:
+			response.Document = documentText(r.bytes())
+		case 5:
+			response.Token = r.string()
+		case 6:
+			response.Version = r.string()
+		case 7:
+			response.SchemaVersion = int(int32(r.varint()))
+		case 8:
+			span, err := decodeProtoSpan(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			response.Trace = append(response.Trace, span)
+		case 9:
+			nsm, err := decodeProtoNSM(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			response.NSM = nsm
+		case 10:
+			response.Random = r.bytes()
+		case 11:
+			index, state, err := decodeProtoPCR(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			if response.PCRs == nil {
+				response.PCRs = make(map[uint16]PCRState)
+			}
+			response.PCRs[index] = state
+		case 12:
+			item, err := protobufCodec{}.UnmarshalResponse(r.bytes())
+			if err != nil {
+				return nil, fmt.Errorf("batch: %v", err)
+			}
+			response.Batch = append(response.Batch, *item)
+		case 13:
+			response.EvidenceType = r.string()
+		case 14:
+			file, err := decodeProtoFile(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			response.File = file
+		case 15:
+			status, err := decodeProtoTime(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			response.Time = status
+		case 16:
+			secret, err := decodeProtoSecret(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			response.Secret = secret
+		case 17:
+			signature, err := decodeProtoSignature(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			response.Signature = signature
+		case 18:
+			object, err := decodeProtoObject(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			response.Object = object
+		case 19:
+			response.FileKey = r.bytes()
+		case 20:
+			session, err := decodeProtoSession(r.bytes())
+			if err != nil {
+				return nil, err
+			}
+			response.Session = session
+		case 21:
+			response.Sealed = r.bytes()
+		default:
+			r.skip()
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+	return response, nil
+}
+
+func decodeProtoSpan(b []byte) (TraceSpan, error) {
+	var span TraceSpan
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			span.Name = r.string()
+		case 2:
+			span.SpanID = r.string()
+		case 3:
+			span.ParentSpanID = r.string()
+		case 4:
+			span.Start = int64(r.varint())
+		case 5:
+			span.End = int64(r.varint())
+		case 6:
+			var key, value string
+			entry := protoReader{b: r.bytes()}
+			for entry.next() {
+				switch entry.num {
+				case 1:
+					key = entry.string()
+				case 2:
+					value = entry.string()
+				default:
+					entry.skip()
+				}
+			}
+			if entry.err != nil {
+				return span, entry.err
+			}
+			if span.Attributes == nil {
+				span.Attributes = make(map[string]string)
+			}
+			span.Attributes[key] = value
+		case 7:
+			span.Error = r.string()
+		default:
+			r.skip()
+		}
+	}
+	return span, r.err
+}
+
+func decodeProtoNSM(b []byte) (*NSMDescription, error) {
+	nsm := &NSMDescription{}
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			nsm.ModuleID = r.string()
+		case 2:
+			nsm.VersionMajor = uint16(r.varint())
+		case 3:
+			nsm.VersionMinor = uint16(r.varint())
+		case 4:
+			nsm.VersionPatch = uint16(r.varint())
+		case 5:
+			nsm.MaxPCRs = uint16(r.varint())
+		case 6:
+			nsm.LockedPCRs = r.appendUint16s(nsm.LockedPCRs)
+		case 7:
+			nsm.Digest = r.string()
+		default:
+			r.skip()
+		}
+	}
+	return nsm, r.err
+}
+
+// map<uint32, PCRState> 的一个条目
+func decodeProtoFile(b []byte) (*FileChunk, error) {
+	file := &FileChunk{}
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			file.Path = r.string()
+		case 2:
+			file.Size = int64(r.varint())
+		case 3:
+			file.Offset = int64(r.varint())
+		case 4:
+			file.Data = r.bytes()
+		case 5:
+			file.SHA256 = r.string()
+		case 6:
+			file.EOF = r.varint() != 0
+		default:
+			r.skip()
+		}
+	}
+	return file, r.err
+}
+
+func decodeProtoTime(b []byte) (*TimeStatus, error) {
+	status := &TimeStatus{}
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			status.TimeUnixNano = int64(r.varint())
+		case 2:
+			status.OffsetNS = int64(r.varint())
+		case 3:
+			status.Authenticated = r.varint() != 0
+		case 4:
+			status.NTPServer = r.string()
+		default:
+			r.skip()
+		}
+	}
+	return status, r.err
+}
+
+func decodeProtoSecret(b []byte) (*SecretHandle, error) {
+	secret := &SecretHandle{}
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			secret.Handle = r.string()
+		case 2:
+			secret.ARN = r.string()
+		case 3:
+			secret.VersionID = r.string()
+		case 4:
+			secret.KeyID = r.string()
+		case 5:
+			secret.Size = int(r.varint())
+		default:
+			r.skip()
+		}
+	}
+	return secret, r.err
+}
+
+func decodeProtoSignature(b []byte) (*KMSSignature, error) {
+	signature := &KMSSignature{}
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			signature.KeyID = r.string()
+		case 2:
+			signature.SigningAlgorithm = r.string()
+		case 3:
+			signature.Signature = r.bytes()
+		default:
+			r.skip()
+		}
+	}
+	return signature, r.err
+}
+
+func decodeProtoObject(b []byte) (*ObjectResult, error) {
+	object := &ObjectResult{}
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			object.Size = int64(r.varint())
+		case 2:
+			object.SHA256 = r.string()
+		case 3:
+			object.Lines = int64(r.varint())
+		case 4:
+			object.KeyID = r.string()
+		case 5:
+			object.Output = r.bytes()
+		default:
+			r.skip()
+		}
+	}
+	return object, r.err
+}
+
+func decodeProtoSession(b []byte) (*SessionInfo, error) {
+	session := &SessionInfo{}
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			session.ID = r.string()
+		case 2:
+			session.PublicKey = r.bytes()
+		case 3:
+			session.RekeyRequests = int64(r.varint())
+		case 4:
+			session.RekeyBytes = int64(r.varint())
+		case 5:
+			session.RekeyInterval = int64(r.varint())
+		case 6:
+			session.Lifetime = int64(r.varint())
+		default:
+			r.skip()
+		}
+	}
+	return session, r.err
+}
+
+func decodeProtoPCR(b []byte) (uint16, PCRState, error) {
+	var index uint16
+	var state PCRState
+	r := protoReader{b: b}
+	for r.next() {
+		switch r.num {
+		case 1:
+			index = uint16(r.varint())
+		case 2:
+			value := protoReader{b: r.bytes()}
+			for value.next() {
+				switch value.num {
+				case 1:
+					state.Locked = value.varint() != 0
+				case 2:
+					state.Value = value.string()
+				default:
+					value.skip()
+				}
+			}
+			if value.err != nil {
+				return 0, state, value.err
+			}
+		default:
+			r.skip()
+		}
+	}
+	return index, state, r.err
+}
+
+// 按字段号读取 protobuf 消息
+type protoReader struct {
+	b   []byte
+	num protowire.Number
+	typ protowire.Type
+	err error
+}
+
+// 读取下一个字段的标签，消息结束或出错时返回 false
+func (r *protoReader) next() bool {
+	if r.err != nil || len(r.b) == 0 {
+		return false
+	}
+	num, typ, n := protowire.ConsumeTag(r.b)
+	if n < 0 {
+		r.err = protowire.ParseError(n)
+		return false
+	}
+	r.b, r.num, r.typ = r.b[n:], num, typ
+	return true
+}
+
+// 跳过当前字段的值之后的 n 字节，n 小于 0 时记录错误
+func (r *protoReader) advance(n int) bool {
+	if n < 0 {
+		r.err = fmt.Errorf("字段 %d: %v", r.num, protowire.ParseError(n))
+		r.b = nil
+		return false
+	}
+	r.b = r.b[n:]
+	return true
+}
+
+func (r *protoReader) expect(typ protowire.Type) bool {
+	if r.typ != typ {
+		r.err = fmt.Errorf("字段 %d 的类型错误", r.num)
+		r.b = nil
+		return false
+	}
+	return true
+}
+
+func (r *protoReader) varint() uint64 {
+	if !r.expect(protowire.VarintType) {
+		return 0
+	}
+	v, n := protowire.ConsumeVarint(r.b)
+	r.advance(n)
+	return v
+}
+
+func (r *protoReader) bytes() []byte {
+	if !r.expect(protowire.BytesType) {
+		return nil
+	}
+	v, n := protowire.ConsumeBytes(r.b)
+	r.advance(n)
+	return v
+}
+
+func (r *protoReader) string() string {
+	return string(r.bytes())
+}
+
+// repeated uint32，兼容 packed 和非 packed 编码
+func (r *protoReader) appendUint16s(dst []uint16) []uint16 {
+	if r.typ == protowire.VarintType {
+		return append(dst, uint16(r.varint()))
+	}
+	packed := protoReader{b: r.bytes(), num: r.num, typ: protowire.VarintType}
+	for r.err == nil && packed.err == nil && len(packed.b) > 0 {
+		dst = append(dst, uint16(packed.varint()))
+	}
+	if packed.err != nil {
+		r.err = packed.err
+	}
+	return dst
+}
+
+// 跳过未知字段
+func (r *protoReader) skip() {
+	r.advance(protowire.ConsumeFieldValue(r.num, r.typ, r.b))
+}
+
+// 按字段号写入 protobuf 消息，与 proto3 相同，零值字段不写入
+type protoWriter []byte
+
+func (w *protoWriter) varint(num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+	*w = protowire.AppendTag(*w, num, protowire.VarintType)
+	*w = protowire.AppendVarint(*w, v)
+}
+
+func (w *protoWriter) bool(num protowire.Number, v bool) {
+	if v {
+		w.varint(num, 1)
+	}
+}
+
+func (w *protoWriter) bytes(num protowire.Number, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	w.message(num, v)
+}
+
+func (w *protoWriter) string(num protowire.Number, v string) {
+	w.bytes(num, []byte(v))
+}
+
+// 嵌套消息，空消息同样写入 (如 map 中的零值)
+func (w *protoWriter) message(num protowire.Number, v []byte) {
+	*w = protowire.AppendTag(*w, num, protowire.BytesType)
+	*w = protowire.AppendBytes(*w, v)
+}
+
+// packed repeated uint32
+func (w *protoWriter) uint16s(num protowire.Number, v []uint16) {
+	if len(v) == 0 {
+		return
+	}
+	var packed []byte
+	for _, x := range v {
+		packed = protowire.AppendVarint(packed, uint64(x))
+	}
+	w.message(num, packed)
+}
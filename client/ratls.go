@@ -0,0 +1,81 @@
+package client
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"net"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 携带证明文档的证书扩展 OID - 与 enclave 端匹配。
+// 1.3.6.1.4.1.99999 是占位的私有企业号，未向 IANA 注册，该 OID 只在本项目内部使用
+var oidAttestationDocument = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1, 1}
+
+// 从 RA-TLS 证书中提取证明文档，检查文档的 public_key 与证书公钥一致，并以 verify 校验文档的签名、证书链等；
+// verify 为空时以内置的 AWS Nitro Enclaves 根证书校验签名和证书链
+func VerifyRATLSCertificate(cert *x509.Certificate, verify func(document []byte) error) ([]byte, error) {
+	var doc []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidAttestationDocument) {
+			doc = ext.Value
+			break
+		}
+	}
+	if doc == nil {
+		return nil, fmt.Errorf("证书中没有证明文档扩展")
+	}
+
+	binding, err := attestation.Parse(doc)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(binding.PublicKey, cert.RawSubjectPublicKeyInfo) {
+		return nil, fmt.Errorf("证明文档中的 public_key 与证书公钥不一致")
+	}
+	if verify == nil {
+		verify = verifyAttestation
+	}
+	if err := verify(doc); err != nil {
+		return nil, fmt.Errorf("证明文档校验失败: %v", err)
+	}
+	return doc, nil
+}
+
+// 与 Enclave 建立 RA-TLS 连接，握手时校验证书中的证明文档
+// 证书是自签名的，信任完全来自证明文档，verify 为空时以内置的 AWS 根证书校验
+func ratlsHandshake(conn net.Conn, verify func(document []byte) error) (*tls.Conn, []byte, error) {
+	var doc []byte
+	tlsConn := tls.Client(conn, &tls.Config{
+		// 不使用 WebPKI 校验，改由 VerifyPeerCertificate 校验证明文档
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS13,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("Enclave 未提供证书")
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return fmt.Errorf("解析 RA-TLS 证书失败: %v", err)
+			}
+			if err := cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature); err != nil {
+				return fmt.Errorf("RA-TLS 证书自签名无效: %v", err)
+			}
+
+			d, err := VerifyRATLSCertificate(cert, verify)
+			if err != nil {
+				return err
+			}
+			doc = d
+			return nil
+		},
+	})
+
+	if err := tlsConn.Handshake(); err != nil {
+		return nil, nil, fmt.Errorf("RA-TLS 握手失败: %v", err)
+	}
+	return tlsConn, doc, nil
+}
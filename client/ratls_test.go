@@ -0,0 +1,47 @@
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRATLSRejectsForgedAttestation(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(1),
+		Subject:         pkix.Name{CommonName: "enclave"},
+		NotBefore:       time.Now().Add(-time.Minute),
+		NotAfter:        time.Now().Add(time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: oidAttestationDocument, Value: forgedDocument(t, spki, nil)}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 公钥绑定成立，但文档未经签名
+	if _, err := VerifyRATLSCertificate(cert, func([]byte) error { return nil }); err != nil {
+		t.Fatalf("伪造文档应能通过绑定检查: %v", err)
+	}
+	if _, err := VerifyRATLSCertificate(cert, nil); err == nil || !strings.Contains(err.Error(), "证明文档校验失败") {
+		t.Fatalf("未签名的证明文档应被拒绝: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package client
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// 发往 Enclave 的加密消息 (encrypt 子命令的输出) - 与 enclave 端匹配
+type SealedMessage struct {
+	// 加密算法: SealHPKE 或 SealRSAOAEP
+	Algorithm string `json:"alg"`
+	// 接收方公钥 (SubjectPublicKeyInfo) 的 SHA-256 (十六进制)
+	KeyID string `json:"kid"`
+	// HPKE 的封装密钥，或以 RSA-OAEP 加密的内容密钥
+	Encapsulated []byte `json:"enc"`
+	// RSA-OAEP 时 AES-GCM 的 nonce
+	Nonce      []byte `json:"iv,omitempty"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// 加密算法 - 与 enclave 端匹配
+const (
+	// RFC 9180 HPKE base 模式: DHKEM(X25519, HKDF-SHA256)、HKDF-SHA256、AES-256-GCM
+	SealHPKE = "HPKE-X25519-SHA256-A256GCM"
+	// RSA-OAEP-SHA256 加密随机的 AES-256-GCM 内容密钥
+	SealRSAOAEP = "RSA-OAEP-256+A256GCM"
+)
+
+// HPKE 的 info、RSA-OAEP 的 label；加密消息的 kid 作为 AEAD 的附加数据 - 与 enclave 端匹配
+const sealInfo = "aws-enclave-attestation sealed message v1"
+
+// 以证明文档中的公钥 (DER 格式的 SubjectPublicKeyInfo) 加密 plaintext：X25519 公钥使用 HPKE，
+// RSA 公钥以 RSA-OAEP 加密随机的 AES-256-GCM 内容密钥；调用方应先校验证明文档
+func Seal(spki, plaintext []byte) (*SealedMessage, error) {
+	return seal(spki, plaintext, sealInfo)
+}
+
+// info 为 HPKE 的 info 或 RSA-OAEP 的 label，区分消息的用途
+func seal(spki, plaintext []byte, info string) (*SealedMessage, error) {
+	public, err := x509.ParsePKIXPublicKey(spki)
+	if err != nil {
+		return nil, fmt.Errorf("解析证明文档中的公钥失败: %v", err)
+	}
+	sum := sha256.Sum256(spki)
+	message := &SealedMessage{KeyID: hex.EncodeToString(sum[:])}
+
+	var contentKey, nonce []byte
+	switch public := public.(type) {
+	case *ecdh.PublicKey:
+		if public.Curve() != ecdh.X25519() {
+			return nil, fmt.Errorf("不支持的 ECDH 曲线")
+		}
+		sharedSecret, enc, err := hpkeEncap(public)
+		if err != nil {
+			return nil, err
+		}
+		message.Algorithm, message.Encapsulated = SealHPKE, enc
+		contentKey, nonce = hpkeKeySchedule(sharedSecret, []byte(info))
+	case *rsa.PublicKey:
+		contentKey = make([]byte, 32)
+		nonce = make([]byte, 12)
+		if _, err := rand.Read(contentKey); err != nil {
+			return nil, err
+		}
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		wrapped, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, public, contentKey, []byte(info))
+		if err != nil {
+			return nil, err
+		}
+		message.Algorithm, message.Encapsulated, message.Nonce = SealRSAOAEP, wrapped, nonce
+	default:
+		return nil, fmt.Errorf("不支持的公钥类型 %T (需要 X25519 或 RSA)", public)
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	message.Ciphertext = gcm.Seal(nil, nonce, plaintext, []byte(message.KeyID))
+	return message, nil
+}
+
+// 请求 Enclave 为 decrypt 方法的解密公钥生成证明文档，nonce 可为空；Enclave 需以 --allow-decrypt 启动
+func (c *Client) DecryptKey(ctx context.Context, nonce string) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodDecryptKey, Nonce: nonce})
+}
+
+// 将 Seal 加密的消息 (SealedMessage 的 JSON) 发送给 Enclave 解密，明文保存在 Enclave 内的 handle 下，
+// 响应的 Secret 中只有句柄和大小
+func (c *Client) Decrypt(ctx context.Context, handle string, sealed []byte) (*Response, error) {
+	return c.call(ctx, CommandArgs{Method: MethodDecrypt, Handle: handle, DataB64: base64.StdEncoding.EncodeToString(sealed)})
+}
+
+// RFC 9180 的算法标识
+const (
+	hpkeKEMX25519  = 0x0020
+	hpkeKDFSHA256  = 0x0001
+	hpkeAEADAES256 = 0x0002
+)
+
+// DHKEM(X25519, HKDF-SHA256) 的 Encap: 以临时密钥与接收方公钥计算共享密钥，返回共享密钥及封装密钥 (临时公钥)
+func hpkeEncap(recipient *ecdh.PublicKey) ([]byte, []byte, error) {
+	ephemeral, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	dh, err := ephemeral.ECDH(recipient)
+	if err != nil {
+		return nil, nil, err
+	}
+	enc := ephemeral.PublicKey().Bytes()
+	kemContext := append(append([]byte{}, enc...), recipient.Bytes()...)
+	suiteID := binary.BigEndian.AppendUint16([]byte("KEM"), hpkeKEMX25519)
+	prk := hpkeLabeledExtract(suiteID, nil, "eae_prk", dh)
+	return hpkeLabeledExpand(suiteID, prk, "shared_secret", kemContext, 32), enc, nil
+}
+
+// base 模式 (无 PSK) 的密钥调度，返回 AEAD 密钥及第一条消息的 nonce
+func hpkeKeySchedule(sharedSecret, info []byte) (key, nonce []byte) {
+	suiteID := []byte("HPKE")
+	for _, id := range []uint16{hpkeKEMX25519, hpkeKDFSHA256, hpkeAEADAES256} {
+		suiteID = binary.BigEndian.AppendUint16(suiteID, id)
+	}
+	context := []byte{0x00}
+	context = append(context, hpkeLabeledExtract(suiteID, nil, "psk_id_hash", nil)...)
+	context = append(context, hpkeLabeledExtract(suiteID, nil, "info_hash", info)...)
+	secret := hpkeLabeledExtract(suiteID, sharedSecret, "secret", nil)
+	return hpkeLabeledExpand(suiteID, secret, "key", context, 32), hpkeLabeledExpand(suiteID, secret, "base_nonce", context, 12)
+}
+
+func hpkeLabeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeled := append(append([]byte("HPKE-v1"), suiteID...), label...)
+	return hkdf.Extract(sha256.New, append(labeled, ikm...), salt)
+}
+
+func hpkeLabeledExpand(suiteID, prk []byte, label string, info []byte, length int) []byte {
+	labeled := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeled = append(append(append(labeled, "HPKE-v1"...), suiteID...), label...)
+	out := make([]byte, length)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, append(labeled, info...)), out)
+	return out
+}
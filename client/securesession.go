@@ -0,0 +1,306 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"golang.org/x/crypto/hkdf"
+)
+
+// HKDF 的 info 前缀，后接会话 ID、密钥代数、客户端公钥和 Enclave 公钥 - 与 enclave 端匹配
+const sessionInfo = "aws-enclave-attestation session v1"
+
+// 会话超过 Enclave 规定的最长有效期，须重新建立
+var ErrSessionExpired = errors.New("加密会话已超过最长有效期")
+
+// session-open、session-rekey 方法的结果 - 与 enclave 端匹配
+type SessionInfo struct {
+	ID string `json:"id" cbor:"id"`
+	// Enclave 的临时 X25519 公钥 (32 字节)，session-open 时证明文档的 public_key 为其 SubjectPublicKeyInfo
+	PublicKey []byte `json:"public_key" cbor:"public_key"`
+	// 每代会话密钥的使用上限: 请求数、加密的字节数 (请求和响应的密文) 及时间 (秒)，达到任一上限后只接受 session-rekey
+	RekeyRequests int64 `json:"rekey_requests,omitempty" cbor:"rekey_requests,omitempty"`
+	RekeyBytes    int64 `json:"rekey_bytes,omitempty" cbor:"rekey_bytes,omitempty"`
+	RekeyInterval int64 `json:"rekey_interval,omitempty" cbor:"rekey_interval,omitempty"`
+	// 会话的最长有效期 (秒，自 session-open 起)，到期后须重新 session-open
+	Lifetime int64 `json:"lifetime,omitempty" cbor:"lifetime,omitempty"`
+}
+
+// 与 Enclave 的加密会话: 请求和响应以 ECDH 派生的 AES-256-GCM 密钥加密后经 session 方法发送。
+// 会话不绑定连接，可在连接到同一 Enclave 的任意 Client 上使用；同一会话的请求串行发送。
+// 当前一代密钥达到 Enclave 规定的使用上限前，Call 自动以 session-rekey 更换密钥
+type Session struct {
+	// Enclave 分配的会话 ID
+	ID string
+
+	// 绑定 Enclave 会话公钥及本次随机数的证明文档
+	attestation []byte
+
+	// Enclave 规定的密钥使用上限和最长有效期，计时均从发送请求前开始，早于 Enclave 到期
+	limits  SessionInfo
+	created time.Time
+
+	mu sync.Mutex
+	// 当前一代的客户端到 Enclave、Enclave 到客户端的密钥，及派生下一代密钥的链密钥
+	send    cipher.AEAD
+	receive cipher.AEAD
+	chain   []byte
+	// 密钥代数及当前一代密钥的启用时间、已发送的请求数和加密字节数
+	epoch      uint64
+	epochStart time.Time
+	requests   int64
+	bytes      int64
+	// 下一个请求的序号，更换密钥后继续递增
+	sequence uint64
+}
+
+// 以临时 X25519 密钥与 Enclave 建立加密会话，证明文档须绑定 Enclave 的会话公钥和本次随机数，
+// 并以 verify 校验 (签名、证书链、PCR 等)；verify 为空时以内置的 AWS Nitro Enclaves 根证书校验签名和证书链
+func (c *Client) OpenSession(ctx context.Context, verify func(document []byte) error) (*Session, error) {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("生成会话随机数失败: %v", err)
+	}
+
+	created := time.Now()
+	response, err := c.call(ctx, CommandArgs{
+		Method:   MethodSessionOpen,
+		DataB64:  base64.StdEncoding.EncodeToString(private.PublicKey().Bytes()),
+		NonceB64: base64.StdEncoding.EncodeToString(nonce),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := response.Err(); err != nil {
+		return nil, err
+	}
+	if response.Session == nil {
+		return nil, fmt.Errorf("Enclave 响应中没有会话")
+	}
+
+	peer, err := ecdh.X25519().NewPublicKey(response.Session.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 Enclave 会话公钥: %v", err)
+	}
+	doc := attestation.Decode([]byte(response.Document))
+	if err := checkSessionBinding(doc, peer, nonce); err != nil {
+		return nil, err
+	}
+	if verify == nil {
+		verify = verifyAttestation
+	}
+	if err := verify(doc); err != nil {
+		return nil, fmt.Errorf("证明文档校验失败: %v", err)
+	}
+
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{ID: response.Session.ID, attestation: doc, limits: *response.Session, created: created, epochStart: created}
+	s.send, s.receive, s.chain, err = deriveSessionKeys(shared, nonce, s.ID, 0, private.PublicKey().Bytes(), peer.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// 检查证明文档是否绑定了 Enclave 的会话公钥和本次的随机数
+func checkSessionBinding(doc []byte, peer *ecdh.PublicKey, nonce []byte) error {
+	binding, err := attestation.Parse(doc)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKIXPublicKey(peer)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(binding.PublicKey, der) {
+		return fmt.Errorf("证明文档中的 public_key 与 Enclave 会话公钥不一致")
+	}
+	if !bytes.Equal(binding.Nonce, nonce) {
+		return fmt.Errorf("证明文档中的 nonce 与会话随机数不一致")
+	}
+	return nil
+}
+
+// 建立会话时 Enclave 提供的证明文档，其 public_key 为会话公钥
+func (s *Session) Attestation() []byte {
+	return s.attestation
+}
+
+// 会话是否已超过 Enclave 规定的最长有效期
+func (s *Session) Expired() bool {
+	return s.limits.Lifetime > 0 && time.Since(s.created) >= time.Duration(s.limits.Lifetime)*time.Second
+}
+
+// 经连接 c 发送加密的请求，返回解密后的响应；当前一代密钥达到使用上限时先更换密钥。
+// Enclave 拒绝会话请求 (会话过期、序号不符等) 时返回其明文错误响应，会话超过最长有效期时返回 ErrSessionExpired
+func (s *Session) Call(ctx context.Context, c *Client, args CommandArgs) (*Response, error) {
+	if args.SchemaVersion == 0 {
+		args.SchemaVersion = SchemaVersion
+	}
+	if args.TraceParent == "" {
+		args.TraceParent = traceParent(ctx)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Expired() {
+		return nil, ErrSessionExpired
+	}
+	if s.rekeyDue() {
+		if err := s.rekey(ctx, c); err != nil {
+			return nil, fmt.Errorf("更换会话密钥失败: %w", err)
+		}
+	}
+	return s.roundTrip(ctx, c, args)
+}
+
+// 立即以新的临时密钥更换会话密钥，旧密钥随即丢弃
+func (s *Session) Rekey(ctx context.Context, c *Client) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.Expired() {
+		return ErrSessionExpired
+	}
+	return s.rekey(ctx, c)
+}
+
+// 当前一代密钥是否已达到 Enclave 规定的使用上限
+func (s *Session) rekeyDue() bool {
+	return s.limits.RekeyRequests > 0 && s.requests >= s.limits.RekeyRequests ||
+		s.limits.RekeyBytes > 0 && s.bytes >= s.limits.RekeyBytes ||
+		s.limits.RekeyInterval > 0 && time.Since(s.epochStart) >= time.Duration(s.limits.RekeyInterval)*time.Second
+}
+
+// 在会话中发送 session-rekey，响应以旧密钥加密，之后以链密钥派生新一代密钥；调用方持有 s.mu
+func (s *Session) rekey(ctx context.Context, c *Client) error {
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	epochStart := time.Now()
+	response, err := s.roundTrip(ctx, c, CommandArgs{
+		SchemaVersion: SchemaVersion,
+		Method:        MethodSessionRekey,
+		DataB64:       base64.StdEncoding.EncodeToString(private.PublicKey().Bytes()),
+		TraceParent:   traceParent(ctx),
+	})
+	if err != nil {
+		return err
+	}
+	if err := response.Err(); err != nil {
+		return err
+	}
+	if response.Session == nil {
+		return fmt.Errorf("Enclave 响应中没有会话")
+	}
+	peer, err := ecdh.X25519().NewPublicKey(response.Session.PublicKey)
+	if err != nil {
+		return fmt.Errorf("无效的 Enclave 会话公钥: %v", err)
+	}
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return err
+	}
+	send, receive, chain, err := deriveSessionKeys(shared, s.chain, s.ID, s.epoch+1, private.PublicKey().Bytes(), peer.Bytes())
+	if err != nil {
+		return err
+	}
+	s.send, s.receive, s.chain = send, receive, chain
+	s.epoch++
+	s.epochStart = epochStart
+	s.requests, s.bytes = 0, 0
+	s.limits.RekeyRequests, s.limits.RekeyBytes, s.limits.RekeyInterval = response.Session.RekeyRequests, response.Session.RekeyBytes, response.Session.RekeyInterval
+	return nil
+}
+
+// 以当前一代密钥完成一次加密的收发；调用方持有 s.mu
+func (s *Session) roundTrip(ctx context.Context, c *Client, args CommandArgs) (*Response, error) {
+	plaintext, err := json.Marshal(args)
+	if err != nil {
+		return nil, fmt.Errorf("序列化参数失败: %v", err)
+	}
+	sequence := s.sequence
+	sealed := s.send.Seal(nil, sessionNonce(sequence), plaintext, []byte(s.ID))
+	response, err := c.exchange(ctx, CommandArgs{
+		SchemaVersion: args.SchemaVersion,
+		Method:        MethodSession,
+		Session:       s.ID,
+		Sequence:      sequence,
+		DataB64:       base64.StdEncoding.EncodeToString(sealed),
+	})
+	if err != nil || !response.Success {
+		return response, err
+	}
+
+	// Enclave 已处理该序号，无论响应能否解密都不能再使用
+	s.sequence++
+	s.requests++
+	s.bytes += int64(len(sealed) + len(response.Sealed))
+	decrypted, err := s.receive.Open(nil, sessionNonce(sequence), response.Sealed, []byte(s.ID))
+	if err != nil {
+		return nil, fmt.Errorf("会话响应认证失败")
+	}
+	var inner Response
+	if err := json.Unmarshal(decrypted, &inner); err != nil {
+		return nil, fmt.Errorf("解析会话响应失败: %v", err)
+	}
+	return &inner, nil
+}
+
+// 由 ECDH 共享密钥派生第 epoch 代的客户端到 Enclave、Enclave 到客户端的 AES-256-GCM 密钥及下一代的链密钥；
+// 第 0 代的 salt 为 session-open 的随机数，之后为上一代的链密钥 - 与 enclave 端匹配
+func deriveSessionKeys(shared, salt []byte, id string, epoch uint64, clientPublic, enclavePublic []byte) (cipher.AEAD, cipher.AEAD, []byte, error) {
+	info := append([]byte(sessionInfo), id...)
+	info = binary.BigEndian.AppendUint64(info, epoch)
+	info = append(append(info, clientPublic...), enclavePublic...)
+	keys := make([]byte, 96)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, info), keys); err != nil {
+		return nil, nil, nil, err
+	}
+	clientToEnclave, err := newSessionAEAD(keys[:32])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	enclaveToClient, err := newSessionAEAD(keys[32:64])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return clientToEnclave, enclaveToClient, keys[64:], nil
+}
+
+func newSessionAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 以序号构造 GCM nonce - 与 enclave 端匹配
+func sessionNonce(sequence uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], sequence)
+	return nonce
+}
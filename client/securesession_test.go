@@ -0,0 +1,46 @@
+package client
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestSessionRejectsForgedAttestation(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+
+	// 伪造的 Enclave: 以未签名的证明文档声明自己的会话公钥
+	go func() {
+		defer server.Close()
+		if _, err := readFrame(server); err != nil {
+			return
+		}
+		ack, _ := json.Marshal(helloAck{Codec: CodecJSON})
+		writeFrame(server, ack)
+
+		payload, err := readFrame(server)
+		if err != nil {
+			return
+		}
+		var args CommandArgs
+		json.Unmarshal(payload, &args)
+		nonce, _ := base64.StdEncoding.DecodeString(args.NonceB64)
+		private, _ := ecdh.X25519().GenerateKey(rand.Reader)
+		response, _ := json.Marshal(Response{
+			Success:  true,
+			Document: base64.StdEncoding.EncodeToString(forgedKeyDocument(t, private.PublicKey(), nonce)),
+			Session:  &SessionInfo{ID: "forged", PublicKey: private.PublicKey().Bytes()},
+		})
+		writeFrame(server, response)
+	}()
+
+	_, err := newClient(client, &Options{Session: true})
+	if err == nil || !strings.Contains(err.Error(), "证明文档校验失败") {
+		t.Fatalf("未签名的证明文档应被拒绝: %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ACM 证书包的最大字节数
+const maxACMBundleSize = 1 << 20
+
+// ACM 证书指纹在证明文档 user_data 中的声明名
+const acmFingerprintClaim = "acm_cert_sha256"
+
+// ACM for Nitro Enclaves 将证书关联到 IAM 角色后放入 S3 的证书包:
+// 证书、证书链及以 KMS 加密的私钥 (base64)，私钥只能以证明文档经 KMS 解密
+type acmBundle struct {
+	Certificate         string
+	CertificateChain    string
+	EncryptedPrivateKey string
+}
+
+// 最近一次加载的 ACM 证书 (含证书链) 及私钥，供 IAM Roles Anywhere 签名
+var (
+	acmKeyPairMu sync.RWMutex
+	acmKeyPair   *tls.Certificate
+)
+
+// 解析 --acm-cert 的 s3://BUCKET/KEY
+func parseS3URL(value string) (bucket, key string, err error) {
+	rest, ok := strings.CutPrefix(value, "s3://")
+	if ok {
+		bucket, key, ok = strings.Cut(rest, "/")
+	}
+	if !ok || bucket == "" || key == "" {
+		return "", "", fmt.Errorf("无效的 S3 地址 %q (格式为 s3://BUCKET/KEY)", value)
+	}
+	return bucket, key, nil
+}
+
+// --acm-dir 下供 Enclave 内 TLS 终结进程使用的证书 (含证书链) 和私钥文件
+func acmCertFile() string { return filepath.Join(config.ACMDir, "cert.pem") }
+func acmKeyFile() string  { return filepath.Join(config.ACMDir, "key.pem") }
+
+// 受监管应用的环境变量: ACM 证书和私钥文件的路径
+func acmEnv() []string {
+	if config.ACMCert == "" {
+		return nil
+	}
+	return []string{"ACM_CERT_FILE=" + acmCertFile(), "ACM_KEY_FILE=" + acmKeyFile()}
+}
+
+// 读取 --acm-cert 的证书包，以证明文档解密私钥后写入 --acm-dir，并将证书指纹写入此后每份证明文档的 user_data；
+// 启动时失败则按退避间隔重试，之后每隔 --acm-refresh 重新读取 (ACM 续期后证书包会更新)
+func startACMCertificate() error {
+	if err := os.MkdirAll(config.ACMDir, 0700); err != nil {
+		return err
+	}
+	if err := retryStartup("读取 ACM 证书", loadACMCertificate); err != nil {
+		return err
+	}
+	if config.ACMRefresh > 0 {
+		go func() {
+			for range time.Tick(config.ACMRefresh) {
+				if err := loadACMCertificate(); err != nil {
+					log.Printf("刷新 ACM 证书失败，继续使用当前证书: %v\n", err)
+				}
+			}
+		}()
+	}
+	return nil
+}
+
+func loadACMCertificate() error {
+	bucket, key, err := parseS3URL(config.ACMCert)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	cfg, err := loadInstanceAWSConfig(ctx, "")
+	if err != nil {
+		return err
+	}
+	data, _, err := getS3Object(ctx, cfg, bucket, key, maxACMBundleSize)
+	if err != nil {
+		return err
+	}
+	var bundle acmBundle
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return fmt.Errorf("解析 ACM 证书包失败: %v", err)
+	}
+	if bundle.Certificate == "" || bundle.EncryptedPrivateKey == "" {
+		return errors.New("ACM 证书包中缺少证书或加密的私钥")
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(bundle.EncryptedPrivateKey)
+	if err != nil {
+		return fmt.Errorf("解码加密的私钥失败: %v", err)
+	}
+	keyPEM, _, err := kmsDecrypt(ctx, cfg, ciphertext, config.ACMKeyID, nil)
+	if err != nil {
+		return fmt.Errorf("解密 ACM 私钥失败: %v", err)
+	}
+
+	certPEM := []byte(strings.TrimSpace(bundle.Certificate) + "\n")
+	if chain := strings.TrimSpace(bundle.CertificateChain); chain != "" {
+		certPEM = append(certPEM, chain+"\n"...)
+	}
+	// 同时校验私钥与证书匹配
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return fmt.Errorf("ACM 证书与私钥不匹配: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return fmt.Errorf("解析 ACM 证书失败: %v", err)
+	}
+	fingerprint := sha256.Sum256(leaf.Raw)
+
+	if err := writePrivateFile(acmKeyFile(), keyPEM); err != nil {
+		return err
+	}
+	if err := writePrivateFile(acmCertFile(), certPEM); err != nil {
+		return err
+	}
+	pair.Leaf = leaf
+	acmKeyPairMu.Lock()
+	acmKeyPair = &pair
+	acmKeyPairMu.Unlock()
+	setUserDataClaim(acmFingerprintClaim, hex.EncodeToString(fingerprint[:]))
+	log.Printf("已加载 ACM 证书 %s (SHA-256 %x)\n", leaf.Subject, fingerprint)
+	return nil
+}
+
+// 以 0600 权限原子地写入文件，TLS 终结进程不会读到写了一半的证书或私钥
+func writePrivateFile(path string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(data); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestLoadACMCertificate(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	t.Cleanup(func() {
+		userDataClaimsMu.Lock()
+		userDataClaims = map[string]interface{}{}
+		userDataClaimsMu.Unlock()
+	})
+	fake := newFakeNSM()
+	useFakeNSM(t, fake)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "app.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	// 证书包中的私钥为 KMS 密文，fakeAWS 的 KMS 返回以证明文档公钥加密的 keyPEM
+	bundle := mustJSON(t, map[string]string{
+		"Certificate":         string(certPEM),
+		"EncryptedPrivateKey": base64.StdEncoding.EncodeToString([]byte("kms-ciphertext")),
+	})
+	useFakeAWS(t, keyPEM, map[string][]byte{"certs/acm.json": bundle})
+	config.ACMDir = t.TempDir()
+
+	config.ACMCert = "s3://certs/missing.json"
+	if err := loadACMCertificate(); err == nil {
+		t.Fatal("不存在的证书包应返回错误")
+	}
+
+	config.ACMCert = "s3://certs/acm.json"
+	if err := loadACMCertificate(); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(acmKeyFile()); err != nil || !bytes.Equal(data, keyPEM) {
+		t.Fatalf("私钥文件: %q, %v", data, err)
+	}
+	if info, err := os.Stat(acmKeyFile()); err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("私钥文件权限: %v, %v", info, err)
+	}
+	if data, err := os.ReadFile(acmCertFile()); err != nil || !bytes.Equal(data, certPEM) {
+		t.Fatalf("证书文件: %q, %v", data, err)
+	}
+
+	// 证书指纹写入此后每份证明文档的 user_data
+	if r := processRequest(CommandArgs{UserData: "hello"}); !r.Success {
+		t.Fatalf("attest: %+v", r)
+	}
+	var claims map[string]interface{}
+	if err := cbor.Unmarshal(fake.userData, &claims); err != nil {
+		t.Fatal(err)
+	}
+	fingerprint := sha256.Sum256(der)
+	if claims[acmFingerprintClaim] != hex.EncodeToString(fingerprint[:]) || string(claims["user_data"].([]byte)) != "hello" {
+		t.Fatalf("user_data 声明: %v", claims)
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// age 插件包装文件密钥时 HPKE 的 info，与 decrypt 方法的消息区分，使 age-unwrap 不能用于解出 decrypt 的明文 - 与 client 端匹配
+const ageSealInfo = "aws-enclave-attestation age file key v1"
+
+// age 的文件密钥长度
+const ageFileKeySize = 16
+
+// age-unwrap 请求: data_b64 为 age 插件 (age-plugin-enclave) 以 decrypt-key 证明的 X25519 公钥包装的文件密钥，
+// Enclave 解出后返回给插件，使只有能连接该 Enclave 的主机才能解密 age 文件；需以 --allow-age-unwrap 启动
+func ageUnwrapRequest(args CommandArgs) Response {
+	if !config.AllowAgeUnwrap {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 age-unwrap 方法 (--allow-age-unwrap)"}
+	}
+	data, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 data_b64 失败: %v", err)}
+	}
+	var message SealedMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解析包装的文件密钥失败: %v", err)}
+	}
+	if message.Algorithm != sealHPKE {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("age 文件密钥只支持 %s (--decrypt-key-type x25519)", sealHPKE)}
+	}
+	key, err := getDecryptKey()
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	if message.KeyID != key.kid {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("文件的接收方密钥 %s 不是当前的解密密钥 %s", message.KeyID, key.kid)}
+	}
+	fileKey, err := openSealedMessage(key, &message, ageSealInfo)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: err.Error()}
+	}
+	if len(fileKey) != ageFileKeySize {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("文件密钥应为 %d 字节", ageFileKeySize)}
+	}
+	log.Printf("已为 age 插件解出文件密钥 (接收方密钥 %s)\n", key.kid)
+	return Response{Success: true, FileKey: fileKey}
+}
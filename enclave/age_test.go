@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"sync"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestAgeUnwrap(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+	decryptKeyOnce, decryptKeyPair, decryptKeyErr = sync.Once{}, nil, nil
+	t.Cleanup(func() { decryptKeyOnce, decryptKeyPair, decryptKeyErr = sync.Once{}, nil, nil })
+
+	config.DecryptKeyType = decryptKeyX25519
+	config.AllowAgeUnwrap = true
+	response := attestDecryptKey(CommandArgs{})
+	if !response.Success {
+		t.Fatalf("未启用 --allow-decrypt 时 age-unwrap 也应能获取解密公钥: %+v", response)
+	}
+	raw, _ := base64.StdEncoding.DecodeString(response.Document)
+	var document map[string][]byte
+	if err := cbor.Unmarshal(raw, &document); err != nil {
+		t.Fatal(err)
+	}
+
+	unwrap := func(message SealedMessage) Response {
+		return ageUnwrapRequest(CommandArgs{DataB64: base64.StdEncoding.EncodeToString(mustJSON(t, message))})
+	}
+	fileKey := bytes.Repeat([]byte{7}, ageFileKeySize)
+	message := sealForTest(t, document["public_key"], fileKey, ageSealInfo)
+	if response := unwrap(message); !response.Success || !bytes.Equal(response.FileKey, fileKey) {
+		t.Fatalf("age-unwrap 失败: %+v", response)
+	}
+
+	// decrypt 方法的消息不能经 age-unwrap 解出
+	if response := unwrap(sealForTest(t, document["public_key"], fileKey, sealInfo)); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("以 decrypt 的 info 加密的消息应被拒绝: %+v", response)
+	}
+	if response := unwrap(sealForTest(t, document["public_key"], []byte("too short"), ageSealInfo)); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("长度错误的文件密钥应被拒绝: %+v", response)
+	}
+
+	config.AllowAgeUnwrap = false
+	if response := unwrap(message); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("未启用时应拒绝: %+v", response)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// 应用重启的最长退避间隔
+const maxAppRestartDelay = 30 * time.Second
+
+// 应用连续运行超过该时间后，下次退出时从最短间隔开始重启
+const appStableRunTime = time.Minute
+
+// 监管 -- 之后的应用命令: 以 Enclave 服务器的环境变量 (含 AWS_EC2_METADATA_SERVICE_ENDPOINT) 加上 env 启动，
+// 标准输出和标准错误与服务器相同，退出后按指数退避间隔重启
+func superviseApplication(env []string) {
+	delay := time.Second
+	for {
+		cmd := exec.Command(config.App[0], config.App[1:]...)
+		cmd.Env = append(os.Environ(), env...)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+
+		start := time.Now()
+		if err := cmd.Start(); err != nil {
+			log.Printf("启动应用失败: %v\n", err)
+		} else {
+			log.Printf("应用已启动 (pid %d): %s\n", cmd.Process.Pid, strings.Join(config.App, " "))
+			if err := cmd.Wait(); err != nil {
+				log.Printf("应用退出: %v\n", err)
+			} else {
+				log.Println("应用退出，状态码 0")
+			}
+		}
+
+		if time.Since(start) > appStableRunTime {
+			delay = time.Second
+		}
+		log.Printf("%s 后重启应用\n", delay)
+		time.Sleep(delay)
+		delay = min(delay*2, maxAppRestartDelay)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// 证据类型，随 attest 响应返回，客户端据此选择校验器 - 与 client 端匹配
+const (
+	// AWS Nitro Enclaves 证明文档 (COSE_Sign1)
+	evidenceNitro = "aws-nitro"
+)
+
+// 证明后端: 生成绑定 user_data、public_key 和 nonce 的 TEE 证据
+// vsock/RPC 层只通过该接口生成证明，AMD SEV-SNP、Intel TDX 等后端实现该接口并加入 attesters 即可
+type Attester interface {
+	// 证据类型，如 aws-nitro
+	Type() string
+	// 生成证据，返回原始字节；空的输入不包含在证据中
+	Attest(userData, publicKey, nonce []byte) ([]byte, error)
+	// 证据中 user_data、nonce 的最大长度及 public_key 的最大长度
+	Limits() (maxUserData, maxPublicKey int)
+}
+
+// 支持的证明后端，按证据类型索引
+var attesters = map[string]Attester{
+	evidenceNitro: nitroAttester{},
+}
+
+// 以 --attester 选择的证明后端
+func currentAttester() (Attester, error) {
+	attester, ok := attesters[config.Attester]
+	if !ok {
+		names := make([]string, 0, len(attesters))
+		for name := range attesters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("不支持的证明后端: %s (可选 %s)", config.Attester, strings.Join(names, "、"))
+	}
+	return attester, nil
+}
+
+// Nitro 证明后端，证据为 NSM 生成的 COSE_Sign1 证明文档，--mock-nsm 时由模拟 NSM 生成
+type nitroAttester struct{}
+
+func (nitroAttester) Type() string { return evidenceNitro }
+
+func (nitroAttester) Attest(userData, publicKey, nonce []byte) ([]byte, error) {
+	return nsmAttest(userData, publicKey, nonce)
+}
+
+func (nitroAttester) Limits() (int, int) {
+	return maxUserDataSize, maxPublicKeySize
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 审计日志首条记录的 prev
+var auditGenesis = strings.Repeat("0", sha256.Size*2)
+
+// 审计日志记录，每行一条 JSON，prev 为上一行的 SHA-256，修改或删除任一行都会破坏哈希链
+type auditEntry struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Prev string    `json:"prev"`
+
+	Peer   string `json:"peer,omitempty"`
+	Method string `json:"method"`
+	// 请求输入 (user_data、public_key、nonce 等) 的 SHA-256，不记录原文
+	InputsHash string `json:"inputs_sha256"`
+	// 返回的证明文档 (解码后) 的 SHA-256
+	DocumentHash string `json:"document_sha256,omitempty"`
+	// ok 或错误码
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// 追加写入的哈希链审计日志
+type auditLog struct {
+	mu   sync.Mutex
+	w    io.Writer
+	seq  uint64
+	prev string
+}
+
+// 未启用审计日志时为 nil
+var audit *auditLog
+
+// 打开审计日志，文件已存在时从最后一条记录继续哈希链，"-" 表示标准输出
+func openAuditLog(path string) (*auditLog, error) {
+	if path == "-" {
+		return &auditLog{w: os.Stdout, prev: auditGenesis}, nil
+	}
+
+	l := &auditLog{prev: auditGenesis}
+	if data, err := os.ReadFile(path); err == nil {
+		if last := lastLine(data); last != nil {
+			var entry auditEntry
+			if err := json.Unmarshal(last, &entry); err != nil {
+				return nil, fmt.Errorf("解析审计日志最后一条记录失败: %v", err)
+			}
+			sum := sha256.Sum256(last)
+			l.seq = entry.Seq
+			l.prev = hex.EncodeToString(sum[:])
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取审计日志失败: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %v", err)
+	}
+	l.w = f
+	return l, nil
+}
+
+// 最后一个非空行
+func lastLine(data []byte) []byte {
+	var last []byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			last = append(last[:0], line...)
+		}
+	}
+	return last
+}
+
+// 追加一条记录，填充序号、时间和 prev
+func (l *auditLog) append(entry auditEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Seq = l.seq + 1
+	entry.Time = enclaveNow().UTC()
+	entry.Prev = l.prev
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		return err
+	}
+
+	sum := sha256.Sum256(line)
+	l.seq = entry.Seq
+	l.prev = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// 记录一次请求及其结果，peer 为对端地址
+func auditRequest(peer string, args CommandArgs, response Response) {
+	if audit == nil {
+		return
+	}
+
+	inputs, _ := json.Marshal([]interface{}{args.UserData, args.UserDataB64, args.PublicKey, args.Nonce, args.NonceB64, args.Audience, args.TTL})
+	inputsHash := sha256.Sum256(inputs)
+	entry := auditEntry{
+		Peer:       peer,
+		Method:     args.Method,
+		InputsHash: hex.EncodeToString(inputsHash[:]),
+		Result:     "ok",
+	}
+	if entry.Method == "" {
+		entry.Method = methodAttest
+	}
+	if response.Document != "" {
+		if doc, err := base64.StdEncoding.DecodeString(response.Document); err == nil {
+			sum := sha256.Sum256(doc)
+			entry.DocumentHash = hex.EncodeToString(sum[:])
+		}
+	}
+	if !response.Success {
+		entry.Result = response.ErrorCode
+		if entry.Result == "" {
+			entry.Result = "error"
+		}
+		entry.Error = response.ErrorMessage
+	}
+
+	if err := audit.append(entry); err != nil {
+		log.Printf("写入审计日志失败: %v\n", err)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// HMAC-SHA256 标签长度
+	hmacTagSize = sha256.Size
+
+	// 握手时下发的随机挑战及客户端随机数的长度
+	hmacChallengeSize = 16
+
+	// 帧方向，防止把请求帧反射为响应帧
+	directionRequest  = 'Q'
+	directionResponse = 'R'
+)
+
+// 帧认证失败
+var errBadFrameMAC = errors.New("帧 HMAC 校验失败")
+
+// 启动时加载的共享密钥，为空表示未启用 HMAC 认证
+var hmacKey []byte
+
+// 从文件加载共享密钥，文件内容为原始字节；只在文件为文本 (如十六进制或 base64) 时去除末尾换行
+func loadHMACKey(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取 HMAC 密钥文件失败: %v", err)
+	}
+
+	key := parseHMACKey(data)
+	if len(key) < 16 {
+		return fmt.Errorf("HMAC 密钥长度至少为 16 字节")
+	}
+	hmacKey = key
+	return nil
+}
+
+// 密钥文件的内容: 除末尾换行外都是可打印 ASCII 时视为文本并去除末尾换行，否则按原始字节使用 - 与 client 端匹配
+func parseHMACKey(data []byte) []byte {
+	text := strings.TrimRight(string(data), "\r\n")
+	for i := 0; i < len(text); i++ {
+		if text[i] < 0x20 || text[i] > 0x7e {
+			return data
+		}
+	}
+	return []byte(text)
+}
+
+// 握手记录的摘要: 客户端的握手 (含其随机数) 和 Enclave 的握手响应 (含挑战) 原文，各带 4 字节长度前缀，
+// 混入每帧标签中，使帧绑定双方的随机数和协商结果 - 与 client 端匹配
+func hmacTranscript(hello, ack []byte) []byte {
+	h := sha256.New()
+	for _, part := range [][]byte{hello, ack} {
+		binary.Write(h, binary.BigEndian, uint32(len(part)))
+		h.Write(part)
+	}
+	return h.Sum(nil)
+}
+
+// 按帧收发数据，启用 HMAC 时每帧前附加认证标签
+// 标签覆盖握手记录、通道号、方向、序号和负载，防止跨连接/跨流重放、乱序及篡改握手协商
+type frameConn struct {
+	rw io.ReadWriter
+
+	key        []byte
+	transcript []byte
+	channel    uint32
+	recvSeq    uint64
+	sendSeq    uint64
+}
+
+func newFrameConn(rw io.ReadWriter, sess session, channel uint32) *frameConn {
+	return &frameConn{rw: rw, key: sess.hmacKey, transcript: sess.transcript, channel: channel}
+}
+
+// 读取一帧并校验请求方向的 HMAC
+func (c *frameConn) ReadFrame(limit int) ([]byte, error) {
+	if c.key == nil {
+		return readFrame(c.rw, limit)
+	}
+
+	frame, err := readFrame(c.rw, limit+hmacTagSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(frame) < hmacTagSize {
+		return nil, errBadFrameMAC
+	}
+
+	tag, payload := frame[:hmacTagSize], frame[hmacTagSize:]
+	if !hmac.Equal(tag, c.mac(directionRequest, c.recvSeq, payload)) {
+		return nil, errBadFrameMAC
+	}
+	c.recvSeq++
+	return payload, nil
+}
+
+// 写出一帧，启用 HMAC 时附加响应方向的标签
+func (c *frameConn) WriteFrame(payload []byte) error {
+	if c.key == nil {
+		return writeFrame(c.rw, payload)
+	}
+
+	frame := append(c.mac(directionResponse, c.sendSeq, payload), payload...)
+	c.sendSeq++
+	return writeFrame(c.rw, frame)
+}
+
+func (c *frameConn) mac(direction byte, seq uint64, payload []byte) []byte {
+	var header [13]byte
+	binary.BigEndian.PutUint32(header[0:4], c.channel)
+	header[4] = direction
+	binary.BigEndian.PutUint64(header[5:13], seq)
+
+	m := hmac.New(sha256.New, c.key)
+	m.Write(c.transcript)
+	m.Write(header[:])
+	m.Write(payload)
+	return m.Sum(nil)
+}
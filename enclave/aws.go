@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// 单次 AWS API 调用 (含连接和 TLS 握手) 的超时时间
+const awsCallTimeout = 30 * time.Second
+
+// AWS 错误响应的最大读取字节数
+const maxAWSErrorBody = 4096
+
+// 一条出站规则: 访问 Target (HOST:PORT) 时改为连接 Address (主机 tcp-proxy 的转发地址)
+type egressRoute struct {
+	Target  string
+	Address string
+}
+
+// 可重复指定的 --egress 参数，格式为 HOST:PORT=vsock://CID:PORT
+type egressList []egressRoute
+
+func (l *egressList) String() string {
+	var parts []string
+	for _, route := range *l {
+		parts = append(parts, route.Target+"="+route.Address)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *egressList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		target, address, ok := strings.Cut(item, "=")
+		if !ok {
+			return fmt.Errorf("无效的出站规则 %q (格式为 HOST:PORT=vsock://CID:PORT)", item)
+		}
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			return fmt.Errorf("无效的出站目标 %q: %v", target, err)
+		}
+		if _, err := parseDialAddress(address); err != nil {
+			return err
+		}
+		*l = append(*l, egressRoute{Target: strings.ToLower(target), Address: address})
+	}
+	return nil
+}
+
+// Enclave 没有网络，出站连接按 --egress 经主机的 tcp-proxy 转发；回环地址 (如本地 IMDS 端点) 直接连接。
+// TLS 在 Enclave 内终止，主机只能看到密文
+func dialEgress(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+	for _, route := range config.Egress {
+		if route.Target == strings.ToLower(addr) {
+			return dialAddress(route.Address)
+		}
+	}
+	return nil, fmt.Errorf("%s 不在 --egress 中", addr)
+}
+
+// 经 --egress 出站的 HTTP 客户端，使用 SDK 的 BuildableClient 以支持 AWS_CA_BUNDLE 等配置
+var egressHTTPClient = awshttp.NewBuildableClient().
+	WithTransportOptions(func(tr *http.Transport) {
+		tr.DialContext = dialEgress
+		tr.MaxIdleConnsPerHost = 4
+	}).
+	WithTimeout(awsCallTimeout)
+
+// AWS 服务的 HTTPS 端点，测试时替换
+var awsEndpoint = func(service, region string) string {
+	return fmt.Sprintf("https://%s.%s.%s/", service, region, awsDomain(region))
+}
+
+// S3 对象的虚拟主机风格 URL，测试时替换
+var s3ObjectURL = func(bucket, key, region string) string {
+	return fmt.Sprintf("https://%s.s3.%s.%s/%s", bucket, region, awsDomain(region), key)
+}
+
+func awsDomain(region string) string {
+	if strings.HasPrefix(region, "cn-") {
+		return "amazonaws.com.cn"
+	}
+	return "amazonaws.com"
+}
+
+// 已加载的 AWS 配置及默认凭证链 (父实例的角色)，加载失败时不缓存 (主机的 imds-proxy 可能尚未就绪)
+var (
+	awsConfigMu        sync.Mutex
+	awsConfigLoaded    *aws.Config
+	awsBaseCredentials aws.CredentialsProvider
+)
+
+// 以默认凭证链 (环境变量或经 --imds-forward 的实例角色) 加载的 AWS 配置，--assume-role、--roles-anywhere-role 时
+// 凭证为 Enclave 自己的角色；首次成功后在进程内复用，凭证由 SDK 缓存并在过期前刷新；region 非空时覆盖默认区域
+func loadAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	awsConfigMu.Lock()
+	if awsConfigLoaded == nil {
+		options := []func(*awsconfig.LoadOptions) error{awsconfig.WithHTTPClient(egressHTTPClient)}
+		if config.IMDSForward != "" {
+			options = append(options, awsconfig.WithEC2IMDSRegion())
+		}
+		loaded, err := awsconfig.LoadDefaultConfig(ctx, options...)
+		if err != nil {
+			awsConfigMu.Unlock()
+			return aws.Config{}, fmt.Errorf("加载 AWS 配置失败: %v", err)
+		}
+		awsBaseCredentials = loaded.Credentials
+		if config.AssumeRole != "" || config.RolesAnywhereRole != "" {
+			if loaded.Region == "" {
+				awsConfigMu.Unlock()
+				return aws.Config{}, fmt.Errorf("--assume-role、--roles-anywhere-role 需要默认区域 (AWS_REGION 或 --imds-forward)")
+			}
+		}
+		switch {
+		case config.AssumeRole != "":
+			loaded.Credentials = webIdentityCredentials(loaded)
+		case config.RolesAnywhereRole != "":
+			loaded.Credentials = rolesAnywhereCredentials(loaded)
+		}
+		awsConfigLoaded = &loaded
+	}
+	cfg := awsConfigLoaded.Copy()
+	awsConfigMu.Unlock()
+
+	if region != "" {
+		cfg.Region = region
+	}
+	if cfg.Region == "" {
+		return aws.Config{}, fmt.Errorf("未指定区域 (请求中的 region、AWS_REGION 或 --imds-forward)")
+	}
+	if cfg.Credentials == nil {
+		return aws.Config{}, fmt.Errorf("没有可用的 AWS 凭证")
+	}
+	return cfg, nil
+}
+
+// 与 loadAWSConfig 相同，但始终使用默认凭证链 (父实例的角色)：ACM for Nitro Enclaves 将证书关联到父实例的角色
+func loadInstanceAWSConfig(ctx context.Context, region string) (aws.Config, error) {
+	cfg, err := loadAWSConfig(ctx, region)
+	if err != nil {
+		return cfg, err
+	}
+	awsConfigMu.Lock()
+	if awsBaseCredentials != nil {
+		cfg.Credentials = awsBaseCredentials
+	}
+	awsConfigMu.Unlock()
+	return cfg, nil
+}
+
+// AWS JSON 协议的错误响应
+type awsErrorBody struct {
+	Type    string `json:"__type"`
+	Message string `json:"message"`
+	// 部分服务使用大写的 Message
+	MessageUpper string `json:"Message"`
+}
+
+// AWS API 调用失败: 请求未送达 (StatusCode 为 0) 或服务返回的错误
+type awsError struct {
+	Target     string
+	StatusCode int
+	// 错误类型 (如 AccessDeniedException)，及错误消息或传输错误
+	Type    string
+	Message string
+}
+
+func (e *awsError) Error() string {
+	if e.Type != "" {
+		return fmt.Sprintf("调用 %s 失败: %s: %s", e.Target, e.Type, e.Message)
+	}
+	return fmt.Sprintf("调用 %s 失败: %s", e.Target, e.Message)
+}
+
+// 是否为区域性的故障 (连接失败、超时、5xx 或限流)，换一个区域重试可能成功
+func (e *awsError) regional() bool {
+	return e.StatusCode == 0 || e.StatusCode >= 500 || e.Type == "ThrottlingException"
+}
+
+// 以 SigV4 签名调用 AWS JSON 协议的 API (KMS、Secrets Manager、SSM 等)，签名时间使用同步后的 Enclave 时钟
+func callAWS(ctx context.Context, cfg aws.Config, service, target string, input, output interface{}) error {
+	body, err := json.Marshal(input)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, awsCallTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, awsEndpoint(service, cfg.Region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", target)
+
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("获取 AWS 凭证失败: %v", err)
+	}
+	payloadHash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(payloadHash[:]), service, cfg.Region, enclaveNow()); err != nil {
+		return fmt.Errorf("签名请求失败: %v", err)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return &awsError{Target: target, Message: err.Error()}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxAWSErrorBody))
+		var awsErr awsErrorBody
+		if json.Unmarshal(data, &awsErr) == nil && awsErr.Type != "" {
+			message := awsErr.Message
+			if message == "" {
+				message = awsErr.MessageUpper
+			}
+			// __type 可能带命名空间前缀，如 com.amazonaws.kms#AccessDeniedException
+			if i := strings.LastIndex(awsErr.Type, "#"); i >= 0 {
+				awsErr.Type = awsErr.Type[i+1:]
+			}
+			return &awsError{Target: target, StatusCode: resp.StatusCode, Type: awsErr.Type, Message: message}
+		}
+		return &awsError{Target: target, StatusCode: resp.StatusCode, Message: resp.Status}
+	}
+	if err := json.NewDecoder(resp.Body).Decode(output); err != nil {
+		return fmt.Errorf("解析 %s 响应失败: %v", target, err)
+	}
+	return nil
+}
+
+// 以 SigV4 签名读取 S3 对象及其响应头 (含 x-amz-meta-* 元数据)，超过 maxSize 字节时返回错误
+func getS3Object(ctx context.Context, cfg aws.Config, bucket, key string, maxSize int64) ([]byte, http.Header, error) {
+	ctx, cancel := context.WithTimeout(ctx, awsCallTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s3ObjectURL(bucket, key, cfg.Region), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取 AWS 凭证失败: %v", err)
+	}
+	emptyHash := sha256.Sum256(nil)
+	payloadHash := hex.EncodeToString(emptyHash[:])
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signer := v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+	if err := signer.SignHTTP(ctx, credentials, req, payloadHash, "s3", cfg.Region, enclaveNow()); err != nil {
+		return nil, nil, fmt.Errorf("签名请求失败: %v", err)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取 s3://%s/%s 失败: %v", bucket, key, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("读取 s3://%s/%s 失败: %s", bucket, key, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, nil, fmt.Errorf("读取 s3://%s/%s 失败: %v", bucket, key, err)
+	}
+	if int64(len(data)) > maxSize {
+		return nil, nil, fmt.Errorf("s3://%s/%s 超过 %d 字节上限", bucket, key, maxSize)
+	}
+	return data, resp.Header, nil
+}
+
+// 启动时访问 AWS 的最大尝试次数 (主机的代理可能晚于 Enclave 就绪)
+const awsStartupAttempts = 5
+
+// 按指数退避间隔重试启动时的 AWS 操作
+func retryStartup(what string, fn func() error) error {
+	var err error
+	for attempt := 0; attempt < awsStartupAttempts; attempt++ {
+		if attempt > 0 {
+			delay := time.Duration(1<<attempt) * time.Second
+			log.Printf("%s失败: %v，%s 后重试\n", what, err, delay)
+			time.Sleep(delay)
+		}
+		if err = fn(); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%s失败: %v", what, err)
+}
@@ -0,0 +1,27 @@
+package main
+
+import "fmt"
+
+// 单个 attest-batch 请求最多包含的 attest 请求数
+const maxBatchSize = 64
+
+// 在同一请求中依次处理 batch 中的 attest 请求 (各自的 nonce、user_data 等)，
+// 单个请求失败不影响其他请求，结果按顺序放在响应的 Batch 中
+func attestBatchRequest(args CommandArgs, span *traceSpan) Response {
+	if len(args.Batch) == 0 {
+		return errorResponse(errCodeBadRequest, "batch 不能为空")
+	}
+	if len(args.Batch) > maxBatchSize {
+		return errorResponse(errCodeBadRequest, fmt.Sprintf("batch 包含 %d 个请求，超过上限 %d", len(args.Batch), maxBatchSize))
+	}
+
+	responses := make([]Response, len(args.Batch))
+	for i, item := range args.Batch {
+		if item.Method != "" && item.Method != methodAttest {
+			responses[i] = errorResponse(errCodeUnsupportedMethod, fmt.Sprintf("batch 中只支持 attest 请求，收到 %s", item.Method))
+			continue
+		}
+		responses[i] = cachedProcessRequest(item, span)
+	}
+	return Response{Success: true, Batch: responses}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 写入每份证明文档 user_data 的声明 (--build-info 的构建信息、--acm-cert 的证书指纹)，编码为确定性 CBOR map
+// (与客户端 --claim 生成的声明集格式相同，可用 verify --expect-claim 断言)，
+// 调用方提供的 user_data 原样放在 user_data 键下；没有声明时 user_data 不变
+var (
+	userDataClaimsMu sync.RWMutex
+	userDataClaims   = map[string]interface{}{}
+)
+
+// 设置一条 user_data 声明，之后生成的证明文档都包含该声明
+func setUserDataClaim(key string, value interface{}) {
+	userDataClaimsMu.Lock()
+	defer userDataClaimsMu.Unlock()
+	userDataClaims[key] = value
+}
+
+// 是否有需要写入 user_data 的声明
+func hasUserDataClaims() bool {
+	userDataClaimsMu.RLock()
+	defer userDataClaimsMu.RUnlock()
+	return len(userDataClaims) > 0
+}
+
+// 读取本程序的模块构建信息 (Go 版本、模块版本、VCS 修订) 及 --app-id
+func loadBuildInfo(appID string) error {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return fmt.Errorf("无法读取构建信息 (未以模块模式构建)")
+	}
+	claims := map[string]interface{}{
+		"go_version":     info.GoVersion,
+		"module_path":    info.Main.Path,
+		"module_version": info.Main.Version,
+	}
+	if appID != "" {
+		claims["app_id"] = appID
+	}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			claims["vcs_revision"] = setting.Value
+		case "vcs.time":
+			claims["vcs_time"] = setting.Value
+		case "vcs.modified":
+			claims["vcs_modified"] = setting.Value
+		}
+	}
+	for key, value := range claims {
+		setUserDataClaim(key, value)
+	}
+	return nil
+}
+
+// 将声明与调用方的 user_data 合并为确定性 CBOR map
+func withUserDataClaims(userData []byte) ([]byte, error) {
+	userDataClaimsMu.RLock()
+	claims := make(map[string]interface{}, len(userDataClaims)+1)
+	for key, value := range userDataClaims {
+		claims[key] = value
+	}
+	userDataClaimsMu.RUnlock()
+	if len(userData) > 0 {
+		claims["user_data"] = userData
+	}
+	mode, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		return nil, err
+	}
+	return mode.Marshal(claims)
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// 缓存的最大条目数，超过时不再缓存新的输入组合
+const maxCacheEntries = 1024
+
+// 证明文档缓存: 输入完全相同的 attest 请求在 TTL 内复用最近生成的文档
+type documentCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]cacheEntry
+}
+
+type cacheEntry struct {
+	response  Response
+	expiresAt time.Time
+}
+
+var attestCache = &documentCache{entries: make(map[[sha256.Size]byte]cacheEntry)}
+
+// 以影响文档内容的输入作为缓存键
+func cacheKey(args CommandArgs) [sha256.Size]byte {
+	data, _ := json.Marshal([]string{args.UserData, args.UserDataB64, args.PublicKey, args.Nonce, args.NonceB64})
+	return sha256.Sum256(data)
+}
+
+// 处理 attest 请求，启用缓存且未要求 fresh 时优先返回缓存的文档
+func cachedProcessRequest(args CommandArgs, span *traceSpan) Response {
+	if config.CacheTTL <= 0 || args.Fresh {
+		return tracedProcessRequest(args, span)
+	}
+
+	key := cacheKey(args)
+	now := time.Now()
+
+	attestCache.mu.Lock()
+	entry, ok := attestCache.entries[key]
+	attestCache.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		span.set("cache", "hit")
+		return entry.response
+	}
+
+	span.set("cache", "miss")
+	response := tracedProcessRequest(args, span)
+	if !response.Success {
+		return response
+	}
+
+	attestCache.mu.Lock()
+	defer attestCache.mu.Unlock()
+	for k, e := range attestCache.entries {
+		if !now.Before(e.expiresAt) {
+			delete(attestCache.entries, k)
+		}
+	}
+	if len(attestCache.entries) < maxCacheEntries {
+		attestCache.entries[key] = cacheEntry{response: response, expiresAt: now.Add(config.CacheTTL)}
+	}
+	return response
+}
+
+// 调用 NSM 生成证明文档，记录为请求 span 的子 span
+func tracedProcessRequest(args CommandArgs, span *traceSpan) Response {
+	nsm := span.child("nsm.attest")
+	response := processRequest(args)
+	nsm.end(response)
+	return response
+}
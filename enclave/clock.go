@@ -0,0 +1,154 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 已同步后，新时间早于当前时钟超过该值时拒绝，防止重放旧的时间证明回拨时钟
+const clockRollbackTolerance = 5 * time.Second
+
+// set-time 方法的结果 - 与 client 端匹配
+type TimeStatus struct {
+	// 同步后 Enclave 使用的当前时间 (Unix 纳秒)
+	TimeUnixNano int64 `json:"time_unix_nano" cbor:"time_unix_nano"`
+	// 相对 Enclave 系统时钟的修正量 (纳秒)
+	OffsetNS int64 `json:"offset_ns" cbor:"offset_ns"`
+	// 时间由 --time-authority-key 签名的证明给出
+	Authenticated bool `json:"authenticated,omitempty" cbor:"authenticated,omitempty"`
+	// 证明中记录的 NTP 服务器
+	NTPServer string `json:"ntp_server,omitempty" cbor:"ntp_server,omitempty"`
+}
+
+// 主机签名的时间证明 (JWT) 的声明
+type timeProofClaims struct {
+	TimeUnixNano int64  `json:"time_unix_nano"`
+	NTPServer    string `json:"ntp_server,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// Enclave 的时钟: 系统时钟加上最近一次 set-time 的修正量，
+// 修正量在同步时按单调时钟计算，之后系统时钟的跳变不影响已同步的时间
+type enclaveClock struct {
+	mu sync.Mutex
+	// 最近一次同步时的时间及对应的系统时钟读数 (带单调时钟)
+	syncedTime time.Time
+	syncedAt   time.Time
+}
+
+var clock enclaveClock
+
+// --time-authority-key 加载的公钥，为 nil 时 set-time 不要求证明
+var timeAuthorityKey *ecdsa.PublicKey
+
+// 当前时间，用于审计记录、JWT 和 RA-TLS 证书的有效期；未同步时为系统时钟
+func enclaveNow() time.Time {
+	return clock.now()
+}
+
+func (c *enclaveClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.syncedAt.IsZero() {
+		return time.Now()
+	}
+	return c.syncedTime.Add(time.Since(c.syncedAt))
+}
+
+// 设置当前时间，已同步时拒绝回拨超过 clockRollbackTolerance 的时间
+func (c *enclaveClock) set(t time.Time) (time.Duration, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if !c.syncedAt.IsZero() {
+		current := c.syncedTime.Add(now.Sub(c.syncedAt))
+		if t.Before(current.Add(-clockRollbackTolerance)) {
+			return 0, fmt.Errorf("时间 %s 早于当前时钟 %s", t.UTC().Format(time.RFC3339Nano), current.UTC().Format(time.RFC3339Nano))
+		}
+	}
+	c.syncedTime, c.syncedAt = t, now
+	return t.Sub(now.Round(0)), nil
+}
+
+// 加载 PEM 格式的 ECDSA 公钥
+func loadTimeAuthorityKey(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取 --time-authority-key 失败: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return fmt.Errorf("--time-authority-key 不是 PEM 格式")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("解析 --time-authority-key 失败: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("--time-authority-key 必须是 ECDSA 公钥")
+	}
+	timeAuthorityKey = ecKey
+	return nil
+}
+
+// 校验时间证明的签名，返回其中的声明；Enclave 的时钟不可信，不检查 exp 等时间声明
+func verifyTimeProof(proof string) (*timeProofClaims, error) {
+	var claims timeProofClaims
+	_, err := jwt.ParseWithClaims(proof, &claims, func(*jwt.Token) (interface{}, error) {
+		return timeAuthorityKey, nil
+	}, jwt.WithValidMethods([]string{"ES256", "ES384", "ES512"}), jwt.WithoutClaimsValidation())
+	if err != nil {
+		return nil, err
+	}
+	if claims.TimeUnixNano <= 0 {
+		return nil, errors.New("证明中没有 time_unix_nano")
+	}
+	return &claims, nil
+}
+
+// set-time 请求: 以主机提供的时间同步 Enclave 的时钟
+// 指定 --time-authority-key 时必须携带其签名的时间证明，否则需以 --allow-set-time 启动
+func setTimeRequest(args CommandArgs) Response {
+	if timeAuthorityKey == nil && !config.AllowSetTime {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 set-time 方法 (--allow-set-time 或 --time-authority-key)"}
+	}
+
+	status := &TimeStatus{}
+	t := time.Unix(0, args.TimeUnixNano)
+	switch {
+	case timeAuthorityKey != nil:
+		if args.TimeProof == "" {
+			return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "必须携带 --time-authority-key 签名的 time_proof"}
+		}
+		claims, err := verifyTimeProof(args.TimeProof)
+		if err != nil {
+			return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: fmt.Sprintf("时间证明无效: %v", err)}
+		}
+		if args.TimeUnixNano != 0 && args.TimeUnixNano != claims.TimeUnixNano {
+			return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "time_unix_nano 与时间证明不符"}
+		}
+		t = time.Unix(0, claims.TimeUnixNano)
+		status.Authenticated, status.NTPServer = true, claims.NTPServer
+	case args.TimeUnixNano <= 0:
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "必须指定 time_unix_nano"}
+	}
+
+	offset, err := clock.set(t)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: err.Error()}
+	}
+	status.OffsetNS = int64(offset)
+	status.TimeUnixNano = enclaveNow().UnixNano()
+	log.Printf("已同步时钟 (修正 %s, 已认证 %t)\n", offset, status.Authenticated)
+	return Response{Success: true, Time: status}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestSetTimeProof(t *testing.T) {
+	savedConfig, savedKey := config, timeAuthorityKey
+	defer func() { config, timeAuthorityKey, clock = savedConfig, savedKey, enclaveClock{} }()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	proof := func(signer *ecdsa.PrivateKey, at time.Time) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodES256, timeProofClaims{TimeUnixNano: at.UnixNano()}).SignedString(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	if r := setTimeRequest(CommandArgs{TimeUnixNano: time.Now().UnixNano()}); r.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("未启用时应拒绝: %+v", r)
+	}
+
+	timeAuthorityKey = &key.PublicKey
+	future := time.Now().Add(time.Hour)
+	if r := setTimeRequest(CommandArgs{TimeUnixNano: future.UnixNano()}); r.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("缺少证明时应拒绝: %+v", r)
+	}
+	if r := setTimeRequest(CommandArgs{TimeProof: proof(other, future)}); r.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("其他密钥签名的证明应被拒绝: %+v", r)
+	}
+	r := setTimeRequest(CommandArgs{TimeProof: proof(key, future)})
+	if !r.Success || !r.Time.Authenticated {
+		t.Fatalf("set-time: %+v", r)
+	}
+	if skew := enclaveNow().Sub(future); skew < 0 || skew > time.Second {
+		t.Fatalf("同步后时钟偏差 %s", skew)
+	}
+
+	// 重放较早的证明不能回拨时钟
+	if r := setTimeRequest(CommandArgs{TimeProof: proof(key, time.Now())}); r.Success {
+		t.Fatal("回拨时钟的证明应被拒绝")
+	}
+}
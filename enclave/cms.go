@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+// CMS (RFC 5652) 中用到的对象标识符
+var (
+	oidEnvelopedData = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 3}
+	oidRSAESOAEP     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 7}
+	oidAES128CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// BER 嵌套的最大深度
+const maxBERDepth = 16
+
+// BER 编码的一个元素: 构造类型保存子元素，基本类型保存内容及完整编码 (用于 asn1.Unmarshal)
+type berNode struct {
+	class       int
+	tag         int
+	constructed bool
+	content     []byte
+	raw         []byte
+	children    []berNode
+}
+
+func (n berNode) is(class, tag int) bool {
+	return n.class == class && n.tag == tag
+}
+
+// 基本类型的内容，或构造编码的字符串 (如分段的 OCTET STRING) 各段内容的拼接
+func (n berNode) octets() []byte {
+	if !n.constructed {
+		return n.content
+	}
+	var out []byte
+	for _, child := range n.children {
+		out = append(out, child.octets()...)
+	}
+	return out
+}
+
+// 解析一个 BER 元素，返回剩余的字节；支持不定长编码 (KMS 返回的 CMS 使用不定长编码)
+func parseBER(data []byte, depth int) (berNode, []byte, error) {
+	if depth > maxBERDepth {
+		return berNode{}, nil, errors.New("BER 嵌套过深")
+	}
+	if len(data) < 2 {
+		return berNode{}, nil, errors.New("BER 数据被截断")
+	}
+	node := berNode{class: int(data[0] >> 6), constructed: data[0]&0x20 != 0, tag: int(data[0] & 0x1f)}
+	offset := 1
+	if node.tag == 0x1f {
+		node.tag = 0
+		for {
+			if offset >= len(data) || offset > 4 {
+				return berNode{}, nil, errors.New("无效的 BER 标签")
+			}
+			b := data[offset]
+			offset++
+			node.tag = node.tag<<7 | int(b&0x7f)
+			if b&0x80 == 0 {
+				break
+			}
+		}
+	}
+	if offset >= len(data) {
+		return berNode{}, nil, errors.New("BER 数据被截断")
+	}
+
+	length := int(data[offset])
+	offset++
+	if length == 0x80 {
+		// 不定长编码: 子元素直到 00 00 结束
+		if !node.constructed {
+			return berNode{}, nil, errors.New("基本类型不能使用不定长编码")
+		}
+		rest := data[offset:]
+		for {
+			if len(rest) >= 2 && rest[0] == 0 && rest[1] == 0 {
+				return node, rest[2:], nil
+			}
+			child, remaining, err := parseBER(rest, depth+1)
+			if err != nil {
+				return berNode{}, nil, err
+			}
+			node.children = append(node.children, child)
+			rest = remaining
+		}
+	}
+	if length > 0x80 {
+		n := length & 0x7f
+		if n > 4 || offset+n > len(data) {
+			return berNode{}, nil, errors.New("无效的 BER 长度")
+		}
+		length = 0
+		for _, b := range data[offset : offset+n] {
+			length = length<<8 | int(b)
+		}
+		offset += n
+	}
+	if length < 0 || offset+length > len(data) {
+		return berNode{}, nil, errors.New("BER 数据被截断")
+	}
+
+	node.content = data[offset : offset+length]
+	node.raw = data[:offset+length]
+	if node.constructed {
+		rest := node.content
+		for len(rest) > 0 {
+			child, remaining, err := parseBER(rest, depth+1)
+			if err != nil {
+				return berNode{}, nil, err
+			}
+			node.children = append(node.children, child)
+			rest = remaining
+		}
+	}
+	return node, data[offset+length:], nil
+}
+
+// 解析基本类型元素中的对象标识符
+func (n berNode) oid() (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	if n.constructed || !n.is(asn1.ClassUniversal, asn1.TagOID) {
+		return nil, errors.New("不是对象标识符")
+	}
+	_, err := asn1.Unmarshal(n.raw, &oid)
+	return oid, err
+}
+
+// 以 RSA 私钥解密 CMS EnvelopedData (KMS Recipient 返回的 CiphertextForRecipient):
+// 内容加密密钥以 RSAES-OAEP-SHA-256 加密，内容以 AES-CBC 加密
+func decryptEnvelopedData(data []byte, key *rsa.PrivateKey) ([]byte, error) {
+	contentInfo, _, err := parseBER(data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("解析 CMS 失败: %v", err)
+	}
+	if !contentInfo.is(asn1.ClassUniversal, asn1.TagSequence) || len(contentInfo.children) < 2 {
+		return nil, errors.New("无效的 CMS ContentInfo")
+	}
+	if oid, err := contentInfo.children[0].oid(); err != nil || !oid.Equal(oidEnvelopedData) {
+		return nil, errors.New("CMS 内容类型不是 EnvelopedData")
+	}
+	explicit := contentInfo.children[1]
+	if !explicit.is(asn1.ClassContextSpecific, 0) || len(explicit.children) != 1 {
+		return nil, errors.New("无效的 CMS ContentInfo")
+	}
+
+	// EnvelopedData: version, [0] originatorInfo (可选), recipientInfos (SET), encryptedContentInfo (SEQUENCE), ...
+	envelopedData := explicit.children[0]
+	var recipientInfos, encryptedContentInfo *berNode
+	for i := range envelopedData.children {
+		child := &envelopedData.children[i]
+		if recipientInfos == nil && child.is(asn1.ClassUniversal, asn1.TagSet) {
+			recipientInfos = child
+		} else if recipientInfos != nil && child.is(asn1.ClassUniversal, asn1.TagSequence) {
+			encryptedContentInfo = child
+			break
+		}
+	}
+	if recipientInfos == nil || encryptedContentInfo == nil {
+		return nil, errors.New("无效的 CMS EnvelopedData")
+	}
+
+	contentKey, err := decryptContentKey(*recipientInfos, key)
+	if err != nil {
+		return nil, err
+	}
+	return decryptEncryptedContent(*encryptedContentInfo, contentKey)
+}
+
+// 依次尝试 KeyTransRecipientInfo (version, rid, keyEncryptionAlgorithm, encryptedKey)，返回内容加密密钥
+func decryptContentKey(recipientInfos berNode, key *rsa.PrivateKey) ([]byte, error) {
+	for _, info := range recipientInfos.children {
+		if !info.is(asn1.ClassUniversal, asn1.TagSequence) || len(info.children) != 4 {
+			continue
+		}
+		algorithm := info.children[2]
+		if len(algorithm.children) == 0 {
+			continue
+		}
+		if oid, err := algorithm.children[0].oid(); err != nil || !oid.Equal(oidRSAESOAEP) {
+			continue
+		}
+		encryptedKey := info.children[3]
+		if !encryptedKey.is(asn1.ClassUniversal, asn1.TagOctetString) {
+			continue
+		}
+		contentKey, err := rsa.DecryptOAEP(sha256.New(), nil, key, encryptedKey.octets(), nil)
+		if err == nil {
+			return contentKey, nil
+		}
+	}
+	return nil, errors.New("CMS 中没有可以解密的 RSAES-OAEP 接收者")
+}
+
+// EncryptedContentInfo: contentType, contentEncryptionAlgorithm (AES-CBC，参数为 IV), [0] encryptedContent
+func decryptEncryptedContent(info berNode, contentKey []byte) ([]byte, error) {
+	if len(info.children) < 3 || len(info.children[1].children) < 2 {
+		return nil, errors.New("无效的 CMS EncryptedContentInfo")
+	}
+	algorithm := info.children[1]
+	oid, err := algorithm.children[0].oid()
+	if err != nil {
+		return nil, errors.New("无效的 CMS 内容加密算法")
+	}
+	keySize := map[string]int{oidAES128CBC.String(): 16, oidAES192CBC.String(): 24, oidAES256CBC.String(): 32}[oid.String()]
+	if keySize == 0 {
+		return nil, fmt.Errorf("不支持的 CMS 内容加密算法 %s", oid)
+	}
+	if len(contentKey) != keySize {
+		return nil, errors.New("CMS 内容加密密钥的长度与算法不符")
+	}
+	iv := algorithm.children[1].octets()
+	if len(iv) != aes.BlockSize {
+		return nil, errors.New("无效的 CMS 初始向量")
+	}
+	encrypted := info.children[2]
+	if !encrypted.is(asn1.ClassContextSpecific, 0) {
+		return nil, errors.New("CMS 中没有加密内容")
+	}
+	ciphertext := encrypted.octets()
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("CMS 加密内容的长度无效")
+	}
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(plaintext[len(plaintext)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, errors.New("CMS 加密内容的填充无效")
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
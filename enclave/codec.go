@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 请求/响应编码，握手时由客户端按名称选择
+// 新的编码只需实现该接口并加入 codecs，请求处理逻辑无需改动
+type Codec interface {
+	// 握手中使用的名称
+	Name() string
+	DecodeRequest(payload []byte) (CommandArgs, error)
+	EncodeResponse(response Response) ([]byte, error)
+}
+
+// 支持的编码，按名称索引
+var codecs = map[string]Codec{
+	codecJSON:     jsonCodec{},
+	codecCBOR:     cborCodec{},
+	codecProtobuf: protobufCodec{},
+}
+
+// 证明文档在 Response 中为 base64 文本，二进制编码下还原为原始字节
+func rawDocument(document string) []byte {
+	if document == "" {
+		return nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(document))
+	if err != nil {
+		return []byte(document)
+	}
+	return decoded
+}
+
+// JSON 编码，未指定编码时的默认值
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string { return codecJSON }
+
+func (jsonCodec) DecodeRequest(payload []byte) (CommandArgs, error) {
+	var args CommandArgs
+	err := json.Unmarshal(payload, &args)
+	return args, err
+}
+
+func (jsonCodec) EncodeResponse(response Response) ([]byte, error) {
+	return json.Marshal(response)
+}
+
+// CBOR 编码的响应，证明文档以原始字节传输，避免 base64 膨胀
+type cborResponse struct {
+	Success       bool                `cbor:"success"`
+	ErrorCode     string              `cbor:"error_code,omitempty"`
+	ErrorMessage  string              `cbor:"error_message,omitempty"`
+	Document      []byte              `cbor:"document,omitempty"`
+	Token         string              `cbor:"token,omitempty"`
+	Version       string              `cbor:"version,omitempty"`
+	SchemaVersion int                 `cbor:"schema_version,omitempty"`
+	Trace         []TraceSpan         `cbor:"trace,omitempty"`
+	NSM           *NSMDescription     `cbor:"nsm,omitempty"`
+	Random        []byte              `cbor:"random,omitempty"`
+	PCRs          map[uint16]PCRState `cbor:"pcrs,omitempty"`
+	Batch         []cborResponse      `cbor:"batch,omitempty"`
+	EvidenceType  string              `cbor:"evidence_type,omitempty"`
+	File          *FileChunk          `cbor:"file,omitempty"`
+	Time          *TimeStatus         `cbor:"time,omitempty"`
+	Secret        *SecretHandle       `cbor:"secret,omitempty"`
+	Signature     *KMSSignature       `cbor:"signature,omitempty"`
+	Object        *ObjectResult       `cbor:"object,omitempty"`
+	FileKey       []byte              `cbor:"file_key,omitempty"`
+	Session       *SessionInfo        `cbor:"session,omitempty"`
+	Sealed        []byte              `cbor:"sealed,omitempty"`
+}
+
+type cborCodec struct{}
+
+func (cborCodec) Name() string { return codecCBOR }
+
+func (cborCodec) DecodeRequest(payload []byte) (CommandArgs, error) {
+	var args CommandArgs
+	err := cbor.Unmarshal(payload, &args)
+	return args, err
+}
+
+func (cborCodec) EncodeResponse(response Response) ([]byte, error) {
+	return cbor.Marshal(newCBORResponse(response))
+}
+
+func newCBORResponse(response Response) cborResponse {
+	var batch []cborResponse
+	for _, item := range response.Batch {
+		batch = append(batch, newCBORResponse(item))
+	}
+	return cborResponse{
+		Success:       response.Success,
+		ErrorCode:     response.ErrorCode,
+		ErrorMessage:  response.ErrorMessage,
+		Document:      rawDocument(response.Document),
+		Token:         response.Token,
+		Version:       response.Version,
+		SchemaVersion: response.SchemaVersion,
+		Trace:         response.Trace,
+		NSM:           response.NSM,
+		Random:        response.Random,
+		PCRs:          response.PCRs,
+		Batch:         batch,
+		EvidenceType:  response.EvidenceType,
+		File:          response.File,
+		Time:          response.Time,
+		Secret:        response.Secret,
+		Signature:     response.Signature,
+		Object:        response.Object,
+		FileKey:       response.FileKey,
+		Session:       response.Session,
+		Sealed:        response.Sealed,
+	}
+}
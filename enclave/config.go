@@ -0,0 +1,461 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 服务器配置
+type serverConfig struct {
+	// vsock 端口
+	Port uint
+
+	// 监听地址 (vsock://PORT、tcp://HOST:PORT 或 unix:///PATH)，为空时监听 vsock 端口 Port
+	Listen string
+
+	// 单个请求的最大字节数
+	MaxRequestSize int
+
+	// 从建立连接到收到握手 (或旧版请求) 的最长等待时间
+	HandshakeTimeout time.Duration
+
+	// 允许连接的对端，为空时不限制
+	AllowedPeers peerList
+
+	// 共享密钥文件，设置后所有帧协议请求必须携带 HMAC
+	HMACKeyFile string
+
+	// 要求所有帧协议连接建立 Noise 加密通道
+	RequireNoise bool
+
+	// 允许的 Noise 客户端静态公钥文件，设置后要求 XX 双向认证
+	NoiseClientKeysFile string
+
+	// RA-TLS 监听端口，0 表示不启用
+	RATLSPort uint
+
+	// RA-TLS 证书 (及其证明文档) 的刷新间隔
+	RATLSRefresh time.Duration
+
+	// token 方法签发的 JWT 的 iss
+	TokenIssuer string
+
+	// token 方法允许的最长 JWT 有效期
+	TokenMaxTTL time.Duration
+
+	// 哈希链审计日志路径，"-" 表示标准输出，为空时不记录
+	AuditLog string
+
+	// 证明文档缓存有效期，0 表示不缓存
+	CacheTTL time.Duration
+
+	// 允许通过 extend-pcr 方法扩展用户 PCR
+	AllowExtendPCR bool
+
+	// 允许通过 lock-pcr、lock-pcrs 方法锁定 PCR
+	AllowLockPCR bool
+
+	// 启动时测量的文件，按顺序将 SHA-384 摘要扩展到 MeasurePCR
+	MeasureFiles fileList
+	MeasurePCR   uint
+
+	// 测量后锁定 MeasurePCR
+	MeasureLock bool
+
+	// 在每份证明文档的 user_data 中附带构建信息及应用标识
+	BuildInfo bool
+	AppID     string
+
+	// 允许主机通过 set-time 方法同步 Enclave 的时钟；指定时间机构公钥时要求其签名的时间证明
+	AllowSetTime     bool
+	TimeAuthorityKey string
+
+	// 文件传输 (file-push、file-pull) 的根目录，为空时不启用；单个文件的最大字节数
+	FileRoot    string
+	MaxFileSize int64
+
+	// 主机 dns-proxy 的地址 (如 vsock://3:8053)，为空时使用系统解析器；
+	// DNSListen 非空时同时在该 UDP 地址上提供存根解析器
+	DNSForward string
+	DNSListen  string
+
+	// 主机 imds-proxy 的地址 (如 vsock://3:8002)，为空时不启用；Enclave 内 IMDS 端点的回环监听地址
+	IMDSForward string
+	IMDSListen  string
+
+	// 出站规则: 访问 AWS 服务等外部地址时经主机 tcp-proxy 转发
+	Egress egressList
+
+	// 允许主机通过 get-secret、get-parameter 方法让 Enclave 读取并解密 Secrets Manager 中的密钥及 SSM 参数
+	AllowGetSecret bool
+
+	// 允许主机通过 kms-sign 方法让 Enclave 签名的 KMS 非对称密钥，为空时不启用 kms-sign
+	KMSSignKeys kmsList
+
+	// 允许主机通过 decrypt-object 方法让 Enclave 解密 S3 加密客户端加密的对象；对象的最大字节数及处理明文的可执行文件
+	AllowDecryptObject bool
+	MaxObjectSize      int64
+	ObjectProcessor    string
+
+	// 允许主机通过 decrypt 方法发送以 decrypt-key 证明的公钥加密的消息；解密密钥的类型
+	AllowDecrypt   bool
+	DecryptKeyType string
+
+	// 允许 age 插件通过 age-unwrap 方法解出以 decrypt-key 证明的公钥包装的 age 文件密钥
+	AllowAgeUnwrap bool
+
+	// 要求除 attest、health 和会话方法之外的请求都经 session-open 建立的加密会话发送；会话的空闲超时及最大数量
+	RequireSession     bool
+	SessionIdleTimeout time.Duration
+	MaxSessions        int
+
+	// 会话密钥的使用上限 (请求数、加密字节数、时间)，达到任一上限后须以 session-rekey 更换密钥；会话的最长有效期
+	SessionRekeyRequests int64
+	SessionRekeyBytes    int64
+	SessionRekeyInterval time.Duration
+	SessionMaxLifetime   time.Duration
+
+	// KMS 的故障切换区域，按顺序尝试
+	KMSRegions kmsList
+
+	// OIDC Broker 的地址及请求的 audience；以 Broker 签发的 ID Token 扮演的 IAM 角色、会话名称及凭证有效期
+	OIDCBroker         string
+	OIDCAudience       string
+	AssumeRole         string
+	AssumeRoleSession  string
+	AssumeRoleDuration time.Duration
+
+	// 以 --acm-cert 的证书经 IAM Roles Anywhere 取得凭证: 信任锚、配置文件及角色的 ARN，凭证有效期
+	RolesAnywhereTrustAnchor string
+	RolesAnywhereProfile     string
+	RolesAnywhereRole        string
+	RolesAnywhereDuration    time.Duration
+
+	// 受监管的应用命令 (-- 之后的参数)，为空时不启动应用；启动前读取的 SSM 参数及其环境变量名
+	App    []string
+	SSMEnv ssmEnvList
+
+	// ACM for Nitro Enclaves 证书包的 S3 地址 (s3://BUCKET/KEY)，为空时不启用；解密私钥所用的 KMS 密钥 (可选)、
+	// 证书和私钥的写入目录及重新读取的间隔 (0 表示不刷新)
+	ACMCert    string
+	ACMKeyID   string
+	ACMDir     string
+	ACMRefresh time.Duration
+
+	// 日志转发地址 (如 vsock://3:9000)，为空时只写入控制台
+	LogForward string
+
+	// 额外监听的 Unix 套接字路径及其文件权限，为空时不启用
+	UnixSocket     string
+	UnixSocketMode fileMode
+
+	// Enclave 内本地 HTTP 接口的回环监听地址，为空时不启用
+	HTTPListen string
+
+	// pprof 监听地址 (vsock://PORT、tcp://HOST:PORT 或 unix:///PATH)，为空时不启用
+	PprofListen string
+
+	// 证明后端 (证据类型)，见 attesters
+	Attester string
+
+	// 使用模拟 NSM 代替 /dev/nsm，仅用于没有 Nitro 硬件的开发环境
+	MockNSM bool
+
+	// 模拟 NSM 的开发 CA 证书和私钥，不存在时自动生成
+	MockCACert string
+	MockCAKey  string
+}
+
+// 允许的对端: CID 和可选的端口
+type allowedPeer struct {
+	CID  uint32
+	Port uint32
+	// 为 false 时允许该 CID 的任意端口
+	HasPort bool
+}
+
+// 可重复指定的 --allow 参数，格式为 CID 或 CID:PORT
+type peerList []allowedPeer
+
+func (l *peerList) String() string {
+	var parts []string
+	for _, p := range *l {
+		if p.HasPort {
+			parts = append(parts, fmt.Sprintf("%d:%d", p.CID, p.Port))
+		} else {
+			parts = append(parts, fmt.Sprintf("%d", p.CID))
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *peerList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		cidStr, portStr, hasPort := strings.Cut(item, ":")
+		cid, err := strconv.ParseUint(cidStr, 10, 32)
+		if err != nil {
+			return fmt.Errorf("无效的 CID %q: %v", cidStr, err)
+		}
+
+		peer := allowedPeer{CID: uint32(cid), HasPort: hasPort}
+		if hasPort {
+			port, err := strconv.ParseUint(portStr, 10, 32)
+			if err != nil {
+				return fmt.Errorf("无效的端口 %q: %v", portStr, err)
+			}
+			peer.Port = uint32(port)
+		}
+		*l = append(*l, peer)
+	}
+	return nil
+}
+
+// 检查对端是否在允许列表中
+func (l peerList) allows(cid uint32, port uint32) bool {
+	if len(l) == 0 {
+		return true
+	}
+	for _, p := range l {
+		if p.CID == cid && (!p.HasPort || p.Port == port) {
+			return true
+		}
+	}
+	return false
+}
+
+// 当前生效的服务器配置
+var config = serverConfig{
+	Port:                  vsockPort,
+	MaxRequestSize:        64 << 10,
+	HandshakeTimeout:      10 * time.Second,
+	RATLSRefresh:          time.Hour,
+	TokenIssuer:           "aws-enclave-attestation",
+	TokenMaxTTL:           time.Hour,
+	MeasurePCR:            firstUserPCR,
+	MeasureLock:           true,
+	MaxFileSize:           64 << 20,
+	MaxObjectSize:         64 << 20,
+	DecryptKeyType:        decryptKeyX25519,
+	SessionIdleTimeout:    30 * time.Minute,
+	MaxSessions:           64,
+	SessionRekeyRequests:  100000,
+	SessionRekeyBytes:     1 << 30,
+	SessionRekeyInterval:  15 * time.Minute,
+	SessionMaxLifetime:    24 * time.Hour,
+	IMDSListen:            "127.0.0.1:1338",
+	ACMDir:                "/run/acm",
+	ACMRefresh:            time.Hour,
+	OIDCAudience:          "sts.amazonaws.com",
+	AssumeRoleSession:     "aws-enclave-attestation",
+	AssumeRoleDuration:    time.Hour,
+	RolesAnywhereDuration: time.Hour,
+	UnixSocketMode:        0660,
+	Attester:              evidenceNitro,
+	MockCACert:            "mock-ca.pem",
+	MockCAKey:             "mock-ca-key.pem",
+}
+
+// 解析服务器模式的命令行参数
+func parseServerFlags(args []string) error {
+	fs := flag.NewFlagSet("server", flag.ContinueOnError)
+	fs.UintVar(&config.Port, "port", config.Port, "vsock 监听端口")
+	fs.StringVar(&config.Listen, "listen", config.Listen, "监听地址 (tcp://HOST:PORT 或 unix:///PATH，用于没有 vsock 的本地测试)，为空时监听 vsock --port")
+	fs.IntVar(&config.MaxRequestSize, "max-request-size", config.MaxRequestSize, "单个请求的最大字节数")
+	fs.DurationVar(&config.HandshakeTimeout, "handshake-timeout", config.HandshakeTimeout, "等待握手或请求的超时时间")
+	fs.Var(&config.AllowedPeers, "allow", "允许连接的对端 CID 或 CID:PORT，可重复或以逗号分隔")
+	fs.StringVar(&config.HMACKeyFile, "hmac-key-file", config.HMACKeyFile, "HMAC 共享密钥文件，设置后要求所有请求携带 HMAC")
+	fs.BoolVar(&config.RequireNoise, "require-noise", config.RequireNoise, "要求帧协议连接使用 Noise 加密通道")
+	fs.StringVar(&config.NoiseClientKeysFile, "noise-client-keys", config.NoiseClientKeysFile, "允许的 Noise 客户端静态公钥文件 (每行一个十六进制公钥)")
+	fs.UintVar(&config.RATLSPort, "ratls-port", config.RATLSPort, "RA-TLS 监听端口，0 表示不启用")
+	fs.DurationVar(&config.RATLSRefresh, "ratls-refresh", config.RATLSRefresh, "RA-TLS 证书及证明文档的刷新间隔")
+	fs.StringVar(&config.TokenIssuer, "token-issuer", config.TokenIssuer, "Enclave 签发的 JWT 的 issuer")
+	fs.DurationVar(&config.TokenMaxTTL, "token-max-ttl", config.TokenMaxTTL, "Enclave 签发的 JWT 的最长有效期")
+	fs.StringVar(&config.AuditLog, "audit-log", config.AuditLog, "记录每个请求的哈希链审计日志 (JSONL)，- 表示标准输出")
+	fs.DurationVar(&config.CacheTTL, "cache-ttl", config.CacheTTL, "输入相同的 attest 请求在该时间内复用缓存的证明文档，0 表示不缓存")
+	fs.BoolVar(&config.AllowExtendPCR, "allow-extend-pcr", config.AllowExtendPCR, "允许客户端通过 extend-pcr 方法扩展 PCR16 及以上的用户 PCR")
+	fs.BoolVar(&config.AllowLockPCR, "allow-lock-pcr", config.AllowLockPCR, "允许客户端通过 lock-pcr、lock-pcrs 方法锁定 PCR")
+	fs.Var(&config.MeasureFiles, "measure", "启动时按顺序测量的文件 (应用二进制、配置、模型等)，可重复或以逗号分隔")
+	fs.UintVar(&config.MeasurePCR, "measure-pcr", config.MeasurePCR, "扩展测量值的用户 PCR (16 及以上)")
+	fs.BoolVar(&config.MeasureLock, "measure-lock", config.MeasureLock, "测量后锁定 --measure-pcr，使测量值出现在每份证明文档中")
+	fs.BoolVar(&config.BuildInfo, "build-info", config.BuildInfo, "在每份证明文档的 user_data 中附带构建信息 (Go 版本、模块版本、VCS 修订)，调用方的 user_data 放在其 user_data 键下")
+	fs.StringVar(&config.AppID, "app-id", config.AppID, "--build-info 时附带的应用标识")
+	fs.BoolVar(&config.AllowSetTime, "allow-set-time", config.AllowSetTime, "允许主机通过 set-time 方法同步 Enclave 的时钟 (用于审计记录、JWT 和 RA-TLS 证书的时间)")
+	fs.StringVar(&config.TimeAuthorityKey, "time-authority-key", config.TimeAuthorityKey, "时间机构的 ECDSA 公钥 (PEM)，指定时 set-time 必须携带其签名的时间证明")
+	fs.StringVar(&config.FileRoot, "file-root", config.FileRoot, "允许 file-push、file-pull 方法读写该目录下的文件，为空时不启用文件传输")
+	fs.Int64Var(&config.MaxFileSize, "max-file-size", config.MaxFileSize, "file-push、file-pull 单个文件的最大字节数")
+	fs.StringVar(&config.DNSForward, "dns-forward", config.DNSForward, "经主机的 dns-proxy 解析域名 (如 vsock://3:8053)，为空时使用系统解析器")
+	fs.StringVar(&config.DNSListen, "dns-listen", config.DNSListen, "--dns-forward 时同时在该 UDP 地址上提供存根解析器 (如 127.0.0.1:53)，供 Enclave 内的其他进程使用")
+	fs.StringVar(&config.IMDSForward, "imds-forward", config.IMDSForward, "经主机的 imds-proxy 提供 IMDS (凭证、区域、实例身份文档，如 vsock://3:8002)，为空时不启用")
+	fs.StringVar(&config.IMDSListen, "imds-listen", config.IMDSListen, "--imds-forward 时 Enclave 内 IMDS 端点的回环监听地址 (AWS_EC2_METADATA_SERVICE_ENDPOINT)")
+	fs.Var(&config.Egress, "egress", "出站规则 HOST:PORT=vsock://CID:PORT，访问 HOST:PORT 时经主机 tcp-proxy 的转发地址连接，可重复或以逗号分隔")
+	fs.BoolVar(&config.AllowGetSecret, "allow-get-secret", config.AllowGetSecret, "允许主机通过 get-secret、get-parameter 方法让 Enclave 经 --egress 读取 Secrets Manager 中的 KMS 密文或 SSM SecureString 参数并以证明文档解密")
+	fs.Var(&config.KMSSignKeys, "kms-sign-key", "允许主机通过 kms-sign 方法让 Enclave 经 --egress 以该 KMS 非对称密钥签名 (ID、ARN 或别名，须与请求的 key_id 一致)，响应附带绑定签名的证明文档；可重复或以逗号分隔，为空时不启用 kms-sign")
+	fs.BoolVar(&config.AllowDecryptObject, "allow-decrypt-object", config.AllowDecryptObject, "允许主机通过 decrypt-object 方法让 Enclave 解密 S3 加密客户端 v2 (KMS 包装的数据密钥、AES-GCM) 加密的对象：由 Enclave 经 --egress 读取或由主机分块上传密文，明文不离开 Enclave，只返回大小、SHA-256、行数及 --object-processor 的输出")
+	fs.Int64Var(&config.MaxObjectSize, "max-object-size", config.MaxObjectSize, "decrypt-object 单个对象的最大字节数")
+	fs.StringVar(&config.ObjectProcessor, "object-processor", config.ObjectProcessor, "decrypt-object 时以明文为标准输入运行的可执行文件，其标准输出 (最多 64KiB) 作为结果返回给主机，为空时只返回大小、SHA-256 和行数")
+	fs.BoolVar(&config.AllowDecrypt, "allow-decrypt", config.AllowDecrypt, "允许主机通过 decrypt-key 方法获取 Enclave 解密公钥的证明文档，并通过 decrypt 方法发送以该公钥加密的消息 (encrypt 子命令)，解密后按句柄保存在 Enclave 内")
+	fs.StringVar(&config.DecryptKeyType, "decrypt-key-type", config.DecryptKeyType, "decrypt 方法的解密密钥类型: x25519 (HPKE) 或 rsa (RSA-OAEP)")
+	fs.BoolVar(&config.AllowAgeUnwrap, "allow-age-unwrap", config.AllowAgeUnwrap, "允许主机上的 age 插件 (age-plugin-enclave) 通过 age-unwrap 方法解出以 decrypt-key 证明的公钥包装的 age 文件密钥 (需要 --decrypt-key-type x25519)，文件密钥返回给主机")
+	fs.BoolVar(&config.RequireSession, "require-session", config.RequireSession, "要求除 attest、attest-batch、health 之外的请求都经 session-open 建立的加密会话发送")
+	fs.DurationVar(&config.SessionIdleTimeout, "session-idle-timeout", config.SessionIdleTimeout, "加密会话的空闲超时，超时后需重新 session-open")
+	fs.IntVar(&config.MaxSessions, "max-sessions", config.MaxSessions, "同时保留的加密会话数，超过时淘汰最久未使用的会话")
+	fs.Int64Var(&config.SessionRekeyRequests, "session-rekey-requests", config.SessionRekeyRequests, "会话密钥最多加密的请求数，达到后须以 session-rekey 更换密钥")
+	fs.Int64Var(&config.SessionRekeyBytes, "session-rekey-bytes", config.SessionRekeyBytes, "会话密钥最多加密的字节数 (请求和响应)，达到后须以 session-rekey 更换密钥")
+	fs.DurationVar(&config.SessionRekeyInterval, "session-rekey-interval", config.SessionRekeyInterval, "会话密钥的最长使用时间，到期后须以 session-rekey 更换密钥")
+	fs.DurationVar(&config.SessionMaxLifetime, "session-max-lifetime", config.SessionMaxLifetime, "加密会话的最长有效期，到期后须重新 session-open (重新证明)")
+	fs.Var(&config.KMSRegions, "kms-region", "KMS 的故障切换区域，默认区域 (或请求中的 region) 出现连接失败、超时、5xx 或限流时依次尝试；解密需使用多区域密钥 (mrk-)，密钥 ARN 中的区域会替换为所尝试的区域。可重复或以逗号分隔，每个区域的 KMS 端点都需要 --egress 规则")
+	fs.StringVar(&config.OIDCBroker, "oidc-broker", config.OIDCBroker, "主机 oidc-broker 的地址 (如 https://broker.example.com:8443)，--assume-role 时经 --egress 以证明文档换取 ID Token")
+	fs.StringVar(&config.OIDCAudience, "oidc-audience", config.OIDCAudience, "向 OIDC Broker 请求的 ID Token audience，须与 IAM OIDC 身份提供商的客户端 ID 一致")
+	fs.StringVar(&config.AssumeRole, "assume-role", config.AssumeRole, "以 --oidc-broker 签发的 ID Token 经 sts:AssumeRoleWithWebIdentity 扮演该 IAM 角色，Enclave 访问 AWS 时使用该角色的凭证而不是父实例的角色")
+	fs.StringVar(&config.AssumeRoleSession, "assume-role-session", config.AssumeRoleSession, "--assume-role 的会话名称 (出现在 CloudTrail 中)")
+	fs.DurationVar(&config.AssumeRoleDuration, "assume-role-duration", config.AssumeRoleDuration, "--assume-role 凭证的有效期，过期前以新的证明文档续期")
+	fs.StringVar(&config.RolesAnywhereTrustAnchor, "roles-anywhere-trust-anchor", config.RolesAnywhereTrustAnchor, "IAM Roles Anywhere 信任锚的 ARN (签发 --acm-cert 证书的 ACM 私有 CA)")
+	fs.StringVar(&config.RolesAnywhereProfile, "roles-anywhere-profile", config.RolesAnywhereProfile, "IAM Roles Anywhere 配置文件的 ARN")
+	fs.StringVar(&config.RolesAnywhereRole, "roles-anywhere-role", config.RolesAnywhereRole, "以 --acm-cert 的证书经 IAM Roles Anywhere 扮演该 IAM 角色，Enclave 访问 AWS 时使用该角色的凭证而不是父实例的角色")
+	fs.DurationVar(&config.RolesAnywhereDuration, "roles-anywhere-duration", config.RolesAnywhereDuration, "--roles-anywhere-role 凭证的有效期 (15m 到 12h)，过期前自动续期")
+	fs.Var(&config.SSMEnv, "ssm-env", "启动应用前读取 SSM SecureString 参数，在 Enclave 内以证明文档解密后作为环境变量传给应用，格式为 ENV=/参数/名称，可重复或以逗号分隔")
+	fs.StringVar(&config.ACMCert, "acm-cert", config.ACMCert, "启动时经 --egress 读取 ACM for Nitro Enclaves 的证书包 (s3://BUCKET/KEY)，以证明文档解密私钥后写入 --acm-dir，并在每份证明文档的 user_data 中附带证书的 SHA-256 指纹")
+	fs.StringVar(&config.ACMKeyID, "acm-key-id", config.ACMKeyID, "解密 ACM 私钥时要求使用的 KMS 密钥 (证书包的 EncryptionKmsKeyId)，为空时不限制")
+	fs.StringVar(&config.ACMDir, "acm-dir", config.ACMDir, "ACM 证书 (cert.pem，含证书链) 和私钥 (key.pem) 的写入目录，供 Enclave 内的 TLS 终结进程使用")
+	fs.DurationVar(&config.ACMRefresh, "acm-refresh", config.ACMRefresh, "重新读取 ACM 证书包的间隔 (ACM 续期证书后更新)，0 表示不刷新")
+	fs.StringVar(&config.LogForward, "log-forward", config.LogForward, "将日志逐条以 JSON 转发到主机的 log-receiver (如 vsock://3:9000)，为空时只写入控制台")
+	fs.StringVar(&config.UnixSocket, "unix-socket", config.UnixSocket, "同时在该 Unix 套接字上提供帧协议，供同一 Enclave 中的边车进程使用，为空时不启用")
+	fs.Var(&config.UnixSocketMode, "unix-socket-mode", "--unix-socket 套接字文件的权限 (八进制)")
+	fs.StringVar(&config.HTTPListen, "http-listen", config.HTTPListen, "在 Enclave 内的回环地址 (如 127.0.0.1:8080) 上提供 GET /attestation，供同一 Enclave 中的进程获取证明文档，为空时不启用")
+	fs.StringVar(&config.PprofListen, "pprof-listen", config.PprofListen, "pprof 调试接口的监听地址 (如 vsock://6060)，为空时不启用")
+	fs.StringVar(&config.Attester, "attester", config.Attester, "证明后端 (目前支持 aws-nitro)")
+	fs.BoolVar(&config.MockNSM, "mock-nsm", config.MockNSM, "使用由开发 CA 签名的模拟证明文档 (仅用于开发测试)")
+	fs.StringVar(&config.MockCACert, "mock-ca-cert", config.MockCACert, "模拟 NSM 的开发 CA 证书，不存在时自动生成")
+	fs.StringVar(&config.MockCAKey, "mock-ca-key", config.MockCAKey, "模拟 NSM 的开发 CA 私钥，不存在时自动生成")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	config.App = fs.Args()
+
+	if _, err := currentAttester(); err != nil {
+		return err
+	}
+
+	if config.AppID != "" && !config.BuildInfo {
+		return fmt.Errorf("--app-id 需要同时指定 --build-info")
+	}
+	if config.BuildInfo {
+		if err := loadBuildInfo(config.AppID); err != nil {
+			return err
+		}
+	}
+
+	if config.TimeAuthorityKey != "" {
+		if err := loadTimeAuthorityKey(config.TimeAuthorityKey); err != nil {
+			return err
+		}
+	}
+
+	if config.IMDSForward != "" {
+		if err := checkLoopbackListen("--imds-listen", config.IMDSListen); err != nil {
+			return err
+		}
+		if _, err := parseDialAddress(config.IMDSForward); err != nil {
+			return err
+		}
+	}
+
+	if len(config.SSMEnv) > 0 && len(config.App) == 0 {
+		return fmt.Errorf("--ssm-env 需要在 -- 之后指定应用命令")
+	}
+
+	if config.ACMCert != "" {
+		if _, _, err := parseS3URL(config.ACMCert); err != nil {
+			return fmt.Errorf("--acm-cert: %v", err)
+		}
+		if config.ACMDir == "" {
+			return fmt.Errorf("--acm-cert 需要指定 --acm-dir")
+		}
+		if config.ACMRefresh < 0 {
+			return fmt.Errorf("--acm-refresh 不能为负数")
+		}
+	}
+
+	if config.AssumeRole != "" && config.OIDCBroker == "" {
+		return fmt.Errorf("--assume-role 需要同时指定 --oidc-broker")
+	}
+	if config.RolesAnywhereRole != "" {
+		if config.AssumeRole != "" {
+			return fmt.Errorf("--roles-anywhere-role 和 --assume-role 不能同时指定")
+		}
+		if config.RolesAnywhereTrustAnchor == "" || config.RolesAnywhereProfile == "" || config.ACMCert == "" {
+			return fmt.Errorf("--roles-anywhere-role 需要同时指定 --roles-anywhere-trust-anchor、--roles-anywhere-profile 和 --acm-cert")
+		}
+		if config.RolesAnywhereDuration < 15*time.Minute || config.RolesAnywhereDuration > 12*time.Hour {
+			return fmt.Errorf("--roles-anywhere-duration 必须在 15m 到 12h 之间")
+		}
+	}
+
+	if config.AllowDecryptObject && config.MaxObjectSize <= 0 {
+		return fmt.Errorf("--max-object-size 必须大于 0")
+	}
+
+	if config.DecryptKeyType != decryptKeyX25519 && config.DecryptKeyType != decryptKeyRSA {
+		return fmt.Errorf("无效的 --decrypt-key-type %q (可选 x25519、rsa)", config.DecryptKeyType)
+	}
+
+	if config.MaxRequestSize <= 0 || config.HandshakeTimeout <= 0 {
+		return fmt.Errorf("--max-request-size 和 --handshake-timeout 必须大于 0")
+	}
+	if config.SessionIdleTimeout <= 0 || config.MaxSessions <= 0 {
+		return fmt.Errorf("--session-idle-timeout 和 --max-sessions 必须大于 0")
+	}
+	if config.SessionRekeyRequests <= 0 || config.SessionRekeyBytes <= 0 || config.SessionRekeyInterval <= 0 || config.SessionMaxLifetime <= 0 {
+		return fmt.Errorf("--session-rekey-requests、--session-rekey-bytes、--session-rekey-interval 和 --session-max-lifetime 必须大于 0")
+	}
+
+	if config.DNSListen != "" && config.DNSForward == "" {
+		return fmt.Errorf("--dns-listen 需要同时指定 --dns-forward")
+	}
+
+	if config.FileRoot != "" {
+		if info, err := os.Stat(config.FileRoot); err != nil || !info.IsDir() {
+			return fmt.Errorf("--file-root 必须是已存在的目录: %s", config.FileRoot)
+		}
+		if config.MaxFileSize <= 0 {
+			return fmt.Errorf("--max-file-size 必须大于 0")
+		}
+	}
+
+	if config.HTTPListen != "" {
+		if err := checkLoopbackListen("--http-listen", config.HTTPListen); err != nil {
+			return err
+		}
+	}
+
+	if config.NoiseClientKeysFile != "" {
+		if err := loadNoiseClientKeys(config.NoiseClientKeysFile); err != nil {
+			return err
+		}
+		config.RequireNoise = true
+	}
+
+	if config.AuditLog != "" {
+		l, err := openAuditLog(config.AuditLog)
+		if err != nil {
+			return err
+		}
+		audit = l
+	}
+
+	if config.HMACKeyFile != "" {
+		if err := loadHMACKey(config.HMACKeyFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
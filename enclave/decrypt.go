@@ -0,0 +1,242 @@
+package main
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// 发往 Enclave 的加密消息 (encrypt 子命令的输出) - 与 client 端匹配
+type SealedMessage struct {
+	// 加密算法: sealHPKE 或 sealRSAOAEP
+	Algorithm string `json:"alg"`
+	// 接收方公钥 (SubjectPublicKeyInfo) 的 SHA-256 (十六进制)
+	KeyID string `json:"kid"`
+	// HPKE 的封装密钥，或以 RSA-OAEP 加密的内容密钥
+	Encapsulated []byte `json:"enc"`
+	// RSA-OAEP 时 AES-GCM 的 nonce
+	Nonce      []byte `json:"iv,omitempty"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// 加密算法 - 与 client 端匹配
+const (
+	// RFC 9180 HPKE base 模式: DHKEM(X25519, HKDF-SHA256)、HKDF-SHA256、AES-256-GCM
+	sealHPKE = "HPKE-X25519-SHA256-A256GCM"
+	// RSA-OAEP-SHA256 加密随机的 AES-256-GCM 内容密钥
+	sealRSAOAEP = "RSA-OAEP-256+A256GCM"
+)
+
+// HPKE 的 info、RSA-OAEP 的 label；加密消息的 kid 作为 AEAD 的附加数据 - 与 client 端匹配
+const sealInfo = "aws-enclave-attestation sealed message v1"
+
+// --decrypt-key-type 可选的密钥类型
+const (
+	decryptKeyX25519 = "x25519"
+	decryptKeyRSA    = "rsa"
+)
+
+// decrypt-key 方法证明的解密密钥，首次使用时生成，只保存在内存中 (Enclave 重启后需重新获取证明文档并加密)
+type decryptKey struct {
+	private crypto.PrivateKey
+	spki    []byte
+	kid     string
+}
+
+var (
+	decryptKeyOnce sync.Once
+	decryptKeyPair *decryptKey
+	decryptKeyErr  error
+)
+
+func getDecryptKey() (*decryptKey, error) {
+	decryptKeyOnce.Do(func() {
+		var private crypto.PrivateKey
+		var public interface{}
+		switch config.DecryptKeyType {
+		case decryptKeyRSA:
+			key, err := rsa.GenerateKey(rand.Reader, 3072)
+			if err != nil {
+				decryptKeyErr = err
+				return
+			}
+			private, public = key, &key.PublicKey
+		default:
+			key, err := ecdh.X25519().GenerateKey(rand.Reader)
+			if err != nil {
+				decryptKeyErr = err
+				return
+			}
+			private, public = key, key.PublicKey()
+		}
+		spki, err := x509.MarshalPKIXPublicKey(public)
+		if err != nil {
+			decryptKeyErr = err
+			return
+		}
+		sum := sha256.Sum256(spki)
+		decryptKeyPair = &decryptKey{private: private, spki: spki, kid: hex.EncodeToString(sum[:])}
+		log.Printf("已生成 %s 解密密钥 %s\n", config.DecryptKeyType, decryptKeyPair.kid)
+	})
+	return decryptKeyPair, decryptKeyErr
+}
+
+// 为解密公钥生成证明文档，可携带调用方随机数以证明新鲜度；验证方校验后以该公钥加密 (encrypt 子命令)
+func attestDecryptKey(args CommandArgs) Response {
+	if !config.AllowDecrypt && !config.AllowAgeUnwrap {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 decrypt 或 age-unwrap 方法 (--allow-decrypt、--allow-age-unwrap)"}
+	}
+	key, err := getDecryptKey()
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	return processRequest(CommandArgs{
+		PublicKey: base64.StdEncoding.EncodeToString(key.spki),
+		Nonce:     args.Nonce,
+	})
+}
+
+// decrypt 请求: data_b64 为以 decrypt-key 证明的公钥加密的消息 (SealedMessage 的 JSON)，
+// 在 Enclave 内解密后与 get-secret 相同按 handle 保存，响应只返回句柄；需以 --allow-decrypt 启动
+func decryptRequest(args CommandArgs) Response {
+	if !config.AllowDecrypt {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 decrypt 方法 (--allow-decrypt)"}
+	}
+	if args.Handle == "" {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "必须指定 handle"}
+	}
+	data, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 data_b64 失败: %v", err)}
+	}
+	var message SealedMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解析加密消息失败: %v", err)}
+	}
+	key, err := getDecryptKey()
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	if message.KeyID != key.kid {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("消息的接收方密钥 %s 不是当前的解密密钥 %s (Enclave 重启后需重新获取 decrypt-key 的证明文档并加密)", message.KeyID, key.kid)}
+	}
+	plaintext, err := openSealedMessage(key, &message, sealInfo)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: err.Error()}
+	}
+
+	secretsMu.Lock()
+	secrets[args.Handle] = plaintext
+	secretsMu.Unlock()
+	log.Printf("已解密加密消息 (句柄 %s，%d 字节)\n", args.Handle, len(plaintext))
+	return Response{Success: true, Secret: &SecretHandle{Handle: args.Handle, KeyID: key.kid, Size: len(plaintext)}}
+}
+
+// 以解密密钥打开加密消息，info 为 HPKE 的 info 或 RSA-OAEP 的 label，区分消息的用途
+func openSealedMessage(key *decryptKey, message *SealedMessage, info string) ([]byte, error) {
+	var contentKey, nonce []byte
+	switch private := key.private.(type) {
+	case *ecdh.PrivateKey:
+		if message.Algorithm != sealHPKE {
+			return nil, fmt.Errorf("解密密钥为 X25519，不支持算法 %q", message.Algorithm)
+		}
+		sharedSecret, err := hpkeDecap(private, message.Encapsulated)
+		if err != nil {
+			return nil, err
+		}
+		contentKey, nonce = hpkeKeySchedule(sharedSecret, []byte(info))
+	case *rsa.PrivateKey:
+		if message.Algorithm != sealRSAOAEP {
+			return nil, fmt.Errorf("解密密钥为 RSA，不支持算法 %q", message.Algorithm)
+		}
+		var err error
+		contentKey, err = rsa.DecryptOAEP(sha256.New(), rand.Reader, private, message.Encapsulated, []byte(info))
+		if err != nil || len(contentKey) != 32 {
+			return nil, errors.New("解密内容密钥失败")
+		}
+		nonce = message.Nonce
+	}
+	defer clear(contentKey)
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, errors.New("无效的 nonce")
+	}
+	plaintext, err := gcm.Open(nil, nonce, message.Ciphertext, []byte(message.KeyID))
+	if err != nil {
+		return nil, errors.New("解密失败 (消息被篡改或不是以该密钥加密)")
+	}
+	return plaintext, nil
+}
+
+// RFC 9180 的算法标识
+const (
+	hpkeKEMX25519  = 0x0020
+	hpkeKDFSHA256  = 0x0001
+	hpkeAEADAES256 = 0x0002
+)
+
+// DHKEM(X25519, HKDF-SHA256) 的 Decap: 由封装密钥 (发送方的临时公钥) 与接收方私钥计算共享密钥
+func hpkeDecap(private *ecdh.PrivateKey, enc []byte) ([]byte, error) {
+	ephemeral, err := ecdh.X25519().NewPublicKey(enc)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 HPKE 封装密钥: %v", err)
+	}
+	dh, err := private.ECDH(ephemeral)
+	if err != nil {
+		return nil, err
+	}
+	kemContext := append(append([]byte{}, enc...), private.PublicKey().Bytes()...)
+	suiteID := binary.BigEndian.AppendUint16([]byte("KEM"), hpkeKEMX25519)
+	prk := hpkeLabeledExtract(suiteID, nil, "eae_prk", dh)
+	return hpkeLabeledExpand(suiteID, prk, "shared_secret", kemContext, 32), nil
+}
+
+// base 模式 (无 PSK) 的密钥调度，返回 AEAD 密钥及第一条消息的 nonce
+func hpkeKeySchedule(sharedSecret, info []byte) (key, nonce []byte) {
+	suiteID := []byte("HPKE")
+	for _, id := range []uint16{hpkeKEMX25519, hpkeKDFSHA256, hpkeAEADAES256} {
+		suiteID = binary.BigEndian.AppendUint16(suiteID, id)
+	}
+	context := []byte{0x00}
+	context = append(context, hpkeLabeledExtract(suiteID, nil, "psk_id_hash", nil)...)
+	context = append(context, hpkeLabeledExtract(suiteID, nil, "info_hash", info)...)
+	secret := hpkeLabeledExtract(suiteID, sharedSecret, "secret", nil)
+	return hpkeLabeledExpand(suiteID, secret, "key", context, 32), hpkeLabeledExpand(suiteID, secret, "base_nonce", context, 12)
+}
+
+func hpkeLabeledExtract(suiteID, salt []byte, label string, ikm []byte) []byte {
+	labeled := append(append([]byte("HPKE-v1"), suiteID...), label...)
+	return hkdf.Extract(sha256.New, append(labeled, ikm...), salt)
+}
+
+func hpkeLabeledExpand(suiteID, prk []byte, label string, info []byte, length int) []byte {
+	labeled := binary.BigEndian.AppendUint16(nil, uint16(length))
+	labeled = append(append(append(labeled, "HPKE-v1"...), suiteID...), label...)
+	out := make([]byte, length)
+	io.ReadFull(hkdf.Expand(sha256.New, prk, append(labeled, info...)), out)
+	return out
+}
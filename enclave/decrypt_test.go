@@ -0,0 +1,115 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 以 client.Seal 相同的方式加密，用于测试 Enclave 端的解密；info 为 HPKE 的 info 或 RSA-OAEP 的 label
+func sealForTest(t *testing.T, spki, plaintext []byte, info string) SealedMessage {
+	t.Helper()
+	public, err := x509.ParsePKIXPublicKey(spki)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(spki)
+	message := SealedMessage{KeyID: hex.EncodeToString(sum[:])}
+	var contentKey, nonce []byte
+	switch public := public.(type) {
+	case *ecdh.PublicKey:
+		ephemeral, _ := ecdh.X25519().GenerateKey(rand.Reader)
+		dh, _ := ephemeral.ECDH(public)
+		enc := ephemeral.PublicKey().Bytes()
+		suiteID := binary.BigEndian.AppendUint16([]byte("KEM"), hpkeKEMX25519)
+		prk := hpkeLabeledExtract(suiteID, nil, "eae_prk", dh)
+		sharedSecret := hpkeLabeledExpand(suiteID, prk, "shared_secret", append(append([]byte{}, enc...), public.Bytes()...), 32)
+		contentKey, nonce = hpkeKeySchedule(sharedSecret, []byte(info))
+		message.Algorithm, message.Encapsulated = sealHPKE, enc
+	case *rsa.PublicKey:
+		contentKey, nonce = make([]byte, 32), make([]byte, 12)
+		rand.Read(contentKey)
+		rand.Read(nonce)
+		message.Encapsulated, err = rsa.EncryptOAEP(sha256.New(), rand.Reader, public, contentKey, []byte(info))
+		if err != nil {
+			t.Fatal(err)
+		}
+		message.Algorithm, message.Nonce = sealRSAOAEP, nonce
+	}
+	block, _ := aes.NewCipher(contentKey)
+	gcm, _ := cipher.NewGCM(block)
+	message.Ciphertext = gcm.Seal(nil, nonce, plaintext, []byte(message.KeyID))
+	return message
+}
+
+func TestDecrypt(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+
+	for _, keyType := range []string{decryptKeyX25519, decryptKeyRSA} {
+		t.Run(keyType, func(t *testing.T) {
+			config.AllowDecrypt = false
+			config.DecryptKeyType = keyType
+			decryptKeyOnce, decryptKeyPair, decryptKeyErr = sync.Once{}, nil, nil
+			t.Cleanup(func() {
+				decryptKeyOnce, decryptKeyPair, decryptKeyErr = sync.Once{}, nil, nil
+				secretsMu.Lock()
+				delete(secrets, "db")
+				secretsMu.Unlock()
+			})
+
+			if response := attestDecryptKey(CommandArgs{}); response.ErrorCode != errCodeUnauthorized {
+				t.Fatalf("未启用时应拒绝: %+v", response)
+			}
+			config.AllowDecrypt = true
+
+			response := attestDecryptKey(CommandArgs{Nonce: "n1"})
+			if !response.Success {
+				t.Fatalf("decrypt-key 失败: %+v", response)
+			}
+			raw, _ := base64.StdEncoding.DecodeString(response.Document)
+			var document map[string][]byte
+			if err := cbor.Unmarshal(raw, &document); err != nil {
+				t.Fatal(err)
+			}
+
+			decrypt := func(message SealedMessage) Response {
+				return decryptRequest(CommandArgs{Handle: "db", DataB64: base64.StdEncoding.EncodeToString(mustJSON(t, message))})
+			}
+			message := sealForTest(t, document["public_key"], []byte("s3cr3t"), sealInfo)
+			if response := decrypt(message); !response.Success || response.Secret.Size != 6 {
+				t.Fatalf("decrypt 失败: %+v", response)
+			}
+			secretsMu.RLock()
+			value := string(secrets["db"])
+			secretsMu.RUnlock()
+			if value != "s3cr3t" {
+				t.Fatalf("句柄 db 的明文为 %q", value)
+			}
+
+			tampered := message
+			tampered.Ciphertext = append([]byte(nil), message.Ciphertext...)
+			tampered.Ciphertext[0] ^= 1
+			if response := decrypt(tampered); response.ErrorCode != errCodeBadRequest {
+				t.Fatalf("篡改的消息应被拒绝: %+v", response)
+			}
+			other := message
+			other.KeyID = hex.EncodeToString(make([]byte, 32))
+			if response := decrypt(other); response.ErrorCode != errCodeBadRequest {
+				t.Fatalf("其他接收方的消息应被拒绝: %+v", response)
+			}
+		})
+	}
+}
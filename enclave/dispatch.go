@@ -0,0 +1,117 @@
+package main
+
+import "fmt"
+
+// 请求方法
+const (
+	methodAttest        = "attest"
+	methodToken         = "token"
+	methodSigningKey    = "signing-key"
+	methodHealth        = "health"
+	methodDescribeNSM   = "describe-nsm"
+	methodGetRandom     = "get-random"
+	methodDescribePCR   = "describe-pcr"
+	methodExtendPCR     = "extend-pcr"
+	methodLockPCR       = "lock-pcr"
+	methodLockPCRs      = "lock-pcrs"
+	methodAttestBatch   = "attest-batch"
+	methodFilePush      = "file-push"
+	methodFilePull      = "file-pull"
+	methodSetTime       = "set-time"
+	methodGetSecret     = "get-secret"
+	methodGetParameter  = "get-parameter"
+	methodKMSSign       = "kms-sign"
+	methodDecryptObject = "decrypt-object"
+	methodDecryptKey    = "decrypt-key"
+	methodDecrypt       = "decrypt"
+	methodAgeUnwrap     = "age-unwrap"
+	methodSessionOpen   = "session-open"
+	methodSession       = "session"
+	methodSessionRekey  = "session-rekey"
+)
+
+// 处理传输层收到的请求，--require-session 时拒绝未经加密会话发送的请求
+func handleRequest(args CommandArgs) Response {
+	if config.RequireSession && !sessionExempt(requestMethod(args)) {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: fmt.Sprintf("服务器要求 %s 请求经加密会话发送 (session-open)", requestMethod(args))}
+	}
+	return serveRequest(args)
+}
+
+// 按请求方法分派，请求带 traceparent 时在响应中附带 Enclave 内的 span
+// 响应按请求的 schema 版本降级，不支持的版本直接拒绝；会话中解密出的请求也由此处理
+func serveRequest(args CommandArgs) Response {
+	if args.SchemaVersion > schemaVersion {
+		return unsupportedSchemaResponse(args.SchemaVersion)
+	}
+
+	trace := newRequestTrace(args.TraceParent)
+	span := trace.start("enclave." + requestMethod(args))
+	response := dispatchRequest(args, span)
+	span.end(response)
+	response.Trace = trace.finished()
+	return downgradeResponse(requestSchemaVersion(args), response)
+}
+
+// 请求方法，为空时为 attest
+func requestMethod(args CommandArgs) string {
+	if args.Method == "" {
+		return methodAttest
+	}
+	return args.Method
+}
+
+func dispatchRequest(args CommandArgs, span *traceSpan) Response {
+	switch args.Method {
+	case "", methodAttest:
+		return cachedProcessRequest(args, span)
+	case methodToken:
+		return issueToken(args)
+	case methodSigningKey:
+		return attestSigningKey(args)
+	case methodHealth:
+		return Response{Success: true, Version: version}
+	case methodDescribeNSM:
+		return describeNSMRequest()
+	case methodGetRandom:
+		return getRandomRequest(args)
+	case methodDescribePCR:
+		return describePCRRequest(args)
+	case methodExtendPCR:
+		return extendPCRRequest(args)
+	case methodLockPCR:
+		return lockPCRRequest(args)
+	case methodLockPCRs:
+		return lockPCRsRequest(args)
+	case methodAttestBatch:
+		return attestBatchRequest(args, span)
+	case methodFilePush:
+		return filePushRequest(args)
+	case methodFilePull:
+		return filePullRequest(args)
+	case methodSetTime:
+		return setTimeRequest(args)
+	case methodGetSecret:
+		return getSecretRequest(args)
+	case methodGetParameter:
+		return getParameterRequest(args)
+	case methodKMSSign:
+		return kmsSignRequest(args)
+	case methodDecryptObject:
+		return decryptObjectRequest(args)
+	case methodDecryptKey:
+		return attestDecryptKey(args)
+	case methodDecrypt:
+		return decryptRequest(args)
+	case methodAgeUnwrap:
+		return ageUnwrapRequest(args)
+	case methodSessionOpen:
+		return sessionOpenRequest(args)
+	case methodSession:
+		return sessionRequest(args)
+	case methodSessionRekey:
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "session-rekey 只能在会话中发送"}
+	default:
+		return Response{ErrorCode: errCodeUnsupportedMethod, ErrorMessage: fmt.Sprintf("不支持的请求方法: %s", args.Method)}
+	}
+}
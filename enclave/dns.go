@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+// 单个 DNS 查询经主机转发的超时时间
+const dnsForwardTimeout = 10 * time.Second
+
+// 将本进程的 net.DefaultResolver 改为经 --dns-forward 转发到主机的 dns-proxy，
+// Go 解析器对非 PacketConn 的连接使用 DNS-over-TCP 格式，与主机端相同
+func startDNSForwarder() error {
+	if _, err := parseDialAddress(config.DNSForward); err != nil {
+		return err
+	}
+	net.DefaultResolver = &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialAddress(config.DNSForward)
+		},
+	}
+	log.Printf("DNS 查询经 %s 转发到主机\n", config.DNSForward)
+	return nil
+}
+
+// 在 --dns-listen (UDP) 上提供 DNS 存根解析器，供 Enclave 内不使用 Go 解析器的进程使用
+// (/etc/resolv.conf 中的 nameserver 指向该地址)
+func startDNSStub() {
+	conn, err := net.ListenPacket("udp", config.DNSListen)
+	if err != nil {
+		log.Fatalf("无法创建 DNS 监听器: %v", err)
+	}
+	log.Printf("DNS 存根解析器已启动，监听 %s\n", config.DNSListen)
+
+	buf := make([]byte, 65535)
+	for {
+		n, peer, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("读取 DNS 查询失败: %v\n", err)
+			continue
+		}
+		query := append([]byte(nil), buf[:n]...)
+		go func() {
+			response, err := forwardDNS(query)
+			if err != nil {
+				log.Printf("转发 DNS 查询失败: %v\n", err)
+				return
+			}
+			conn.WriteTo(response, peer)
+		}()
+	}
+}
+
+// 经主机转发一个 DNS 查询
+func forwardDNS(query []byte) ([]byte, error) {
+	conn, err := dialAddress(config.DNSForward)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsForwardTimeout))
+
+	message := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(message, uint16(len(query)))
+	copy(message[2:], query)
+	if _, err := conn.Write(message); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	response := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, response); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
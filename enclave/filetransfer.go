@@ -0,0 +1,208 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// file-pull 单次返回的最大字节数，保证 base64 编码后的响应远小于单帧上限
+const maxFilePullChunk = 4 << 20
+
+// file-pull 未指定 length 时单次返回的字节数
+const defaultFilePullChunk = 1 << 20
+
+// file-push、file-pull 方法的结果 - 与 client 端匹配
+type FileChunk struct {
+	// --file-root 下的相对路径
+	Path string `json:"path" cbor:"path"`
+	// file-push: 已接收的字节数；file-pull: 文件总大小
+	Size int64 `json:"size" cbor:"size"`
+	// file-pull: 本块在文件中的偏移及内容
+	Offset int64  `json:"offset,omitempty" cbor:"offset,omitempty"`
+	Data   []byte `json:"data,omitempty" cbor:"data,omitempty"`
+	// 整个文件的 SHA-256 (十六进制)，file-push 完成时及 file-pull 的最后一块返回
+	SHA256 string `json:"sha256,omitempty" cbor:"sha256,omitempty"`
+	// file-pull: 本块是否为文件末尾
+	EOF bool `json:"eof,omitempty" cbor:"eof,omitempty"`
+}
+
+// 进行中的上传: 按顺序写入 <path>.partial，完成并校验摘要后重命名到 path
+type fileUpload struct {
+	file *os.File
+	size int64
+	hash hash.Hash
+}
+
+var (
+	fileUploadsMu sync.Mutex
+	fileUploads   = map[string]*fileUpload{}
+)
+
+// 将请求中的相对路径解析为 --file-root 下的路径，拒绝绝对路径和跳出 --file-root 的路径
+func resolveFilePath(path string) (string, error) {
+	if path == "" {
+		return "", errors.New("必须指定 path")
+	}
+	if !filepath.IsLocal(path) || strings.HasSuffix(path, ".partial") {
+		return "", fmt.Errorf("无效的 path: %q (必须是 --file-root 下的相对路径)", path)
+	}
+	return filepath.Join(config.FileRoot, path), nil
+}
+
+// file-push 请求: 按偏移顺序上传一块，final 时校验整个文件的 SHA-256 后原子地替换目标文件
+// offset 为 0 时开始新的上传，丢弃该路径上未完成的上传；需以 --file-root 启动
+func filePushRequest(args CommandArgs) Response {
+	if config.FileRoot == "" {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用文件传输 (--file-root)"}
+	}
+	target, err := resolveFilePath(args.Path)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: err.Error()}
+	}
+	data, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 data_b64 失败: %v", err)}
+	}
+	if args.Final && len(args.SHA256) != sha256.Size*2 {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "最后一块必须携带整个文件的 sha256 (十六进制)"}
+	}
+
+	fileUploadsMu.Lock()
+	defer fileUploadsMu.Unlock()
+
+	upload := fileUploads[target]
+	if args.Offset == 0 {
+		if upload != nil {
+			upload.abort()
+		}
+		if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+			return errorResponse(errCodeInternal, fmt.Sprintf("创建目录失败: %v", err))
+		}
+		file, err := os.OpenFile(target+".partial", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return errorResponse(errCodeInternal, fmt.Sprintf("创建文件失败: %v", err))
+		}
+		upload = &fileUpload{file: file, hash: sha256.New()}
+		fileUploads[target] = upload
+	}
+	if upload == nil || args.Offset != upload.size {
+		expected := int64(0)
+		if upload != nil {
+			expected = upload.size
+		}
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("偏移 %d 与已接收的 %d 字节不符，需从偏移 0 重新上传", args.Offset, expected)}
+	}
+	if upload.size+int64(len(data)) > config.MaxFileSize {
+		upload.abort()
+		delete(fileUploads, target)
+		return Response{ErrorCode: errCodeRequestTooLarge, ErrorMessage: fmt.Sprintf("文件超过 %d 字节上限 (--max-file-size)", config.MaxFileSize)}
+	}
+
+	if _, err := upload.file.Write(data); err != nil {
+		upload.abort()
+		delete(fileUploads, target)
+		return errorResponse(errCodeInternal, fmt.Sprintf("写入文件失败: %v", err))
+	}
+	upload.hash.Write(data)
+	upload.size += int64(len(data))
+	if !args.Final {
+		return Response{Success: true, File: &FileChunk{Path: args.Path, Size: upload.size}}
+	}
+
+	delete(fileUploads, target)
+	digest := hex.EncodeToString(upload.hash.Sum(nil))
+	if !strings.EqualFold(digest, args.SHA256) {
+		upload.abort()
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("SHA-256 校验失败: 收到 %s，期望 %s", digest, args.SHA256)}
+	}
+	if err := upload.commit(target); err != nil {
+		upload.abort()
+		return errorResponse(errCodeInternal, fmt.Sprintf("保存文件失败: %v", err))
+	}
+	log.Printf("已接收文件 %s (%d 字节, sha256 %s)\n", args.Path, upload.size, digest)
+	return Response{Success: true, File: &FileChunk{Path: args.Path, Size: upload.size, SHA256: digest}}
+}
+
+// 同步并重命名到目标路径
+func (u *fileUpload) commit(target string) error {
+	if err := u.file.Sync(); err != nil {
+		return err
+	}
+	if err := u.file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(u.file.Name(), target)
+}
+
+// 放弃上传并删除未完成的文件
+func (u *fileUpload) abort() {
+	u.file.Close()
+	os.Remove(u.file.Name())
+}
+
+// file-pull 请求: 读取从 offset 开始的最多 length 字节，最后一块附带整个文件的 SHA-256
+// 需以 --file-root 启动
+func filePullRequest(args CommandArgs) Response {
+	if config.FileRoot == "" {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用文件传输 (--file-root)"}
+	}
+	source, err := resolveFilePath(args.Path)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: err.Error()}
+	}
+	length := args.Length
+	if length == 0 {
+		length = defaultFilePullChunk
+	}
+	if length < 0 || length > maxFilePullChunk {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("length 必须在 1 到 %d 之间", maxFilePullChunk)}
+	}
+
+	file, err := os.Open(source)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("文件不存在: %s", args.Path)}
+		}
+		return errorResponse(errCodeInternal, fmt.Sprintf("打开文件失败: %v", err))
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return errorResponse(errCodeInternal, fmt.Sprintf("读取文件信息失败: %v", err))
+	}
+	if !info.Mode().IsRegular() {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("不是普通文件: %s", args.Path)}
+	}
+	if info.Size() > config.MaxFileSize {
+		return Response{ErrorCode: errCodeRequestTooLarge, ErrorMessage: fmt.Sprintf("文件超过 %d 字节上限 (--max-file-size)", config.MaxFileSize)}
+	}
+	if args.Offset < 0 || args.Offset > info.Size() {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("偏移 %d 超出文件大小 %d", args.Offset, info.Size())}
+	}
+
+	data := make([]byte, length)
+	n, err := file.ReadAt(data, args.Offset)
+	if err != nil && err != io.EOF {
+		return errorResponse(errCodeInternal, fmt.Sprintf("读取文件失败: %v", err))
+	}
+	chunk := &FileChunk{Path: args.Path, Size: info.Size(), Offset: args.Offset, Data: data[:n]}
+	if args.Offset+int64(n) >= info.Size() {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, io.NewSectionReader(file, 0, info.Size())); err != nil {
+			return errorResponse(errCodeInternal, fmt.Sprintf("读取文件失败: %v", err))
+		}
+		chunk.SHA256 = hex.EncodeToString(hash.Sum(nil))
+		chunk.EOF = true
+	}
+	return Response{Success: true, File: chunk}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilePushPull(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	config.FileRoot = t.TempDir()
+	config.MaxFileSize = 1 << 20
+
+	data := bytes.Repeat([]byte("enclave"), 1000)
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])
+	push := func(offset int, chunk []byte, final bool, digest string) Response {
+		return filePushRequest(CommandArgs{Method: methodFilePush, Path: "a/b.bin", Offset: int64(offset), DataB64: base64.StdEncoding.EncodeToString(chunk), Final: final, SHA256: digest})
+	}
+
+	if r := push(0, data[:3000], false, ""); !r.Success || r.File.Size != 3000 {
+		t.Fatalf("第一块: %+v", r)
+	}
+	if r := push(1000, data[3000:], true, digest); r.Success {
+		t.Fatal("偏移不连续的块应被拒绝")
+	}
+	if r := push(3000, data[3000:], true, digest); !r.Success || r.File.SHA256 != digest {
+		t.Fatalf("最后一块: %+v", r)
+	}
+	if _, err := os.Stat(filepath.Join(config.FileRoot, "a/b.bin.partial")); !os.IsNotExist(err) {
+		t.Fatal("完成后不应保留 .partial 文件")
+	}
+
+	// 摘要不符时不替换已有文件
+	if r := push(0, []byte("other"), true, digest); r.Success || r.ErrorCode != errCodeBadRequest {
+		t.Fatalf("摘要不符: %+v", r)
+	}
+
+	var pulled []byte
+	for offset := 0; ; {
+		r := filePullRequest(CommandArgs{Method: methodFilePull, Path: "a/b.bin", Offset: int64(offset), Length: 4096})
+		if !r.Success {
+			t.Fatalf("file-pull: %+v", r)
+		}
+		pulled = append(pulled, r.File.Data...)
+		offset += len(r.File.Data)
+		if r.File.EOF {
+			if r.File.SHA256 != digest {
+				t.Fatalf("sha256 = %s，期望 %s", r.File.SHA256, digest)
+			}
+			break
+		}
+	}
+	if !bytes.Equal(pulled, data) {
+		t.Fatal("下载的内容与上传的不同")
+	}
+
+	for _, path := range []string{"", "../x", "/etc/passwd", "a/../../x", "a/b.bin.partial"} {
+		if r := filePullRequest(CommandArgs{Path: path}); r.ErrorCode != errCodeBadRequest {
+			t.Errorf("path %q 应被拒绝: %+v", path, r)
+		}
+	}
+
+	config.MaxFileSize = 100
+	if r := push(0, data[:200], true, digest); r.ErrorCode != errCodeRequestTooLarge {
+		t.Fatalf("超过上限: %+v", r)
+	}
+}
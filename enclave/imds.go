@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// IMDSv2 令牌的最长有效期 (秒)，与 EC2 相同
+const maxIMDSTokenTTL = 21600
+
+// Enclave 内的 IMDS 端点: 在本地签发并校验 IMDSv2 令牌，
+// 其余请求经 --imds-forward 转发到主机的 imds-proxy (由主机使用自己的令牌访问 IMDS)
+type localIMDS struct {
+	proxy *httputil.ReverseProxy
+
+	mu     sync.Mutex
+	tokens map[string]time.Time
+}
+
+func newLocalIMDS() *localIMDS {
+	target := &url.URL{Scheme: "http", Host: "imds"}
+	proxy := httputil.NewSingleHostReverseProxy(target)
+	proxy.Transport = &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialAddress(config.IMDSForward)
+		},
+		MaxIdleConns:    4,
+		IdleConnTimeout: 30 * time.Second,
+	}
+	return &localIMDS{proxy: proxy, tokens: map[string]time.Time{}}
+}
+
+func (m *localIMDS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPut && r.URL.Path == "/latest/api/token" {
+		m.issueToken(w, r)
+		return
+	}
+	if r.Method != http.MethodGet {
+		http.Error(w, "只支持 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	if !m.validToken(r.Header.Get("X-aws-ec2-metadata-token")) {
+		http.Error(w, "缺少或无效的 IMDSv2 令牌", http.StatusUnauthorized)
+		return
+	}
+	r.Header.Del("X-aws-ec2-metadata-token")
+	m.proxy.ServeHTTP(w, r)
+}
+
+// 签发 IMDSv2 令牌，有效期由 X-aws-ec2-metadata-token-ttl-seconds 指定 (1 到 21600 秒)
+func (m *localIMDS) issueToken(w http.ResponseWriter, r *http.Request) {
+	ttl, err := strconv.Atoi(r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+	if err != nil || ttl < 1 || ttl > maxIMDSTokenTTL {
+		http.Error(w, "无效的 X-aws-ec2-metadata-token-ttl-seconds", http.StatusBadRequest)
+		return
+	}
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	token := base64.RawURLEncoding.EncodeToString(random)
+
+	m.mu.Lock()
+	now := time.Now()
+	for t, expiry := range m.tokens {
+		if now.After(expiry) {
+			delete(m.tokens, t)
+		}
+	}
+	m.tokens[token] = now.Add(time.Duration(ttl) * time.Second)
+	m.mu.Unlock()
+
+	w.Header().Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(ttl))
+	w.Write([]byte(token))
+}
+
+func (m *localIMDS) validToken(token string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	expiry, ok := m.tokens[token]
+	return ok && time.Now().Before(expiry)
+}
+
+// 在 --imds-listen 上提供 IMDS 端点，并将本进程的 AWS_EC2_METADATA_SERVICE_ENDPOINT 指向它，
+// Enclave 内的其他进程 (AWS SDK) 设置同一环境变量即可使用默认凭证链
+func startLocalIMDS() error {
+	listener, err := net.Listen("tcp", config.IMDSListen)
+	if err != nil {
+		return err
+	}
+	if os.Getenv("AWS_EC2_METADATA_SERVICE_ENDPOINT") == "" {
+		os.Setenv("AWS_EC2_METADATA_SERVICE_ENDPOINT", "http://"+config.IMDSListen)
+	}
+
+	server := &http.Server{
+		Handler:           newLocalIMDS(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("IMDS 端点已启动，监听 %s，经 %s 转发到主机\n", config.IMDSListen, config.IMDSForward)
+	go func() {
+		log.Fatalf("IMDS 端点退出: %v", server.Serve(listener))
+	}()
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// KMS Recipient 使用的临时 RSA 密钥长度
+const kmsRecipientKeyBits = 2048
+
+// 可重复指定的 --kms-sign-key、--kms-region 参数，也可以逗号分隔
+type kmsList []string
+
+func (l *kmsList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *kmsList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// 依次尝试的 KMS 区域: cfg.Region (请求中的 region 或默认区域) 在前，随后是 --kms-region 中的其他区域
+func kmsRegions(cfg aws.Config) []string {
+	regions := []string{cfg.Region}
+	for _, region := range config.KMSRegions {
+		if region != cfg.Region {
+			regions = append(regions, region)
+		}
+	}
+	return regions
+}
+
+// 将 KMS 密钥或别名 ARN 中的区域替换为 region: 多区域密钥 (mrk-) 在各区域的副本 ARN 只有区域不同；
+// 密钥 ID 和别名名称与区域无关，原样返回
+func regionalKeyID(keyID, region string) string {
+	parts := strings.SplitN(keyID, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "kms" {
+		return keyID
+	}
+	parts[3] = region
+	return strings.Join(parts, ":")
+}
+
+// 按 kmsRegions 的顺序调用 KMS: 遇到区域性故障 (连接失败、超时、5xx、限流) 时切换到下一个区域，
+// 其他错误 (如 AccessDeniedException) 直接返回；input 以各区域的密钥 ARN 构造请求。
+// 多区域密钥加密的密文可由任一区域的副本解密，单区域密钥在其他区域会失败
+func callKMS(ctx context.Context, cfg aws.Config, target, keyID string, input func(regionalKeyID string) interface{}, output interface{}) error {
+	regions := kmsRegions(cfg)
+	var err error
+	for i, region := range regions {
+		regional := cfg.Copy()
+		regional.Region = region
+		err = callAWS(ctx, regional, "kms", target, input(regionalKeyID(keyID, region)), output)
+		var awsErr *awsError
+		if err == nil || !errors.As(err, &awsErr) || !awsErr.regional() {
+			return err
+		}
+		if i+1 < len(regions) {
+			log.Printf("KMS 区域 %s 不可用: %v，切换到 %s\n", region, err, regions[i+1])
+		}
+	}
+	return err
+}
+
+// KMS Decrypt 请求的 Recipient 参数
+type kmsRecipient struct {
+	KeyEncryptionAlgorithm string
+	AttestationDocument    []byte
+}
+
+type kmsDecryptInput struct {
+	CiphertextBlob    []byte
+	KeyId             string            `json:",omitempty"`
+	EncryptionContext map[string]string `json:",omitempty"`
+	Recipient         *kmsRecipient
+}
+
+type kmsDecryptOutput struct {
+	KeyId                  string
+	CiphertextForRecipient []byte
+}
+
+// 以证明文档调用 KMS Decrypt: 证明文档绑定一次性的 RSA 公钥，KMS 按密钥策略中的
+// kms:RecipientAttestation:PCR0 等条件校验后，只返回以该公钥加密的明文 (CMS EnvelopedData)，
+// 明文只在 Enclave 内解出；keyID 非空时要求密文属于该密钥，encryptionContext 须与加密时相同
+func kmsDecrypt(ctx context.Context, cfg aws.Config, ciphertext []byte, keyID string, encryptionContext map[string]string) ([]byte, string, error) {
+	key, err := rsa.GenerateKey(rand.Reader, kmsRecipientKeyBits)
+	if err != nil {
+		return nil, "", err
+	}
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, "", err
+	}
+	attester, err := currentAttester()
+	if err != nil {
+		return nil, "", err
+	}
+	document, err := attester.Attest(nil, spki, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("生成证明文档失败: %v", err)
+	}
+
+	input := kmsDecryptInput{
+		CiphertextBlob:    ciphertext,
+		KeyId:             keyID,
+		EncryptionContext: encryptionContext,
+		Recipient:         &kmsRecipient{KeyEncryptionAlgorithm: "RSAES_OAEP_SHA_256", AttestationDocument: document},
+	}
+	var output kmsDecryptOutput
+	err = callKMS(ctx, cfg, "TrentService.Decrypt", keyID, func(regionalKeyID string) interface{} {
+		input.KeyId = regionalKeyID
+		return input
+	}, &output)
+	if err != nil {
+		return nil, "", err
+	}
+	if len(output.CiphertextForRecipient) == 0 {
+		return nil, "", errors.New("KMS 未返回 CiphertextForRecipient (密钥策略未要求证明或 KMS 不支持 Recipient)")
+	}
+	plaintext, err := decryptEnvelopedData(output.CiphertextForRecipient, key)
+	if err != nil {
+		return nil, "", fmt.Errorf("解密 CiphertextForRecipient 失败: %v", err)
+	}
+	return plaintext, output.KeyId, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegionalKeyID(t *testing.T) {
+	tests := []struct{ keyID, want string }{
+		{"arn:aws:kms:us-east-1:123456789012:key/mrk-1234", "arn:aws:kms:eu-west-1:123456789012:key/mrk-1234"},
+		{"arn:aws:kms:us-east-1:123456789012:alias/app", "arn:aws:kms:eu-west-1:123456789012:alias/app"},
+		{"mrk-1234", "mrk-1234"},
+		{"alias/app", "alias/app"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := regionalKeyID(tt.keyID, "eu-west-1"); got != tt.want {
+			t.Errorf("regionalKeyID(%q) = %q，期望 %q", tt.keyID, got, tt.want)
+		}
+	}
+}
+
+func TestKMSRegionFailover(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+	plaintext := []byte("database password")
+	useFakeAWS(t, plaintext, nil)
+
+	// us-east-1 的 KMS 不可用，eu-west-1 拒绝访问，us-west-2 正常
+	unavailable := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"__type":"KMSInternalException","message":"unavailable"}`))
+	}))
+	defer unavailable.Close()
+	denied := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"__type":"AccessDeniedException","message":"denied"}`))
+	}))
+	defer denied.Close()
+	healthy := awsEndpoint
+	awsEndpoint = func(service, region string) string {
+		switch {
+		case service == "kms" && region == "us-east-1":
+			return unavailable.URL
+		case service == "kms" && region == "eu-west-1":
+			return denied.URL
+		}
+		return healthy(service, region)
+	}
+
+	config.KMSSignKeys = kmsList{"arn:aws:kms:us-east-1:123456789012:key/mrk-1234"}
+	config.KMSRegions = kmsList{"us-east-1", "us-west-2"}
+	r := kmsSignRequest(CommandArgs{SigningAlgorithm: "ECDSA_SHA_256", DataB64: "bWVzc2FnZQ=="})
+	if !r.Success || !strings.Contains(r.Signature.KeyID, "arn:aws:kms:us-west-2:123456789012:key/mrk-1234") {
+		t.Fatalf("应切换到 us-west-2 的副本密钥: %+v", r)
+	}
+
+	// 非区域性错误不切换区域
+	config.KMSRegions = kmsList{"eu-west-1", "us-west-2"}
+	config.AllowGetSecret = true
+	r = getSecretRequest(CommandArgs{SecretID: "db", Region: "eu-west-1"})
+	if r.Success || !strings.Contains(r.ErrorMessage, "AccessDeniedException") {
+		t.Fatalf("AccessDeniedException 不应切换区域: %+v", r)
+	}
+}
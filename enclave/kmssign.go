@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"slices"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// KMS Sign 的 RAW 消息最大字节数，更长的消息需由调用方先计算摘要
+const maxKMSSignMessage = 4096
+
+// kms-sign 方法的结果 - 与 client 端匹配
+type KMSSignature struct {
+	// 签名所用的 KMS 密钥 ARN 及签名算法
+	KeyID            string `json:"key_id" cbor:"key_id"`
+	SigningAlgorithm string `json:"signing_algorithm" cbor:"signing_algorithm"`
+	Signature        []byte `json:"signature" cbor:"signature"`
+}
+
+type kmsSignOutput struct {
+	KeyId            string
+	Signature        []byte
+	SigningAlgorithm string
+}
+
+// kms-sign 请求: Enclave 经 --egress 以 KMS 非对称密钥对 data_b64 签名 (digest 为 true 时 data_b64 已是摘要)；
+// 只允许 --kms-sign-key 中的密钥，未指定 key_id 且只配置了一个密钥时使用该密钥。
+// KMS Sign 不接受证明文档 (Recipient)，密钥策略无法以 kms:RecipientAttestation 条件限定签名，
+// 因此响应附带证明文档，其 user_data 绑定密钥、算法、消息摘要和签名，验证方据此确认签名由度量过的 Enclave 发起
+func kmsSignRequest(args CommandArgs) Response {
+	if len(config.KMSSignKeys) == 0 {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 kms-sign 方法 (--kms-sign-key)"}
+	}
+	keyID := args.KeyID
+	if keyID == "" {
+		if len(config.KMSSignKeys) != 1 {
+			return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "配置了多个签名密钥，必须指定 key_id"}
+		}
+		keyID = config.KMSSignKeys[0]
+	}
+	if !slices.Contains(config.KMSSignKeys, keyID) {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: fmt.Sprintf("密钥 %s 不在 --kms-sign-key 中", keyID)}
+	}
+	if args.SigningAlgorithm == "" {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "必须指定 signing_algorithm"}
+	}
+	message, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 data_b64 失败: %v", err)}
+	}
+	if len(message) == 0 {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "必须指定 data_b64"}
+	}
+	messageType := "RAW"
+	if args.Digest {
+		messageType = "DIGEST"
+	}
+	if len(message) > maxKMSSignMessage {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("消息超过 %d 字节，请先计算摘要并指定 digest", maxKMSSignMessage)}
+	}
+
+	ctx := context.Background()
+	cfg, err := loadAWSConfig(ctx, args.Region)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	var output kmsSignOutput
+	err = callKMS(ctx, cfg, "TrentService.Sign", keyID, func(regionalKeyID string) interface{} {
+		return map[string]string{
+			"KeyId":            regionalKeyID,
+			"Message":          base64.StdEncoding.EncodeToString(message),
+			"MessageType":      messageType,
+			"SigningAlgorithm": args.SigningAlgorithm,
+		}
+	}, &output)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+
+	// 以确定性 CBOR map 将签名绑定到证明文档
+	messageSHA256 := sha256.Sum256(message)
+	mode, err := cbor.CoreDetEncOptions().EncMode()
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	userData, err := mode.Marshal(map[string]interface{}{
+		"kms_key_id":        output.KeyId,
+		"signing_algorithm": output.SigningAlgorithm,
+		"message_type":      messageType,
+		"message_sha256":    messageSHA256[:],
+		"signature":         output.Signature,
+	})
+	if err != nil {
+		return errorResponse(errCodeInternal, fmt.Sprintf("编码签名声明失败: %v", err))
+	}
+	response := processRequest(CommandArgs{
+		UserDataB64: base64.StdEncoding.EncodeToString(userData),
+		Nonce:       args.Nonce,
+		NonceB64:    args.NonceB64,
+	})
+	if !response.Success {
+		return response
+	}
+	log.Printf("已以 KMS 密钥 %s 签名 (%s，%s 消息 %d 字节)\n", output.KeyId, output.SigningAlgorithm, messageType, len(message))
+	response.Signature = &KMSSignature{
+		KeyID:            output.KeyId,
+		SigningAlgorithm: output.SigningAlgorithm,
+		Signature:        output.Signature,
+	}
+	return response
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestKMSSign(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	fake := newFakeNSM()
+	useFakeNSM(t, fake)
+	useFakeAWS(t, nil, nil)
+
+	message := []byte("release v1.2.3")
+	request := CommandArgs{SigningAlgorithm: "ECDSA_SHA_256", DataB64: base64.StdEncoding.EncodeToString(message), Nonce: "n1"}
+	if r := kmsSignRequest(request); r.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("未配置 --kms-sign-key 时应拒绝: %+v", r)
+	}
+	config.KMSSignKeys = kmsList{"alias/release"}
+	other := request
+	other.KeyID = "alias/other"
+	if r := kmsSignRequest(other); r.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("不在 --kms-sign-key 中的密钥应被拒绝: %+v", r)
+	}
+
+	r := kmsSignRequest(request)
+	if !r.Success || r.Signature == nil || r.Document == "" {
+		t.Fatalf("kms-sign: %+v", r)
+	}
+	wantSignature := "RAW:" + request.DataB64
+	if r.Signature.KeyID != "arn:aws:kms:us-east-1:123456789012:key/alias/release" || string(r.Signature.Signature) != wantSignature {
+		t.Fatalf("签名: %+v", r.Signature)
+	}
+
+	// 证明文档的 user_data 绑定密钥、消息摘要和签名
+	var claims map[string]interface{}
+	if err := cbor.Unmarshal(fake.userData, &claims); err != nil {
+		t.Fatal(err)
+	}
+	messageSHA256 := sha256.Sum256(message)
+	if claims["kms_key_id"] != r.Signature.KeyID || string(claims["message_sha256"].([]byte)) != string(messageSHA256[:]) ||
+		string(claims["signature"].([]byte)) != wantSignature || string(fake.nonce) != "n1" {
+		t.Fatalf("user_data 声明: %v", claims)
+	}
+
+	request.Digest = true
+	request.DataB64 = base64.StdEncoding.EncodeToString(make([]byte, maxKMSSignMessage+1))
+	if r := kmsSignRequest(request); r.ErrorCode != errCodeBadRequest {
+		t.Fatalf("超长的消息应被拒绝: %+v", r)
+	}
+}
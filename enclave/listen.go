@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mdlayher/vsock"
+)
+
+// 按 --listen 创建监听器，为空时监听 vsock 端口
+// tcp:// 和 unix:// 用于没有 vsock 的 CI 及本地集成测试
+func listen(address string, port uint) (net.Listener, error) {
+	if address == "" {
+		return vsock.Listen(uint32(port), nil)
+	}
+
+	scheme, addr, ok := strings.Cut(address, "://")
+	if !ok || addr == "" {
+		return nil, fmt.Errorf("无效的监听地址: %s (格式为 vsock://PORT、tcp://HOST:PORT 或 unix:///PATH)", address)
+	}
+	switch scheme {
+	case "vsock":
+		p, err := strconv.ParseUint(strings.TrimPrefix(addr, ":"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 vsock 端口: %s", addr)
+		}
+		return vsock.Listen(uint32(p), nil)
+	case "tcp":
+		return net.Listen("tcp", addr)
+	case "unix":
+		// 清理上次运行遗留的套接字文件
+		if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+		return net.Listen("unix", addr)
+	default:
+		return nil, fmt.Errorf("不支持的监听地址类型: %s (可选 vsock、tcp、unix)", scheme)
+	}
+}
+
+// 在 Enclave 内的 Unix 套接字上提供与 vsock 相同的帧协议，供同一 Enclave 中的边车进程使用，
+// 访问权限由套接字文件的权限控制 (--unix-socket-mode)，不受 --allow 限制
+func startUnixServer() {
+	listener, err := listen("unix://"+config.UnixSocket, 0)
+	if err != nil {
+		log.Fatalf("无法创建 Unix 套接字监听器: %v", err)
+	}
+	if err := os.Chmod(config.UnixSocket, os.FileMode(config.UnixSocketMode)); err != nil {
+		log.Fatalf("设置 Unix 套接字权限失败: %v", err)
+	}
+	log.Printf("Unix 套接字服务已启动，监听 %s (权限 %04o)\n", config.UnixSocket, config.UnixSocketMode)
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("接受 Unix 套接字连接失败: %v\n", err)
+			continue
+		}
+		go handleClient(conn)
+	}
+}
+
+// 八进制文件权限参数 (如 0660)
+type fileMode uint32
+
+func (m *fileMode) String() string {
+	return fmt.Sprintf("%04o", uint32(*m))
+}
+
+func (m *fileMode) Set(value string) error {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0777 {
+		return fmt.Errorf("无效的文件权限 %q (应为八进制，如 0660)", value)
+	}
+	*m = fileMode(mode)
+	return nil
+}
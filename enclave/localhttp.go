@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 本地 HTTP 接口返回原始 COSE_Sign1 文档时的媒体类型
+const mediaTypeCOSE = "application/cose; cose-type=\"cose-sign1\""
+
+// 检查 --http-listen 等监听地址是否为回环地址，这些接口不做认证，只供 Enclave 内的进程使用
+func checkLoopbackListen(flagName, address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("无效的 %s %q: %v", flagName, address, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("%s 只能监听回环地址 (如 127.0.0.1:8080)，当前为 %s", flagName, address)
+	}
+	return nil
+}
+
+// Enclave 内的本地 HTTP 接口: GET /attestation?nonce=...&user_data=...
+// 参数与 vsock 协议的 attest 请求相同 (user_data、user_data_b64、nonce、nonce_b64、public_key 为 base64 DER、fresh)，
+// 默认返回与 vsock 协议相同的 JSON 响应，Accept 为 application/cose 或 format=raw 时直接返回文档原始字节；
+// GET /secrets/<handle> 返回 get-secret 解密的密钥
+func newLocalHTTPMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/attestation", handleLocalAttestation)
+	mux.HandleFunc("/secrets/", handleLocalSecret)
+	return mux
+}
+
+func handleLocalAttestation(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeLocalResponse(w, http.StatusMethodNotAllowed, errorResponse(errCodeUnsupportedMethod, "只支持 GET"))
+		return
+	}
+
+	query := r.URL.Query()
+	args := CommandArgs{
+		Method:      methodAttest,
+		UserData:    query.Get("user_data"),
+		UserDataB64: query.Get("user_data_b64"),
+		Nonce:       query.Get("nonce"),
+		NonceB64:    query.Get("nonce_b64"),
+		PublicKey:   query.Get("public_key"),
+		TraceParent: r.Header.Get("traceparent"),
+	}
+	if fresh := query.Get("fresh"); fresh != "" {
+		value, err := strconv.ParseBool(fresh)
+		if err != nil {
+			writeLocalResponse(w, http.StatusBadRequest, errorResponse(errCodeBadRequest, fmt.Sprintf("无效的 fresh: %q", fresh)))
+			return
+		}
+		args.Fresh = value
+	}
+
+	response := handleRequest(args)
+	auditRequest("http://"+r.RemoteAddr, args, response)
+	if !response.Success {
+		writeLocalResponse(w, localHTTPStatus(response.ErrorCode), response)
+		return
+	}
+
+	if query.Get("format") == "raw" || strings.HasPrefix(r.Header.Get("Accept"), "application/cose") {
+		document, err := base64.StdEncoding.DecodeString(response.Document)
+		if err != nil {
+			writeLocalResponse(w, http.StatusInternalServerError, errorResponse(errCodeInternal, err.Error()))
+			return
+		}
+		w.Header().Set("Content-Type", mediaTypeCOSE)
+		w.Header().Set("X-Evidence-Type", response.EvidenceType)
+		w.Write(document)
+		return
+	}
+	writeLocalResponse(w, http.StatusOK, response)
+}
+
+// 错误码对应的 HTTP 状态码
+func localHTTPStatus(errorCode string) int {
+	switch errorCode {
+	case errCodeBadRequest, errCodeInvalidPublicKey, errCodeUnsupportedSchema:
+		return http.StatusBadRequest
+	case errCodeNSMUnavailable:
+		return http.StatusServiceUnavailable
+	case errCodeTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func writeLocalResponse(w http.ResponseWriter, status int, response Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// 在回环地址上提供本地 HTTP 接口，供 Enclave 内任意语言编写的进程获取证明文档
+func startLocalHTTPServer() {
+	listener, err := net.Listen("tcp", config.HTTPListen)
+	if err != nil {
+		log.Fatalf("无法创建本地 HTTP 监听器: %v", err)
+	}
+
+	server := &http.Server{
+		Handler:           newLocalHTTPMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("本地 HTTP 接口已启动，监听 %s\n", config.HTTPListen)
+	log.Fatalf("本地 HTTP 接口退出: %v", server.Serve(listener))
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/mdlayher/vsock"
+)
+
+// 转发队列长度，主机不可达时超出的日志只写入控制台
+const logForwardQueue = 4096
+
+// 转发到主机的一条日志，每条一行 JSON - 与 client 端匹配
+type LogRecord struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+}
+
+// 将 log 包的输出同时写入控制台并转发到主机 (--log-forward)，
+// 连接断开时按退避间隔重连，队列满时丢弃并计数，不阻塞请求处理
+type logForwarder struct {
+	address string
+	queue   chan LogRecord
+	dropped atomic.Int64
+}
+
+func (f *logForwarder) Write(p []byte) (int, error) {
+	os.Stderr.Write(p)
+
+	message := string(p)
+	// 去掉 log 包的 "2006/01/02 15:04:05 " 前缀，时间记录在 time 字段中
+	if log.Flags() == log.LstdFlags && len(message) >= 20 {
+		message = message[20:]
+	}
+	record := LogRecord{Time: enclaveNow().UTC(), Source: "enclave", Message: strings.TrimRight(message, "\n")}
+	select {
+	case f.queue <- record:
+	default:
+		f.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+// 逐条发送队列中的日志，发送失败的记录在重连后重发
+func (f *logForwarder) run() {
+	var (
+		conn    net.Conn
+		encoder *json.Encoder
+		backoff = time.Second
+	)
+	for record := range f.queue {
+		for {
+			if conn == nil {
+				c, err := dialAddress(f.address)
+				if err != nil {
+					fmt.Fprintf(os.Stderr, "连接日志接收端 %s 失败: %v，%s 后重试\n", f.address, err, backoff)
+					time.Sleep(backoff)
+					if backoff < 30*time.Second {
+						backoff *= 2
+					}
+					continue
+				}
+				conn, encoder, backoff = c, json.NewEncoder(c), time.Second
+				if dropped := f.dropped.Swap(0); dropped > 0 {
+					encoder.Encode(LogRecord{Time: enclaveNow().UTC(), Source: "log-forward", Message: fmt.Sprintf("转发队列已满，丢弃了 %d 条日志", dropped)})
+				}
+			}
+			if err := encoder.Encode(record); err != nil {
+				fmt.Fprintf(os.Stderr, "转发日志失败: %v\n", err)
+				conn.Close()
+				conn = nil
+				continue
+			}
+			break
+		}
+	}
+}
+
+// 启动日志转发，之后 log 包的输出同时发往主机
+func startLogForwarder() error {
+	if _, err := parseDialAddress(config.LogForward); err != nil {
+		return err
+	}
+	forwarder := &logForwarder{address: config.LogForward, queue: make(chan LogRecord, logForwardQueue)}
+	go forwarder.run()
+	log.SetOutput(forwarder)
+	log.Printf("日志同时转发到 %s\n", config.LogForward)
+	return nil
+}
+
+// 主动连接的地址: vsock://CID:PORT (父实例的 CID 为 3)、tcp://HOST:PORT 或 unix:///PATH
+type dialTarget struct {
+	network string
+	addr    string
+	cid     uint32
+	port    uint32
+}
+
+func parseDialAddress(address string) (dialTarget, error) {
+	scheme, addr, ok := strings.Cut(address, "://")
+	if !ok || addr == "" {
+		return dialTarget{}, fmt.Errorf("无效的地址: %s (格式为 vsock://CID:PORT、tcp://HOST:PORT 或 unix:///PATH)", address)
+	}
+	target := dialTarget{network: scheme, addr: addr}
+	switch scheme {
+	case "vsock":
+		cidText, portText, ok := strings.Cut(addr, ":")
+		cid, cidErr := strconv.ParseUint(cidText, 10, 32)
+		port, portErr := strconv.ParseUint(portText, 10, 32)
+		if !ok || cidErr != nil || portErr != nil {
+			return dialTarget{}, fmt.Errorf("无效的 vsock 地址: %s (格式为 CID:PORT)", addr)
+		}
+		target.cid, target.port = uint32(cid), uint32(port)
+	case "tcp", "unix":
+	default:
+		return dialTarget{}, fmt.Errorf("不支持的地址类型: %s (可选 vsock、tcp、unix)", scheme)
+	}
+	return target, nil
+}
+
+// 连接主机上的服务
+func dialAddress(address string) (net.Conn, error) {
+	target, err := parseDialAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	if target.network == "vsock" {
+		return vsock.Dial(target.cid, target.port, nil)
+	}
+	return net.Dial(target.network, target.addr)
+}
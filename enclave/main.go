@@ -1,16 +1,20 @@
 package main
 
 import (
+	"bufio"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
-	"os/exec"
 	"encoding/base64"
 	"github.com/mdlayher/vsock"
 	"github.com/spf13/cobra"
-	"strings"
+	"time"
 )
 
 const (
@@ -20,107 +24,213 @@ const (
 
 // 命令行参数结构
 type CommandArgs struct {
+	// 请求使用的 schema 版本，未指定时为 1
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// 请求方法，为空时等同于 attest
+	Method    string `json:"method,omitempty"`
 	UserData  string `json:"user_data"`
 	PublicKey string `json:"public_key,omitempty"`
 	Nonce     string `json:"nonce,omitempty"`
+	// base64 编码的二进制 user_data，与 UserData 互斥
+	UserDataB64 string `json:"user_data_b64,omitempty"`
+	// base64 编码的二进制 nonce，与 Nonce 互斥
+	NonceB64 string `json:"nonce_b64,omitempty"`
+	// token 方法: JWT 的 aud 和有效期 (秒)
+	Audience string `json:"audience,omitempty"`
+	TTL      int    `json:"ttl,omitempty"`
+	// attest 方法: 跳过 Enclave 的证明文档缓存
+	Fresh bool `json:"fresh,omitempty"`
+	// W3C traceparent，指定且带采样标志时在响应中返回 Enclave 内的 span
+	TraceParent string `json:"traceparent,omitempty"`
+	// get-random 方法: 随机字节数
+	Length int `json:"length,omitempty"`
+	// extend-pcr 方法: PCR 索引及 base64 编码的扩展数据
+	PCRIndex uint16 `json:"pcr_index,omitempty"`
+	DataB64  string `json:"data_b64,omitempty"`
+	// describe-pcr 方法: PCR 索引，为空时读取全部 PCR
+	PCRIndices []uint16 `json:"pcr_indices,omitempty"`
+	// lock-pcrs 方法: 锁定 PCR0 到 PCR(pcr_range-1)；lock-pcr 方法使用 pcr_index
+	PCRRange uint16 `json:"pcr_range,omitempty"`
+	// attest-batch 方法: 在同一请求中处理的多个 attest 请求
+	Batch []CommandArgs `json:"batch,omitempty"`
+	// file-push、file-pull 方法: --file-root 下的相对路径及本块的偏移；
+	// file-push 的数据在 data_b64 中，最后一块 final 为 true 并携带整个文件的 SHA-256 (十六进制)，
+	// file-pull 每块最多读取 length 字节
+	Path   string `json:"path,omitempty"`
+	Offset int64  `json:"offset,omitempty"`
+	Final  bool   `json:"final,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	// set-time 方法: 主机的当前时间 (Unix 纳秒) 及可选的时间证明 (时间机构签名的 JWT)
+	TimeUnixNano int64  `json:"time_unix_nano,omitempty"`
+	TimeProof    string `json:"time_proof,omitempty"`
+	// get-secret 方法: Secrets Manager 密钥 ID (名称或 ARN)、要求的 KMS 密钥、区域及 Enclave 内的句柄 (默认为 secret_id)
+	SecretID string `json:"secret_id,omitempty"`
+	KeyID    string `json:"key_id,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Handle   string `json:"handle,omitempty"`
+	// get-parameter 方法: SSM SecureString 参数名称或 ARN，key_id、region、handle 与 get-secret 相同
+	Parameter string `json:"parameter,omitempty"`
+	// kms-sign 方法: 签名算法 (如 ECDSA_SHA_256)，data_b64 为消息，digest 为 true 时 data_b64 已是摘要；key_id、region 与 get-secret 相同
+	SigningAlgorithm string `json:"signing_algorithm,omitempty"`
+	Digest           bool   `json:"digest,omitempty"`
+	// decrypt-object 方法: 由 Enclave 读取的对象 (s3://BUCKET/KEY)；或由主机以 handle、offset、data_b64、final 分块上传密文，
+	// 第一块的 envelope 为对象元数据 (x-amz-key-v2 等) 的 JSON；region 与 get-secret 相同
+	S3URI    string `json:"s3_uri,omitempty"`
+	Envelope string `json:"envelope,omitempty"`
+	// session 方法: session-open 返回的会话 ID 及本请求的序号 (从 0 开始逐个递增)，data_b64 为加密的请求
+	Session  string `json:"session,omitempty"`
+	Sequence uint64 `json:"sequence,omitempty"`
 }
 
 // 响应结构
 type Response struct {
 	Success      bool   `json:"success"`
+	ErrorCode    string `json:"error_code,omitempty"`
 	ErrorMessage string `json:"error_message,omitempty"`
 	Document     string `json:"document,omitempty"`
+	Token        string `json:"token,omitempty"`
+	Version      string `json:"version,omitempty"`
+	// 响应使用的 schema 版本，与请求的版本相同
+	SchemaVersion int `json:"schema_version,omitempty"`
+	// 请求带 traceparent 时 Enclave 内记录的 span
+	Trace []TraceSpan `json:"trace,omitempty"`
+	// describe-nsm 方法的结果
+	NSM *NSMDescription `json:"nsm,omitempty"`
+	// get-random 方法的随机数
+	Random []byte `json:"random,omitempty"`
+	// describe-pcr、extend-pcr、lock-pcr(s) 方法的结果: PCR 索引 → 锁定状态及值
+	PCRs map[uint16]PCRState `json:"pcrs,omitempty"`
+	// attest-batch 方法的结果，与请求中的 batch 一一对应
+	Batch []Response `json:"batch,omitempty"`
+	// attest 方法: 证据类型 (如 aws-nitro)，客户端据此选择校验器
+	EvidenceType string `json:"evidence_type,omitempty"`
+	// file-push、file-pull 方法的结果
+	File *FileChunk `json:"file,omitempty"`
+	// set-time 方法的结果
+	Time *TimeStatus `json:"time,omitempty"`
+	// get-secret、get-parameter 方法的结果
+	Secret *SecretHandle `json:"secret,omitempty"`
+	// kms-sign 方法的结果，document 为绑定签名的证明文档
+	Signature *KMSSignature `json:"signature,omitempty"`
+	// decrypt-object 方法的结果
+	Object *ObjectResult `json:"object,omitempty"`
+	// age-unwrap 方法解出的 age 文件密钥
+	FileKey []byte `json:"file_key,omitempty"`
+	// session-open 方法建立的会话，document 为绑定会话公钥的证明文档
+	Session *SessionInfo `json:"session,omitempty"`
+	// session 方法加密的响应
+	Sealed []byte `json:"sealed,omitempty"`
 }
 
+// 服务器版本，构建时通过 -ldflags "-X main.version=..." 设置
+var version = "dev"
+
+// 错误码，error_message 为对应的说明 - 与 client 端匹配
+const (
+	// 请求超过 --max-request-size
+	errCodeRequestTooLarge = "REQUEST_TOO_LARGE"
+	// 请求或握手帧无法解析
+	errCodeParseError = "PARSE_ERROR"
+	// 请求参数无效
+	errCodeBadRequest = "BAD_REQUEST"
+	// 不支持的请求方法
+	errCodeUnsupportedMethod = "UNSUPPORTED_METHOD"
+	// public_key 不是有效的 base64 或超过 NSM 长度限制
+	errCodeInvalidPublicKey = "INVALID_PUBLIC_KEY"
+	// 对端未通过认证或方法未启用
+	errCodeUnauthorized = "UNAUTHORIZED"
+	// 无法打开或调用 NSM 设备
+	errCodeNSMUnavailable = "NSM_UNAVAILABLE"
+	// NSM 拒绝了请求，如 PCR 已锁定
+	errCodeNSMError = "NSM_ERROR"
+	// 等待握手或请求超时
+	errCodeTimeout = "TIMEOUT"
+	// 服务器内部错误
+	errCodeInternal = "INTERNAL_ERROR"
+	// 请求的 schema_version 高于服务器支持的版本
+	errCodeUnsupportedSchema = "UNSUPPORTED_SCHEMA_VERSION"
+)
+
+// NSM 公钥的最大长度
+const maxPublicKeySize = 1024
+
+// NSM user_data 和 nonce 的最大长度
+const maxUserDataSize = 512
+
 // 处理客户端连接
 func handleClient(conn net.Conn) {
 	defer conn.Close()
+	// 握手阶段的 panic 无法确定对端协议，只记录并关闭连接
+	defer recoverConnection(nil)
 	log.Println("接收到新的客户端连接")
 
-	// 读取客户端发送的参数
-	buffer := make([]byte, 4096)
-	n, err := conn.Read(buffer)
+	// 限制等待握手或请求的时间，防止空闲连接长期占用
+	conn.SetReadDeadline(time.Now().Add(config.HandshakeTimeout))
+
+	// 旧版客户端直接发送 JSON，新版客户端以帧协议握手开始
+	reader := bufio.NewReaderSize(conn, 4096)
+	first, err := reader.Peek(1)
 	if err != nil {
 		log.Printf("读取客户端数据失败: %v\n", err)
-		sendErrorResponse(conn, fmt.Sprintf("读取客户端数据失败: %v", err))
-		return
-	}
-
-	// 解析参数
-	var args CommandArgs
-	if err := json.Unmarshal(buffer[:n], &args); err != nil {
-		log.Printf("解析参数失败: %v\n", err)
-		sendErrorResponse(conn, fmt.Sprintf("解析参数失败: %v", err))
 		return
 	}
-
-	// 使用 nsm-cli 生成证明文档
-	cmdArgs := []string{"attest"}
-	
-	if args.UserData != "" {
-		// 直接使用 --user-data 参数，不进行 Base64 编码
-		cmdArgs = append(cmdArgs, "--user-data", args.UserData)
-	}
-	
-	if args.PublicKey != "" {
-		// 创建临时文件存储公钥
-		tmpFile, err := os.CreateTemp("", "pubkey-*.der")
-		if err != nil {
-			log.Printf("创建临时公钥文件失败: %v\n", err)
-			sendErrorResponse(conn, fmt.Sprintf("创建临时公钥文件失败: %v", err))
+	authErr := checkPeer(conn.RemoteAddr())
+	switch first[0] {
+	case '{':
+		if authErr != nil {
+			log.Printf("拒绝连接: %v\n", authErr)
+			sendResponse(conn, Response{ErrorCode: errCodeUnauthorized, ErrorMessage: authErr.Error()})
 			return
 		}
-		defer os.Remove(tmpFile.Name())
-		
-		// 解码 Base64 编码的公钥
-		pubKeyData, err := base64.StdEncoding.DecodeString(args.PublicKey)
-		if err != nil {
-			log.Printf("解码公钥失败: %v\n", err)
-			sendErrorResponse(conn, fmt.Sprintf("解码公钥失败: %v", err))
+		// 旧版协议没有握手，无法完成 HMAC 认证或建立 Noise 通道
+		if hmacKey != nil {
+			log.Println("拒绝连接: 服务器要求 HMAC 认证，不接受旧版 JSON 请求")
+			sendResponse(conn, Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "服务器要求 HMAC 认证，请使用帧协议"})
 			return
 		}
-		
-		if _, err := tmpFile.Write(pubKeyData); err != nil {
-			log.Printf("写入公钥文件失败: %v\n", err)
-			sendErrorResponse(conn, fmt.Sprintf("写入公钥文件失败: %v", err))
+		if config.RequireNoise || noiseClientKeys != nil {
+			log.Println("拒绝连接: 服务器要求 Noise 加密通道，不接受旧版 JSON 请求")
+			sendResponse(conn, Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "服务器要求 Noise 加密通道，请使用帧协议"})
 			return
 		}
-		
-		if err := tmpFile.Close(); err != nil {
-			log.Printf("关闭公钥文件失败: %v\n", err)
-			sendErrorResponse(conn, fmt.Sprintf("关闭公钥文件失败: %v", err))
+	case 0:
+		// 握手帧长度远小于 16MB，长度前缀首字节必为 0
+		handleFramedClient(&bufferedConn{Conn: conn, reader: reader}, authErr)
+		return
+	default:
+		log.Printf("无法识别的协议数据 (首字节 0x%02x)，关闭连接\n", first[0])
+		return
+	}
+
+	// 读取并解析客户端发送的参数，超过上限时返回结构化错误
+	counter := &countingReader{r: io.LimitReader(reader, int64(config.MaxRequestSize)+1)}
+	var args CommandArgs
+	if err := json.NewDecoder(counter).Decode(&args); err != nil {
+		if counter.n > int64(config.MaxRequestSize) {
+			log.Printf("请求超过 %d 字节上限\n", config.MaxRequestSize)
+			sendResponse(conn, requestTooLargeResponse())
 			return
 		}
-		
-		cmdArgs = append(cmdArgs, "--public-key", tmpFile.Name())
-	}
-	
-	if args.Nonce != "" {
-		// 直接使用 --nonce 参数，不进行 Base64 编码
-		cmdArgs = append(cmdArgs, "--nonce", args.Nonce)
-	}
-	
-	log.Printf("执行命令: nsm-cli %s\n", strings.Join(cmdArgs, " "))
-	
-	cmd := exec.Command("nsm-cli", cmdArgs...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		log.Printf("执行 nsm-cli attest 失败: %v\n输出: %s\n", err, string(output))
-		sendErrorResponse(conn, fmt.Sprintf("执行 nsm-cli attest 失败: %v", err))
+		if isTimeout(err) {
+			log.Printf("等待请求超时: %v\n", err)
+			sendResponse(conn, Response{ErrorCode: errCodeTimeout, ErrorMessage: "等待请求超时"})
+			return
+		}
+		log.Printf("解析参数失败: %v\n", err)
+		sendResponse(conn, Response{ErrorCode: errCodeParseError, ErrorMessage: fmt.Sprintf("解析参数失败: %v", err)})
 		return
 	}
+	conn.SetReadDeadline(time.Time{})
 
-	// 准备响应
-	response := Response{
-		Success:  true,
-		Document: string(output),
-	}
+	defer recoverConnection(func(r Response) { sendResponse(conn, r) })
+	response := handleRequest(args)
+	auditRequest(conn.RemoteAddr().String(), args, response)
 
 	// 序列化响应
 	responseJSON, err := json.Marshal(response)
 	if err != nil {
 		log.Printf("序列化响应失败: %v\n", err)
-		sendErrorResponse(conn, fmt.Sprintf("序列化响应失败: %v", err))
+		sendErrorResponse(conn, errCodeInternal, fmt.Sprintf("序列化响应失败: %v", err))
 		return
 	}
 
@@ -130,16 +240,99 @@ func handleClient(conn net.Conn) {
 		return
 	}
 
-	log.Println("已成功发送证明文档")
+	if response.Success {
+		log.Println("已成功发送证明文档")
+	}
 }
 
-// 发送错误响应
-func sendErrorResponse(conn net.Conn, errorMessage string) {
-	response := Response{
+// 处理单个请求，通过 --attester 选择的证明后端生成证明文档
+// 公钥等输入直接在内存中交给 NSM，不写入临时文件
+func processRequest(args CommandArgs) Response {
+	if args.UserData != "" && args.UserDataB64 != "" {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "user_data 和 user_data_b64 不能同时指定"}
+	}
+	if args.Nonce != "" && args.NonceB64 != "" {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "nonce 和 nonce_b64 不能同时指定"}
+	}
+
+	// user_data 和 nonce 直接使用原始字符串，*_b64 解码后将原始字节交给 NSM
+	userData := []byte(args.UserData)
+	if args.UserDataB64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(args.UserDataB64)
+		if err != nil {
+			return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 user_data_b64 失败: %v", err)}
+		}
+		userData = decoded
+	}
+	nonce := []byte(args.Nonce)
+	if args.NonceB64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(args.NonceB64)
+		if err != nil {
+			return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 nonce_b64 失败: %v", err)}
+		}
+		nonce = decoded
+	}
+	if hasUserDataClaims() {
+		wrapped, err := withUserDataClaims(userData)
+		if err != nil {
+			return errorResponse(errCodeInternal, fmt.Sprintf("编码 user_data 声明失败: %v", err))
+		}
+		userData = wrapped
+	}
+	publicKey, err := base64.StdEncoding.DecodeString(args.PublicKey)
+	if err != nil {
+		log.Printf("解码公钥失败: %v\n", err)
+		return errorResponse(errCodeInvalidPublicKey, fmt.Sprintf("解码公钥失败: %v", err))
+	}
+
+	attester, err := currentAttester()
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	maxUserData, maxPublicKey := attester.Limits()
+	if len(publicKey) > maxPublicKey {
+		return errorResponse(errCodeInvalidPublicKey, fmt.Sprintf("公钥超过 NSM 长度限制 %d 字节", maxPublicKey))
+	}
+	if len(userData) > maxUserData || len(nonce) > maxUserData {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "user_data 或 nonce 超过 NSM 长度限制"}
+	}
+
+	log.Printf("请求 NSM 生成证明文档 (user_data %d 字节, public_key %d 字节, nonce %d 字节)\n", len(userData), len(publicKey), len(nonce))
+	document, err := attester.Attest(userData, publicKey, nonce)
+	if err != nil {
+		log.Printf("NSM 生成证明文档失败: %v\n", err)
+		return nsmErrorResponse(fmt.Errorf("NSM 生成证明文档失败: %w", err))
+	}
+
+	return Response{
+		Success:      true,
+		Document:     base64.StdEncoding.EncodeToString(document),
+		EvidenceType: attester.Type(),
+	}
+}
+
+// 构造错误响应
+func errorResponse(errorCode string, errorMessage string) Response {
+	return Response{
 		Success:      false,
+		ErrorCode:    errorCode,
 		ErrorMessage: errorMessage,
 	}
+}
+
+// 发送错误响应
+func sendErrorResponse(conn net.Conn, errorCode string, errorMessage string) {
+	sendResponse(conn, errorResponse(errorCode, errorMessage))
+}
 
+// 是否为读写超时
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// 以旧版协议发送响应
+func sendResponse(conn net.Conn, response Response) {
 	responseJSON, err := json.Marshal(response)
 	if err != nil {
 		log.Printf("序列化错误响应失败: %v\n", err)
@@ -152,17 +345,61 @@ func sendErrorResponse(conn net.Conn, errorMessage string) {
 	}
 }
 
-// 启动 vsock 服务器
+// 检查对端 CID/端口是否被允许访问
+func checkPeer(addr net.Addr) error {
+	if len(config.AllowedPeers) == 0 {
+		return nil
+	}
+	// Unix 套接字的访问由文件权限控制
+	if _, ok := addr.(*net.UnixAddr); ok {
+		return nil
+	}
+
+	vsockAddr, ok := addr.(*vsock.Addr)
+	if !ok {
+		return fmt.Errorf("不支持的对端地址类型: %v", addr)
+	}
+	if !config.AllowedPeers.allows(vsockAddr.ContextID, vsockAddr.Port) {
+		return fmt.Errorf("对端 CID %d 端口 %d 不在允许列表中", vsockAddr.ContextID, vsockAddr.Port)
+	}
+	return nil
+}
+
+// 请求过大的结构化错误
+func requestTooLargeResponse() Response {
+	return Response{
+		ErrorCode:    errCodeRequestTooLarge,
+		ErrorMessage: fmt.Sprintf("请求超过 %d 字节上限", config.MaxRequestSize),
+	}
+}
+
+// 统计已读取字节数的 Reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// 启动 vsock 服务器 (或 --listen 指定的 TCP/Unix 套接字服务器)
 func startVsockServer() {
 	log.Println("启动 vsock 服务器...")
 
-	listener, err := vsock.Listen(uint32(vsockPort), nil)
+	listener, err := listen(config.Listen, config.Port)
 	if err != nil {
-		log.Fatalf("无法创建 vsock 监听器: %v", err)
+		log.Fatalf("无法创建监听器: %v", err)
 	}
 	defer listener.Close()
 
-	log.Printf("vsock 服务器已启动，监听端口 %d\n", vsockPort)
+	if config.Listen != "" {
+		log.Printf("服务器已启动，监听 %s\n", config.Listen)
+	} else {
+		log.Printf("vsock 服务器已启动，监听端口 %d\n", config.Port)
+	}
 
 	for {
 		conn, err := listener.Accept()
@@ -178,81 +415,145 @@ func startVsockServer() {
 
 // CLI 命令实现
 func describeNSM() {
-	fmt.Println("NSM 描述功能在当前版本的 nsm-cli 中不可用")
-}
-
-func getRandom() {
-	cmd := exec.Command("nsm-cli", "get-random", "--length", "256")
-	output, err := cmd.Output()
+	description, err := describeNSMDevice()
 	if err != nil {
-		fmt.Printf("执行 nsm-cli get-random 失败: %v\n", err)
-		return
+		fmt.Printf("查询 NSM 描述失败: %v\n", err)
+		os.Exit(1)
 	}
+	output, _ := json.MarshalIndent(description, "", "  ")
 	fmt.Println(string(output))
 }
 
-func describePCR(index uint16) {
-	cmd := exec.Command("nsm-cli", "describe-pcr", "--index", fmt.Sprintf("%d", index))
-	output, err := cmd.Output()
+func getRandom(length int, format string, output string) {
+	if length <= 0 {
+		fmt.Println("--length 必须大于 0")
+		os.Exit(1)
+	}
+	random, err := nsmGetRandom(length)
 	if err != nil {
-		fmt.Printf("执行 nsm-cli describe-pcr 失败: %v\n", err)
+		fmt.Printf("获取随机数失败: %v\n", err)
+		os.Exit(1)
+	}
+	data, err := encodeRandom(random, format)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if output != "" {
+		if err := os.WriteFile(output, data, 0600); err != nil {
+			fmt.Printf("写入随机数失败: %v\n", err)
+			os.Exit(1)
+		}
 		return
 	}
-	fmt.Println(string(output))
+	os.Stdout.Write(data)
 }
 
-func generateAttestation(userData string, publicKey string, nonce string) {
-	args := []string{"attest"}
-	
-	if userData != "" {
-		args = append(args, "--user-data", userData)
-	}
-	
-	if publicKey != "" {
-		// 创建临时文件存储公钥
-		tmpFile, err := os.CreateTemp("", "pubkey-*.der")
+// 读取 PCR 并以 JSON 输出，all 为 true 时读取 DescribeNSM 报告的全部 PCR
+func describePCR(indexList string, all bool) {
+	var indices []uint16
+	switch {
+	case all:
+		description, err := describeNSMDevice()
 		if err != nil {
-			fmt.Printf("创建临时公钥文件失败: %v\n", err)
-			return
+			fmt.Printf("查询 NSM 描述失败: %v\n", err)
+			os.Exit(1)
+		}
+		for i := uint16(0); i < description.MaxPCRs; i++ {
+			indices = append(indices, i)
+		}
+	case indexList != "":
+		var err error
+		if indices, err = parsePCRIndices(indexList); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		defer os.Remove(tmpFile.Name())
-		
-		// 解码 Base64 编码的公钥
-		pubKeyData, err := base64.StdEncoding.DecodeString(publicKey)
+	default:
+		fmt.Println("必须指定 --index 或 --all")
+		os.Exit(1)
+	}
+
+	states := make([]*PCRState, len(indices))
+	for i, index := range indices {
+		state, err := nsmDescribePCR(index)
 		if err != nil {
-			fmt.Printf("解码公钥失败: %v\n", err)
-			return
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		
-		if _, err := tmpFile.Write(pubKeyData); err != nil {
-			fmt.Printf("写入公钥文件失败: %v\n", err)
-			return
+		states[i] = state
+	}
+	output, err := marshalPCRStates(indices, states)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println(string(output))
+}
+
+// 扩展用户 PCR，file 非空时扩展文件的 SHA-384 摘要，否则扩展 data
+func extendPCR(index uint16, data string, file string) {
+	measurement := []byte(data)
+	if file != "" {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("读取文件失败: %v\n", err)
+			os.Exit(1)
 		}
-		
-		if err := tmpFile.Close(); err != nil {
-			fmt.Printf("关闭公钥文件失败: %v\n", err)
-			return
+		sum := sha512.Sum384(content)
+		measurement = sum[:]
+	}
+	if len(measurement) == 0 {
+		fmt.Println("必须指定 --data 或 --file")
+		os.Exit(1)
+	}
+
+	value, err := nsmExtendPCR(index, measurement)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	output, err := marshalPCRStates([]uint16{index}, []*PCRState{{Value: hex.EncodeToString(value)}})
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	fmt.Println(string(output))
+}
+
+// 锁定 PCR 并输出锁定后的状态，first 到 end (不含) 为锁定的范围
+func lockPCRs(first uint16, end uint16, lock func() error) {
+	if err := lock(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	var indices []uint16
+	var states []*PCRState
+	for index := first; index < end; index++ {
+		state, err := nsmDescribePCR(index)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
 		}
-		
-		args = append(args, "--public-key", tmpFile.Name())
-	}
-	
-	if nonce != "" {
-		args = append(args, "--nonce", nonce)
-	}
-	
-	fmt.Printf("执行命令: nsm-cli %s\n", strings.Join(args, " "))
-	
-	cmd := exec.Command("nsm-cli", args...)
-	output, err := cmd.CombinedOutput()
+		indices = append(indices, index)
+		states = append(states, state)
+	}
+	output, err := marshalPCRStates(indices, states)
 	if err != nil {
-		fmt.Printf("执行 nsm-cli attest 失败: %v\n输出: %s\n", err, string(output))
-		return
+		fmt.Println(err)
+		os.Exit(1)
 	}
-	
 	fmt.Println(string(output))
 }
 
+func generateAttestation(userData string, publicKey string, nonce string) {
+	response := processRequest(CommandArgs{UserData: userData, PublicKey: publicKey, Nonce: nonce})
+	if !response.Success {
+		fmt.Printf("生成证明文档失败: %s\n", response.ErrorMessage)
+		os.Exit(1)
+	}
+	fmt.Println(response.Document)
+}
+
 // 设置 CLI 命令
 func setupCLI() *cobra.Command {
 	rootCmd := &cobra.Command{
@@ -274,26 +575,80 @@ func setupCLI() *cobra.Command {
 	// Add get-random subcommand
 	getRandomCmd := &cobra.Command{
 		Use:   "get-random",
-		Short: "Returns 256 bytes of pseudo-random numbers (entropy)",
+		Short: "Returns pseudo-random numbers (entropy) from the NSM",
 		Run: func(cmd *cobra.Command, args []string) {
-			getRandom()
+			length, _ := cmd.Flags().GetInt("length")
+			format, _ := cmd.Flags().GetString("format")
+			output, _ := cmd.Flags().GetString("output")
+			getRandom(length, format, output)
 		},
 	}
+	getRandomCmd.Flags().IntP("length", "l", nsmMaxRandomSize, "Number of random bytes, fetched in multiple NSM calls if needed")
+	getRandomCmd.Flags().StringP("format", "f", randomFormatHex, "Output encoding (hex, base64 or raw)")
+	getRandomCmd.Flags().StringP("output", "o", "", "Write the random bytes to this file instead of stdout")
 	rootCmd.AddCommand(getRandomCmd)
 
 	// Add describe-pcr subcommand
 	describePCRCmd := &cobra.Command{
 		Use:   "describe-pcr",
-		Short: "Read data from PlatformConfigurationRegister at some index",
+		Short: "Read lock state and data of PlatformConfigurationRegisters as JSON",
 		Run: func(cmd *cobra.Command, args []string) {
-			index, _ := cmd.Flags().GetInt("index")
-			describePCR(uint16(index))
+			index, _ := cmd.Flags().GetString("index")
+			all, _ := cmd.Flags().GetBool("all")
+			describePCR(index, all)
 		},
 	}
-	describePCRCmd.Flags().IntP("index", "i", 0, "The PCR index (0..n)")
-	describePCRCmd.MarkFlagRequired("index")
+	describePCRCmd.Flags().StringP("index", "i", "", "PCR indices, e.g. 0, 0,1,2,8 or 16-19")
+	describePCRCmd.Flags().BoolP("all", "a", false, "Read every PCR reported by describe-nsm")
 	rootCmd.AddCommand(describePCRCmd)
 
+	// Add extend-pcr subcommand
+	extendPCRCmd := &cobra.Command{
+		Use:   "extend-pcr",
+		Short: "Extend a user PlatformConfigurationRegister (16+) with data or a file's SHA-384 digest",
+		Run: func(cmd *cobra.Command, args []string) {
+			index, _ := cmd.Flags().GetUint16("index")
+			data, _ := cmd.Flags().GetString("data")
+			file, _ := cmd.Flags().GetString("file")
+			extendPCR(index, data, file)
+		},
+	}
+	extendPCRCmd.Flags().Uint16P("index", "i", 0, "The PCR index (16..n)")
+	extendPCRCmd.Flags().StringP("data", "d", "", "Data to extend the PCR with")
+	extendPCRCmd.Flags().StringP("file", "f", "", "Extend the PCR with the SHA-384 digest of this file")
+	extendPCRCmd.MarkFlagRequired("index")
+	rootCmd.AddCommand(extendPCRCmd)
+
+	// Add lock-pcr subcommand
+	lockPCRCmd := &cobra.Command{
+		Use:   "lock-pcr",
+		Short: "Lock a PlatformConfigurationRegister so it can no longer be extended",
+		Run: func(cmd *cobra.Command, args []string) {
+			index, _ := cmd.Flags().GetUint16("index")
+			lockPCRs(index, index+1, func() error { return nsmLockPCR(index) })
+		},
+	}
+	lockPCRCmd.Flags().Uint16P("index", "i", 0, "The PCR index (0..n)")
+	lockPCRCmd.MarkFlagRequired("index")
+	rootCmd.AddCommand(lockPCRCmd)
+
+	// Add lock-pcrs subcommand
+	lockPCRsCmd := &cobra.Command{
+		Use:   "lock-pcrs",
+		Short: "Lock PlatformConfigurationRegisters 0 to range-1",
+		Run: func(cmd *cobra.Command, args []string) {
+			pcrRange, _ := cmd.Flags().GetUint16("range")
+			if pcrRange == 0 {
+				fmt.Println("--range 必须大于 0")
+				os.Exit(1)
+			}
+			lockPCRs(0, pcrRange, func() error { return nsmLockPCRs(pcrRange) })
+		},
+	}
+	lockPCRsCmd.Flags().Uint16P("range", "r", 0, "Lock PCRs 0..range-1")
+	lockPCRsCmd.MarkFlagRequired("range")
+	rootCmd.AddCommand(lockPCRsCmd)
+
 	// Add attestation subcommand
 	attestationCmd := &cobra.Command{
 		Use:   "attestation",
@@ -318,6 +673,9 @@ func main() {
 	if len(os.Args) > 1 && (os.Args[1] == "describe-nsm" || 
 							os.Args[1] == "get-random" || 
 							os.Args[1] == "describe-pcr" || 
+							os.Args[1] == "extend-pcr" || 
+							os.Args[1] == "lock-pcr" || 
+							os.Args[1] == "lock-pcrs" || 
 							os.Args[1] == "attestation") {
 		rootCmd := setupCLI()
 		if err := rootCmd.Execute(); err != nil {
@@ -328,5 +686,57 @@ func main() {
 	}
 
 	// 否则启动 vsock 服务器
+	if err := parseServerFlags(os.Args[1:]); err != nil {
+		log.Fatalf("解析服务器参数失败: %v", err)
+	}
+	if config.LogForward != "" {
+		if err := startLogForwarder(); err != nil {
+			log.Fatalf("启动日志转发失败: %v", err)
+		}
+	}
+	if config.DNSForward != "" {
+		if err := startDNSForwarder(); err != nil {
+			log.Fatalf("启动 DNS 转发失败: %v", err)
+		}
+		if config.DNSListen != "" {
+			go startDNSStub()
+		}
+	}
+	if len(config.MeasureFiles) > 0 {
+		if err := measureStartupFiles(); err != nil {
+			log.Fatalf("启动测量失败: %v", err)
+		}
+	}
+	if config.RATLSPort != 0 {
+		go startRATLSServer()
+	}
+	if config.PprofListen != "" {
+		go startPprofServer()
+	}
+	if config.UnixSocket != "" {
+		go startUnixServer()
+	}
+	if config.HTTPListen != "" {
+		go startLocalHTTPServer()
+	}
+	if config.IMDSForward != "" {
+		if err := startLocalIMDS(); err != nil {
+			log.Fatalf("启动 IMDS 端点失败: %v", err)
+		}
+	}
+	if config.ACMCert != "" {
+		if err := startACMCertificate(); err != nil {
+			log.Fatalf("读取 ACM 证书失败: %v", err)
+		}
+	}
+	if len(config.App) > 0 {
+		go func() {
+			env, err := loadSSMEnv()
+			if err != nil {
+				log.Fatalf("读取 SSM 参数失败: %v", err)
+			}
+			superviseApplication(append(env, acmEnv()...))
+		}()
+	}
 	startVsockServer()
 }
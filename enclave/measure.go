@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// 可重复指定的文件列表参数，也可以逗号分隔
+type fileList []string
+
+func (l *fileList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *fileList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// 启动时依次将 --measure 文件的 SHA-384 摘要扩展到 --measure-pcr，
+// 并按 --measure-lock 锁定该 PCR，使测量值出现在之后的每份证明文档中
+// 任一文件读取或扩展失败时返回错误，服务器不应在测量不完整时启动
+func measureStartupFiles() error {
+	if config.MeasurePCR < firstUserPCR || config.MeasurePCR > 0xffff {
+		return fmt.Errorf("--measure-pcr 必须是 PCR%d 及以上的用户 PCR", firstUserPCR)
+	}
+	index := uint16(config.MeasurePCR)
+
+	var value []byte
+	for _, path := range config.MeasureFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("读取测量文件失败: %v", err)
+		}
+		digest := sha512.Sum384(content)
+		if value, err = nsmExtendPCR(index, digest[:]); err != nil {
+			return err
+		}
+		log.Printf("已将 %s (SHA-384 %s) 扩展到 PCR%d\n", path, hex.EncodeToString(digest[:]), index)
+	}
+
+	if config.MeasureLock {
+		if err := nsmLockPCR(index); err != nil {
+			return err
+		}
+		log.Printf("PCR%d 已锁定: %s\n", index, hex.EncodeToString(value))
+	} else {
+		log.Printf("PCR%d: %s\n", index, hex.EncodeToString(value))
+	}
+	return nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 模拟 NSM: 在没有 Nitro 硬件的开发环境中生成结构合法、由开发 CA 签名的证明文档
+// 文档不具备任何安全意义，校验端需通过 --root-cert 显式信任开发 CA
+type mockNSM struct {
+	caCert *x509.Certificate
+	caKey  *ecdsa.PrivateKey
+
+	moduleID string
+
+	// PCR 值及锁定状态，extend-pcr、lock-pcr 会修改
+	mu     sync.Mutex
+	pcrs   map[int][]byte
+	locked map[int]bool
+}
+
+// 模拟 NSM 的 PCR 数量，与 Nitro 相同
+const mockPCRCount = 32
+
+var (
+	mockNSMOnce sync.Once
+	mockNSMVal  *mockNSM
+	mockNSMErr  error
+)
+
+// 模拟 NSM 的证明文档载荷
+type mockDocument struct {
+	ModuleID    string         `cbor:"module_id"`
+	Digest      string         `cbor:"digest"`
+	Timestamp   uint64         `cbor:"timestamp"`
+	PCRs        map[int][]byte `cbor:"pcrs"`
+	Certificate []byte         `cbor:"certificate"`
+	CABundle    [][]byte       `cbor:"cabundle"`
+	PublicKey   []byte         `cbor:"public_key"`
+	UserData    []byte         `cbor:"user_data"`
+	Nonce       []byte         `cbor:"nonce"`
+}
+
+func getMockNSM() (*mockNSM, error) {
+	mockNSMOnce.Do(func() {
+		mockNSMVal, mockNSMErr = newMockNSM(config.MockCACert, config.MockCAKey)
+	})
+	if mockNSMErr != nil {
+		return nil, fmt.Errorf("%w: %v", errNSMUnavailable, mockNSMErr)
+	}
+	return mockNSMVal, nil
+}
+
+// 加载开发 CA，文件不存在时生成并写入，便于 host 端通过 --root-cert 信任
+func newMockNSM(certPath, keyPath string) (*mockNSM, error) {
+	caCert, caKey, err := loadMockCA(certPath, keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		caCert, caKey, err = createMockCA(certPath, keyPath)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// PCR0/1/2/3/4/8 使用固定的非零值，便于在策略中配置；其余 PCR 为零
+	// 与 Nitro 相同，启动时测量的 PCR0-15 已锁定，PCR16 及以上可由应用扩展
+	pcrs := make(map[int][]byte)
+	locked := make(map[int]bool)
+	for i := 0; i < mockPCRCount; i++ {
+		pcrs[i] = make([]byte, sha512.Size384)
+		locked[i] = i < firstUserPCR
+	}
+	for _, i := range []int{0, 1, 2, 3, 4, 8} {
+		sum := sha512.Sum384([]byte("mock-nsm-pcr" + strconv.Itoa(i)))
+		pcrs[i] = sum[:]
+	}
+
+	suffix := make([]byte, 8)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, err
+	}
+
+	log.Printf("警告: 使用模拟 NSM，证明文档由开发 CA %s 签名，仅用于开发测试\n", certPath)
+	return &mockNSM{
+		caCert:   caCert,
+		caKey:    caKey,
+		moduleID: "i-mock-enc" + hex.EncodeToString(suffix),
+		pcrs:     pcrs,
+		locked:   locked,
+	}, nil
+}
+
+// 模拟 NSM 的描述
+func (m *mockNSM) DescribeNSM() (*NSMDescription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	locked := make([]uint16, 0, mockPCRCount)
+	for i := 0; i < mockPCRCount; i++ {
+		if m.locked[i] {
+			locked = append(locked, uint16(i))
+		}
+	}
+	return &NSMDescription{
+		ModuleID:     m.moduleID,
+		VersionMajor: 1,
+		MaxPCRs:      mockPCRCount,
+		LockedPCRs:   locked,
+		Digest:       "SHA384",
+	}, nil
+}
+
+// 读取模拟 NSM 的 PCR
+func (m *mockNSM) DescribePCR(index uint16) (*PCRState, error) {
+	if index >= mockPCRCount {
+		return nil, nsmError("InvalidIndex")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &PCRState{Locked: m.locked[int(index)], Value: hex.EncodeToString(m.pcrs[int(index)])}, nil
+}
+
+// 与 NSM 相同: 新值 = SHA384(旧值 || data)，已锁定的 PCR 返回 ReadOnlyIndex
+func (m *mockNSM) ExtendPCR(index uint16, data []byte) ([]byte, error) {
+	if index >= mockPCRCount {
+		return nil, nsmError("InvalidIndex")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.locked[int(index)] {
+		return nil, nsmError("ReadOnlyIndex")
+	}
+	h := sha512.New384()
+	h.Write(m.pcrs[int(index)])
+	h.Write(data)
+	m.pcrs[int(index)] = h.Sum(nil)
+	return m.pcrs[int(index)], nil
+}
+
+func (m *mockNSM) LockPCR(index uint16) error {
+	return m.lockPCRs(index, index+1)
+}
+
+func (m *mockNSM) LockPCRs(pcrRange uint16) error {
+	return m.lockPCRs(0, pcrRange)
+}
+
+// 锁定 [first, end) 范围内的 PCR，已锁定的 PCR 保持不变
+func (m *mockNSM) lockPCRs(first uint16, end uint16) error {
+	if end > mockPCRCount || first >= end {
+		return nsmError("InvalidIndex")
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := first; i < end; i++ {
+		m.locked[int(i)] = true
+	}
+	return nil
+}
+
+// 文档中的 PCR: 与 NSM 相同，只包含已锁定的 PCR
+func (m *mockNSM) lockedPCRs() map[int][]byte {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pcrs := make(map[int][]byte)
+	for i, value := range m.pcrs {
+		if m.locked[i] {
+			pcrs[i] = value
+		}
+	}
+	return pcrs
+}
+
+func loadMockCA(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, nil, fmt.Errorf("解析开发 CA 失败")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析开发 CA 证书失败: %v", err)
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析开发 CA 私钥失败: %v", err)
+	}
+	return cert, key, nil
+}
+
+func createMockCA(certPath, keyPath string) (*x509.Certificate, *ecdsa.PrivateKey, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(now.UnixNano()),
+		Subject:               pkix.Name{Organization: []string{"Mock"}, CommonName: "mock.nitro-enclaves"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(10, 0, 0),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("创建开发 CA 证书失败: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		return nil, nil, fmt.Errorf("写入开发 CA 私钥失败: %v", err)
+	}
+	if err := os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644); err != nil {
+		return nil, nil, fmt.Errorf("写入开发 CA 证书失败: %v", err)
+	}
+
+	log.Printf("已生成开发 CA: %s\n", certPath)
+	return cert, key, nil
+}
+
+// 模拟 NSM 的随机数来自系统随机数
+func (m *mockNSM) GetRandom(length int) ([]byte, error) {
+	random := make([]byte, length)
+	if _, err := rand.Read(random); err != nil {
+		return nil, err
+	}
+	return random, nil
+}
+
+// 生成并签名证明文档，返回 COSE_Sign1 原始字节
+func (m *mockNSM) Attest(userData, publicKey, nonce []byte) ([]byte, error) {
+	leafKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	// 与真实 NSM 一样，每份文档使用新的短期签名证书
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(now.UnixNano()),
+		Subject:      pkix.Name{Organization: []string{"Mock"}, CommonName: m.moduleID},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(3 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leaf, err := x509.CreateCertificate(rand.Reader, template, m.caCert, &leafKey.PublicKey, m.caKey)
+	if err != nil {
+		return nil, fmt.Errorf("创建签名证书失败: %v", err)
+	}
+
+	payload, err := cbor.Marshal(mockDocument{
+		ModuleID:    m.moduleID,
+		Digest:      "SHA384",
+		Timestamp:   uint64(now.UnixMilli()),
+		PCRs:        m.lockedPCRs(),
+		Certificate: leaf,
+		CABundle:    [][]byte{m.caCert.Raw},
+		PublicKey:   publicKey,
+		UserData:    userData,
+		Nonce:       nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// COSE_Sign1，受保护头部 {1: -35} 表示 ES384
+	protected, err := cbor.Marshal(map[int]int{1: -35})
+	if err != nil {
+		return nil, err
+	}
+	sigStructure, err := cbor.Marshal([]interface{}{"Signature1", protected, []byte{}, payload})
+	if err != nil {
+		return nil, err
+	}
+	digest := sha512.Sum384(sigStructure)
+	r, s, err := ecdsa.Sign(rand.Reader, leafKey, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature := make([]byte, 96)
+	r.FillBytes(signature[:48])
+	s.FillBytes(signature[48:])
+
+	document, err := cbor.Marshal([]interface{}{protected, map[int]interface{}{}, payload, signature})
+	if err != nil {
+		return nil, err
+	}
+	return document, nil
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bufio"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/flynn/noise"
+)
+
+const (
+	// Noise 握手模式
+	noisePatternNK = "NK"
+	noisePatternXX = "XX"
+
+	// 单条 Noise 消息最大长度 (含 16 字节认证标签)
+	noiseMaxMessageSize = 65535
+	noiseTagSize        = 16
+
+	// 握手前言前缀，与握手帧原文一起绑定到 Noise 会话
+	noisePrologue = "aws-enclave-attestation/noise/v1"
+)
+
+var noiseCipherSuite = noise.NewCipherSuite(noise.DH25519, noise.CipherChaChaPoly, noise.HashSHA256)
+
+// Enclave 的 Noise 静态密钥，首次使用时生成，仅保存在内存中
+var (
+	noiseStaticOnce sync.Once
+	noiseStatic     noise.DHKey
+	noiseStaticErr  error
+)
+
+// 允许的客户端静态公钥，为空时不要求 XX 模式
+var noiseClientKeys map[string]bool
+
+func noiseStaticKey() (noise.DHKey, error) {
+	noiseStaticOnce.Do(func() {
+		noiseStatic, noiseStaticErr = noiseCipherSuite.GenerateKeypair(rand.Reader)
+		if noiseStaticErr == nil {
+			log.Printf("已生成 Noise 静态公钥: %x\n", noiseStatic.Public)
+		}
+	})
+	return noiseStatic, noiseStaticErr
+}
+
+// 从文件加载允许的客户端静态公钥，每行一个十六进制公钥，# 开头为注释
+func loadNoiseClientKeys(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("读取 Noise 客户端公钥文件失败: %v", err)
+	}
+
+	keys := make(map[string]bool)
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, err := hex.DecodeString(line)
+		if err != nil || len(key) != 32 {
+			return fmt.Errorf("无效的 Noise 客户端公钥: %q", line)
+		}
+		keys[string(key)] = true
+	}
+	noiseClientKeys = keys
+	return nil
+}
+
+// 将 X25519 公钥编码为 DER 格式的 SubjectPublicKeyInfo，用作证明文档的 public_key
+func noisePublicKeyDER(public []byte) ([]byte, error) {
+	key, err := ecdh.X25519().NewPublicKey(public)
+	if err != nil {
+		return nil, err
+	}
+	return x509.MarshalPKIXPublicKey(key)
+}
+
+// 生成绑定 Noise 静态公钥和客户端随机数的证明文档
+func attestNoiseKey(public []byte, nonce string) (string, error) {
+	der, err := noisePublicKeyDER(public)
+	if err != nil {
+		return "", fmt.Errorf("编码 Noise 公钥失败: %v", err)
+	}
+
+	response := processRequest(CommandArgs{
+		PublicKey: base64.StdEncoding.EncodeToString(der),
+		Nonce:     nonce,
+	})
+	if !response.Success {
+		return "", fmt.Errorf("%s", response.ErrorMessage)
+	}
+	return response.Document, nil
+}
+
+// 作为响应方完成 Noise 握手，返回加密后的连接
+// prologue 为握手帧和握手响应原文，防止协商参数被篡改
+func noiseServerHandshake(conn net.Conn, pattern string, prologue []byte) (*noiseConn, error) {
+	static, err := noiseStaticKey()
+	if err != nil {
+		return nil, fmt.Errorf("生成 Noise 静态密钥失败: %v", err)
+	}
+
+	var hp noise.HandshakePattern
+	switch pattern {
+	case noisePatternNK:
+		hp = noise.HandshakeNK
+	case noisePatternXX:
+		hp = noise.HandshakeXX
+	default:
+		return nil, fmt.Errorf("不支持的 Noise 握手模式: %s", pattern)
+	}
+
+	hs, err := noise.NewHandshakeState(noise.Config{
+		CipherSuite:   noiseCipherSuite,
+		Random:        rand.Reader,
+		Pattern:       hp,
+		Initiator:     false,
+		Prologue:      append([]byte(noisePrologue), prologue...),
+		StaticKeypair: static,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// NK: -> e, es  <- e, ee
+	// XX: -> e  <- e, ee, s, es  -> s, se
+	msg, err := readFrame(conn, noiseMaxMessageSize)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Noise 握手消息失败: %v", err)
+	}
+	if _, _, _, err := hs.ReadMessage(nil, msg); err != nil {
+		return nil, fmt.Errorf("Noise 握手失败: %v", err)
+	}
+
+	msg, cs1, cs2, err := hs.WriteMessage(nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Noise 握手失败: %v", err)
+	}
+	if err := writeFrame(conn, msg); err != nil {
+		return nil, fmt.Errorf("发送 Noise 握手消息失败: %v", err)
+	}
+
+	if pattern == noisePatternXX {
+		msg, err := readFrame(conn, noiseMaxMessageSize)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Noise 握手消息失败: %v", err)
+		}
+		if _, cs1, cs2, err = hs.ReadMessage(nil, msg); err != nil {
+			return nil, fmt.Errorf("Noise 握手失败: %v", err)
+		}
+
+		peer := hs.PeerStatic()
+		if noiseClientKeys != nil && !noiseClientKeys[string(peer)] {
+			return nil, fmt.Errorf("客户端 Noise 公钥 %x 不在允许列表中", peer)
+		}
+		log.Printf("Noise 客户端公钥: %x\n", peer)
+	}
+
+	// cs1 用于发起方到响应方，cs2 用于响应方到发起方
+	return newNoiseConn(conn, cs2, cs1), nil
+}
+
+// Noise 加密的连接，每条记录为 2 字节长度 + 密文
+type noiseConn struct {
+	net.Conn
+	reader *bufio.Reader
+
+	enc *noise.CipherState
+	dec *noise.CipherState
+
+	writeMu sync.Mutex
+	pending []byte
+}
+
+func newNoiseConn(conn net.Conn, enc *noise.CipherState, dec *noise.CipherState) *noiseConn {
+	return &noiseConn{Conn: conn, reader: bufio.NewReader(conn), enc: enc, dec: dec}
+}
+
+func (c *noiseConn) Read(p []byte) (int, error) {
+	if len(c.pending) == 0 {
+		var header [2]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return 0, err
+		}
+
+		ciphertext := make([]byte, binary.BigEndian.Uint16(header[:]))
+		if _, err := io.ReadFull(c.reader, ciphertext); err != nil {
+			return 0, err
+		}
+
+		plaintext, err := c.dec.Decrypt(nil, nil, ciphertext)
+		if err != nil {
+			return 0, fmt.Errorf("Noise 解密失败: %v", err)
+		}
+		c.pending = plaintext
+	}
+
+	n := copy(p, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+func (c *noiseConn) Write(p []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written := 0
+	for len(p) > 0 {
+		n := len(p)
+		if n > noiseMaxMessageSize-noiseTagSize {
+			n = noiseMaxMessageSize - noiseTagSize
+		}
+
+		record := make([]byte, 2, 2+n+noiseTagSize)
+		record, err := c.enc.Encrypt(record, nil, p[:n])
+		if err != nil {
+			return written, err
+		}
+		binary.BigEndian.PutUint16(record, uint16(len(record)-2))
+		if _, err := c.Conn.Write(record); err != nil {
+			return written, err
+		}
+
+		written += n
+		p = p[n:]
+	}
+	return written, nil
+}
@@ -0,0 +1,508 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"unsafe"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// NSM 驱动设备及请求 ioctl: _IOWR(0x0A, 0, struct nsm_message)
+const (
+	nsmDevicePath   = "/dev/nsm"
+	nsmIoctlRequest = 0xC0200A00
+
+	// NSM 响应的最大长度
+	nsmMaxResponseSize = 0x3000
+
+	// 单次 GetRandom 调用最多返回的字节数
+	nsmMaxRandomSize = 256
+)
+
+// 应用可扩展的第一个 PCR，PCR0-15 由 Nitro 在启动时测量并锁定
+const firstUserPCR = 16
+
+// get-random 一次请求的最大字节数
+const maxRandomLength = 64 * 1024
+
+// ioctl 参数: 请求和响应缓冲区
+type nsmMessage struct {
+	request  syscall.Iovec
+	response syscall.Iovec
+}
+
+// DescribeNSM 的结果 - 与 client 端匹配
+type NSMDescription struct {
+	ModuleID     string   `json:"module_id" cbor:"module_id"`
+	VersionMajor uint16   `json:"version_major" cbor:"version_major"`
+	VersionMinor uint16   `json:"version_minor" cbor:"version_minor"`
+	VersionPatch uint16   `json:"version_patch" cbor:"version_patch"`
+	MaxPCRs      uint16   `json:"max_pcrs" cbor:"max_pcrs"`
+	LockedPCRs   []uint16 `json:"locked_pcrs" cbor:"locked_pcrs"`
+	Digest       string   `json:"digest" cbor:"digest"`
+}
+
+// NSM 返回的错误码，如 InvalidIndex、ReadOnlyIndex
+type nsmError string
+
+func (e nsmError) Error() string {
+	return "NSM 返回错误: " + string(e)
+}
+
+// 无法打开或调用 NSM 设备
+var errNSMUnavailable = errors.New("NSM 不可用")
+
+// NSM 操作失败的响应: 设备不可用时为 NSM_UNAVAILABLE，其余为 NSM_ERROR
+func nsmErrorResponse(err error) Response {
+	if errors.Is(err, errNSMUnavailable) {
+		return errorResponse(errCodeNSMUnavailable, err.Error())
+	}
+	return errorResponse(errCodeNSMError, err.Error())
+}
+
+// NSM 后端，请求处理逻辑只通过该接口访问 NSM，便于在没有 Nitro 硬件的环境中替换实现
+type NSMClient interface {
+	// 生成证明文档，返回 COSE_Sign1 原始字节；空的输入不包含在文档中
+	Attest(userData, publicKey, nonce []byte) ([]byte, error)
+	// 获取 length 字节随机数，超过单次调用上限时由实现循环获取
+	GetRandom(length int) ([]byte, error)
+	DescribePCR(index uint16) (*PCRState, error)
+	DescribeNSM() (*NSMDescription, error)
+	// 扩展 PCR 并返回新值，已锁定的 PCR 返回 nsmError("ReadOnlyIndex")
+	ExtendPCR(index uint16, data []byte) ([]byte, error)
+	LockPCR(index uint16) error
+	// 锁定 PCR0 到 PCR(pcrRange-1)
+	LockPCRs(pcrRange uint16) error
+}
+
+// 使用的 NSM 后端，为 nil 时按 --mock-nsm 选择 /dev/nsm 或模拟 NSM
+var nsmClient NSMClient
+
+func nsmBackend() (NSMClient, error) {
+	if nsmClient != nil {
+		return nsmClient, nil
+	}
+	if config.MockNSM {
+		m, err := getMockNSM()
+		if err != nil {
+			return nil, fmt.Errorf("初始化模拟 NSM 失败: %w", err)
+		}
+		return m, nil
+	}
+	return deviceNSM{}, nil
+}
+
+// 通过 /dev/nsm 的 ioctl 访问 Nitro NSM
+type deviceNSM struct{}
+
+func (deviceNSM) Attest(userData, publicKey, nonce []byte) ([]byte, error) {
+	optional := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		return b
+	}
+	request := map[string]interface{}{"Attestation": map[string][]byte{
+		"user_data":  optional(userData),
+		"nonce":      optional(nonce),
+		"public_key": optional(publicKey),
+	}}
+
+	var result struct {
+		Document []byte `cbor:"document"`
+	}
+	if err := nsmCall(request, "Attestation", &result); err != nil {
+		return nil, err
+	}
+	return result.Document, nil
+}
+
+func (deviceNSM) GetRandom(length int) ([]byte, error) {
+	random := make([]byte, 0, length)
+	for len(random) < length {
+		var result struct {
+			Random []byte `cbor:"random"`
+		}
+		if err := nsmCall("GetRandom", "GetRandom", &result); err != nil {
+			return nil, err
+		}
+		if len(result.Random) == 0 {
+			return nil, errors.New("NSM 未返回随机数")
+		}
+		random = append(random, result.Random...)
+	}
+	return random[:length], nil
+}
+
+func (deviceNSM) DescribePCR(index uint16) (*PCRState, error) {
+	var result struct {
+		Lock bool   `cbor:"lock"`
+		Data []byte `cbor:"data"`
+	}
+	request := map[string]interface{}{"DescribePCR": map[string]uint16{"index": index}}
+	if err := nsmCall(request, "DescribePCR", &result); err != nil {
+		return nil, err
+	}
+	return &PCRState{Locked: result.Lock, Value: hex.EncodeToString(result.Data)}, nil
+}
+
+func (deviceNSM) DescribeNSM() (*NSMDescription, error) {
+	var description NSMDescription
+	if err := nsmCall("DescribeNSM", "DescribeNSM", &description); err != nil {
+		return nil, err
+	}
+	return &description, nil
+}
+
+func (deviceNSM) ExtendPCR(index uint16, data []byte) ([]byte, error) {
+	var result struct {
+		Data []byte `cbor:"data"`
+	}
+	request := map[string]interface{}{"ExtendPCR": map[string]interface{}{"index": index, "data": data}}
+	if err := nsmCall(request, "ExtendPCR", &result); err != nil {
+		return nil, err
+	}
+	return result.Data, nil
+}
+
+func (deviceNSM) LockPCR(index uint16) error {
+	return nsmCall(map[string]interface{}{"LockPCR": map[string]uint16{"index": index}}, "LockPCR", nil)
+}
+
+func (deviceNSM) LockPCRs(pcrRange uint16) error {
+	return nsmCall(map[string]interface{}{"LockPCRs": map[string]uint16{"range": pcrRange}}, "LockPCRs", nil)
+}
+
+// 向 NSM 发送一个 CBOR 编码的请求，将响应中 name 对应的结果解码到 out，out 为 nil 时忽略结果
+// 请求格式与 aws-nitro-enclaves-nsm-api 相同: 无参数请求为字符串，有参数请求为 {名称: 参数}
+func nsmCall(request interface{}, name string, out interface{}) error {
+	payload, err := cbor.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("编码 NSM 请求失败: %v", err)
+	}
+
+	device, err := os.OpenFile(nsmDevicePath, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf("%w: 打开 %s 失败: %v", errNSMUnavailable, nsmDevicePath, err)
+	}
+	defer device.Close()
+
+	response := make([]byte, nsmMaxResponseSize)
+	var msg nsmMessage
+	msg.request.Base = &payload[0]
+	msg.request.SetLen(len(payload))
+	msg.response.Base = &response[0]
+	msg.response.SetLen(len(response))
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, device.Fd(), nsmIoctlRequest, uintptr(unsafe.Pointer(&msg))); errno != 0 {
+		return fmt.Errorf("%w: ioctl 失败: %v", errNSMUnavailable, errno)
+	}
+	response = response[:msg.response.Len]
+
+	// 无结果的响应 (如 LockPCR) 为字符串
+	var unit string
+	if cbor.Unmarshal(response, &unit) == nil && unit == name {
+		return nil
+	}
+
+	var decoded map[string]cbor.RawMessage
+	if err := cbor.Unmarshal(response, &decoded); err != nil {
+		return fmt.Errorf("解析 NSM 响应失败: %v", err)
+	}
+	if raw, ok := decoded["Error"]; ok {
+		var code string
+		cbor.Unmarshal(raw, &code)
+		return nsmError(code)
+	}
+	raw, ok := decoded[name]
+	if !ok {
+		return errors.New("NSM 响应中没有 " + name)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := cbor.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("解析 NSM %s 响应失败: %v", name, err)
+	}
+	return nil
+}
+
+// 请求 NSM 生成证明文档，返回 COSE_Sign1 原始字节；空的输入不包含在文档中
+func nsmAttest(userData []byte, publicKey []byte, nonce []byte) ([]byte, error) {
+	backend, err := nsmBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.Attest(userData, publicKey, nonce)
+}
+
+// 查询 NSM 的版本、模块 ID、PCR 数量及已锁定的 PCR
+func describeNSMDevice() (*NSMDescription, error) {
+	backend, err := nsmBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.DescribeNSM()
+}
+
+// describe-nsm 请求
+func describeNSMRequest() Response {
+	description, err := describeNSMDevice()
+	if err != nil {
+		return nsmErrorResponse(err)
+	}
+	return Response{Success: true, NSM: description}
+}
+
+// 从 NSM 获取 length 字节随机数
+func nsmGetRandom(length int) ([]byte, error) {
+	backend, err := nsmBackend()
+	if err != nil {
+		return nil, err
+	}
+	return backend.GetRandom(length)
+}
+
+// get-random 请求，length 为 0 时返回 NSM 单次调用的字节数
+func getRandomRequest(args CommandArgs) Response {
+	length := args.Length
+	if length == 0 {
+		length = nsmMaxRandomSize
+	}
+	if length < 0 || length > maxRandomLength {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("随机数长度必须在 1 到 %d 之间", maxRandomLength)}
+	}
+	random, err := nsmGetRandom(length)
+	if err != nil {
+		return nsmErrorResponse(err)
+	}
+	return Response{Success: true, Random: random}
+}
+
+// 随机数输出格式
+const (
+	randomFormatHex    = "hex"
+	randomFormatBase64 = "base64"
+	randomFormatRaw    = "raw"
+)
+
+// 按格式编码随机数，文本格式末尾带换行
+func encodeRandom(random []byte, format string) ([]byte, error) {
+	switch format {
+	case randomFormatHex:
+		return []byte(hex.EncodeToString(random) + "\n"), nil
+	case randomFormatBase64:
+		return []byte(base64.StdEncoding.EncodeToString(random) + "\n"), nil
+	case randomFormatRaw:
+		return random, nil
+	default:
+		return nil, fmt.Errorf("不支持的输出格式: %s (可选 hex、base64、raw)", format)
+	}
+}
+
+// 一个 PCR 的锁定状态和值 (十六进制) - 与 client 端匹配
+type PCRState struct {
+	Locked bool   `json:"locked" cbor:"locked"`
+	Value  string `json:"value" cbor:"value"`
+}
+
+// 读取一个 PCR
+func nsmDescribePCR(index uint16) (*PCRState, error) {
+	backend, err := nsmBackend()
+	if err != nil {
+		return nil, err
+	}
+	state, err := backend.DescribePCR(index)
+	if err != nil {
+		return nil, fmt.Errorf("读取 PCR%d 失败: %w", index, err)
+	}
+	return state, nil
+}
+
+// 解析 PCR 索引列表，如 0、0,1,2,8 或 16-19
+func parsePCRIndices(text string) ([]uint16, error) {
+	var indices []uint16
+	for _, part := range strings.Split(text, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.ParseUint(first, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 PCR 索引: %s", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.ParseUint(last, 10, 16); err != nil || end < start {
+				return nil, fmt.Errorf("无效的 PCR 索引范围: %s", part)
+			}
+		}
+		for i := start; i <= end; i++ {
+			indices = append(indices, uint16(i))
+		}
+	}
+	return indices, nil
+}
+
+// 按索引顺序输出 {"索引": {"locked": ..., "value": ...}} 形式的 JSON
+func marshalPCRStates(indices []uint16, states []*PCRState) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, index := range indices {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		state, err := json.Marshal(states[i])
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&buf, "%q:%s", strconv.Itoa(int(index)), state)
+	}
+	buf.WriteByte('}')
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buf.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	return indented.Bytes(), nil
+}
+
+// 扩展用户 PCR: 新值 = SHA384(旧值 || data)，返回新值
+func nsmExtendPCR(index uint16, data []byte) ([]byte, error) {
+	if index < firstUserPCR {
+		return nil, fmt.Errorf("PCR%d 由 Nitro 在启动时测量，只能扩展 PCR%d 及以上", index, firstUserPCR)
+	}
+	backend, err := nsmBackend()
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := backend.ExtendPCR(index, data)
+	var code nsmError
+	if errors.As(err, &code) && code == "ReadOnlyIndex" {
+		return nil, fmt.Errorf("PCR%d 已锁定，无法扩展", index)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("扩展 PCR%d 失败: %w", index, err)
+	}
+	return value, nil
+}
+
+// 锁定一个 PCR，锁定后不能再扩展，且出现在之后的每份证明文档中
+func nsmLockPCR(index uint16) error {
+	backend, err := nsmBackend()
+	if err != nil {
+		return err
+	}
+	if err := backend.LockPCR(index); err != nil {
+		return fmt.Errorf("锁定 PCR%d 失败: %w", index, err)
+	}
+	return nil
+}
+
+// 锁定 PCR0 到 PCR(pcrRange-1)
+func nsmLockPCRs(pcrRange uint16) error {
+	backend, err := nsmBackend()
+	if err != nil {
+		return err
+	}
+	if err := backend.LockPCRs(pcrRange); err != nil {
+		return fmt.Errorf("锁定 PCR0-%d 失败: %w", int(pcrRange)-1, err)
+	}
+	return nil
+}
+
+// 锁定后 PCR 的状态
+func lockedPCRStates(first uint16, end uint16) (map[uint16]PCRState, error) {
+	pcrs := make(map[uint16]PCRState)
+	for index := first; index < end; index++ {
+		state, err := nsmDescribePCR(index)
+		if err != nil {
+			return nil, err
+		}
+		pcrs[index] = *state
+	}
+	return pcrs, nil
+}
+
+// lock-pcr 请求，需以 --allow-lock-pcr 启动
+func lockPCRRequest(args CommandArgs) Response {
+	if !config.AllowLockPCR {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 lock-pcr 方法 (--allow-lock-pcr)"}
+	}
+	if err := nsmLockPCR(args.PCRIndex); err != nil {
+		return nsmErrorResponse(err)
+	}
+	pcrs, err := lockedPCRStates(args.PCRIndex, args.PCRIndex+1)
+	if err != nil {
+		return nsmErrorResponse(err)
+	}
+	return Response{Success: true, PCRs: pcrs}
+}
+
+// lock-pcrs 请求，需以 --allow-lock-pcr 启动
+func lockPCRsRequest(args CommandArgs) Response {
+	if !config.AllowLockPCR {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 lock-pcrs 方法 (--allow-lock-pcr)"}
+	}
+	if args.PCRRange == 0 {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "pcr_range 必须大于 0"}
+	}
+	if err := nsmLockPCRs(args.PCRRange); err != nil {
+		return nsmErrorResponse(err)
+	}
+	pcrs, err := lockedPCRStates(0, args.PCRRange)
+	if err != nil {
+		return nsmErrorResponse(err)
+	}
+	return Response{Success: true, PCRs: pcrs}
+}
+
+// extend-pcr 请求，需以 --allow-extend-pcr 启动
+func extendPCRRequest(args CommandArgs) Response {
+	if !config.AllowExtendPCR {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 extend-pcr 方法 (--allow-extend-pcr)"}
+	}
+	if args.PCRIndex < firstUserPCR {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("只能扩展 PCR%d 及以上", firstUserPCR)}
+	}
+	data, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil || len(data) == 0 {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "data_b64 必须是非空的 base64 数据"}
+	}
+	value, err := nsmExtendPCR(args.PCRIndex, data)
+	if err != nil {
+		return nsmErrorResponse(err)
+	}
+	return Response{Success: true, PCRs: map[uint16]PCRState{args.PCRIndex: {Value: hex.EncodeToString(value)}}}
+}
+
+// describe-pcr 请求，未指定索引时读取全部 PCR
+func describePCRRequest(args CommandArgs) Response {
+	indices := args.PCRIndices
+	if len(indices) == 0 {
+		description, err := describeNSMDevice()
+		if err != nil {
+			return nsmErrorResponse(err)
+		}
+		for i := uint16(0); i < description.MaxPCRs; i++ {
+			indices = append(indices, i)
+		}
+	}
+
+	pcrs := make(map[uint16]PCRState, len(indices))
+	for _, index := range indices {
+		state, err := nsmDescribePCR(index)
+		if err != nil {
+			return nsmErrorResponse(err)
+		}
+		pcrs[index] = *state
+	}
+	return Response{Success: true, PCRs: pcrs}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 内存中的 NSM 后端: 记录收到的请求，证明文档为输入的 CBOR 编码，err 非空时所有调用返回该错误
+type fakeNSM struct {
+	err    error
+	pcrs   map[uint16][]byte
+	locked map[uint16]bool
+
+	// 最近一次 Attest 的输入
+	userData, publicKey, nonce []byte
+}
+
+func newFakeNSM() *fakeNSM {
+	f := &fakeNSM{pcrs: map[uint16][]byte{}, locked: map[uint16]bool{}}
+	for i := uint16(0); i < 32; i++ {
+		f.pcrs[i] = make([]byte, sha512.Size384)
+		f.locked[i] = i < firstUserPCR
+	}
+	return f
+}
+
+// 在测试期间以 f 作为 NSM 后端
+func useFakeNSM(t *testing.T, f *fakeNSM) {
+	t.Helper()
+	nsmClient = f
+	t.Cleanup(func() { nsmClient = nil })
+}
+
+func (f *fakeNSM) Attest(userData, publicKey, nonce []byte) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	f.userData, f.publicKey, f.nonce = userData, publicKey, nonce
+	return cbor.Marshal(map[string][]byte{"user_data": userData, "public_key": publicKey, "nonce": nonce})
+}
+
+func (f *fakeNSM) GetRandom(length int) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	random := make([]byte, length)
+	for i := range random {
+		random[i] = byte(i)
+	}
+	return random, nil
+}
+
+func (f *fakeNSM) DescribePCR(index uint16) (*PCRState, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	value, ok := f.pcrs[index]
+	if !ok {
+		return nil, nsmError("InvalidIndex")
+	}
+	return &PCRState{Locked: f.locked[index], Value: hex.EncodeToString(value)}, nil
+}
+
+func (f *fakeNSM) DescribeNSM() (*NSMDescription, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	return &NSMDescription{ModuleID: "i-fake", VersionMajor: 1, MaxPCRs: uint16(len(f.pcrs)), Digest: "SHA384"}, nil
+}
+
+func (f *fakeNSM) ExtendPCR(index uint16, data []byte) ([]byte, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if f.locked[index] {
+		return nil, nsmError("ReadOnlyIndex")
+	}
+	h := sha512.New384()
+	h.Write(f.pcrs[index])
+	h.Write(data)
+	f.pcrs[index] = h.Sum(nil)
+	return f.pcrs[index], nil
+}
+
+func (f *fakeNSM) LockPCR(index uint16) error {
+	if f.err != nil {
+		return f.err
+	}
+	f.locked[index] = true
+	return nil
+}
+
+func (f *fakeNSM) LockPCRs(pcrRange uint16) error {
+	if f.err != nil {
+		return f.err
+	}
+	for i := uint16(0); i < pcrRange; i++ {
+		f.locked[i] = true
+	}
+	return nil
+}
+
+func TestProcessRequest(t *testing.T) {
+	b64 := base64.StdEncoding.EncodeToString
+	tests := []struct {
+		name     string
+		args     CommandArgs
+		nsmErr   error
+		wantCode string
+		// 成功时 NSM 收到的 user_data、public_key、nonce
+		userData, publicKey, nonce string
+	}{
+		{name: "文本输入", args: CommandArgs{UserData: "hello", Nonce: "n1"}, userData: "hello", nonce: "n1"},
+		{name: "base64 输入", args: CommandArgs{UserDataB64: b64([]byte{0, 1}), NonceB64: b64([]byte{2}), PublicKey: b64([]byte("pk"))}, userData: "\x00\x01", publicKey: "pk", nonce: "\x02"},
+		{name: "user_data 互斥", args: CommandArgs{UserData: "a", UserDataB64: "YQ=="}, wantCode: errCodeBadRequest},
+		{name: "nonce 互斥", args: CommandArgs{Nonce: "a", NonceB64: "YQ=="}, wantCode: errCodeBadRequest},
+		{name: "无效的 nonce_b64", args: CommandArgs{NonceB64: "!"}, wantCode: errCodeBadRequest},
+		{name: "无效的公钥", args: CommandArgs{PublicKey: "!"}, wantCode: errCodeInvalidPublicKey},
+		{name: "公钥过长", args: CommandArgs{PublicKey: b64(make([]byte, maxPublicKeySize+1))}, wantCode: errCodeInvalidPublicKey},
+		{name: "user_data 过长", args: CommandArgs{UserData: strings.Repeat("a", maxUserDataSize+1)}, wantCode: errCodeBadRequest},
+		{name: "NSM 不可用", nsmErr: fmt.Errorf("%w: 测试", errNSMUnavailable), wantCode: errCodeNSMUnavailable},
+		{name: "NSM 错误", nsmErr: nsmError("InternalError"), wantCode: errCodeNSMError},
+	}
+	for _, tt := range tests {
+		fake := newFakeNSM()
+		fake.err = tt.nsmErr
+		useFakeNSM(t, fake)
+
+		response := processRequest(tt.args)
+		if tt.wantCode != "" {
+			if response.Success || response.ErrorCode != tt.wantCode {
+				t.Errorf("%s: 期望错误码 %s，实际为 %+v", tt.name, tt.wantCode, response)
+			}
+			continue
+		}
+		if !response.Success {
+			t.Errorf("%s: %+v", tt.name, response)
+			continue
+		}
+		if string(fake.userData) != tt.userData || string(fake.publicKey) != tt.publicKey || string(fake.nonce) != tt.nonce {
+			t.Errorf("%s: NSM 收到的输入不符: %q %q %q", tt.name, fake.userData, fake.publicKey, fake.nonce)
+		}
+		if _, err := base64.StdEncoding.DecodeString(response.Document); err != nil {
+			t.Errorf("%s: 文档不是 base64: %v", tt.name, err)
+		}
+	}
+}
+
+func TestPCRRequests(t *testing.T) {
+	fake := newFakeNSM()
+	useFakeNSM(t, fake)
+
+	response := describePCRRequest(CommandArgs{})
+	if !response.Success || len(response.PCRs) != 32 || !response.PCRs[0].Locked || response.PCRs[16].Locked {
+		t.Fatalf("describe-pcr 全部 PCR: %+v", response)
+	}
+
+	config.AllowExtendPCR = false
+	if response := extendPCRRequest(CommandArgs{PCRIndex: 16, DataB64: "YQ=="}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("未启用 extend-pcr 时应拒绝: %+v", response)
+	}
+	config.AllowExtendPCR = true
+	defer func() { config.AllowExtendPCR = false }()
+
+	if response := extendPCRRequest(CommandArgs{PCRIndex: 4, DataB64: "YQ=="}); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("扩展 PCR4 应被拒绝: %+v", response)
+	}
+	response = extendPCRRequest(CommandArgs{PCRIndex: 16, DataB64: "YQ=="})
+	want := sha512.Sum384(append(make([]byte, sha512.Size384), 'a'))
+	if !response.Success || response.PCRs[16].Value != hex.EncodeToString(want[:]) {
+		t.Fatalf("extend-pcr: %+v", response)
+	}
+
+	config.AllowLockPCR = true
+	defer func() { config.AllowLockPCR = false }()
+	if response := lockPCRRequest(CommandArgs{PCRIndex: 16}); !response.Success || !response.PCRs[16].Locked {
+		t.Fatalf("lock-pcr: %+v", response)
+	}
+	if response := extendPCRRequest(CommandArgs{PCRIndex: 16, DataB64: "YQ=="}); response.ErrorCode != errCodeNSMError || !strings.Contains(response.ErrorMessage, "已锁定") {
+		t.Fatalf("扩展已锁定的 PCR 应失败: %+v", response)
+	}
+}
+
+func TestGetRandomRequest(t *testing.T) {
+	useFakeNSM(t, newFakeNSM())
+
+	if response := getRandomRequest(CommandArgs{}); !response.Success || len(response.Random) != nsmMaxRandomSize {
+		t.Fatalf("默认长度: %+v", response)
+	}
+	if response := getRandomRequest(CommandArgs{Length: 1000}); !response.Success || len(response.Random) != 1000 {
+		t.Fatalf("长度 1000: %d", len(response.Random))
+	}
+	if response := getRandomRequest(CommandArgs{Length: maxRandomLength + 1}); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("超过上限应被拒绝: %+v", response)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// --object-processor 输出的最大字节数及运行超时
+const (
+	maxObjectOutput        = 64 << 10
+	objectProcessorTimeout = time.Minute
+)
+
+// decrypt-object 方法的结果: 只有由明文导出的结果，明文不离开 Enclave - 与 client 端匹配
+type ObjectResult struct {
+	// 已接收的密文字节数 (分块上传未完成时)，或明文字节数
+	Size int64 `json:"size" cbor:"size"`
+	// 明文的 SHA-256 (十六进制) 及行数，完成时返回
+	SHA256 string `json:"sha256,omitempty" cbor:"sha256,omitempty"`
+	Lines  int64  `json:"lines,omitempty" cbor:"lines,omitempty"`
+	// 解密数据密钥所用的 KMS 密钥 ARN
+	KeyID string `json:"key_id,omitempty" cbor:"key_id,omitempty"`
+	// --object-processor 的标准输出
+	Output []byte `json:"output,omitempty" cbor:"output,omitempty"`
+}
+
+// S3 加密客户端 v2 写入对象元数据 (x-amz-meta-*) 的信封字段
+const (
+	envelopeKey     = "x-amz-key-v2"
+	envelopeIV      = "x-amz-iv"
+	envelopeMatDesc = "x-amz-matdesc"
+	envelopeWrapAlg = "x-amz-wrap-alg"
+	envelopeCEKAlg  = "x-amz-cek-alg"
+	envelopeTagLen  = "x-amz-tag-len"
+)
+
+// 进行中的分块上传: 按偏移顺序缓存密文，final 时解密
+type objectUpload struct {
+	envelope map[string]string
+	data     bytes.Buffer
+}
+
+var (
+	objectUploadsMu sync.Mutex
+	objectUploads   = map[string]*objectUpload{}
+)
+
+// decrypt-object 请求: 以 S3 加密客户端 v2 格式 (kms+context 包装的数据密钥、AES-GCM) 加密的对象，
+// 指定 s3_uri 时由 Enclave 经 --egress 读取；否则由主机以 handle 为会话按偏移分块上传密文 (data_b64)，
+// 第一块携带对象元数据 envelope。Enclave 以证明文档经 KMS 解密数据密钥，解密并校验后交给 --object-processor，
+// 响应只返回明文的大小、SHA-256、行数及处理结果；需以 --allow-decrypt-object 启动
+func decryptObjectRequest(args CommandArgs) Response {
+	if !config.AllowDecryptObject {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 decrypt-object 方法 (--allow-decrypt-object)"}
+	}
+	if args.S3URI != "" {
+		return fetchObjectRequest(args)
+	}
+	if args.Handle == "" {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "必须指定 s3_uri 或 handle"}
+	}
+	data, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 data_b64 失败: %v", err)}
+	}
+
+	objectUploadsMu.Lock()
+	upload := objectUploads[args.Handle]
+	if args.Offset == 0 {
+		var envelope map[string]string
+		if err := json.Unmarshal([]byte(args.Envelope), &envelope); err != nil {
+			objectUploadsMu.Unlock()
+			return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("第一块必须携带对象元数据 envelope: %v", err)}
+		}
+		upload = &objectUpload{envelope: envelope}
+		objectUploads[args.Handle] = upload
+	}
+	if upload == nil || args.Offset != int64(upload.data.Len()) {
+		objectUploadsMu.Unlock()
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("偏移 %d 与已接收的字节数不符，需从偏移 0 重新上传", args.Offset)}
+	}
+	if int64(upload.data.Len()+len(data)) > config.MaxObjectSize {
+		delete(objectUploads, args.Handle)
+		objectUploadsMu.Unlock()
+		return Response{ErrorCode: errCodeRequestTooLarge, ErrorMessage: fmt.Sprintf("对象超过 %d 字节上限 (--max-object-size)", config.MaxObjectSize)}
+	}
+	upload.data.Write(data)
+	if !args.Final {
+		size := int64(upload.data.Len())
+		objectUploadsMu.Unlock()
+		return Response{Success: true, Object: &ObjectResult{Size: size}}
+	}
+	delete(objectUploads, args.Handle)
+	objectUploadsMu.Unlock()
+
+	ctx := context.Background()
+	cfg, err := loadAWSConfig(ctx, args.Region)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	return processEncryptedObject(ctx, cfg, args.Handle, upload.envelope, upload.data.Bytes())
+}
+
+// 经 --egress 读取 s3_uri 及其元数据后解密处理
+func fetchObjectRequest(args CommandArgs) Response {
+	bucket, key, err := parseS3URL(args.S3URI)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: err.Error()}
+	}
+	ctx := context.Background()
+	cfg, err := loadAWSConfig(ctx, args.Region)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	ciphertext, header, err := getS3Object(ctx, cfg, bucket, key, config.MaxObjectSize)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	return processEncryptedObject(ctx, cfg, args.S3URI, envelopeFromHeader(header), ciphertext)
+}
+
+// 从 S3 响应头 (x-amz-meta-*) 中取出信封字段
+func envelopeFromHeader(header http.Header) map[string]string {
+	envelope := map[string]string{}
+	for _, name := range []string{envelopeKey, envelopeIV, envelopeMatDesc, envelopeWrapAlg, envelopeCEKAlg, envelopeTagLen} {
+		if value := header.Get("X-Amz-Meta-" + name); value != "" {
+			envelope[name] = value
+		}
+	}
+	return envelope
+}
+
+func processEncryptedObject(ctx context.Context, cfg aws.Config, name string, envelope map[string]string, ciphertext []byte) Response {
+	plaintext, keyID, err := decryptS3Envelope(ctx, cfg, envelope, ciphertext)
+	var awsErr *awsError
+	if errors.As(err, &awsErr) {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	if err != nil {
+		return errorResponse(errCodeBadRequest, err.Error())
+	}
+	defer clear(plaintext)
+
+	digest := sha256.Sum256(plaintext)
+	result := &ObjectResult{
+		Size:   int64(len(plaintext)),
+		SHA256: hex.EncodeToString(digest[:]),
+		Lines:  int64(bytes.Count(plaintext, []byte("\n"))),
+		KeyID:  keyID,
+	}
+	if config.ObjectProcessor != "" {
+		if result.Output, err = runObjectProcessor(plaintext); err != nil {
+			return errorResponse(errCodeInternal, err.Error())
+		}
+	}
+	log.Printf("已解密并处理对象 %s (%d 字节, sha256 %s)\n", name, result.Size, result.SHA256)
+	return Response{Success: true, Object: result}
+}
+
+// 以 S3 加密客户端 v2 的信封解密对象: 数据密钥由 KMS 以 x-amz-matdesc 为加密上下文包装 (kms+context)，
+// 内容为 AES-GCM 加密，认证标签附在密文末尾
+func decryptS3Envelope(ctx context.Context, cfg aws.Config, envelope map[string]string, ciphertext []byte) ([]byte, string, error) {
+	if wrapAlg := envelope[envelopeWrapAlg]; wrapAlg != "kms+context" {
+		return nil, "", fmt.Errorf("不支持的密钥包装算法 %q (只支持 S3 加密客户端 v2 的 kms+context)", wrapAlg)
+	}
+	cekAlg := envelope[envelopeCEKAlg]
+	if cekAlg != "AES/GCM/NoPadding" {
+		return nil, "", fmt.Errorf("不支持的内容加密算法 %q (只支持 AES/GCM/NoPadding)", cekAlg)
+	}
+	if tagLen := envelope[envelopeTagLen]; tagLen != "" && tagLen != "128" {
+		return nil, "", fmt.Errorf("不支持的认证标签长度 %s", tagLen)
+	}
+	var encryptionContext map[string]string
+	if err := json.Unmarshal([]byte(envelope[envelopeMatDesc]), &encryptionContext); err != nil {
+		return nil, "", fmt.Errorf("解析 %s 失败: %v", envelopeMatDesc, err)
+	}
+	// kms+context 要求加密上下文中包含内容加密算法，防止算法被替换
+	if encryptionContext["aws:"+envelopeCEKAlg] != cekAlg {
+		return nil, "", fmt.Errorf("%s 中的 aws:%s 与 %s 不符", envelopeMatDesc, envelopeCEKAlg, cekAlg)
+	}
+	wrappedKey, err := base64.StdEncoding.DecodeString(envelope[envelopeKey])
+	if err != nil || len(wrappedKey) == 0 {
+		return nil, "", fmt.Errorf("无效的 %s", envelopeKey)
+	}
+	iv, err := base64.StdEncoding.DecodeString(envelope[envelopeIV])
+	if err != nil || len(iv) != 12 {
+		return nil, "", fmt.Errorf("无效的 %s", envelopeIV)
+	}
+
+	dataKey, keyID, err := kmsDecrypt(ctx, cfg, wrappedKey, "", encryptionContext)
+	if err != nil {
+		return nil, "", fmt.Errorf("解密数据密钥失败: %w", err)
+	}
+	defer clear(dataKey)
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		return nil, "", fmt.Errorf("无效的数据密钥: %v", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, "", err
+	}
+	plaintext, err := gcm.Open(nil, iv, ciphertext, nil)
+	if err != nil {
+		return nil, "", errors.New("对象解密失败 (密文或元数据被篡改)")
+	}
+	return plaintext, keyID, nil
+}
+
+// 以明文为标准输入运行 --object-processor，返回其标准输出
+func runObjectProcessor(plaintext []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), objectProcessorTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, config.ObjectProcessor)
+	cmd.Stdin = bytes.NewReader(plaintext)
+	var stdout, stderr limitedBuffer
+	stdout.limit, stderr.limit = maxObjectOutput, maxObjectOutput
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("--object-processor 失败: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if stdout.truncated {
+		return nil, fmt.Errorf("--object-processor 的输出超过 %d 字节", maxObjectOutput)
+	}
+	return stdout.Bytes(), nil
+}
+
+// 超过 limit 字节后丢弃写入内容的缓冲区
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if room := b.limit - b.Len(); len(p) > room {
+		b.truncated = true
+		if room > 0 {
+			b.Buffer.Write(p[:room])
+		}
+		return len(p), nil
+	}
+	return b.Buffer.Write(p)
+}
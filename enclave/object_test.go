@@ -0,0 +1,136 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 以 S3 加密客户端 v2 的格式加密 plaintext，返回密文及对象元数据
+func encryptS3Object(t *testing.T, dataKey, plaintext []byte) ([]byte, map[string]string) {
+	t.Helper()
+	block, err := aes.NewCipher(dataKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	iv := make([]byte, gcm.NonceSize())
+	rand.Read(iv)
+	return gcm.Seal(nil, iv, plaintext, nil), map[string]string{
+		envelopeKey:     base64.StdEncoding.EncodeToString([]byte("kms-ciphertext")),
+		envelopeIV:      base64.StdEncoding.EncodeToString(iv),
+		envelopeMatDesc: `{"aws:x-amz-cek-alg":"AES/GCM/NoPadding"}`,
+		envelopeWrapAlg: "kms+context",
+		envelopeCEKAlg:  "AES/GCM/NoPadding",
+		envelopeTagLen:  "128",
+	}
+}
+
+func TestDecryptObject(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+
+	dataKey := make([]byte, 32)
+	rand.Read(dataKey)
+	plaintext := []byte("a,1\nb,2\nc,3\n")
+	digest := sha256.Sum256(plaintext)
+	ciphertext, envelope := encryptS3Object(t, dataKey, plaintext)
+	// fakeAWS 的 KMS 返回以证明文档公钥加密的数据密钥
+	useFakeAWS(t, dataKey, nil)
+
+	push := func(offset int64, data []byte, final bool, envelope map[string]string) Response {
+		args := CommandArgs{Handle: "upload", Offset: offset, DataB64: base64.StdEncoding.EncodeToString(data), Final: final}
+		if envelope != nil {
+			args.Envelope = string(mustJSON(t, envelope))
+		}
+		return decryptObjectRequest(args)
+	}
+
+	if response := push(0, ciphertext, true, envelope); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("未启用时应拒绝: %+v", response)
+	}
+	config.AllowDecryptObject = true
+	config.MaxObjectSize = 1 << 20
+
+	// 主机分块上传
+	if response := push(0, ciphertext[:5], false, envelope); !response.Success || response.Object.Size != 5 {
+		t.Fatalf("第一块: %+v", response)
+	}
+	if response := push(3, ciphertext[5:], true, nil); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("错误的偏移应被拒绝: %+v", response)
+	}
+	push(0, ciphertext[:5], false, envelope)
+	response := push(5, ciphertext[5:], true, nil)
+	if !response.Success {
+		t.Fatalf("decrypt-object 失败: %+v", response)
+	}
+	want := ObjectResult{Size: int64(len(plaintext)), SHA256: hex.EncodeToString(digest[:]), Lines: 3, KeyID: "arn:aws:kms:us-east-1:123456789012:key/test"}
+	if object := response.Object; object.Size != want.Size || object.SHA256 != want.SHA256 || object.Lines != want.Lines || object.KeyID != want.KeyID || object.Output != nil {
+		t.Fatalf("结果 %+v，期望 %+v", object, want)
+	}
+
+	// 密文被篡改
+	tampered := append([]byte(nil), ciphertext...)
+	tampered[0] ^= 1
+	if response := push(0, tampered, true, envelope); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("篡改的密文应被拒绝: %+v", response)
+	}
+	// 加密上下文中缺少内容加密算法
+	bad := map[string]string{}
+	for k, v := range envelope {
+		bad[k] = v
+	}
+	bad[envelopeMatDesc] = `{}`
+	if response := push(0, ciphertext, true, bad); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("缺少 aws:x-amz-cek-alg 应被拒绝: %+v", response)
+	}
+
+	config.MaxObjectSize = 4
+	if response := push(0, ciphertext, true, envelope); response.ErrorCode != errCodeRequestTooLarge {
+		t.Fatalf("超过 --max-object-size 应被拒绝: %+v", response)
+	}
+	config.MaxObjectSize = 1 << 20
+
+	// Enclave 读取对象，信封在 x-amz-meta-* 响应头中
+	s3 := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/data/report.csv" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		for name, value := range envelope {
+			w.Header().Set("X-Amz-Meta-"+name, value)
+		}
+		w.Write(ciphertext)
+	}))
+	defer s3.Close()
+	s3ObjectURL = func(bucket, key, region string) string { return s3.URL + "/" + bucket + "/" + key }
+
+	processor := filepath.Join(t.TempDir(), "count.sh")
+	if err := os.WriteFile(processor, []byte("#!/bin/sh\nwc -l\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config.ObjectProcessor = processor
+	response = decryptObjectRequest(CommandArgs{S3URI: "s3://data/report.csv"})
+	if !response.Success || response.Object.SHA256 != want.SHA256 {
+		t.Fatalf("decrypt-object s3_uri 失败: %+v", response)
+	}
+	if output := strings.TrimSpace(string(response.Object.Output)); output != "3" {
+		t.Fatalf("--object-processor 输出 %q", output)
+	}
+	if response := decryptObjectRequest(CommandArgs{S3URI: "s3://data/missing.csv"}); response.ErrorCode != errCodeInternal {
+		t.Fatalf("不存在的对象应返回错误: %+v", response)
+	}
+}
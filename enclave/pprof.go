@@ -0,0 +1,55 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+)
+
+// pprof 调试接口，使用独立的 ServeMux，不注册到 http.DefaultServeMux
+func newPprofMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// 只接受 --allow 允许的对端的监听器
+type peerCheckedListener struct {
+	net.Listener
+}
+
+func (l peerCheckedListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkPeer(conn.RemoteAddr()); err != nil {
+			log.Printf("拒绝 pprof 连接: %v\n", err)
+			conn.Close()
+			continue
+		}
+		return conn, nil
+	}
+}
+
+// 在独立的监听地址上提供 pprof，主机通过 pprof-proxy 转发到本地后使用 go tool pprof 分析
+func startPprofServer() {
+	listener, err := listen(config.PprofListen, 0)
+	if err != nil {
+		log.Fatalf("无法创建 pprof 监听器: %v", err)
+	}
+
+	server := &http.Server{
+		Handler:           newPprofMux(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	log.Printf("pprof 服务已启动，监听 %s\n", config.PprofListen)
+	log.Fatalf("pprof 服务退出: %v", server.Serve(peerCheckedListener{listener}))
+}
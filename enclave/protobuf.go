@@ -0,0 +1,391 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// protobuf 编码，消息定义见 proto/attestation.proto
+// 不依赖生成代码，直接按字段号读写 - 与 client 端匹配
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string { return codecProtobuf }
+
+func (protobufCodec) DecodeRequest(payload []byte) (CommandArgs, error) {
+	return decodeProtoRequest(payload, true)
+}
+
+// 解码请求消息，batch 为 false 时拒绝嵌套的批量请求，避免恶意输入造成深度递归
+func decodeProtoRequest(payload []byte, batch bool) (CommandArgs, error) {
+	var args CommandArgs
+	r := protoReader{b: payload}
+	for r.next() {
+		switch r.num {
+		case 1:
+			args.SchemaVersion = int(int32(r.varint()))
+		case 2:
+			args.Method = r.string()
+		case 3:
+			args.UserData = r.string()
+		case 4:
+			args.PublicKey = r.string()
+		case 5:
+			args.Nonce = r.string()
+		case 6:
+			args.UserDataB64 = r.string()
+		case 7:
+			args.NonceB64 = r.string()
+		case 8:
+			args.Audience = r.string()
+		case 9:
+			args.TTL = int(int32(r.varint()))
+		case 10:
+			args.Fresh = r.varint() != 0
+		case 11:
+			args.TraceParent = r.string()
+		case 12:
+			args.Length = int(int32(r.varint()))
+		case 13:
+			args.PCRIndex = uint16(r.varint())
+		case 14:
+			args.DataB64 = r.string()
+		case 15:
+			args.PCRIndices = r.appendUint16s(args.PCRIndices)
+		case 16:
+			args.PCRRange = uint16(r.varint())
+		case 17:
+			if !batch {
+				r.err = fmt.Errorf("batch 中的请求不能再包含 batch")
+				break
+			}
+			item, err := decodeProtoRequest(r.bytes(), false)
+			if err != nil && r.err == nil {
+				r.err = fmt.Errorf("batch: %v", err)
+			}
+			args.Batch = append(args.Batch, item)
+		case 18:
+			args.Path = r.string()
+		case 19:
+			args.Offset = int64(r.varint())
+		case 20:
+			args.Final = r.varint() != 0
+		case 21:
+			args.SHA256 = r.string()
+		case 22:
+			args.TimeUnixNano = int64(r.varint())
+		case 23:
+			args.TimeProof = r.string()
+		case 24:
+			args.SecretID = r.string()
+		case 25:
+			args.KeyID = r.string()
+		case 26:
+			args.Region = r.string()
+		case 27:
+			args.Handle = r.string()
+		case 28:
+			args.Parameter = r.string()
+		case 29:
+			args.SigningAlgorithm = r.string()
+		case 30:
+			args.Digest = r.varint() != 0
+		case 31:
+			args.S3URI = r.string()
+		case 32:
+			args.Envelope = r.string()
+		case 33:
+			args.Session = r.string()
+		case 34:
+			args.Sequence = r.varint()
+		default:
+			r.skip()
+		}
+	}
+	return args, r.err
+}
+
+func (protobufCodec) EncodeResponse(response Response) ([]byte, error) {
+	var w protoWriter
+	w.bool(1, response.Success)
+	w.string(2, response.ErrorCode)
+	w.string(3, response.ErrorMessage)
+	w.bytes(4, rawDocument(response.Document))
+	w.string(5, response.Token)
+	w.string(6, response.Version)
+	w.varint(7, uint64(int64(response.SchemaVersion)))
+	for _, span := range response.Trace {
+		w.message(8, encodeProtoSpan(span))
+	}
+	if response.NSM != nil {
+		w.message(9, encodeProtoNSM(response.NSM))
+	}
+	w.bytes(10, response.Random)
+
+	indices := make([]int, 0, len(response.PCRs))
+	for index := range response.PCRs {
+		indices = append(indices, int(index))
+	}
+	sort.Ints(indices)
+	for _, index := range indices {
+		state := response.PCRs[uint16(index)]
+		var value protoWriter
+		value.bool(1, state.Locked)
+		value.string(2, state.Value)
+		var entry protoWriter
+		entry.varint(1, uint64(index))
+		entry.message(2, value)
+		w.message(11, entry)
+	}
+	for _, item := range response.Batch {
+		encoded, _ := protobufCodec{}.EncodeResponse(item)
+		w.message(12, encoded)
+	}
+	w.string(13, response.EvidenceType)
+	if response.File != nil {
+		w.message(14, encodeProtoFile(response.File))
+	}
+	if response.Time != nil {
+		w.message(15, encodeProtoTime(response.Time))
+	}
+	if response.Secret != nil {
+		w.message(16, encodeProtoSecret(response.Secret))
+	}
+	if response.Signature != nil {
+		w.message(17, encodeProtoSignature(response.Signature))
+	}
+	if response.Object != nil {
+		w.message(18, encodeProtoObject(response.Object))
+	}
+	w.bytes(19, response.FileKey)
+	if response.Session != nil {
+		w.message(20, encodeProtoSession(response.Session))
+	}
+	w.bytes(21, response.Sealed)
+	return w, nil
+}
+
+func encodeProtoSpan(span TraceSpan) []byte {
+	var w protoWriter
+	w.string(1, span.Name)
+	w.string(2, span.SpanID)
+	w.string(3, span.ParentSpanID)
+	w.varint(4, uint64(span.Start))
+	w.varint(5, uint64(span.End))
+
+	keys := make([]string, 0, len(span.Attributes))
+	for key := range span.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var entry protoWriter
+		entry.string(1, key)
+		entry.string(2, span.Attributes[key])
+		w.message(6, entry)
+	}
+	w.string(7, span.Error)
+	return w
+}
+
+func encodeProtoNSM(nsm *NSMDescription) []byte {
+	var w protoWriter
+	w.string(1, nsm.ModuleID)
+	w.varint(2, uint64(nsm.VersionMajor))
+	w.varint(3, uint64(nsm.VersionMinor))
+	w.varint(4, uint64(nsm.VersionPatch))
+	w.varint(5, uint64(nsm.MaxPCRs))
+	w.uint16s(6, nsm.LockedPCRs)
+	w.string(7, nsm.Digest)
+	return w
+}
+
+func encodeProtoFile(file *FileChunk) []byte {
+	var w protoWriter
+	w.string(1, file.Path)
+	w.varint(2, uint64(file.Size))
+	w.varint(3, uint64(file.Offset))
+	w.bytes(4, file.Data)
+	w.string(5, file.SHA256)
+	w.bool(6, file.EOF)
+	return w
+}
+
+func encodeProtoTime(status *TimeStatus) []byte {
+	var w protoWriter
+	w.varint(1, uint64(status.TimeUnixNano))
+	w.varint(2, uint64(status.OffsetNS))
+	w.bool(3, status.Authenticated)
+	w.string(4, status.NTPServer)
+	return w
+}
+
+func encodeProtoSecret(secret *SecretHandle) []byte {
+	var w protoWriter
+	w.string(1, secret.Handle)
+	w.string(2, secret.ARN)
+	w.string(3, secret.VersionID)
+	w.string(4, secret.KeyID)
+	w.varint(5, uint64(secret.Size))
+	return w
+}
+
+func encodeProtoSignature(signature *KMSSignature) []byte {
+	var w protoWriter
+	w.string(1, signature.KeyID)
+	w.string(2, signature.SigningAlgorithm)
+	w.bytes(3, signature.Signature)
+	return w
+}
+
+func encodeProtoObject(object *ObjectResult) []byte {
+	var w protoWriter
+	w.varint(1, uint64(object.Size))
+	w.string(2, object.SHA256)
+	w.varint(3, uint64(object.Lines))
+	w.string(4, object.KeyID)
+	w.bytes(5, object.Output)
+	return w
+}
+
+func encodeProtoSession(session *SessionInfo) []byte {
+	var w protoWriter
+	w.string(1, session.ID)
+	w.bytes(2, session.PublicKey)
+	w.varint(3, uint64(session.RekeyRequests))
+	w.varint(4, uint64(session.RekeyBytes))
+	w.varint(5, uint64(session.RekeyInterval))
+	w.varint(6, uint64(session.Lifetime))
+	return w
+}
+
+// 按字段号读取 protobuf 消息
+type protoReader struct {
+	b   []byte
+	num protowire.Number
+	typ protowire.Type
+	err error
+}
+
+// 读取下一个字段的标签，消息结束或出错时返回 false
+func (r *protoReader) next() bool {
+	if r.err != nil || len(r.b) == 0 {
+		return false
+	}
+	num, typ, n := protowire.ConsumeTag(r.b)
+	if n < 0 {
+		r.err = protowire.ParseError(n)
+		return false
+	}
+	r.b, r.num, r.typ = r.b[n:], num, typ
+	return true
+}
+
+// 跳过当前字段的值之后的 n 字节，n 小于 0 时记录错误
+func (r *protoReader) advance(n int) bool {
+	if n < 0 {
+		r.err = fmt.Errorf("字段 %d: %v", r.num, protowire.ParseError(n))
+		r.b = nil
+		return false
+	}
+	r.b = r.b[n:]
+	return true
+}
+
+func (r *protoReader) expect(typ protowire.Type) bool {
+	if r.typ != typ {
+		r.err = fmt.Errorf("字段 %d 的类型错误", r.num)
+		r.b = nil
+		return false
+	}
+	return true
+}
+
+func (r *protoReader) varint() uint64 {
+	if !r.expect(protowire.VarintType) {
+		return 0
+	}
+	v, n := protowire.ConsumeVarint(r.b)
+	r.advance(n)
+	return v
+}
+
+func (r *protoReader) bytes() []byte {
+	if !r.expect(protowire.BytesType) {
+		return nil
+	}
+	v, n := protowire.ConsumeBytes(r.b)
+	r.advance(n)
+	return v
+}
+
+func (r *protoReader) string() string {
+	return string(r.bytes())
+}
+
+// repeated uint32，兼容 packed 和非 packed 编码
+func (r *protoReader) appendUint16s(dst []uint16) []uint16 {
+	if r.typ == protowire.VarintType {
+		return append(dst, uint16(r.varint()))
+	}
+	packed := protoReader{b: r.bytes(), num: r.num, typ: protowire.VarintType}
+	for r.err == nil && packed.err == nil && len(packed.b) > 0 {
+		dst = append(dst, uint16(packed.varint()))
+	}
+	if packed.err != nil {
+		r.err = packed.err
+	}
+	return dst
+}
+
+// 跳过未知字段
+func (r *protoReader) skip() {
+	r.advance(protowire.ConsumeFieldValue(r.num, r.typ, r.b))
+}
+
+// 按字段号写入 protobuf 消息，与 proto3 相同，零值字段不写入
+type protoWriter []byte
+
+func (w *protoWriter) varint(num protowire.Number, v uint64) {
+	if v == 0 {
+		return
+	}
+	*w = protowire.AppendTag(*w, num, protowire.VarintType)
+	*w = protowire.AppendVarint(*w, v)
+}
+
+func (w *protoWriter) bool(num protowire.Number, v bool) {
+	if v {
+		w.varint(num, 1)
+	}
+}
+
+func (w *protoWriter) bytes(num protowire.Number, v []byte) {
+	if len(v) == 0 {
+		return
+	}
+	w.message(num, v)
+}
+
+func (w *protoWriter) string(num protowire.Number, v string) {
+	w.bytes(num, []byte(v))
+}
+
+// 嵌套消息，空消息同样写入 (如 map 中的零值)
+func (w *protoWriter) message(num protowire.Number, v []byte) {
+	*w = protowire.AppendTag(*w, num, protowire.BytesType)
+	*w = protowire.AppendBytes(*w, v)
+}
+
+// packed repeated uint32
+func (w *protoWriter) uint16s(num protowire.Number, v []uint16) {
+	if len(v) == 0 {
+		return
+	}
+	var packed []byte
+	for _, x := range v {
+		packed = protowire.AppendVarint(packed, uint64(x))
+	}
+	w.message(num, packed)
+}
@@ -0,0 +1,416 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/hashicorp/yamux"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	// 单帧最大长度
+	maxFrameSize = 16 << 20
+
+	// 握手帧最大长度
+	maxHelloSize = 4 << 10
+
+	// 支持的请求/响应编码，握手帧本身始终使用 JSON
+	codecJSON     = "json"
+	codecCBOR     = "cbor"
+	codecProtobuf = "protobuf"
+
+	// 支持的响应压缩算法
+	compressionGzip = "gzip"
+	compressionZstd = "zstd"
+
+	// 流式响应的分块大小范围
+	minChunkSize = 1 << 10
+	maxChunkSize = 1 << 20
+
+	// 流式响应中每个分块帧的首字节
+	chunkMore  = 0x01
+	chunkFinal = 0x00
+)
+
+// 握手请求 - 帧协议连接上的第一帧
+type Hello struct {
+	Mux   bool   `json:"mux,omitempty"`
+	Codec string `json:"codec,omitempty"`
+	// 客户端可接受的压缩算法，按优先级排列
+	Compression []string `json:"compression,omitempty"`
+	// 非 0 时响应以该大小分块流式返回
+	ChunkSize int `json:"chunk_size,omitempty"`
+	// 请求对后续帧启用共享密钥 HMAC 认证，及客户端的随机数 (与 Enclave 的挑战一起绑定到每帧标签)
+	HMAC      bool   `json:"hmac,omitempty"`
+	HMACNonce []byte `json:"hmac_nonce,omitempty"`
+	// 请求建立 Noise 加密通道 (NK 或 XX)，以及用于证明静态公钥的随机数
+	Noise      string `json:"noise,omitempty"`
+	NoiseNonce string `json:"noise_nonce,omitempty"`
+}
+
+// 握手响应
+type HelloAck struct {
+	Mux          bool   `json:"mux"`
+	Codec        string `json:"codec"`
+	Compression  string `json:"compression,omitempty"`
+	ChunkSize    int    `json:"chunk_size,omitempty"`
+	HMAC         bool   `json:"hmac,omitempty"`
+	Challenge    []byte `json:"challenge,omitempty"` // 启用 HMAC 时的随机挑战，混入每帧标签中
+	Noise        string `json:"noise,omitempty"`
+	NoiseStatic  []byte `json:"noise_static,omitempty"` // Enclave 的 Noise 静态公钥
+	Attestation  string `json:"attestation,omitempty"`  // public_key 为 Noise 静态公钥的证明文档
+	ErrorCode    string `json:"error_code,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// 连接上协商出的参数
+type session struct {
+	codec       Codec
+	compression string
+	chunkSize   int
+
+	// HMAC 认证参数，hmacKey 为空表示未启用；transcript 为握手记录的摘要
+	hmacKey    []byte
+	transcript []byte
+
+	// 对端地址，用于审计日志
+	peer string
+}
+
+// 帧长度超过上限
+var errFrameTooLarge = errors.New("帧长度超过上限")
+
+// 读取一帧: 4 字节大端长度 + 负载，长度超过 limit 时返回 errFrameTooLarge
+func readFrame(r io.Reader, limit int) ([]byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > uint32(limit) {
+		return nil, fmt.Errorf("%w: %d > %d", errFrameTooLarge, size, limit)
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, fmt.Errorf("读取帧负载失败: %w", err)
+	}
+	return payload, nil
+}
+
+// 写入一帧
+func writeFrame(w io.Writer, payload []byte) error {
+	if len(payload) > maxFrameSize {
+		return fmt.Errorf("帧长度 %d 超过上限 %d", len(payload), maxFrameSize)
+	}
+
+	frame := make([]byte, 4+len(payload))
+	binary.BigEndian.PutUint32(frame, uint32(len(payload)))
+	copy(frame[4:], payload)
+	_, err := w.Write(frame)
+	return err
+}
+
+// 将负载拆分为多个分块帧写出，每帧首字节标记是否还有后续分块
+func writeChunked(fc *frameConn, payload []byte, chunkSize int) error {
+	for {
+		n := len(payload)
+		flag := byte(chunkFinal)
+		if n > chunkSize {
+			n = chunkSize
+			flag = chunkMore
+		}
+
+		chunk := make([]byte, 1+n)
+		chunk[0] = flag
+		copy(chunk[1:], payload[:n])
+		if err := fc.WriteFrame(chunk); err != nil {
+			return err
+		}
+
+		payload = payload[n:]
+		if flag == chunkFinal {
+			return nil
+		}
+	}
+}
+
+// 带缓冲读取的连接，用于在探测协议后继续读取
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// 处理帧协议连接: 握手后按帧收发请求，可选 yamux 多路复用
+// authErr 非空时在握手阶段拒绝该连接
+func handleFramedClient(conn *bufferedConn, authErr error) {
+	payload, err := readFrame(conn, maxHelloSize)
+	if err != nil {
+		log.Printf("读取握手帧失败: %v\n", err)
+		if isTimeout(err) {
+			writeHelloAck(conn, HelloAck{ErrorCode: errCodeTimeout, ErrorMessage: "等待握手超时"})
+		}
+		return
+	}
+
+	if authErr != nil {
+		log.Printf("拒绝连接: %v\n", authErr)
+		writeHelloAck(conn, HelloAck{ErrorCode: errCodeUnauthorized, ErrorMessage: authErr.Error()})
+		return
+	}
+
+	var hello Hello
+	if err := json.Unmarshal(payload, &hello); err != nil {
+		log.Printf("解析握手帧失败: %v\n", err)
+		writeHelloAck(conn, HelloAck{ErrorCode: errCodeParseError, ErrorMessage: fmt.Sprintf("解析握手帧失败: %v", err)})
+		return
+	}
+
+	codecName := hello.Codec
+	if codecName == "" {
+		codecName = codecJSON
+	}
+	codec, ok := codecs[codecName]
+	if !ok {
+		log.Printf("不支持的编码: %s\n", codecName)
+		writeHelloAck(conn, HelloAck{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("不支持的编码: %s", codecName)})
+		return
+	}
+
+	// 选择第一个支持的压缩算法，都不支持时不压缩
+	compression := ""
+	for _, alg := range hello.Compression {
+		if alg == compressionGzip || alg == compressionZstd {
+			compression = alg
+			break
+		}
+	}
+
+	chunkSize := hello.ChunkSize
+	if chunkSize > 0 && chunkSize < minChunkSize {
+		chunkSize = minChunkSize
+	}
+	if chunkSize > maxChunkSize {
+		chunkSize = maxChunkSize
+	}
+
+	// 配置了共享密钥时强制要求 HMAC 认证
+	if hmacKey != nil && !hello.HMAC {
+		log.Println("拒绝连接: 客户端未启用 HMAC 认证")
+		writeHelloAck(conn, HelloAck{ErrorCode: errCodeUnauthorized, ErrorMessage: "服务器要求 HMAC 认证"})
+		return
+	}
+	if hmacKey != nil && len(hello.HMACNonce) < hmacChallengeSize {
+		writeHelloAck(conn, HelloAck{ErrorCode: errCodeUnauthorized, ErrorMessage: fmt.Sprintf("HMAC 认证需要至少 %d 字节的 hmac_nonce", hmacChallengeSize)})
+		return
+	}
+	if hmacKey == nil && hello.HMAC {
+		writeHelloAck(conn, HelloAck{ErrorCode: errCodeBadRequest, ErrorMessage: "服务器未配置 HMAC 密钥"})
+		return
+	}
+
+	sess := session{codec: codec, compression: compression, chunkSize: chunkSize, peer: conn.RemoteAddr().String()}
+	ack := HelloAck{Mux: hello.Mux, Codec: codec.Name(), Compression: compression, ChunkSize: chunkSize}
+	if hmacKey != nil {
+		challenge := make([]byte, hmacChallengeSize)
+		if _, err := rand.Read(challenge); err != nil {
+			log.Printf("生成 HMAC 挑战失败: %v\n", err)
+			return
+		}
+		sess.hmacKey = hmacKey
+		ack.HMAC = true
+		ack.Challenge = challenge
+	}
+
+	switch {
+	case hello.Noise != "":
+		if hello.Noise != noisePatternNK && hello.Noise != noisePatternXX {
+			writeHelloAck(conn, HelloAck{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("不支持的 Noise 握手模式: %s", hello.Noise)})
+			return
+		}
+		if noiseClientKeys != nil && hello.Noise != noisePatternXX {
+			writeHelloAck(conn, HelloAck{ErrorCode: errCodeUnauthorized, ErrorMessage: "服务器要求 Noise XX 双向认证"})
+			return
+		}
+
+		static, err := noiseStaticKey()
+		if err != nil {
+			log.Printf("生成 Noise 静态密钥失败: %v\n", err)
+			writeHelloAck(conn, HelloAck{ErrorCode: errCodeInternal, ErrorMessage: "生成 Noise 静态密钥失败"})
+			return
+		}
+		document, err := attestNoiseKey(static.Public, hello.NoiseNonce)
+		if err != nil {
+			log.Printf("证明 Noise 静态公钥失败: %v\n", err)
+			writeHelloAck(conn, HelloAck{ErrorCode: errCodeNSMError, ErrorMessage: fmt.Sprintf("证明 Noise 静态公钥失败: %v", err)})
+			return
+		}
+		ack.Noise = hello.Noise
+		ack.NoiseStatic = static.Public
+		ack.Attestation = document
+	case config.RequireNoise:
+		writeHelloAck(conn, HelloAck{ErrorCode: errCodeUnauthorized, ErrorMessage: "服务器要求 Noise 加密通道"})
+		return
+	}
+
+	ackPayload, err := json.Marshal(ack)
+	if err != nil {
+		log.Printf("序列化握手响应失败: %v\n", err)
+		return
+	}
+	if err := writeFrame(conn, ackPayload); err != nil {
+		log.Printf("发送握手响应失败: %v\n", err)
+		return
+	}
+	if sess.hmacKey != nil {
+		sess.transcript = hmacTranscript(payload, ackPayload)
+	}
+
+	// 后续数据经过的传输层，启用 Noise 时为加密连接
+	var transport net.Conn = conn
+	if ack.Noise != "" {
+		nc, err := noiseServerHandshake(conn, ack.Noise, append(payload, ackPayload...))
+		if err != nil {
+			log.Printf("Noise 握手失败: %v\n", err)
+			return
+		}
+		transport = nc
+		log.Printf("已建立 Noise %s 加密通道\n", ack.Noise)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	if !hello.Mux {
+		serveFrames(newFrameConn(transport, sess, 0), sess)
+		return
+	}
+
+	muxSession, err := yamux.Server(transport, nil)
+	if err != nil {
+		log.Printf("创建 yamux 会话失败: %v\n", err)
+		return
+	}
+	defer muxSession.Close()
+
+	log.Println("已建立多路复用会话")
+
+	for {
+		stream, err := muxSession.AcceptStream()
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("接受 yamux 流失败: %v\n", err)
+			}
+			return
+		}
+
+		go func() {
+			defer stream.Close()
+			serveFrames(newFrameConn(stream, sess, stream.StreamID()), sess)
+		}()
+	}
+}
+
+func writeHelloAck(w io.Writer, ack HelloAck) error {
+	payload, err := json.Marshal(ack)
+	if err != nil {
+		return err
+	}
+	return writeFrame(w, payload)
+}
+
+// 在一条连接或流上顺序处理请求帧，直到对端关闭
+// 请求过大、无法解析或处理时发生 panic 时返回结构化错误并结束该连接或流
+func serveFrames(fc *frameConn, sess session) {
+	defer recoverConnection(func(r Response) { writeResponse(fc, sess, r) })
+
+	for {
+		payload, err := fc.ReadFrame(config.MaxRequestSize)
+		if err != nil {
+			if errors.Is(err, errFrameTooLarge) {
+				log.Printf("请求超过 %d 字节上限\n", config.MaxRequestSize)
+				writeResponse(fc, sess, requestTooLargeResponse())
+			} else if errors.Is(err, errBadFrameMAC) {
+				log.Printf("拒绝请求: %v\n", err)
+				writeResponse(fc, sess, Response{ErrorCode: errCodeUnauthorized, ErrorMessage: err.Error()})
+			} else if err != io.EOF {
+				log.Printf("读取请求帧失败: %v\n", err)
+			}
+			return
+		}
+
+		args, err := sess.codec.DecodeRequest(payload)
+		if err != nil {
+			log.Printf("解析参数失败: %v\n", err)
+			writeResponse(fc, sess, Response{ErrorCode: errCodeParseError, ErrorMessage: fmt.Sprintf("解析参数失败: %v", err)})
+			return
+		}
+
+		response := handleRequest(args)
+		auditRequest(sess.peer, args, response)
+		if err := writeResponse(fc, sess, response); err != nil {
+			log.Printf("发送响应失败: %v\n", err)
+			return
+		}
+	}
+}
+
+// 按会话参数编码、压缩并写出响应
+func writeResponse(fc *frameConn, sess session, response Response) error {
+	responsePayload, err := sess.codec.EncodeResponse(response)
+	if err != nil {
+		return fmt.Errorf("序列化响应失败: %v", err)
+	}
+
+	if sess.compression != "" {
+		responsePayload, err = compressPayload(sess.compression, responsePayload)
+		if err != nil {
+			return fmt.Errorf("压缩响应失败: %v", err)
+		}
+	}
+
+	if sess.chunkSize > 0 {
+		return writeChunked(fc, responsePayload, sess.chunkSize)
+	}
+	return fc.WriteFrame(responsePayload)
+}
+
+// 按协商的算法压缩响应负载
+func compressPayload(compression string, payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch compression {
+	case compressionGzip:
+		w = gzip.NewWriter(&buf)
+	case compressionZstd:
+		zw, err := zstd.NewWriter(&buf)
+		if err != nil {
+			return nil, err
+		}
+		w = zw
+	default:
+		return nil, fmt.Errorf("不支持的压缩算法: %s", compression)
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
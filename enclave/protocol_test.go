@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// 帧头: 4 字节大端长度
+func frame(payload []byte) []byte {
+	out := binary.BigEndian.AppendUint32(nil, uint32(len(payload)))
+	return append(out, payload...)
+}
+
+// 读取帧时负载长度不超过上限，截断的输入返回错误而不是 panic
+func FuzzReadFrame(f *testing.F) {
+	f.Add(frame([]byte(`{"mux":true,"codec":"cbor"}`)))
+	f.Add(frame(nil))
+	f.Add([]byte{0x00, 0x00, 0x10})
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0x00})
+	f.Add([]byte{0x00, 0x00, 0x00, 0x08, 0x01})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		payload, err := readFrame(bytes.NewReader(data), maxHelloSize)
+		if err != nil {
+			if payload != nil {
+				t.Fatalf("出错时返回了负载: %v", err)
+			}
+			return
+		}
+		if len(payload) > maxHelloSize {
+			t.Fatalf("负载长度 %d 超过上限 %d", len(payload), maxHelloSize)
+		}
+		if len(data) < 4+len(payload) || !bytes.Equal(payload, data[4:4+len(payload)]) {
+			t.Fatalf("负载与输入不一致")
+		}
+	})
+}
+
+func TestReadFrameTooLarge(t *testing.T) {
+	_, err := readFrame(bytes.NewReader(frame(make([]byte, maxHelloSize+1))), maxHelloSize)
+	if !errors.Is(err, errFrameTooLarge) {
+		t.Fatalf("期望 errFrameTooLarge，实际为 %v", err)
+	}
+	_, err = readFrame(bytes.NewReader(nil), maxHelloSize)
+	if err != io.EOF {
+		t.Fatalf("期望 io.EOF，实际为 %v", err)
+	}
+}
+
+// 各编码的请求种子
+func requestSeeds(t testing.TB) map[string][][]byte {
+	args := CommandArgs{
+		Method:     methodAttestBatch,
+		UserData:   "hello",
+		NonceB64:   "AAECAw==",
+		PCRIndices: []uint16{0, 1, 16},
+		Batch:      []CommandArgs{{UserData: "a", Fresh: true}, {PublicKey: "pk"}},
+	}
+	seeds := map[string][][]byte{}
+
+	data, err := json.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds[codecJSON] = [][]byte{data, []byte(`{"user_data":"x","pcr_indices":[65536]}`), []byte(`null`)}
+
+	data, err = cbor.Marshal(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seeds[codecCBOR] = [][]byte{data, {0x9f, 0x9f, 0x9f}, {0xa1, 0x60, 0x5b, 0xff}}
+
+	var item []byte
+	item = protowire.AppendTag(item, 3, protowire.BytesType)
+	item = protowire.AppendString(item, "a")
+	item = protowire.AppendTag(item, 10, protowire.VarintType)
+	item = protowire.AppendVarint(item, 1)
+	var msg []byte
+	msg = protowire.AppendTag(msg, 2, protowire.BytesType)
+	msg = protowire.AppendString(msg, methodAttestBatch)
+	msg = protowire.AppendTag(msg, 15, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, []byte{0x00, 0x01, 0x10})
+	msg = protowire.AppendTag(msg, 17, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, item)
+	seeds[codecProtobuf] = [][]byte{msg, {0x0a, 0xff, 0xff, 0xff, 0xff, 0x0f}, {0x8a, 0x01, 0x02, 0x8a, 0x01}}
+	return seeds
+}
+
+// 三种编码的请求解码器处理任意输入都不 panic
+func FuzzDecodeRequest(f *testing.F) {
+	names := []string{codecJSON, codecCBOR, codecProtobuf}
+	seeds := requestSeeds(f)
+	for i, name := range names {
+		for _, seed := range seeds[name] {
+			f.Add(uint8(i), seed)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, codec uint8, payload []byte) {
+		args, err := codecs[names[int(codec)%len(names)]].DecodeRequest(payload)
+		if err != nil {
+			return
+		}
+		for _, item := range args.Batch {
+			if len(item.Batch) > 0 && names[int(codec)%len(names)] == codecProtobuf {
+				t.Fatalf("protobuf 解码接受了嵌套的 batch")
+			}
+		}
+	})
+}
+
+func TestDecodeRequestRoundTrip(t *testing.T) {
+	for name, seeds := range requestSeeds(t) {
+		args, err := codecs[name].DecodeRequest(seeds[0])
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if args.Method != methodAttestBatch || len(args.Batch) == 0 || args.Batch[0].UserData != "a" || !args.Batch[0].Fresh {
+			t.Fatalf("%s: 解码结果不符: %+v", name, args)
+		}
+	}
+}
+
+func TestDecodeProtobufNestedBatch(t *testing.T) {
+	var inner []byte
+	inner = protowire.AppendTag(inner, 17, protowire.BytesType)
+	inner = protowire.AppendBytes(inner, nil)
+	var msg []byte
+	msg = protowire.AppendTag(msg, 17, protowire.BytesType)
+	msg = protowire.AppendBytes(msg, inner)
+	if _, err := (protobufCodec{}).DecodeRequest(msg); err == nil {
+		t.Fatal("嵌套的 batch 应被拒绝")
+	}
+}
+
+// 以旧版协议 (直接发送 JSON) 发送请求并读取响应
+func legacyRequest(t *testing.T, args CommandArgs) Response {
+	t.Helper()
+	client, server := net.Pipe()
+	defer client.Close()
+	go handleClient(server)
+
+	if _, err := client.Write(mustJSON(t, args)); err != nil {
+		t.Fatal(err)
+	}
+	var response Response
+	if err := json.NewDecoder(client).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return response
+}
+
+func TestLegacyJSONRequiresHMAC(t *testing.T) {
+	useFakeNSM(t, newFakeNSM())
+	if response := legacyRequest(t, CommandArgs{Method: methodHealth}); !response.Success {
+		t.Fatalf("未配置 HMAC 密钥时应接受旧版请求: %+v", response)
+	}
+
+	hmacKey = []byte("0123456789abcdef0123456789abcdef")
+	defer func() { hmacKey = nil }()
+	if response := legacyRequest(t, CommandArgs{Method: methodHealth}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("配置 HMAC 密钥时应拒绝旧版请求: %+v", response)
+	}
+}
+
+func TestLegacyJSONRequiresNoise(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+
+	config.RequireNoise = true
+	if response := legacyRequest(t, CommandArgs{Method: methodHealth}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("--require-noise 时应拒绝旧版请求: %+v", response)
+	}
+
+	config.RequireNoise = false
+	noiseClientKeys = map[string]bool{}
+	defer func() { noiseClientKeys = nil }()
+	if response := legacyRequest(t, CommandArgs{Method: methodHealth}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("--noise-client-keys 时应拒绝旧版请求: %+v", response)
+	}
+}
+
+// 以帧协议完成 HMAC 握手，返回连接、握手记录摘要及握手响应
+func hmacHandshake(t *testing.T, hello Hello) (net.Conn, []byte, HelloAck) {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go handleClient(server)
+
+	helloPayload := mustJSON(t, hello)
+	if _, err := client.Write(frame(helloPayload)); err != nil {
+		t.Fatal(err)
+	}
+	ackPayload, err := readFrame(client, maxHelloSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ack HelloAck
+	if err := json.Unmarshal(ackPayload, &ack); err != nil {
+		t.Fatal(err)
+	}
+	return client, hmacTranscript(helloPayload, ackPayload), ack
+}
+
+func TestHMACTranscript(t *testing.T) {
+	useFakeNSM(t, newFakeNSM())
+	hmacKey = []byte("0123456789abcdef0123456789abcdef")
+	defer func() { hmacKey = nil }()
+
+	if _, _, ack := hmacHandshake(t, Hello{HMAC: true}); ack.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("缺少客户端随机数的握手应被拒绝: %+v", ack)
+	}
+
+	nonce := bytes.Repeat([]byte{1}, hmacChallengeSize)
+	conn, transcript, ack := hmacHandshake(t, Hello{HMAC: true, HMACNonce: nonce})
+	if !ack.HMAC {
+		t.Fatalf("握手失败: %+v", ack)
+	}
+	fc := &frameConn{rw: conn, key: hmacKey, transcript: transcript}
+	request := mustJSON(t, CommandArgs{Method: methodHealth})
+	conn.Write(frame(append(fc.mac(directionRequest, 0, request), request...)))
+	payload, err := readFrame(conn, maxFrameSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(payload[:hmacTagSize], fc.mac(directionResponse, 0, payload[hmacTagSize:])) {
+		t.Fatalf("响应标签应绑定握手记录")
+	}
+
+	// 以另一次握手的记录计算的标签 (如重放录制的握手响应) 不被接受
+	conn, _, _ = hmacHandshake(t, Hello{HMAC: true, HMACNonce: nonce})
+	conn.Write(frame(append(fc.mac(directionRequest, 0, request), request...)))
+	payload, err = readFrame(conn, maxFrameSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var response Response
+	if err := json.Unmarshal(payload[hmacTagSize:], &response); err != nil || response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("绑定其他握手记录的请求应被拒绝: %+v %v", response, err)
+	}
+}
+
+func TestParseHMACKey(t *testing.T) {
+	if key := parseHMACKey([]byte("0123456789abcdef\r\n")); string(key) != "0123456789abcdef" {
+		t.Fatalf("文本密钥应去除末尾换行: %q", key)
+	}
+	binary := append(bytes.Repeat([]byte{0xff}, 15), '\n')
+	if key := parseHMACKey(binary); !bytes.Equal(key, binary) {
+		t.Fatalf("二进制密钥末尾的 0x0a 不应被去除: %x", key)
+	}
+}
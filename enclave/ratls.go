@@ -0,0 +1,127 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mdlayher/vsock"
+)
+
+// 携带证明文档的证书扩展 OID (非关键扩展) - 与 host 端匹配。
+// 1.3.6.1.4.1.99999 是占位的私有企业号，未向 IANA 注册，该 OID 只在本项目的 Enclave 和客户端之间使用，
+// 不能与其他 RA-TLS 实现互通
+var oidAttestationDocument = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1, 1}
+
+// RA-TLS 证书缓存，超过刷新间隔后重新生成密钥、证明文档和证书
+type ratlsCertCache struct {
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	createdAt time.Time
+}
+
+var ratlsCerts ratlsCertCache
+
+func (c *ratlsCertCache) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cert != nil && time.Since(c.createdAt) < config.RATLSRefresh {
+		return c.cert, nil
+	}
+
+	cert, err := newRATLSCertificate(config.RATLSRefresh * 2)
+	if err != nil {
+		log.Printf("生成 RA-TLS 证书失败: %v\n", err)
+		return nil, err
+	}
+	c.cert = cert
+	c.createdAt = time.Now()
+	return cert, nil
+}
+
+// 生成新的 TLS 密钥对和自签名证书，证书扩展中携带 public_key 为该 TLS 公钥的证明文档
+func newRATLSCertificate(validity time.Duration) (*tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成 TLS 密钥失败: %v", err)
+	}
+
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("编码 TLS 公钥失败: %v", err)
+	}
+
+	response := processRequest(CommandArgs{PublicKey: base64.StdEncoding.EncodeToString(spki)})
+	if !response.Success {
+		return nil, fmt.Errorf("证明 TLS 公钥失败: %s", response.ErrorMessage)
+	}
+	document, err := base64.StdEncoding.DecodeString(strings.TrimSpace(response.Document))
+	if err != nil {
+		document = []byte(response.Document)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+
+	now := enclaveNow()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "aws-enclave-attestation"},
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(validity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		ExtraExtensions: []pkix.Extension{
+			{Id: oidAttestationDocument, Value: document},
+		},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, fmt.Errorf("创建 RA-TLS 证书失败: %v", err)
+	}
+
+	log.Printf("已生成 RA-TLS 证书，证明文档 %d 字节，有效期至 %s\n", len(document), template.NotAfter.Format(time.RFC3339))
+	return &tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// 启动 RA-TLS 监听器，TLS 之上使用与普通 vsock 端口相同的协议
+func startRATLSServer() {
+	listener, err := vsock.Listen(uint32(config.RATLSPort), nil)
+	if err != nil {
+		log.Fatalf("无法创建 RA-TLS vsock 监听器: %v", err)
+	}
+
+	tlsListener := tls.NewListener(listener, &tls.Config{
+		GetCertificate: ratlsCerts.GetCertificate,
+		MinVersion:     tls.VersionTLS13,
+	})
+	defer tlsListener.Close()
+
+	log.Printf("RA-TLS 服务器已启动，监听端口 %d\n", config.RATLSPort)
+
+	for {
+		conn, err := tlsListener.Accept()
+		if err != nil {
+			log.Printf("接受 RA-TLS 连接失败: %v\n", err)
+			continue
+		}
+
+		log.Printf("接收到新 RA-TLS 连接: %v\n", conn.RemoteAddr())
+		go handleClient(conn)
+	}
+}
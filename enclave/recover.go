@@ -0,0 +1,24 @@
+package main
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+// 处理请求时发生 panic 的结构化错误，不向客户端暴露内部细节
+func internalErrorResponse() Response {
+	return Response{ErrorCode: errCodeInternal, ErrorMessage: "服务器内部错误"}
+}
+
+// 以 defer 调用: 捕获当前连接上的 panic 并记录堆栈，respond 不为空时返回内部错误响应
+// 之后由调用方关闭该连接，其他连接不受影响
+func recoverConnection(respond func(Response)) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	log.Printf("处理连接时发生 panic: %v\n%s", r, debug.Stack())
+	if respond != nil {
+		respond(internalErrorResponse())
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// IAM Roles Anywhere CreateSession 的请求和响应
+type rolesAnywhereSessionInput struct {
+	DurationSeconds int    `json:"durationSeconds"`
+	ProfileArn      string `json:"profileArn"`
+	RoleArn         string `json:"roleArn"`
+	TrustAnchorArn  string `json:"trustAnchorArn"`
+}
+
+type rolesAnywhereSessionOutput struct {
+	CredentialSet []struct {
+		Credentials struct {
+			AccessKeyID     string `json:"accessKeyId"`
+			SecretAccessKey string `json:"secretAccessKey"`
+			SessionToken    string `json:"sessionToken"`
+			Expiration      string `json:"expiration"`
+		} `json:"credentials"`
+	} `json:"credentialSet"`
+}
+
+// --roles-anywhere-role 时以 --acm-cert 的证书经 IAM Roles Anywhere 取得角色凭证：证书由信任锚 (ACM 私有 CA) 签发，
+// 私钥只在 Enclave 内以证明文档解密，因此只有该 Enclave 镜像能取得凭证；凭证在过期前自动续期
+func rolesAnywhereCredentials(cfg aws.Config) aws.CredentialsProvider {
+	return aws.NewCredentialsCache(aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return createRolesAnywhereSession(ctx, cfg)
+	}), func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = time.Minute
+	})
+}
+
+func createRolesAnywhereSession(ctx context.Context, cfg aws.Config) (aws.Credentials, error) {
+	acmKeyPairMu.RLock()
+	pair := acmKeyPair
+	acmKeyPairMu.RUnlock()
+	if pair == nil {
+		return aws.Credentials{}, errors.New("ACM 证书尚未加载 (--acm-cert)")
+	}
+	if pair.Leaf == nil {
+		return aws.Credentials{}, errors.New("ACM 证书未解析")
+	}
+
+	body, err := json.Marshal(rolesAnywhereSessionInput{
+		DurationSeconds: int(config.RolesAnywhereDuration / time.Second),
+		ProfileArn:      config.RolesAnywhereProfile,
+		RoleArn:         config.RolesAnywhereRole,
+		TrustAnchorArn:  config.RolesAnywhereTrustAnchor,
+	})
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, awsCallTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, awsEndpoint("rolesanywhere", cfg.Region)+"sessions", bytes.NewReader(body))
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Amz-X509", base64.StdEncoding.EncodeToString(pair.Certificate[0]))
+	if len(pair.Certificate) > 1 {
+		var chain []string
+		for _, der := range pair.Certificate[1:] {
+			chain = append(chain, base64.StdEncoding.EncodeToString(der))
+		}
+		req.Header.Set("X-Amz-X509-Chain", strings.Join(chain, ","))
+	}
+	if err := signX509(req, body, pair, cfg.Region, enclaveNow()); err != nil {
+		return aws.Credentials{}, fmt.Errorf("签名 CreateSession 请求失败: %v", err)
+	}
+
+	resp, err := cfg.HTTPClient.Do(req)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("调用 IAM Roles Anywhere 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBrokerResponse))
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return aws.Credentials{}, fmt.Errorf("IAM Roles Anywhere 返回 %s: %s", resp.Status, bytes.TrimSpace(data))
+	}
+	var output rolesAnywhereSessionOutput
+	if err := json.Unmarshal(data, &output); err != nil {
+		return aws.Credentials{}, fmt.Errorf("解析 CreateSession 响应失败: %v", err)
+	}
+	if len(output.CredentialSet) == 0 {
+		return aws.Credentials{}, errors.New("IAM Roles Anywhere 未返回凭证")
+	}
+	credentials := output.CredentialSet[0].Credentials
+	expiration, err := time.Parse(time.RFC3339, credentials.Expiration)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("无效的凭证过期时间 %q", credentials.Expiration)
+	}
+	return aws.Credentials{
+		AccessKeyID:     credentials.AccessKeyID,
+		SecretAccessKey: credentials.SecretAccessKey,
+		SessionToken:    credentials.SessionToken,
+		Source:          "RolesAnywhere",
+		CanExpire:       true,
+		Expires:         expiration,
+	}, nil
+}
+
+// 以证书私钥按 IAM Roles Anywhere 的 X.509 SigV4 签名请求: 与 SigV4 相同的规范请求，
+// 凭证范围以证书序列号代替访问密钥，签名为私钥对待签字符串的 ECDSA 或 RSA PKCS#1 v1.5 签名
+func signX509(req *http.Request, body []byte, pair *tls.Certificate, region string, now time.Time) error {
+	var algorithm string
+	switch pair.PrivateKey.(type) {
+	case *ecdsa.PrivateKey:
+		algorithm = "AWS4-X509-ECDSA-SHA256"
+	case *rsa.PrivateKey:
+		algorithm = "AWS4-X509-RSA-SHA256"
+	default:
+		return fmt.Errorf("不支持的私钥类型 %T", pair.PrivateKey)
+	}
+
+	amzDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", amzDate)
+	// 签名全部请求头及 Host
+	names := []string{"host"}
+	for name := range req.Header {
+		names = append(names, strings.ToLower(name))
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		value := req.URL.Host
+		if name != "host" {
+			value = strings.TrimSpace(req.Header.Get(name))
+		}
+		canonicalHeaders.WriteString(name + ":" + value + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := amzDate[:8] + "/" + region + "/rolesanywhere/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := algorithm + "\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+	digest := sha256.Sum256([]byte(stringToSign))
+	signature, err := pair.PrivateKey.(crypto.Signer).Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, pair.Leaf.SerialNumber.String(), scope, signedHeaders, hex.EncodeToString(signature)))
+	return nil
+}
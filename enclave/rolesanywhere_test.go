@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 由私有 CA 签发的 ECDSA 证书及其证书链
+func caIssuedKeyPair(t *testing.T) *tls.Certificate {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "private CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(4242),
+		Subject:      pkix.Name{CommonName: "enclave"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caTemplate, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, _ := x509.ParseCertificate(leafDER)
+	return &tls.Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: key, Leaf: leaf}
+}
+
+var x509Authorization = regexp.MustCompile(`^AWS4-X509-ECDSA-SHA256 Credential=(\d+)/(\d{8}/us-east-1/rolesanywhere/aws4_request), SignedHeaders=([a-z0-9;-]+), Signature=([0-9a-f]+)$`)
+
+func TestRolesAnywhereCredentials(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeAWS(t, nil, nil)
+	pair := caIssuedKeyPair(t)
+	acmKeyPair = pair
+	defer func() { acmKeyPair = nil }()
+
+	config.RolesAnywhereTrustAnchor = "arn:aws:rolesanywhere:us-east-1:123456789012:trust-anchor/ta"
+	config.RolesAnywhereProfile = "arn:aws:rolesanywhere:us-east-1:123456789012:profile/p"
+	config.RolesAnywhereRole = "arn:aws:iam::123456789012:role/enclave"
+	config.RolesAnywhereDuration = time.Hour
+
+	// 以 X-Amz-X509 中的证书公钥校验 X.509 SigV4 签名
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		match := x509Authorization.FindStringSubmatch(r.Header.Get("Authorization"))
+		if r.URL.Path != "/sessions" || match == nil || match[1] != "4242" {
+			t.Errorf("无效的 CreateSession 请求: %s %v", r.URL.Path, r.Header)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		der, _ := base64.StdEncoding.DecodeString(r.Header.Get("X-Amz-X509"))
+		cert, err := x509.ParseCertificate(der)
+		if err != nil || r.Header.Get("X-Amz-X509-Chain") != base64.StdEncoding.EncodeToString(pair.Certificate[1]) {
+			t.Errorf("证书或证书链无效: %v", err)
+		}
+		var canonicalHeaders strings.Builder
+		for _, name := range strings.Split(match[3], ";") {
+			value := r.Header.Get(name)
+			if name == "host" {
+				value = r.Host
+			}
+			canonicalHeaders.WriteString(name + ":" + value + "\n")
+		}
+		payloadHash := sha256.Sum256(body)
+		canonicalRequest := strings.Join([]string{r.Method, r.URL.EscapedPath(), r.URL.RawQuery, canonicalHeaders.String(), match[3], hex.EncodeToString(payloadHash[:])}, "\n")
+		requestHash := sha256.Sum256([]byte(canonicalRequest))
+		stringToSign := "AWS4-X509-ECDSA-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + match[2] + "\n" + hex.EncodeToString(requestHash[:])
+		digest := sha256.Sum256([]byte(stringToSign))
+		signature, _ := hex.DecodeString(match[4])
+		if !ecdsa.VerifyASN1(cert.PublicKey.(*ecdsa.PublicKey), digest[:], signature) {
+			t.Error("X.509 SigV4 签名无效")
+		}
+		var input rolesAnywhereSessionInput
+		json.Unmarshal(body, &input)
+		if input.RoleArn != config.RolesAnywhereRole || input.DurationSeconds != 3600 {
+			t.Errorf("CreateSession 参数: %+v", input)
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"credentialSet":[{"credentials":{"accessKeyId":"ASIAANYWHERE","secretAccessKey":"secret",
+"sessionToken":"session","expiration":"2099-01-01T00:00:00Z"}}]}`))
+	}))
+	defer server.Close()
+	awsEndpoint = func(service, region string) string { return server.URL + "/" }
+
+	credentials, err := rolesAnywhereCredentials(*awsConfigLoaded).Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.AccessKeyID != "ASIAANYWHERE" || !credentials.CanExpire {
+		t.Fatalf("角色凭证: %+v", credentials)
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import "fmt"
+
+// 请求/响应的 schema 版本 - 与 client 端匹配
+//
+//	1: 引入 schema_version 之前的格式，未指定 schema_version 的请求按 1 处理。
+//	   错误码只有 REQUEST_TOO_LARGE、BAD_REQUEST、UNAUTHORIZED 和 INTERNAL_ERROR，NSM 失败时不带错误码
+//	2: 细分错误码 (PARSE_ERROR、UNSUPPORTED_METHOD、INVALID_PUBLIC_KEY、NSM_UNAVAILABLE、NSM_ERROR、TIMEOUT)
+//
+// 版本 1 的请求字段是版本 2 的子集，无需转换；响应按请求的版本降级 (见 downgradeResponse)。
+// 高于 schemaVersion 的请求返回 UNSUPPORTED_SCHEMA_VERSION，响应的 schema_version 为服务器支持的最高版本，
+// 客户端可据此降级重试，主机和 Enclave 因此可以分别升级
+const (
+	minSchemaVersion = 1
+	schemaVersion    = 2
+)
+
+// 请求的 schema 版本，未指定时为 1
+func requestSchemaVersion(args CommandArgs) int {
+	if args.SchemaVersion < minSchemaVersion {
+		return minSchemaVersion
+	}
+	return args.SchemaVersion
+}
+
+// 请求的 schema 版本高于服务器支持的版本时的错误响应
+func unsupportedSchemaResponse(version int) Response {
+	return Response{
+		ErrorCode:     errCodeUnsupportedSchema,
+		ErrorMessage:  fmt.Sprintf("不支持的 schema_version %d，服务器最高支持 %d", version, schemaVersion),
+		SchemaVersion: schemaVersion,
+	}
+}
+
+// 版本 2 新增的错误码在版本 1 中的对应值，空字符串表示版本 1 不带错误码
+var schemaV1ErrorCodes = map[string]string{
+	errCodeParseError:        errCodeBadRequest,
+	errCodeUnsupportedMethod: errCodeBadRequest,
+	errCodeInvalidPublicKey:  errCodeBadRequest,
+	errCodeNSMUnavailable:    "",
+	errCodeNSMError:          "",
+	errCodeTimeout:           "",
+}
+
+// 将响应转换为请求方使用的 schema 版本
+func downgradeResponse(version int, response Response) Response {
+	response.SchemaVersion = version
+	if version < 2 {
+		response.ErrorCode = schemaV1ErrorCode(response.ErrorCode)
+		for i := range response.Batch {
+			response.Batch[i].ErrorCode = schemaV1ErrorCode(response.Batch[i].ErrorCode)
+		}
+	}
+	return response
+}
+
+// 错误码在版本 1 中的对应值
+func schemaV1ErrorCode(code string) string {
+	if v1, ok := schemaV1ErrorCodes[code]; ok {
+		return v1
+	}
+	return code
+}
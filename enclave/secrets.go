@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// get-secret 方法的结果: 只有句柄和元数据，明文不离开 Enclave - 与 client 端匹配
+type SecretHandle struct {
+	// Enclave 内的进程以该句柄读取明文 (本地 HTTP 接口的 GET /secrets/<handle>)
+	Handle string `json:"handle" cbor:"handle"`
+	// 密钥的 ARN 及版本
+	ARN       string `json:"arn,omitempty" cbor:"arn,omitempty"`
+	VersionID string `json:"version_id,omitempty" cbor:"version_id,omitempty"`
+	// 解密所用的 KMS 密钥 ARN
+	KeyID string `json:"key_id,omitempty" cbor:"key_id,omitempty"`
+	// 明文字节数
+	Size int `json:"size" cbor:"size"`
+}
+
+// 已解密的密钥，按句柄索引，仅保存在内存中
+var (
+	secretsMu sync.RWMutex
+	secrets   = map[string][]byte{}
+)
+
+type getSecretValueOutput struct {
+	ARN          string
+	VersionId    string
+	SecretBinary []byte
+	SecretString *string
+}
+
+// get-secret 请求: 经 --egress 从 Secrets Manager 读取 secret_id，其值为 KMS 密文 (SecretBinary，
+// 或 base64 编码的 SecretString)，再以证明文档调用 KMS Decrypt 在 Enclave 内解出明文并按 handle 保存，
+// 响应只返回句柄；需以 --allow-get-secret 启动
+func getSecretRequest(args CommandArgs) Response {
+	if !config.AllowGetSecret {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 get-secret 方法 (--allow-get-secret)"}
+	}
+	if args.SecretID == "" {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "必须指定 secret_id"}
+	}
+	handle := args.Handle
+	if handle == "" {
+		handle = args.SecretID
+	}
+
+	ctx := context.Background()
+	cfg, err := loadAWSConfig(ctx, args.Region)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	var secret getSecretValueOutput
+	input := map[string]string{"SecretId": args.SecretID}
+	if err := callAWS(ctx, cfg, "secretsmanager", "secretsmanager.GetSecretValue", input, &secret); err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	ciphertext := secret.SecretBinary
+	if ciphertext == nil && secret.SecretString != nil {
+		ciphertext, err = base64.StdEncoding.DecodeString(strings.TrimSpace(*secret.SecretString))
+		if err != nil {
+			return errorResponse(errCodeBadRequest, fmt.Sprintf("SecretString 不是 base64 编码的 KMS 密文: %v", err))
+		}
+	}
+	if len(ciphertext) == 0 {
+		return errorResponse(errCodeBadRequest, "密钥的值为空")
+	}
+
+	plaintext, keyID, err := kmsDecrypt(ctx, cfg, ciphertext, args.KeyID, nil)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	secretsMu.Lock()
+	secrets[handle] = plaintext
+	secretsMu.Unlock()
+	log.Printf("已获取密钥 %s (版本 %s，句柄 %s，%d 字节)\n", secret.ARN, secret.VersionId, handle, len(plaintext))
+
+	return Response{Success: true, Secret: &SecretHandle{
+		Handle:    handle,
+		ARN:       secret.ARN,
+		VersionID: secret.VersionId,
+		KeyID:     keyID,
+		Size:      len(plaintext),
+	}}
+}
+
+// 本地 HTTP 接口: GET /secrets/<handle> 返回 get-secret 解密的明文
+func handleLocalSecret(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeLocalResponse(w, http.StatusMethodNotAllowed, errorResponse(errCodeUnsupportedMethod, "只支持 GET"))
+		return
+	}
+	handle := strings.TrimPrefix(r.URL.Path, "/secrets/")
+	secretsMu.RLock()
+	value, ok := secrets[handle]
+	secretsMu.RUnlock()
+	if !ok {
+		writeLocalResponse(w, http.StatusNotFound, errorResponse(errCodeBadRequest, fmt.Sprintf("没有句柄为 %q 的密钥", handle)))
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.Itoa(len(value)))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(value)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 定长编码的 BER 元素
+func berTLV(tag byte, parts ...[]byte) []byte {
+	content := bytes.Join(parts, nil)
+	n := len(content)
+	header := []byte{tag, byte(n)}
+	if n >= 0x80 {
+		header = []byte{tag, 0x82, byte(n >> 8), byte(n)}
+	}
+	return append(header, content...)
+}
+
+// 不定长编码的 BER 元素，与 KMS 返回的 CiphertextForRecipient 相同
+func berIndefinite(tag byte, parts ...[]byte) []byte {
+	out := append([]byte{tag, 0x80}, bytes.Join(parts, nil)...)
+	return append(out, 0, 0)
+}
+
+func berOID(t *testing.T, oid asn1.ObjectIdentifier) []byte {
+	encoded, err := asn1.Marshal(oid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoded
+}
+
+// 以 RSAES-OAEP-SHA-256 和 AES-256-CBC 将 plaintext 加密为 CMS EnvelopedData，加密内容分两段
+func envelopedData(t *testing.T, publicKey *rsa.PublicKey, plaintext []byte) []byte {
+	contentKey := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	rand.Read(contentKey)
+	rand.Read(iv)
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKey, contentKey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	ciphertext := append(append([]byte(nil), plaintext...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	block, _ := aes.NewCipher(contentKey)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, ciphertext)
+
+	recipient := berTLV(0x30,
+		berTLV(0x02, []byte{2}),
+		berTLV(0x80, []byte("subject-key-id")),
+		berTLV(0x30, berOID(t, oidRSAESOAEP)),
+		berTLV(0x04, encryptedKey))
+	encryptedContentInfo := berIndefinite(0x30,
+		berOID(t, asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}),
+		berTLV(0x30, berOID(t, oidAES256CBC), berTLV(0x04, iv)),
+		berIndefinite(0xa0, berTLV(0x04, ciphertext[:aes.BlockSize]), berTLV(0x04, ciphertext[aes.BlockSize:])))
+	return berIndefinite(0x30,
+		berOID(t, oidEnvelopedData),
+		berIndefinite(0xa0, berIndefinite(0x30, berTLV(0x02, []byte{2}), berTLV(0x31, recipient), encryptedContentInfo)))
+}
+
+// 模拟 Secrets Manager、SSM、KMS (Decrypt、Sign) 和 S3 (objects 以 BUCKET/KEY 索引): 密钥和 SecureString 参数的值为 KMS 密文，
+// KMS 以证明文档中的公钥加密明文
+func fakeAWS(t *testing.T, plaintext []byte, objects map[string][]byte) *httptest.Server {
+	return httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 ") {
+			t.Errorf("请求未签名: %v", r.Header)
+		}
+		switch r.Header.Get("X-Amz-Target") {
+		case "":
+			if r.Method != http.MethodGet || r.Header.Get("X-Amz-Content-Sha256") == "" {
+				t.Errorf("无效的 S3 请求: %s %v", r.Method, r.Header)
+			}
+			object, ok := objects[strings.TrimPrefix(r.URL.Path, "/")]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.Write(object)
+		case "secretsmanager.GetSecretValue":
+			var input struct{ SecretId string }
+			json.NewDecoder(r.Body).Decode(&input)
+			if input.SecretId == "missing" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"ResourceNotFoundException","Message":"not found"}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]string{
+				"ARN":          "arn:aws:secretsmanager:us-east-1:123456789012:secret:" + input.SecretId,
+				"VersionId":    "v1",
+				"SecretString": base64.StdEncoding.EncodeToString([]byte("kms-ciphertext")),
+			})
+		case "AmazonSSM.GetParameter":
+			var input struct {
+				Name           string
+				WithDecryption bool
+			}
+			json.NewDecoder(r.Body).Decode(&input)
+			if input.WithDecryption {
+				t.Error("不应让 SSM 解密参数")
+			}
+			parameterType := "SecureString"
+			if input.Name == "/app/plain" {
+				parameterType = "String"
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"Parameter": map[string]interface{}{
+				"Name":    input.Name,
+				"Type":    parameterType,
+				"Value":   base64.StdEncoding.EncodeToString([]byte("kms-ciphertext")),
+				"Version": 3,
+				"ARN":     "arn:aws:ssm:us-east-1:123456789012:parameter" + input.Name,
+			}})
+		case "TrentService.Decrypt":
+			var input kmsDecryptInput
+			json.NewDecoder(r.Body).Decode(&input)
+			// SSM 参数的加密上下文为 PARAMETER_ARN
+			if arn, ok := input.EncryptionContext["PARAMETER_ARN"]; ok && !strings.HasPrefix(arn, "arn:aws:ssm:") {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"__type":"InvalidCiphertextException"}`))
+				return
+			}
+			if string(input.CiphertextBlob) != "kms-ciphertext" || input.Recipient == nil || input.Recipient.KeyEncryptionAlgorithm != "RSAES_OAEP_SHA_256" {
+				t.Errorf("无效的 Decrypt 请求: %+v", input)
+			}
+			var document map[string][]byte
+			if err := cbor.Unmarshal(input.Recipient.AttestationDocument, &document); err != nil {
+				t.Fatal(err)
+			}
+			publicKey, err := x509.ParsePKIXPublicKey(document["public_key"])
+			if err != nil {
+				t.Fatal(err)
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyId":                  "arn:aws:kms:us-east-1:123456789012:key/test",
+				"CiphertextForRecipient": envelopedData(t, publicKey.(*rsa.PublicKey), plaintext),
+			})
+		case "TrentService.Sign":
+			var input struct{ KeyId, Message, MessageType, SigningAlgorithm string }
+			json.NewDecoder(r.Body).Decode(&input)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"KeyId":            "arn:aws:kms:us-east-1:123456789012:key/" + input.KeyId,
+				"SigningAlgorithm": input.SigningAlgorithm,
+				"Signature":        []byte(input.MessageType + ":" + input.Message),
+			})
+		default:
+			t.Errorf("未知的 X-Amz-Target: %s", r.Header.Get("X-Amz-Target"))
+		}
+	}))
+}
+
+// 在测试期间以 fakeAWS 代替 AWS 端点及凭证
+func useFakeAWS(t *testing.T, plaintext []byte, objects map[string][]byte) {
+	t.Helper()
+	server := fakeAWS(t, plaintext, objects)
+	savedEndpoint, savedS3ObjectURL := awsEndpoint, s3ObjectURL
+	awsEndpoint = func(service, region string) string { return server.URL }
+	s3ObjectURL = func(bucket, key, region string) string { return server.URL + "/" + bucket + "/" + key }
+	awsConfigLoaded = &aws.Config{
+		Region:     "us-east-1",
+		HTTPClient: server.Client(),
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return aws.Credentials{AccessKeyID: "AKID", SecretAccessKey: "secret"}, nil
+		}),
+	}
+	t.Cleanup(func() {
+		server.Close()
+		awsEndpoint, s3ObjectURL = savedEndpoint, savedS3ObjectURL
+		awsConfigLoaded = nil
+	})
+}
+
+func TestGetSecret(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+
+	plaintext := []byte("database password")
+	useFakeAWS(t, plaintext, nil)
+
+	if r := getSecretRequest(CommandArgs{SecretID: "db"}); r.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("未启用时应拒绝: %+v", r)
+	}
+	config.AllowGetSecret = true
+
+	r := getSecretRequest(CommandArgs{SecretID: "db", Handle: "db-password"})
+	if !r.Success || r.Secret.Handle != "db-password" || r.Secret.Size != len(plaintext) || r.Secret.VersionID != "v1" {
+		t.Fatalf("get-secret: %+v", r)
+	}
+	if bytes.Contains(mustJSON(t, r), plaintext) {
+		t.Fatal("响应中不应包含明文")
+	}
+
+	recorder := httptest.NewRecorder()
+	handleLocalSecret(recorder, httptest.NewRequest(http.MethodGet, "/secrets/db-password", nil))
+	if recorder.Code != http.StatusOK || !bytes.Equal(recorder.Body.Bytes(), plaintext) {
+		t.Fatalf("GET /secrets/db-password: %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	r = getSecretRequest(CommandArgs{SecretID: "missing"})
+	if r.Success || !strings.Contains(r.ErrorMessage, "ResourceNotFoundException") {
+		t.Fatalf("不存在的密钥: %+v", r)
+	}
+}
+
+func mustJSON(t *testing.T, v interface{}) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// 加密会话: 客户端以 session-open 发送临时 X25519 公钥和随机数，Enclave 以自己的临时密钥完成 ECDH，
+// 返回 public_key 为该公钥、nonce 为客户端随机数的证明文档；双方以 HKDF-SHA256 派生两个方向的 AES-256-GCM 密钥，
+// 之后的请求以 session 方法加密发送，vsock 上只有会话 ID、序号和密文。会话不绑定连接，可跨连接使用。
+// 会话密钥达到使用上限 (请求数、字节数、时间) 后，客户端在会话中发送 session-rekey，双方以新的临时密钥再次 ECDH，
+// 并以上一代的链密钥为 salt 派生新密钥后丢弃旧密钥，长期会话因此保持前向安全
+
+const (
+	// HKDF 的 info 前缀，后接会话 ID、密钥代数、客户端公钥和 Enclave 公钥 - 与 client 端匹配
+	sessionInfo = "aws-enclave-attestation session v1"
+	// 会话 ID 的字节数 (十六进制编码后为两倍长度)
+	sessionIDSize = 16
+	// 客户端随机数的最小字节数
+	minSessionNonceSize = 16
+)
+
+// session-open、session-rekey 方法的结果 - 与 client 端匹配
+type SessionInfo struct {
+	ID string `json:"id" cbor:"id"`
+	// Enclave 的临时 X25519 公钥 (32 字节)，session-open 时证明文档的 public_key 为其 SubjectPublicKeyInfo
+	PublicKey []byte `json:"public_key" cbor:"public_key"`
+	// 每代会话密钥的使用上限: 请求数、加密的字节数 (请求和响应的密文) 及时间 (秒)，达到任一上限后只接受 session-rekey
+	RekeyRequests int64 `json:"rekey_requests,omitempty" cbor:"rekey_requests,omitempty"`
+	RekeyBytes    int64 `json:"rekey_bytes,omitempty" cbor:"rekey_bytes,omitempty"`
+	RekeyInterval int64 `json:"rekey_interval,omitempty" cbor:"rekey_interval,omitempty"`
+	// 会话的最长有效期 (秒，自 session-open 起)，到期后须重新 session-open
+	Lifetime int64 `json:"lifetime,omitempty" cbor:"lifetime,omitempty"`
+}
+
+// Enclave 端的会话状态，mu 保证同一会话的请求按序号串行处理
+type secureSession struct {
+	id      string
+	created time.Time
+
+	mu sync.Mutex
+	// 当前一代的客户端到 Enclave、Enclave 到客户端的密钥，及派生下一代密钥的链密钥
+	receive cipher.AEAD
+	send    cipher.AEAD
+	chain   []byte
+	// 密钥代数及当前一代密钥的启用时间、已处理的请求数和加密字节数
+	epoch      uint64
+	epochStart time.Time
+	requests   int64
+	bytes      int64
+	// 下一个请求的序号，更换密钥后继续递增
+	sequence uint64
+
+	// 最后一次使用的时间 (Unix 纳秒)，淘汰时不需要持有 mu
+	lastUsed atomic.Int64
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = map[string]*secureSession{}
+)
+
+// 不经会话也允许的方法 (--require-session)
+func sessionExempt(method string) bool {
+	switch method {
+	case methodAttest, methodAttestBatch, methodHealth, methodSessionOpen, methodSession:
+		return true
+	}
+	return false
+}
+
+// 当前配置下的会话参数
+func newSessionInfo(id string, public []byte) *SessionInfo {
+	return &SessionInfo{
+		ID:            id,
+		PublicKey:     public,
+		RekeyRequests: config.SessionRekeyRequests,
+		RekeyBytes:    config.SessionRekeyBytes,
+		RekeyInterval: int64(config.SessionRekeyInterval / time.Second),
+		Lifetime:      int64(config.SessionMaxLifetime / time.Second),
+	}
+}
+
+// session-open 请求: data_b64 为客户端的临时 X25519 公钥，nonce_b64 为写入证明文档的随机数
+func sessionOpenRequest(args CommandArgs) Response {
+	clientPublic, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 data_b64 失败: %v", err)}
+	}
+	nonce, err := base64.StdEncoding.DecodeString(args.NonceB64)
+	if err != nil || len(nonce) < minSessionNonceSize {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("nonce_b64 必须是至少 %d 字节的随机数", minSessionNonceSize)}
+	}
+	private, shared, errResponse := sessionECDH(clientPublic)
+	if errResponse != nil {
+		return *errResponse
+	}
+	spki, err := x509.MarshalPKIXPublicKey(private.PublicKey())
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	response := processRequest(CommandArgs{PublicKey: base64.StdEncoding.EncodeToString(spki), NonceB64: args.NonceB64})
+	if !response.Success {
+		return response
+	}
+
+	id := make([]byte, sessionIDSize)
+	if _, err := rand.Read(id); err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	now := time.Now()
+	sess := &secureSession{id: hex.EncodeToString(id), created: now, epochStart: now}
+	enclavePublic := private.PublicKey().Bytes()
+	sess.receive, sess.send, sess.chain, err = deriveSessionKeys(shared, nonce, sess.id, 0, clientPublic, enclavePublic)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	sess.lastUsed.Store(now.UnixNano())
+	storeSession(sess)
+	log.Printf("已建立加密会话 %s\n", sess.id)
+
+	response.Session = newSessionInfo(sess.id, enclavePublic)
+	return response
+}
+
+// 以新的临时密钥与客户端公钥完成 ECDH
+func sessionECDH(clientPublic []byte) (*ecdh.PrivateKey, []byte, *Response) {
+	peer, err := ecdh.X25519().NewPublicKey(clientPublic)
+	if err != nil {
+		return nil, nil, &Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("无效的客户端 X25519 公钥: %v", err)}
+	}
+	private, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		response := errorResponse(errCodeInternal, fmt.Sprintf("生成会话密钥失败: %v", err))
+		return nil, nil, &response
+	}
+	shared, err := private.ECDH(peer)
+	if err != nil {
+		return nil, nil, &Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("ECDH 失败: %v", err)}
+	}
+	return private, shared, nil
+}
+
+// 由 ECDH 共享密钥派生第 epoch 代的客户端到 Enclave、Enclave 到客户端的 AES-256-GCM 密钥及下一代的链密钥；
+// 第 0 代的 salt 为 session-open 的随机数，之后为上一代的链密钥 - 与 client 端匹配
+func deriveSessionKeys(shared, salt []byte, id string, epoch uint64, clientPublic, enclavePublic []byte) (cipher.AEAD, cipher.AEAD, []byte, error) {
+	info := append([]byte(sessionInfo), id...)
+	info = binary.BigEndian.AppendUint64(info, epoch)
+	info = append(append(info, clientPublic...), enclavePublic...)
+	keys := make([]byte, 96)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, info), keys); err != nil {
+		return nil, nil, nil, err
+	}
+	clientToEnclave, err := newSessionAEAD(keys[:32])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	enclaveToClient, err := newSessionAEAD(keys[32:64])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return clientToEnclave, enclaveToClient, keys[64:], nil
+}
+
+func newSessionAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// 以序号构造 GCM nonce，两个方向使用不同的密钥，同一密钥下序号不重复 - 与 client 端匹配
+func sessionNonce(sequence uint64) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], sequence)
+	return nonce
+}
+
+// 保存新会话，先淘汰空闲超时的会话，仍超过 --max-sessions 时淘汰最久未使用的会话
+func storeSession(sess *secureSession) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+
+	idleBefore := time.Now().Add(-config.SessionIdleTimeout).UnixNano()
+	for id, existing := range sessions {
+		if existing.lastUsed.Load() < idleBefore {
+			delete(sessions, id)
+		}
+	}
+	for len(sessions) >= config.MaxSessions {
+		var oldest *secureSession
+		for _, existing := range sessions {
+			if oldest == nil || existing.lastUsed.Load() < oldest.lastUsed.Load() {
+				oldest = existing
+			}
+		}
+		delete(sessions, oldest.id)
+		log.Printf("会话数达到上限 %d，淘汰会话 %s\n", config.MaxSessions, oldest.id)
+	}
+	sessions[sess.id] = sess
+}
+
+// 查找未过期的会话，空闲超时或超过 --session-max-lifetime 的会话被删除
+func lookupSession(id string) *secureSession {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	sess, ok := sessions[id]
+	if !ok {
+		return nil
+	}
+	if time.Since(time.Unix(0, sess.lastUsed.Load())) > config.SessionIdleTimeout || time.Since(sess.created) > config.SessionMaxLifetime {
+		delete(sessions, id)
+		return nil
+	}
+	return sess
+}
+
+// 当前一代密钥是否已达到使用上限
+func (s *secureSession) rekeyDue() bool {
+	return s.requests >= config.SessionRekeyRequests || s.bytes >= config.SessionRekeyBytes ||
+		time.Since(s.epochStart) >= config.SessionRekeyInterval
+}
+
+// session 请求: 解密 data_b64 中的请求 (JSON 编码的 CommandArgs)，处理后将响应加密放在 sealed 中；
+// 解密失败或序号不符时以明文返回错误，会话状态不变
+func sessionRequest(args CommandArgs) Response {
+	sess := lookupSession(args.Session)
+	if sess == nil {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "会话不存在或已过期，请重新 session-open"}
+	}
+	sealed, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 data_b64 失败: %v", err)}
+	}
+
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	if args.Sequence != sess.sequence {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("会话请求序号为 %d，期望 %d", args.Sequence, sess.sequence)}
+	}
+	plaintext, err := sess.receive.Open(nil, sessionNonce(sess.sequence), sealed, []byte(sess.id))
+	if err != nil {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "会话请求认证失败"}
+	}
+	sequence := sess.sequence
+	sess.sequence++
+	sess.lastUsed.Store(time.Now().UnixNano())
+
+	// 更换密钥前的检查基于本请求之前的用量，与客户端的计数一致
+	rekeyDue := sess.rekeyDue()
+	var inner CommandArgs
+	var response Response
+	var rekey func()
+	if err := json.Unmarshal(plaintext, &inner); err != nil {
+		response = Response{ErrorCode: errCodeParseError, ErrorMessage: fmt.Sprintf("解析会话请求失败: %v", err)}
+	} else if inner.Method == methodSessionRekey {
+		response, rekey = sess.rekeyRequest(inner)
+	} else if inner.Method == methodSessionOpen || inner.Method == methodSession {
+		response = Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("会话中不能再发送 %s 请求", inner.Method)}
+	} else if rekeyDue {
+		response = Response{ErrorCode: errCodeBadRequest, ErrorMessage: "会话密钥已达到使用上限，须先发送 session-rekey"}
+	} else {
+		response = serveRequest(inner)
+		auditRequest("session:"+sess.id, inner, response)
+	}
+
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return errorResponse(errCodeInternal, fmt.Sprintf("序列化会话响应失败: %v", err))
+	}
+	sealedResponse := sess.send.Seal(nil, sessionNonce(sequence), encoded, []byte(sess.id))
+	sess.requests++
+	sess.bytes += int64(len(sealed) + len(sealedResponse))
+	// 响应仍以旧密钥加密，之后才启用新密钥
+	if rekey != nil {
+		rekey()
+	}
+	return Response{Success: true, Sealed: sealedResponse}
+}
+
+// session-rekey 请求 (只能在会话中发送): data_b64 为客户端新的临时 X25519 公钥，
+// 返回 Enclave 新的临时公钥及启用新一代密钥的函数
+func (s *secureSession) rekeyRequest(args CommandArgs) (Response, func()) {
+	clientPublic, err := base64.StdEncoding.DecodeString(args.DataB64)
+	if err != nil {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("解码 data_b64 失败: %v", err)}, nil
+	}
+	private, shared, errResponse := sessionECDH(clientPublic)
+	if errResponse != nil {
+		return *errResponse, nil
+	}
+	enclavePublic := private.PublicKey().Bytes()
+	receive, send, chain, err := deriveSessionKeys(shared, s.chain, s.id, s.epoch+1, clientPublic, enclavePublic)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error()), nil
+	}
+	return Response{Success: true, Session: newSessionInfo(s.id, enclavePublic)}, func() {
+		s.receive, s.send, s.chain = receive, send, chain
+		s.epoch++
+		s.epochStart = time.Now()
+		s.requests, s.bytes = 0, 0
+		log.Printf("会话 %s 已更换为第 %d 代密钥\n", s.id, s.epoch)
+	}
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// 以客户端的身份建立会话，返回会话 ID、客户端到 Enclave、Enclave 到客户端的密钥及链密钥
+func openTestSession(t *testing.T) (string, cipher.AEAD, cipher.AEAD, []byte) {
+	t.Helper()
+	private, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	nonce := make([]byte, 32)
+	rand.Read(nonce)
+	response := handleRequest(CommandArgs{
+		Method:   methodSessionOpen,
+		DataB64:  base64.StdEncoding.EncodeToString(private.PublicKey().Bytes()),
+		NonceB64: base64.StdEncoding.EncodeToString(nonce),
+	})
+	if !response.Success || response.Session == nil {
+		t.Fatalf("session-open 失败: %+v", response)
+	}
+
+	raw, _ := base64.StdEncoding.DecodeString(response.Document)
+	var document map[string][]byte
+	if err := cbor.Unmarshal(raw, &document); err != nil {
+		t.Fatal(err)
+	}
+	peer, err := ecdh.X25519().NewPublicKey(response.Session.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spki, _ := x509.MarshalPKIXPublicKey(peer)
+	if !bytes.Equal(document["public_key"], spki) || !bytes.Equal(document["nonce"], nonce) {
+		t.Fatalf("证明文档未绑定会话公钥和随机数")
+	}
+
+	shared, _ := private.ECDH(peer)
+	send, receive, chain, err := deriveSessionKeys(shared, nonce, response.Session.ID, 0, private.PublicKey().Bytes(), peer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return response.Session.ID, send, receive, chain
+}
+
+func TestSecureSession(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+	t.Cleanup(func() {
+		sessionsMu.Lock()
+		sessions = map[string]*secureSession{}
+		sessionsMu.Unlock()
+	})
+	config.RequireSession = true
+
+	if response := handleRequest(CommandArgs{Method: methodGetRandom}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("--require-session 时应拒绝未加密的请求: %+v", response)
+	}
+	if response := handleRequest(CommandArgs{Method: methodHealth}); !response.Success {
+		t.Fatalf("health 不要求会话: %+v", response)
+	}
+
+	id, send, receive, _ := openTestSession(t)
+	call := func(sequence uint64, inner CommandArgs) (Response, []byte) {
+		sealed := send.Seal(nil, sessionNonce(sequence), mustJSON(t, inner), []byte(id))
+		return handleRequest(CommandArgs{Method: methodSession, Session: id, Sequence: sequence, DataB64: base64.StdEncoding.EncodeToString(sealed)}), sealed
+	}
+	open := func(sequence uint64, response Response) Response {
+		t.Helper()
+		plaintext, err := receive.Open(nil, sessionNonce(sequence), response.Sealed, []byte(id))
+		if err != nil {
+			t.Fatalf("解密会话响应失败: %v", err)
+		}
+		var inner Response
+		if err := json.Unmarshal(plaintext, &inner); err != nil {
+			t.Fatal(err)
+		}
+		return inner
+	}
+
+	response, sealed := call(0, CommandArgs{Method: methodGetRandom, Length: 16})
+	if !response.Success || response.Random != nil {
+		t.Fatalf("会话请求失败或响应未加密: %+v", response)
+	}
+	if inner := open(0, response); !inner.Success || len(inner.Random) != 16 {
+		t.Fatalf("会话中的 get-random 失败: %+v", inner)
+	}
+
+	// 重放已处理的请求
+	replay := handleRequest(CommandArgs{Method: methodSession, Session: id, Sequence: 0, DataB64: base64.StdEncoding.EncodeToString(sealed)})
+	if replay.ErrorCode != errCodeBadRequest {
+		t.Fatalf("重放的请求应被拒绝: %+v", replay)
+	}
+	// 篡改的请求不消耗序号
+	tampered := send.Seal(nil, sessionNonce(1), mustJSON(t, CommandArgs{Method: methodHealth}), []byte(id))
+	tampered[0] ^= 1
+	if response := handleRequest(CommandArgs{Method: methodSession, Session: id, Sequence: 1, DataB64: base64.StdEncoding.EncodeToString(tampered)}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("篡改的请求应被拒绝: %+v", response)
+	}
+	response, _ = call(1, CommandArgs{Method: methodSessionOpen})
+	if inner := open(1, response); inner.ErrorCode != errCodeBadRequest {
+		t.Fatalf("会话中不能嵌套 session-open: %+v", inner)
+	}
+
+	if response := handleRequest(CommandArgs{Method: methodSession, Session: "unknown"}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("未知会话应被拒绝: %+v", response)
+	}
+
+	// 超过 --max-sessions 时淘汰最久未使用的会话
+	config.MaxSessions = 1
+	openTestSession(t)
+	if response, _ := call(2, CommandArgs{Method: methodHealth}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("被淘汰的会话应被拒绝: %+v", response)
+	}
+}
+
+func TestSessionRekey(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+	t.Cleanup(func() {
+		sessionsMu.Lock()
+		sessions = map[string]*secureSession{}
+		sessionsMu.Unlock()
+	})
+	config.SessionRekeyRequests = 2
+
+	if response := handleRequest(CommandArgs{Method: methodSessionRekey}); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("会话外的 session-rekey 应被拒绝: %+v", response)
+	}
+
+	id, send, receive, chain := openTestSession(t)
+	var sequence uint64
+	call := func(inner CommandArgs) Response {
+		t.Helper()
+		sealed := send.Seal(nil, sessionNonce(sequence), mustJSON(t, inner), []byte(id))
+		response := handleRequest(CommandArgs{Method: methodSession, Session: id, Sequence: sequence, DataB64: base64.StdEncoding.EncodeToString(sealed)})
+		if !response.Success {
+			return response
+		}
+		plaintext, err := receive.Open(nil, sessionNonce(sequence), response.Sealed, []byte(id))
+		if err != nil {
+			t.Fatalf("解密会话响应失败: %v", err)
+		}
+		sequence++
+		var decrypted Response
+		if err := json.Unmarshal(plaintext, &decrypted); err != nil {
+			t.Fatal(err)
+		}
+		return decrypted
+	}
+
+	for i := 0; i < 2; i++ {
+		if response := call(CommandArgs{Method: methodHealth}); !response.Success {
+			t.Fatalf("会话请求失败: %+v", response)
+		}
+	}
+	if response := call(CommandArgs{Method: methodHealth}); response.ErrorCode != errCodeBadRequest {
+		t.Fatalf("达到 --session-rekey-requests 后应只接受 session-rekey: %+v", response)
+	}
+
+	// 响应以旧密钥加密，之后双方以链密钥派生新一代密钥
+	private, _ := ecdh.X25519().GenerateKey(rand.Reader)
+	response := call(CommandArgs{Method: methodSessionRekey, DataB64: base64.StdEncoding.EncodeToString(private.PublicKey().Bytes())})
+	if !response.Success || response.Session == nil || response.Session.RekeyRequests != 2 {
+		t.Fatalf("session-rekey 失败: %+v", response)
+	}
+	peer, err := ecdh.X25519().NewPublicKey(response.Session.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shared, _ := private.ECDH(peer)
+	oldSend := send
+	send, receive, _, err = deriveSessionKeys(shared, chain, id, 1, private.PublicKey().Bytes(), peer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response := call(CommandArgs{Method: methodGetRandom, Length: 8}); !response.Success || len(response.Random) != 8 {
+		t.Fatalf("更换密钥后的会话请求失败: %+v", response)
+	}
+	stale := oldSend.Seal(nil, sessionNonce(sequence), mustJSON(t, CommandArgs{Method: methodHealth}), []byte(id))
+	if response := handleRequest(CommandArgs{Method: methodSession, Session: id, Sequence: sequence, DataB64: base64.StdEncoding.EncodeToString(stale)}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("以旧密钥加密的请求应被拒绝: %+v", response)
+	}
+
+	// 超过 --session-max-lifetime 的会话被删除
+	sessionsMu.Lock()
+	sessions[id].created = time.Now().Add(-config.SessionMaxLifetime - time.Minute)
+	sessionsMu.Unlock()
+	if response := call(CommandArgs{Method: methodHealth}); response.ErrorCode != errCodeUnauthorized {
+		t.Fatalf("超过最长有效期的会话应被拒绝: %+v", response)
+	}
+}
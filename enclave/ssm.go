@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+// 环境变量名
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// 一条 --ssm-env 映射: 将 SSM 参数的明文作为环境变量 Env 传给受监管的应用
+type ssmEnvMapping struct {
+	Env       string
+	Parameter string
+}
+
+// 可重复指定的 --ssm-env 参数，格式为 ENV=/参数/名称
+type ssmEnvList []ssmEnvMapping
+
+func (l *ssmEnvList) String() string {
+	var parts []string
+	for _, m := range *l {
+		parts = append(parts, m.Env+"="+m.Parameter)
+	}
+	return strings.Join(parts, ",")
+}
+
+func (l *ssmEnvList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		env, parameter, ok := strings.Cut(item, "=")
+		if !ok || parameter == "" {
+			return fmt.Errorf("无效的参数映射 %q (格式为 ENV=/参数/名称)", item)
+		}
+		if !envNamePattern.MatchString(env) {
+			return fmt.Errorf("无效的环境变量名 %q", env)
+		}
+		*l = append(*l, ssmEnvMapping{Env: env, Parameter: parameter})
+	}
+	return nil
+}
+
+type ssmParameter struct {
+	Name    string
+	Type    string
+	Value   string
+	Version int64
+	ARN     string
+}
+
+// 读取 SecureString 参数的密文 (不让 SSM 解密)，再以证明文档经 KMS 解密；
+// SSM 以 PARAMETER_ARN 作为加密上下文，只支持标准层参数
+func getSSMParameter(ctx context.Context, cfg aws.Config, name, keyID string) ([]byte, ssmParameter, string, error) {
+	var output struct{ Parameter ssmParameter }
+	input := map[string]interface{}{"Name": name, "WithDecryption": false}
+	if err := callAWS(ctx, cfg, "ssm", "AmazonSSM.GetParameter", input, &output); err != nil {
+		return nil, ssmParameter{}, "", err
+	}
+	parameter := output.Parameter
+	if parameter.Type != "SecureString" {
+		return nil, parameter, "", fmt.Errorf("参数 %s 的类型为 %s，只支持 SecureString", name, parameter.Type)
+	}
+	ciphertext, err := base64.StdEncoding.DecodeString(parameter.Value)
+	if err != nil {
+		return nil, parameter, "", fmt.Errorf("参数 %s 的值不是 KMS 密文: %v", name, err)
+	}
+	plaintext, usedKey, err := kmsDecrypt(ctx, cfg, ciphertext, keyID, map[string]string{"PARAMETER_ARN": parameter.ARN})
+	if err != nil {
+		return nil, parameter, "", err
+	}
+	return plaintext, parameter, usedKey, nil
+}
+
+// get-parameter 请求: 读取 SecureString 参数 parameter 并在 Enclave 内解密，明文与 get-secret 的密钥一样按 handle 保存，
+// 响应只返回句柄；需以 --allow-get-secret 启动
+func getParameterRequest(args CommandArgs) Response {
+	if !config.AllowGetSecret {
+		return Response{ErrorCode: errCodeUnauthorized, ErrorMessage: "未启用 get-parameter 方法 (--allow-get-secret)"}
+	}
+	if args.Parameter == "" {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "必须指定 parameter"}
+	}
+	handle := args.Handle
+	if handle == "" {
+		handle = args.Parameter
+	}
+
+	ctx := context.Background()
+	cfg, err := loadAWSConfig(ctx, args.Region)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	plaintext, parameter, keyID, err := getSSMParameter(ctx, cfg, args.Parameter, args.KeyID)
+	if err != nil {
+		return errorResponse(errCodeInternal, err.Error())
+	}
+	secretsMu.Lock()
+	secrets[handle] = plaintext
+	secretsMu.Unlock()
+	log.Printf("已获取参数 %s (版本 %d，句柄 %s，%d 字节)\n", parameter.ARN, parameter.Version, handle, len(plaintext))
+
+	return Response{Success: true, Secret: &SecretHandle{
+		Handle:    handle,
+		ARN:       parameter.ARN,
+		VersionID: strconv.FormatInt(parameter.Version, 10),
+		KeyID:     keyID,
+		Size:      len(plaintext),
+	}}
+}
+
+// 启动时读取 --ssm-env 中的参数，返回 ENV=明文 形式的环境变量；失败时按退避间隔重试
+func loadSSMEnv() ([]string, error) {
+	var env []string
+	for _, mapping := range config.SSMEnv {
+		mapping := mapping
+		err := retryStartup("读取参数 "+mapping.Parameter, func() error {
+			ctx := context.Background()
+			cfg, err := loadAWSConfig(ctx, "")
+			if err != nil {
+				return err
+			}
+			plaintext, parameter, _, err := getSSMParameter(ctx, cfg, mapping.Parameter, "")
+			if err != nil {
+				return err
+			}
+			log.Printf("已读取参数 %s (版本 %d) 到环境变量 %s\n", parameter.ARN, parameter.Version, mapping.Env)
+			env = append(env, mapping.Env+"="+string(plaintext))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return env, nil
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestGetParameter(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+	plaintext := []byte("api-token")
+	useFakeAWS(t, plaintext, nil)
+	config.AllowGetSecret = true
+
+	r := getParameterRequest(CommandArgs{Parameter: "/app/token"})
+	if !r.Success || r.Secret.Handle != "/app/token" || r.Secret.VersionID != "3" || r.Secret.Size != len(plaintext) {
+		t.Fatalf("get-parameter: %+v", r)
+	}
+	secretsMu.RLock()
+	stored := secrets["/app/token"]
+	secretsMu.RUnlock()
+	if !bytes.Equal(stored, plaintext) {
+		t.Fatalf("保存的明文 = %q", stored)
+	}
+
+	if r := getParameterRequest(CommandArgs{Parameter: "/app/plain"}); r.Success || !strings.Contains(r.ErrorMessage, "SecureString") {
+		t.Fatalf("String 参数应被拒绝: %+v", r)
+	}
+
+	var mappings ssmEnvList
+	if err := mappings.Set("API_TOKEN=/app/token"); err != nil {
+		t.Fatal(err)
+	}
+	if err := mappings.Set("1BAD=/app/token"); err == nil {
+		t.Fatal("无效的环境变量名应被拒绝")
+	}
+	config.SSMEnv = mappings
+	env, err := loadSSMEnv()
+	if err != nil || len(env) != 1 || env[0] != "API_TOKEN=api-token" {
+		t.Fatalf("loadSSMEnv = %q, %v", env, err)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// OIDC Broker 响应的最大字节数
+const maxBrokerResponse = 64 << 10
+
+// 换取 ID Token 的请求和响应 - 与 oidc 包匹配
+type brokerNonceResponse struct {
+	Nonce string `json:"nonce"`
+}
+
+type brokerTokenRequest struct {
+	Document string `json:"document"`
+	Audience string `json:"audience,omitempty"`
+}
+
+type brokerTokenResponse struct {
+	IDToken string `json:"id_token"`
+}
+
+type brokerErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// 以证明文档从 --oidc-broker 换取 ID Token，供 AssumeRoleWithWebIdentity 使用：
+// 先向 Broker 获取一次性随机数，再提交带该随机数的证明文档，Broker 校验签名和 PCR 后签发 sub 为度量值的 ID Token
+type attestedIdentityToken struct{}
+
+func (attestedIdentityToken) GetIdentityToken() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), awsCallTimeout)
+	defer cancel()
+
+	var nonce brokerNonceResponse
+	if err := postBroker(ctx, "/v1/nonce", struct{}{}, &nonce); err != nil {
+		return nil, err
+	}
+	response := processRequest(CommandArgs{Nonce: nonce.Nonce})
+	if !response.Success {
+		return nil, fmt.Errorf("生成证明文档失败: %s", response.ErrorMessage)
+	}
+	var token brokerTokenResponse
+	if err := postBroker(ctx, "/v1/token", brokerTokenRequest{Document: response.Document, Audience: config.OIDCAudience}, &token); err != nil {
+		return nil, err
+	}
+	if token.IDToken == "" {
+		return nil, fmt.Errorf("OIDC Broker 未返回 ID Token")
+	}
+	return []byte(token.IDToken), nil
+}
+
+// 经 --egress 向 OIDC Broker 发送 JSON 请求
+func postBroker(ctx context.Context, path string, body, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(config.OIDCBroker, "/")+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := egressHTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 OIDC Broker 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		var apiErr brokerErrorResponse
+		json.NewDecoder(io.LimitReader(resp.Body, maxBrokerResponse)).Decode(&apiErr)
+		return fmt.Errorf("OIDC Broker 返回 %d: %s", resp.StatusCode, apiErr.Error)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxBrokerResponse)).Decode(out)
+}
+
+// --assume-role 时以 AssumeRoleWithWebIdentity 取得的角色凭证代替默认凭证链 (父实例的角色)：
+// 该调用不需要签名，Enclave 的 AWS 权限只取决于 Broker 签发的 ID Token，即 Enclave 的度量值；
+// 凭证在过期前自动以新的证明文档续期
+func webIdentityCredentials(cfg aws.Config) aws.CredentialsProvider {
+	client := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		o.BaseEndpoint = aws.String(awsEndpoint("sts", cfg.Region))
+	})
+	provider := stscreds.NewWebIdentityRoleProvider(client, config.AssumeRole, attestedIdentityToken{}, func(o *stscreds.WebIdentityRoleOptions) {
+		o.RoleSessionName = config.AssumeRoleSession
+		o.Duration = config.AssumeRoleDuration
+	})
+	return aws.NewCredentialsCache(provider, func(o *aws.CredentialsCacheOptions) {
+		o.ExpiryWindow = time.Minute
+	})
+}
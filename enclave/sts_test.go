@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+func TestWebIdentityCredentials(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+	useFakeNSM(t, newFakeNSM())
+	useFakeAWS(t, nil, nil)
+
+	// OIDC Broker: 签发随机数，校验证明文档中的随机数后签发 ID Token
+	broker := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/nonce":
+			json.NewEncoder(w).Encode(brokerNonceResponse{Nonce: "broker-nonce"})
+		case "/v1/token":
+			var req brokerTokenRequest
+			json.NewDecoder(r.Body).Decode(&req)
+			data, _ := base64.StdEncoding.DecodeString(req.Document)
+			var document map[string][]byte
+			if err := cbor.Unmarshal(data, &document); err != nil || string(document["nonce"]) != "broker-nonce" || req.Audience != "sts.amazonaws.com" {
+				w.WriteHeader(http.StatusUnauthorized)
+				json.NewEncoder(w).Encode(brokerErrorResponse{Error: "证明文档无效"})
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id_token": "attested-id-token", "token_type": "N_A", "expires_in": 900})
+		}
+	}))
+	defer broker.Close()
+
+	// STS: AssumeRoleWithWebIdentity 不签名，以 ID Token 换取角色凭证
+	stsServer := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Header.Get("Authorization") != "" || r.Form.Get("Action") != "AssumeRoleWithWebIdentity" ||
+			r.Form.Get("WebIdentityToken") != "attested-id-token" || r.Form.Get("RoleArn") != "arn:aws:iam::123456789012:role/enclave" {
+			t.Errorf("无效的 STS 请求: %v %v", r.Header, r.Form)
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		w.Write([]byte(`<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+<AssumeRoleWithWebIdentityResult><Credentials><AccessKeyId>ASIAENCLAVE</AccessKeyId><SecretAccessKey>secret</SecretAccessKey>
+<SessionToken>session</SessionToken><Expiration>2099-01-01T00:00:00Z</Expiration></Credentials></AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`))
+	}))
+	defer stsServer.Close()
+	awsEndpoint = func(service, region string) string { return stsServer.URL }
+
+	config.OIDCBroker = broker.URL
+	config.AssumeRole = "arn:aws:iam::123456789012:role/enclave"
+	credentials, err := webIdentityCredentials(*awsConfigLoaded).Retrieve(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if credentials.AccessKeyID != "ASIAENCLAVE" || credentials.SessionToken != "session" {
+		t.Fatalf("角色凭证: %+v", credentials)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// token 方法默认的 JWT 有效期
+const defaultTokenTTL = 5 * time.Minute
+
+// JWT 签名密钥及绑定其公钥的证明文档，首次使用时生成，仅保存在内存中
+type tokenSigner struct {
+	key      *ecdsa.PrivateKey
+	keyID    string
+	spki     []byte
+	document string
+
+	moduleID string
+	pcrs     map[int][]byte
+}
+
+var (
+	tokenSignerOnce sync.Once
+	tokenSignerVal  *tokenSigner
+	tokenSignerErr  error
+)
+
+// 证明文档载荷中用于 JWT 声明的字段
+type tokenDocumentClaims struct {
+	ModuleID string         `cbor:"module_id"`
+	PCRs     map[int][]byte `cbor:"pcrs"`
+}
+
+func getTokenSigner() (*tokenSigner, error) {
+	tokenSignerOnce.Do(func() {
+		tokenSignerVal, tokenSignerErr = newTokenSigner()
+		if tokenSignerErr == nil {
+			log.Printf("已生成 JWT 签名密钥: %s\n", tokenSignerVal.keyID)
+		}
+	})
+	return tokenSignerVal, tokenSignerErr
+}
+
+// 生成 ECDSA P-256 签名密钥，并获取 public_key 为该公钥的证明文档
+func newTokenSigner() (*tokenSigner, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("生成 JWT 签名密钥失败: %v", err)
+	}
+
+	spki, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("编码 JWT 签名公钥失败: %v", err)
+	}
+
+	response := processRequest(CommandArgs{PublicKey: base64.StdEncoding.EncodeToString(spki)})
+	if !response.Success {
+		return nil, fmt.Errorf("证明 JWT 签名公钥失败: %s", response.ErrorMessage)
+	}
+
+	claims, err := parseDocumentClaims(response.Document)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenSigner{
+		key:      key,
+		keyID:    jwkThumbprint(&key.PublicKey),
+		spki:     spki,
+		document: strings.TrimSpace(response.Document),
+		moduleID: claims.ModuleID,
+		pcrs:     claims.PCRs,
+	}, nil
+}
+
+// 从本 Enclave 生成的证明文档中提取 module_id 和 PCR
+func parseDocumentClaims(document string) (*tokenDocumentClaims, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(document))
+	if err != nil {
+		raw = []byte(document)
+	}
+
+	var sign1 struct {
+		_           struct{} `cbor:",toarray"`
+		Protected   []byte
+		Unprotected cbor.RawMessage
+		Payload     []byte
+		Signature   []byte
+	}
+	if err := cbor.Unmarshal(raw, &sign1); err != nil {
+		return nil, fmt.Errorf("解析证明文档失败: %v", err)
+	}
+
+	var claims tokenDocumentClaims
+	if err := cbor.Unmarshal(sign1.Payload, &claims); err != nil {
+		return nil, fmt.Errorf("解析证明文档载荷失败: %v", err)
+	}
+	return &claims, nil
+}
+
+// 签发以证明过的密钥签名的短期 JWT，响应同时携带该密钥的证明文档
+func issueToken(args CommandArgs) Response {
+	if args.Audience == "" {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: "token 方法必须指定 audience"}
+	}
+
+	ttl := defaultTokenTTL
+	if args.TTL > 0 {
+		ttl = time.Duration(args.TTL) * time.Second
+	}
+	if ttl > config.TokenMaxTTL {
+		return Response{ErrorCode: errCodeBadRequest, ErrorMessage: fmt.Sprintf("有效期超过上限 %s", config.TokenMaxTTL)}
+	}
+
+	signer, err := getTokenSigner()
+	if err != nil {
+		log.Printf("%v\n", err)
+		return errorResponse(errCodeInternal, err.Error())
+	}
+
+	now := enclaveNow()
+	claims := jwt.MapClaims{
+		"iss":       config.TokenIssuer,
+		"sub":       signer.moduleID,
+		"aud":       args.Audience,
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"exp":       now.Add(ttl).Unix(),
+		"module_id": signer.moduleID,
+	}
+	for index, value := range signer.pcrs {
+		claims["pcr"+strconv.Itoa(index)] = hex.EncodeToString(value)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = signer.keyID
+	signed, err := token.SignedString(signer.key)
+	if err != nil {
+		return errorResponse(errCodeInternal, fmt.Sprintf("签发 JWT 失败: %v", err))
+	}
+
+	log.Printf("已签发 audience 为 %s 的 JWT，有效期 %s\n", args.Audience, ttl)
+	return Response{Success: true, Token: signed, Document: signer.document}
+}
+
+// 为 JWT 签名公钥生成新的证明文档，可携带调用方随机数以证明新鲜度
+func attestSigningKey(args CommandArgs) Response {
+	signer, err := getTokenSigner()
+	if err != nil {
+		log.Printf("%v\n", err)
+		return errorResponse(errCodeInternal, err.Error())
+	}
+
+	return processRequest(CommandArgs{
+		PublicKey: base64.StdEncoding.EncodeToString(signer.spki),
+		Nonce:     args.Nonce,
+	})
+}
+
+// RFC 7638 JWK 指纹，用作 kid
+func jwkThumbprint(key *ecdsa.PublicKey) string {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	canonical := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`,
+		base64.RawURLEncoding.EncodeToString(x), base64.RawURLEncoding.EncodeToString(y))
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Enclave 内记录的 span，随响应返回，由主机以 OTLP 导出
+// Enclave 没有网络，因此不直接导出 - 与 client 端匹配
+type TraceSpan struct {
+	Name         string            `json:"name"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id"`
+	Start        int64             `json:"start"`
+	End          int64             `json:"end"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// 一个请求的追踪，traceparent 为空或无效时为 nil，所有方法均可在 nil 上调用
+type requestTrace struct {
+	parentID string
+
+	mu    sync.Mutex
+	spans []TraceSpan
+}
+
+// 解析 W3C traceparent: 00-<trace-id>-<parent-id>-<flags>，只记录带采样标志的请求
+func newRequestTrace(traceparent string) *requestTrace {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || flags[0]&0x01 == 0 {
+		return nil
+	}
+	return &requestTrace{parentID: parts[2]}
+}
+
+// 进行中的 span
+type traceSpan struct {
+	trace *requestTrace
+	span  TraceSpan
+}
+
+func newSpanID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// 开始一个以请求方 span 为父的 span
+func (t *requestTrace) start(name string) *traceSpan {
+	if t == nil {
+		return nil
+	}
+	return &traceSpan{trace: t, span: TraceSpan{Name: name, SpanID: newSpanID(), ParentSpanID: t.parentID, Start: time.Now().UnixNano()}}
+}
+
+// 已结束的 span
+func (t *requestTrace) finished() []TraceSpan {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.spans
+}
+
+// 开始子 span
+func (s *traceSpan) child(name string) *traceSpan {
+	if s == nil {
+		return nil
+	}
+	return &traceSpan{trace: s.trace, span: TraceSpan{Name: name, SpanID: newSpanID(), ParentSpanID: s.span.SpanID, Start: time.Now().UnixNano()}}
+}
+
+func (s *traceSpan) set(key string, value string) {
+	if s == nil {
+		return
+	}
+	if s.span.Attributes == nil {
+		s.span.Attributes = make(map[string]string)
+	}
+	s.span.Attributes[key] = value
+}
+
+// 结束 span，响应失败时记录错误
+func (s *traceSpan) end(response Response) {
+	if s == nil {
+		return
+	}
+	s.span.End = time.Now().UnixNano()
+	if !response.Success {
+		s.span.Error = response.ErrorMessage
+		if response.ErrorCode != "" {
+			s.set("error_code", response.ErrorCode)
+		}
+	}
+	s.trace.mu.Lock()
+	s.trace.spans = append(s.trace.spans, s.span)
+	s.trace.mu.Unlock()
+}
@@ -0,0 +1,326 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdh"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// age 插件名: recipient 为 age1enclave1...，identity 为 AGE-PLUGIN-ENCLAVE-1...，
+// age 以 age-plugin-enclave --age-plugin=recipient-v1|identity-v1 启动插件
+const (
+	agePluginName = "enclave"
+	ageStanzaType = "enclave"
+)
+
+// 校验 decrypt-key 的证明文档后输出 age recipient，以其加密的文件只有该 Enclave 能解出文件密钥
+func ageRecipientCommand(fs *flag.FlagSet) func(args []string) {
+	var policy verifyPolicy
+	policy.register(fs)
+	docPath := fs.String("doc", "", "Enclave 解密公钥的证明文档 (decrypt-key 子命令的输出，需 --decrypt-key-type x25519)")
+	return func(args []string) {
+		if *docPath == "" {
+			exitf(exitBadInput, "必须指定 --doc")
+		}
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		data, err := os.ReadFile(*docPath)
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		claims, err := policy.verifyEvidence(attestation.EvidenceNitro, attestation.Decode(data))
+		if err != nil {
+			exitf(exitCode(err), "校验失败: %v", err)
+		}
+		public, err := x509.ParsePKIXPublicKey(claims.PublicKey)
+		if err != nil {
+			exitf(exitBadInput, "解析证明文档中的公钥失败: %v", err)
+		}
+		key, ok := public.(*ecdh.PublicKey)
+		if !ok || key.Curve() != ecdh.X25519() {
+			exitf(exitBadInput, "age 需要 X25519 解密公钥 (Enclave 以 --decrypt-key-type x25519 启动)，证明文档中为 %T", public)
+		}
+		recipient := bech32Encode("age1"+agePluginName, key.Bytes())
+		if jsonOutput {
+			printJSON(map[string]string{"recipient": recipient, "module_id": claims.ModuleID})
+			return
+		}
+		fmt.Println(recipient)
+	}
+}
+
+// 输出 age identity，其中只记录 Enclave 的地址；age -d 经插件将文件密钥发给该 Enclave 解出
+func ageIdentityCommand(fs *flag.FlagSet) func(args []string) {
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		address := enclave.address()
+		fmt.Printf("# created: %s\n", time.Now().Format(time.RFC3339))
+		fmt.Printf("# enclave: %s\n", address)
+		fmt.Println(bech32Encode("AGE-PLUGIN-"+strings.ToUpper(agePluginName)+"-", []byte(address)))
+	}
+}
+
+// age 插件协议中的消息: "-> 类型 参数...\n" 后接以 64 列换行的 base64 (无填充) 正文，
+// 正文的最后一行总是短于 64 列 (可为空行)
+type ageStanza struct {
+	Type string
+	Args []string
+	Body []byte
+}
+
+func readAgeStanza(r *bufio.Reader) (*ageStanza, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	header, ok := strings.CutPrefix(strings.TrimSuffix(line, "\n"), "-> ")
+	if !ok {
+		return nil, fmt.Errorf("无效的 age 消息: %q", line)
+	}
+	fields := strings.Split(header, " ")
+	stanza := &ageStanza{Type: fields[0], Args: fields[1:]}
+	var body strings.Builder
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimSuffix(line, "\n")
+		body.WriteString(line)
+		if len(line) < 64 {
+			break
+		}
+	}
+	stanza.Body, err = base64.RawStdEncoding.Strict().DecodeString(body.String())
+	if err != nil {
+		return nil, fmt.Errorf("解码 age 消息正文失败: %v", err)
+	}
+	return stanza, nil
+}
+
+func writeAgeStanza(w io.Writer, typ string, args []string, body []byte) error {
+	var b strings.Builder
+	b.WriteString("-> " + strings.Join(append([]string{typ}, args...), " ") + "\n")
+	encoded := base64.RawStdEncoding.EncodeToString(body)
+	for len(encoded) >= 64 {
+		b.WriteString(encoded[:64] + "\n")
+		encoded = encoded[64:]
+	}
+	b.WriteString(encoded + "\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// 插件端的连接: 每条命令发出后等待 age 的回复 (ok 或 unsupported)
+type agePluginConn struct {
+	r *bufio.Reader
+	w io.Writer
+}
+
+func (c *agePluginConn) command(typ string, args []string, body []byte) error {
+	if err := writeAgeStanza(c.w, typ, args, body); err != nil {
+		return err
+	}
+	_, err := readAgeStanza(c.r)
+	return err
+}
+
+// 读取第一阶段中 age 发来的消息，直到 done
+func (c *agePluginConn) readPhaseOne() ([]*ageStanza, error) {
+	var stanzas []*ageStanza
+	for {
+		stanza, err := readAgeStanza(c.r)
+		if err != nil {
+			return nil, err
+		}
+		if stanza.Type == "done" {
+			return stanzas, nil
+		}
+		stanzas = append(stanzas, stanza)
+	}
+}
+
+// 以插件模式运行 (age 以 --age-plugin=<状态机> 启动 age-plugin-enclave)
+func runAgePlugin(stateMachine string) {
+	conn := &agePluginConn{r: bufio.NewReader(os.Stdin), w: os.Stdout}
+	var err error
+	switch stateMachine {
+	case "recipient-v1":
+		err = ageWrapFileKeys(conn)
+	case "identity-v1":
+		err = ageUnwrapFileKeys(conn)
+	default:
+		exitf(exitBadInput, "不支持的 age 插件状态机: %s", stateMachine)
+	}
+	if err != nil {
+		exitf(exitFailure, "age 插件: %v", err)
+	}
+}
+
+// recipient-v1: 以 recipient 中的 X25519 公钥包装每个文件密钥
+func ageWrapFileKeys(conn *agePluginConn) error {
+	stanzas, err := conn.readPhaseOne()
+	if err != nil {
+		return err
+	}
+	var recipients []string
+	var fileKeys [][]byte
+	for _, stanza := range stanzas {
+		switch stanza.Type {
+		case "add-recipient":
+			if len(stanza.Args) != 1 {
+				return fmt.Errorf("无效的 add-recipient 消息")
+			}
+			recipients = append(recipients, stanza.Args[0])
+		case "add-identity":
+			return conn.command("error", []string{"identity", "0"}, []byte("enclave 插件不支持加密到 identity，请使用 age-recipient 子命令输出的 recipient"))
+		case "wrap-file-key":
+			fileKeys = append(fileKeys, stanza.Body)
+		}
+	}
+
+	var publicKeys [][]byte
+	for i, recipient := range recipients {
+		hrp, data, err := bech32Decode(recipient)
+		if err != nil || hrp != "age1"+agePluginName || len(data) != 32 {
+			return conn.command("error", []string{"recipient", strconv.Itoa(i)}, []byte("无效的 enclave recipient"))
+		}
+		publicKeys = append(publicKeys, data)
+	}
+	for i, fileKey := range fileKeys {
+		for _, publicKey := range publicKeys {
+			message, err := client.SealAgeFileKey(publicKey, fileKey)
+			if err != nil {
+				return conn.command("error", []string{"internal"}, []byte(err.Error()))
+			}
+			args := []string{strconv.Itoa(i), ageStanzaType, message.KeyID, base64.RawStdEncoding.EncodeToString(message.Encapsulated)}
+			if err := conn.command("recipient-stanza", args, message.Ciphertext); err != nil {
+				return err
+			}
+		}
+	}
+	return writeAgeStanza(conn.w, "done", nil, nil)
+}
+
+// identity-v1: 将 enclave 类型的片段发送给 identity 中的 Enclave 解出文件密钥
+func ageUnwrapFileKeys(conn *agePluginConn) error {
+	stanzas, err := conn.readPhaseOne()
+	if err != nil {
+		return err
+	}
+	var addresses []string
+	files := map[int][]*ageStanza{}
+	var order []int
+	for _, stanza := range stanzas {
+		switch stanza.Type {
+		case "add-identity":
+			if len(stanza.Args) != 1 {
+				return fmt.Errorf("无效的 add-identity 消息")
+			}
+			hrp, data, err := bech32Decode(stanza.Args[0])
+			if err != nil || hrp != "AGE-PLUGIN-"+strings.ToUpper(agePluginName)+"-" {
+				return conn.command("error", []string{"identity", strconv.Itoa(len(addresses))}, []byte("无效的 enclave identity"))
+			}
+			addresses = append(addresses, string(data))
+		case "recipient-stanza":
+			if len(stanza.Args) < 2 {
+				return fmt.Errorf("无效的 recipient-stanza 消息")
+			}
+			index, err := strconv.Atoi(stanza.Args[0])
+			if err != nil {
+				return fmt.Errorf("无效的 recipient-stanza 消息")
+			}
+			if _, ok := files[index]; !ok {
+				order = append(order, index)
+			}
+			files[index] = append(files[index], &ageStanza{Type: stanza.Args[1], Args: stanza.Args[2:], Body: stanza.Body})
+		}
+	}
+
+	for _, index := range order {
+		fileKey, err := ageUnwrapFile(addresses, files[index])
+		if err != nil {
+			// age 收到 error 后即中止解密
+			return conn.command("error", []string{"internal"}, []byte(err.Error()))
+		}
+		if fileKey == nil {
+			continue
+		}
+		if err := conn.command("file-key", []string{strconv.Itoa(index)}, fileKey); err != nil {
+			return err
+		}
+	}
+	return writeAgeStanza(conn.w, "done", nil, nil)
+}
+
+// 依次以每个 Enclave 尝试解出文件中的 enclave 片段；没有 enclave 片段时返回 nil，
+// 所有 Enclave 都失败时返回最后一个错误
+func ageUnwrapFile(addresses []string, stanzas []*ageStanza) ([]byte, error) {
+	var lastErr error
+	for _, stanza := range stanzas {
+		if stanza.Type != ageStanzaType {
+			continue
+		}
+		if len(stanza.Args) != 2 {
+			return nil, fmt.Errorf("无效的 enclave 片段")
+		}
+		encapsulated, err := base64.RawStdEncoding.Strict().DecodeString(stanza.Args[1])
+		if err != nil {
+			return nil, fmt.Errorf("无效的 enclave 片段: %v", err)
+		}
+		sealed, err := json.Marshal(client.SealedMessage{
+			Algorithm:    client.SealHPKE,
+			KeyID:        stanza.Args[0],
+			Encapsulated: encapsulated,
+			Ciphertext:   stanza.Body,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, address := range addresses {
+			fileKey, err := ageUnwrapWith(address, sealed)
+			if err != nil {
+				log.Printf("Enclave %s 未能解出文件密钥: %v\n", address, err)
+				lastErr = err
+				continue
+			}
+			return fileKey, nil
+		}
+	}
+	return nil, lastErr
+}
+
+func ageUnwrapWith(address string, sealed []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	conn, err := client.DialAddressContext(ctx, address, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	response, err := conn.AgeUnwrap(ctx, sealed)
+	if err != nil {
+		return nil, err
+	}
+	if !response.Success {
+		return nil, response.Err()
+	}
+	return response.FileKey, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// 审计日志首条记录的 prev - 与 enclave 端匹配
+var auditGenesis = strings.Repeat("0", sha256.Size*2)
+
+// 审计日志记录 - 与 enclave 端匹配
+// 每行一条 JSON，prev 为上一行的 SHA-256，修改或删除任一行都会破坏哈希链
+type auditEntry struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	Prev string    `json:"prev"`
+
+	Peer   string `json:"peer,omitempty"`
+	Method string `json:"method"`
+	// 请求输入 (user_data、public_key、nonce 等) 的 SHA-256，不记录原文
+	InputsHash string `json:"inputs_sha256"`
+	// 返回的证明文档 (解码后) 的 SHA-256
+	DocumentHash string `json:"document_sha256,omitempty"`
+	// ok 或错误码
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// 主机端记录的校验失败结果
+const auditVerificationFailed = "VERIFICATION_FAILED"
+
+// 追加写入的哈希链审计日志
+type auditLog struct {
+	mu   sync.Mutex
+	w    io.Writer
+	seq  uint64
+	prev string
+}
+
+// 打开审计日志，文件已存在时从最后一条记录继续哈希链
+func openAuditLog(path string) (*auditLog, error) {
+	l := &auditLog{prev: auditGenesis}
+	if data, err := os.ReadFile(path); err == nil {
+		lines, err := auditLines(data)
+		if err != nil {
+			return nil, err
+		}
+		if len(lines) > 0 {
+			last := lines[len(lines)-1]
+			var entry auditEntry
+			if err := json.Unmarshal(last, &entry); err != nil {
+				return nil, fmt.Errorf("解析审计日志最后一条记录失败: %v", err)
+			}
+			sum := sha256.Sum256(last)
+			l.seq = entry.Seq
+			l.prev = hex.EncodeToString(sum[:])
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取审计日志失败: %v", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("打开审计日志失败: %v", err)
+	}
+	l.w = f
+	return l, nil
+}
+
+// 按行拆分审计日志，忽略空行
+func auditLines(data []byte) ([][]byte, error) {
+	var lines [][]byte
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if line := bytes.TrimSpace(scanner.Bytes()); len(line) > 0 {
+			lines = append(lines, append([]byte(nil), line...))
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// 追加一条记录，填充序号、时间和 prev
+func (l *auditLog) append(entry auditEntry) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	entry.Seq = l.seq + 1
+	entry.Time = time.Now().UTC()
+	entry.Prev = l.prev
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if _, err := l.w.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("写入审计日志失败: %v", err)
+	}
+
+	sum := sha256.Sum256(line)
+	l.seq = entry.Seq
+	l.prev = hex.EncodeToString(sum[:])
+	return nil
+}
+
+// 请求输入的 SHA-256 - 与 enclave 端匹配
+func auditInputsHash(args client.CommandArgs) string {
+	inputs, _ := json.Marshal([]interface{}{args.UserData, args.UserDataB64, args.PublicKey, args.Nonce, args.NonceB64, args.Audience, args.TTL})
+	sum := sha256.Sum256(inputs)
+	return hex.EncodeToString(sum[:])
+}
+
+// 校验审计日志的哈希链，返回已校验的记录数
+func verifyAuditLog(data []byte) (int, error) {
+	lines, err := auditLines(data)
+	if err != nil {
+		return 0, err
+	}
+
+	prev := auditGenesis
+	var seq uint64
+	for i, line := range lines {
+		var entry auditEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return i, fmt.Errorf("第 %d 行无法解析: %v", i+1, err)
+		}
+		// 首条记录可以从任意序号开始 (日志轮转后)，此后必须连续
+		if i > 0 && entry.Seq != seq+1 {
+			return i, fmt.Errorf("第 %d 行序号 %d 不连续 (应为 %d)", i+1, entry.Seq, seq+1)
+		}
+		if i > 0 && entry.Prev != prev {
+			return i, fmt.Errorf("第 %d 行 (序号 %d) 的 prev 与上一行哈希不一致，日志可能被篡改", i+1, entry.Seq)
+		}
+		sum := sha256.Sum256(line)
+		prev = hex.EncodeToString(sum[:])
+		seq = entry.Seq
+	}
+	return len(lines), nil
+}
+
+// 校验主机或 Enclave 审计日志的哈希链
+func auditVerifyCommand(fs *flag.FlagSet) func(args []string) {
+	return func(args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取审计日志失败: %v", err)
+		}
+		n, err := verifyAuditLog(data)
+		if err != nil {
+			exitf(exitVerification, "哈希链校验失败: %v", err)
+		}
+		if jsonOutput {
+			printJSON(struct {
+				Valid   bool `json:"valid"`
+				Entries int  `json:"entries"`
+			}{true, n})
+			return
+		}
+		fmt.Printf("哈希链完好: %d 条记录\n", n)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// age 的 recipient 和 identity 使用的 Bech32 编码 (BIP 173，不限制长度，HRP 为大写时输出大写)
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+var bech32Generator = []uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+
+func bech32Polymod(values []byte) uint32 {
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range bech32Generator {
+			if top>>i&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
+
+func bech32HRPExpand(hrp string) []byte {
+	hrp = strings.ToLower(hrp)
+	values := make([]byte, 0, len(hrp)*2+1)
+	for _, c := range []byte(hrp) {
+		values = append(values, c>>5)
+	}
+	values = append(values, 0)
+	for _, c := range []byte(hrp) {
+		values = append(values, c&31)
+	}
+	return values
+}
+
+// 在 8 位与 5 位分组之间转换，pad 为 false 时拒绝非零或多余的填充位
+func bech32ConvertBits(data []byte, from, to uint, pad bool) ([]byte, error) {
+	var out []byte
+	acc, bits := uint32(0), uint(0)
+	max := uint32(1)<<to - 1
+	for _, value := range data {
+		if value>>from != 0 {
+			return nil, fmt.Errorf("无效的数据")
+		}
+		acc = acc<<from | uint32(value)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&max))
+		}
+	}
+	if pad {
+		if bits > 0 {
+			out = append(out, byte(acc<<(to-bits)&max))
+		}
+	} else if bits >= from || acc<<(to-bits)&max != 0 {
+		return nil, fmt.Errorf("无效的填充位")
+	}
+	return out, nil
+}
+
+func bech32Encode(hrp string, data []byte) string {
+	values, _ := bech32ConvertBits(data, 8, 5, true)
+	lower := strings.ToLower(hrp)
+	checksum := bech32Polymod(append(append(bech32HRPExpand(lower), values...), 0, 0, 0, 0, 0, 0)) ^ 1
+	var b strings.Builder
+	b.WriteString(lower)
+	b.WriteByte('1')
+	for _, v := range values {
+		b.WriteByte(bech32Charset[v])
+	}
+	for i := 0; i < 6; i++ {
+		b.WriteByte(bech32Charset[checksum>>(5*(5-i))&31])
+	}
+	if hrp != lower {
+		return strings.ToUpper(b.String())
+	}
+	return b.String()
+}
+
+// 返回 HRP (与输入的大小写一致) 及数据
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, fmt.Errorf("Bech32 字符串大小写混用")
+	}
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, fmt.Errorf("无效的 Bech32 字符串")
+	}
+	hrp := s[:pos]
+	var values []byte
+	for _, c := range strings.ToLower(s[pos+1:]) {
+		v := strings.IndexRune(bech32Charset, c)
+		if v < 0 {
+			return "", nil, fmt.Errorf("Bech32 字符串中有无效字符 %q", c)
+		}
+		values = append(values, byte(v))
+	}
+	if bech32Polymod(append(bech32HRPExpand(hrp), values...)) != 1 {
+		return "", nil, fmt.Errorf("Bech32 校验和错误")
+	}
+	data, err := bech32ConvertBits(values[:len(values)-6], 5, 8, false)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
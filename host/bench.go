@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// --json 时 bench 输出的压测结果，延迟单位为毫秒
+type benchResult struct {
+	Concurrency int     `json:"concurrency"`
+	Duration    string  `json:"duration"`
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	Throughput  float64 `json:"throughput"`
+	P50MS       float64 `json:"p50_ms"`
+	P95MS       float64 `json:"p95_ms"`
+	P99MS       float64 `json:"p99_ms"`
+	MaxMS       float64 `json:"max_ms"`
+}
+
+// 已排序延迟的百分位数 (最近秩法)
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(p/100*float64(len(sorted))+0.5) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(sorted) {
+		rank = len(sorted) - 1
+	}
+	return sorted[rank]
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// 以 --concurrency 个并发 worker 持续请求证明文档，统计吞吐量和延迟分布
+func benchCommand(fs *flag.FlagSet) func(args []string) {
+	concurrency := fs.Int("concurrency", 1, "并发请求数")
+	duration := fs.Duration("duration", 60*time.Second, "压测时长")
+	codecFlag := fs.String("codec", "json", "vsock 协议编码 (json、cbor 或 protobuf)")
+	mux := fs.Bool("mux", false, "所有 worker 共享一个 yamux 多路复用连接 (默认每个 worker 使用独立连接)")
+	cached := fs.Bool("cached", false, "允许 Enclave 返回缓存的文档 (默认每个请求都生成新文档)")
+	userData := fs.String("userdata", "", "用户数据")
+	var nonceRandom randomNonceFlag
+	fs.Var(&nonceRandom, "nonce-random", "每个请求在本地生成 N 字节随机数 (默认 32) 作为 nonce")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *concurrency < 1 {
+			exitf(exitBadInput, "--concurrency 必须大于 0")
+		}
+		if *duration <= 0 {
+			exitf(exitBadInput, "--duration 必须大于 0")
+		}
+
+		opts := &client.Options{Mux: *mux, Codec: *codecFlag}
+		conns := make([]*client.Client, *concurrency)
+		for i := range conns {
+			if *mux && i > 0 {
+				conns[i] = conns[0]
+				continue
+			}
+			conn, err := enclave.dial(opts)
+			if err != nil {
+				exitWithError(err)
+			}
+			defer conn.Close()
+			conns[i] = conn
+		}
+
+		request := client.CommandArgs{UserData: *userData, Fresh: !*cached}
+		ctx, cancel := context.WithTimeout(context.Background(), *duration)
+		defer cancel()
+
+		log.Printf("压测 Enclave %s: 并发 %d，时长 %s\n", &enclave, *concurrency, *duration)
+		var (
+			mu        sync.Mutex
+			latencies []time.Duration
+			failures  int
+			lastErr   error
+			wg        sync.WaitGroup
+		)
+		start := time.Now()
+		for i := range conns {
+			wg.Add(1)
+			go func(conn *client.Client) {
+				defer wg.Done()
+				for ctx.Err() == nil {
+					args := request
+					if nonceRandom > 0 {
+						nonce := make([]byte, nonceRandom)
+						if _, err := rand.Read(nonce); err != nil {
+							exitf(exitFailure, "生成随机 nonce 失败: %v", err)
+						}
+						args.NonceB64 = base64.StdEncoding.EncodeToString(nonce)
+					}
+					requestStart := time.Now()
+					response, err := conn.Attest(ctx, args)
+					latency := time.Since(requestStart)
+					if err == nil && !response.Success {
+						err = response.Err()
+					}
+					// 压测结束时被取消的请求不计入统计
+					if ctx.Err() != nil {
+						return
+					}
+					mu.Lock()
+					if err != nil {
+						failures++
+						lastErr = err
+					} else {
+						latencies = append(latencies, latency)
+					}
+					mu.Unlock()
+				}
+			}(conns[i])
+		}
+		wg.Wait()
+		elapsed := time.Since(start)
+
+		if lastErr != nil {
+			log.Printf("%d 个请求失败，最近一次错误: %v\n", failures, lastErr)
+		}
+		if len(latencies) == 0 {
+			if lastErr != nil {
+				exitWithError(lastErr)
+			}
+			exitf(exitFailure, "压测期间没有完成任何请求")
+		}
+
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		result := benchResult{
+			Concurrency: *concurrency,
+			Duration:    elapsed.Round(time.Millisecond).String(),
+			Requests:    len(latencies),
+			Errors:      failures,
+			Throughput:  float64(len(latencies)) / elapsed.Seconds(),
+			P50MS:       milliseconds(percentile(latencies, 50)),
+			P95MS:       milliseconds(percentile(latencies, 95)),
+			P99MS:       milliseconds(percentile(latencies, 99)),
+			MaxMS:       milliseconds(latencies[len(latencies)-1]),
+		}
+		if jsonOutput {
+			printJSON(result)
+			return
+		}
+		fmt.Printf("请求数: %d (失败 %d)，耗时 %s\n", result.Requests, result.Errors, result.Duration)
+		fmt.Printf("吞吐量: %.1f 次/秒\n", result.Throughput)
+		fmt.Printf("延迟: p50 %.2fms  p95 %.2fms  p99 %.2fms  最大 %.2fms\n", result.P50MS, result.P95MS, result.P99MS, result.MaxMS)
+	}
+}
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// 所有连接 Enclave 的子命令共享的连接参数 (--cid、--port、--connect、--enclave、--enclaves-config)
+var enclave endpoint
+
+// 子命令: define 以标准库 flag 注册参数并返回执行函数，参数由 cobra 统一解析
+type subcommand struct {
+	use    string
+	short  string
+	args   cobra.PositionalArgs
+	define func(fs *flag.FlagSet) func(args []string)
+}
+
+var subcommands = []subcommand{
+	{"attest", "请求证明文档 (未指定子命令时的默认行为)", cobra.NoArgs, attestCommand},
+	{"verify <证明文档文件>", "离线校验已保存的证明文档", cobra.ExactArgs(1), verifyCommand},
+	{"inspect <证明文档文件>", "打印本地证明文档的内容", cobra.ExactArgs(1), inspectCommand},
+	{"eat <证明文档文件>", "校验证明文档并转换为 EAT (CWT/UCCS 或 JSON 声明集)", cobra.ExactArgs(1), eatCommand},
+	{"intoto <证明文档文件>", "以 in-toto Statement 输出证明文档的校验结论和 PCR", cobra.ExactArgs(1), inTotoCommand},
+	{"jwt <证明文档文件>", "校验证明文档并转换为由本地密钥签名的 JWT", cobra.ExactArgs(1), jwtCommand},
+	{"cosign-attach <证明文档文件>", "校验证明文档并作为 cosign 证明附加到容器镜像", cobra.ExactArgs(1), cosignAttachCommand},
+	{"pcrs <证明文档文件>", "从本地证明文档中导出 PCR", cobra.ExactArgs(1), pcrsCommand},
+	{"diff <证明文档文件> <证明文档文件>", "逐字段比较两个本地证明文档", cobra.ExactArgs(2), diffCommand},
+	{"instance-pcrs", "由父实例的 IAM 角色 ARN 和实例 ID 计算期望的 PCR3/PCR4", cobra.NoArgs, instancePCRsCommand},
+	{"measure-eif <EIF 文件>", "由 Enclave 镜像文件计算期望的 PCR0/1/2 (及签名镜像的 PCR8)", cobra.ExactArgs(1), measureEIFCommand},
+	{"health", "检查 Enclave 是否可用并显示其版本", cobra.NoArgs, healthCommand},
+	{"watch", "定期刷新磁盘上的证明文档", cobra.NoArgs, watchCommand},
+	{"bench", "压测 Enclave 的证明文档吞吐量和延迟", cobra.NoArgs, benchCommand},
+	{"list", "探测多 Enclave 配置中每个 Enclave 的可用性和版本", cobra.NoArgs, listCommand},
+	{"token", "请求 Enclave 签发的令牌", cobra.NoArgs, tokenCommand},
+	{"vault-bridge", "启动校验证明文档并签发 Vault JWT 的 Bridge 服务", cobra.NoArgs, vaultBridgeCommand},
+	{"vault-setup", "配置 Vault 的 JWT 认证方法及角色映射", cobra.NoArgs, vaultSetupCommand},
+	{"vault-login", "以证明文档登录 Vault", cobra.NoArgs, vaultLoginCommand},
+	{"jwks-gateway", "启动 JWKS 网关", cobra.NoArgs, jwksGatewayCommand},
+	{"oidc-broker", "启动以证明文档换取 OIDC ID Token 的 Broker", cobra.NoArgs, oidcBrokerCommand},
+	{"oidc-token", "以证明文档换取 OIDC ID Token", cobra.NoArgs, oidcTokenCommand},
+	{"audit-verify <审计日志文件>", "校验审计日志的哈希链", cobra.ExactArgs(1), auditVerifyCommand},
+	{"time-sync", "以主机或 NTP 服务器的时间同步 Enclave 的时钟", cobra.NoArgs, timeSyncCommand},
+	{"push-file <本地文件> <Enclave 路径>", "将文件分块上传到 Enclave 的 --file-root 下并校验 SHA-256", cobra.ExactArgs(2), pushFileCommand},
+	{"pull-file <Enclave 路径> <本地文件>", "从 Enclave 的 --file-root 下分块下载文件并校验 SHA-256", cobra.ExactArgs(2), pullFileCommand},
+	{"get-secret <密钥 ID>", "让 Enclave 从 Secrets Manager 读取密钥并以证明文档经 KMS 解密，只返回句柄", cobra.ExactArgs(1), getSecretCommand},
+	{"get-parameter <参数名称>", "让 Enclave 读取 SSM SecureString 参数并以证明文档经 KMS 解密，只返回句柄", cobra.ExactArgs(1), getParameterCommand},
+	{"kms-sign <消息文件>", "让 Enclave 以 KMS 非对称密钥签名，并返回绑定签名的证明文档", cobra.ExactArgs(1), kmsSignCommand},
+	{"decrypt-object <s3://BUCKET/KEY>", "让 Enclave 解密处理 S3 加密客户端加密的对象，只返回由明文导出的结果", cobra.ExactArgs(1), decryptObjectCommand},
+	{"decrypt-key", "获取 Enclave 解密公钥的证明文档，供 encrypt 加密", cobra.NoArgs, decryptKeyCommand},
+	{"encrypt", "校验证明文档后以其中的公钥加密数据 (HPKE 或 RSA-OAEP)，只有该 Enclave 能解密", cobra.NoArgs, encryptCommand},
+	{"decrypt <加密消息文件>", "将 encrypt 的加密消息发送给 Enclave 解密，明文只保存在 Enclave 内", cobra.ExactArgs(1), decryptCommand},
+	{"age-recipient", "校验解密公钥的证明文档并输出 age recipient，加密的文件只有该 Enclave 能解出文件密钥", cobra.NoArgs, ageRecipientCommand},
+	{"age-identity", "输出连接当前 Enclave 的 age identity，供 age -d -i 经 age-plugin-enclave 解密", cobra.NoArgs, ageIdentityCommand},
+	{"dns-proxy", "为 Enclave 转发允许列表中域名的 DNS 查询", cobra.NoArgs, dnsProxyCommand},
+	{"tcp-proxy", "将 Enclave 经 vsock 发起的连接转发到允许列表中的目标 (与 vsock-proxy 相同)", cobra.NoArgs, tcpProxyCommand},
+	{"imds-proxy", "将 Enclave 的 IMDS 请求 (凭证、区域、实例身份文档) 转发到主机的 IMDS", cobra.NoArgs, imdsProxyCommand},
+	{"log-receiver", "接收 Enclave 转发的日志并写入文件、journald 或 CloudWatch Logs", cobra.NoArgs, logReceiverCommand},
+	{"pprof-proxy", "将本地 TCP 端口转发到 Enclave 的 pprof 端口", cobra.NoArgs, pprofProxyCommand},
+	{"describe-nsm", "查询 Enclave 中 NSM 的描述", cobra.NoArgs, describeNSMCommand},
+	{"get-random", "从 Enclave 的 NSM 获取随机数", cobra.NoArgs, getRandomCommand},
+	{"describe-pcr", "读取 Enclave 的 PCR", cobra.NoArgs, describePCRCommand},
+	{"extend-pcr", "扩展 Enclave 的 PCR", cobra.NoArgs, extendPCRCommand},
+	{"lock-pcr", "锁定 Enclave 的单个 PCR", cobra.NoArgs, lockPCRCommand},
+	{"lock-pcrs", "锁定 Enclave 的前 N 个 PCR", cobra.NoArgs, lockPCRsCommand},
+}
+
+// 以标准库 flag 定义的参数和执行函数创建 cobra 命令
+func newCommand(sub subcommand) *cobra.Command {
+	fs := flag.NewFlagSet(sub.use, flag.ContinueOnError)
+	run := sub.define(fs)
+	cmd := &cobra.Command{
+		Use:   sub.use,
+		Short: sub.short,
+		Args:  sub.args,
+		Run: func(cmd *cobra.Command, args []string) {
+			run(args)
+		},
+	}
+	cmd.Flags().AddGoFlagSet(fs)
+	return cmd
+}
+
+// 设置命令行: 连接参数为全局参数，未指定子命令时按 attest 处理
+func setupCLI() *cobra.Command {
+	rootCmd := &cobra.Command{
+		Use:          "attestation-client",
+		Short:        "AWS Nitro Enclave 证明文档客户端",
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if offlineBuild {
+				enforceOffline()
+			}
+			if err := applyEnv(cmd.Flags()); err != nil {
+				return err
+			}
+			enclave.markExplicit(cmd.Flags())
+			return nil
+		},
+	}
+
+	connection := flag.NewFlagSet("connection", flag.ContinueOnError)
+	enclave.register(connection)
+	rootCmd.PersistentFlags().AddGoFlagSet(connection)
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "结果和错误以单个 JSON 对象输出到标准输出，日志仍输出到标准错误")
+
+	for _, sub := range subcommands {
+		cmd := newCommand(sub)
+		// 离线构建不包含连接网络或 Enclave 的子命令，未指定子命令时也不按 attest 处理
+		if offlineBuild && !offlineCommands[cmd.Name()] {
+			continue
+		}
+		rootCmd.AddCommand(cmd)
+		if cmd.Name() == "attest" {
+			rootCmd.Args = sub.args
+			rootCmd.Run = cmd.Run
+			rootCmd.Flags().AddFlagSet(cmd.Flags())
+		}
+	}
+
+	return rootCmd
+}
+
+// 检查 Enclave 是否可用并显示其版本
+func healthCommand(fs *flag.FlagSet) func(args []string) {
+	timeout := fs.Duration("timeout", 5*time.Second, "超时时间")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		start := time.Now()
+		version, err := enclave.health(*timeout)
+		if err != nil {
+			exitWithError(err)
+		}
+		latency := time.Since(start).Round(time.Millisecond)
+		if jsonOutput {
+			printJSON(healthResult{Address: enclave.address(), Status: "ok", Version: version, LatencyMS: latency.Milliseconds()})
+			return
+		}
+		fmt.Printf("Enclave 可用 (%s): 版本 %s，耗时 %s\n", &enclave, version, latency)
+	}
+}
+
+// --json 时 health 和 list 输出的 Enclave 状态
+type healthResult struct {
+	Name      string `json:"name,omitempty"`
+	Address   string `json:"address"`
+	Status    string `json:"status"`
+	Version   string `json:"version,omitempty"`
+	LatencyMS int64  `json:"latency_ms,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+func main() {
+	// 以 age-plugin-enclave 的名称安装时由 age 启动
+	if len(os.Args) == 2 && strings.HasPrefix(os.Args[1], "--age-plugin=") && !offlineBuild {
+		runAgePlugin(strings.TrimPrefix(os.Args[1], "--age-plugin="))
+		return
+	}
+	if err := setupCLI().Execute(); err != nil {
+		if jsonOutput {
+			printJSON(errorResult{Error: err.Error(), Class: exitClass(exitBadInput), ExitCode: exitBadInput})
+		}
+		os.Exit(exitBadInput)
+	}
+}
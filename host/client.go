@@ -1,7 +1,13 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"encoding/pem"
 	"flag"
@@ -9,146 +15,650 @@ import (
 	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
-	"github.com/mdlayher/vsock"
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/client"
+	"go.opentelemetry.io/otel/attribute"
 )
 
-// 命令行参数结构 - 与 enclave 端匹配
-type CommandArgs struct {
-	UserData  string `json:"user_data"`
-	PublicKey string `json:"public_key,omitempty"`
-	Nonce     string `json:"nonce,omitempty"`
-}
+// 证明文档的保存格式
+const (
+	formatRaw    = "raw"
+	formatBase64 = "base64"
+	formatPEM    = "pem"
+	formatJSON   = "json"
+	formatJWS    = "jws"
+)
 
-// 响应结构 - 与 enclave 端匹配
-type Response struct {
-	Success      bool   `json:"success"`
-	ErrorMessage string `json:"error_message,omitempty"`
-	Document     string `json:"document,omitempty"`
+// 按指定格式编码证明文档
+func encodeDocument(raw []byte, format string) ([]byte, error) {
+	switch format {
+	case formatRaw:
+		return raw, nil
+	case formatBase64:
+		return []byte(base64.StdEncoding.EncodeToString(raw) + "\n"), nil
+	case formatPEM:
+		return attestation.EncodePEM(raw), nil
+	case formatJSON:
+		doc, err := attestation.Parse(raw)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	case formatJWS:
+		jws, err := attestation.EncodeJWS(raw)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.MarshalIndent(jws, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(data, '\n'), nil
+	default:
+		return nil, fmt.Errorf("不支持的输出格式: %s (可选 raw、base64、pem、json、jws)", format)
+	}
 }
 
-// 保存证明文档到文件
-func saveAttestationDoc(document string, filename string) error {
-	// 尝试解码 base64 编码的文档
-	docBytes, err := base64.StdEncoding.DecodeString(document)
+// 按指定格式保存证明文档到文件
+func saveAttestationDoc(document string, filename string, format string) error {
+	data, err := encodeDocument(attestation.Decode([]byte(document)), format)
 	if err != nil {
-		// 如果解码失败，直接保存原始内容
-		docBytes = []byte(document)
+		return err
 	}
 
 	// 写入文件
-	if err := os.WriteFile(filename, docBytes, 0644); err != nil {
+	if err := os.WriteFile(filename, data, 0644); err != nil {
 		return fmt.Errorf("写入文件失败: %v", err)
 	}
 
 	return nil
 }
 
-func main() {
-	// 定义命令行参数
-	cidFlag := flag.Uint("cid", 16, "Enclave 的 CID")
-	portFlag := flag.Uint("port", 5000, "vsock 端口")
-	userDataFlag := flag.String("userdata", "", "用户数据")
-	publicKeyFlag := flag.String("public-key", "", "公钥文件路径")
-	nonceFlag := flag.String("nonce", "", "随机数")
-	outputFlag := flag.String("output", "attestation_doc.bin", "输出文件路径")
-	flag.Parse()
-
-	// 检查 CID
-	cid := *cidFlag
-	if cid == 0 {
-		log.Fatalf("必须指定 Enclave 的 CID")
+// NSM 允许的 user_data 和 nonce 最大长度
+const (
+	maxUserDataSize = 512
+	maxNonceSize    = 512
+)
+
+// 可重复指定的字符串参数
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// --nonce-random[=N]，未指定长度时生成 32 字节
+type randomNonceFlag int
+
+func (f *randomNonceFlag) String() string {
+	return strconv.Itoa(int(*f))
+}
+
+func (f *randomNonceFlag) Set(value string) error {
+	if value == "true" {
+		*f = 32
+		return nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 || n > maxNonceSize {
+		return fmt.Errorf("随机数长度必须在 1 到 %d 字节之间", maxNonceSize)
 	}
+	*f = randomNonceFlag(n)
+	return nil
+}
 
-	// 连接到 Enclave - 使用 mdlayher/vsock 库
-	conn, err := vsock.Dial(uint32(cid), uint32(*portFlag), nil)
-	if err != nil {
-		log.Fatalf("连接到 Enclave 失败: %v", err)
+func (f *randomNonceFlag) IsBoolFlag() bool {
+	return true
+}
+
+// 计算用户数据摘要
+func hashUserData(algorithm string, data []byte) ([]byte, error) {
+	switch algorithm {
+	case "sha256":
+		sum := sha256.Sum256(data)
+		return sum[:], nil
+	case "sha384":
+		sum := sha512.Sum384(data)
+		return sum[:], nil
+	default:
+		return nil, fmt.Errorf("不支持的摘要算法: %s (可选 sha256、sha384)", algorithm)
 	}
-	defer conn.Close()
+}
 
-	log.Printf("已连接到 Enclave (CID: %d)\n", cid)
+// --json 时 attest 的输出
+type attestResult struct {
+	Enclave   string             `json:"enclave"`
+	Documents []attestedDocument `json:"documents"`
+}
 
-	// 读取公钥文件（如果提供）
-	var publicKeyContent string
-	if *publicKeyFlag != "" {
-		pkData, err := os.ReadFile(*publicKeyFlag)
-		if err != nil {
-			log.Fatalf("读取公钥文件失败: %v", err)
+// 收到的一份证明文档的摘要
+type attestedDocument struct {
+	Output            string    `json:"output,omitempty"`
+	Format            string    `json:"format,omitempty"`
+	Size              int       `json:"size"`
+	DocumentSHA256    string    `json:"document_sha256"`
+	ModuleID          string    `json:"module_id"`
+	Timestamp         time.Time `json:"timestamp"`
+	Nonce             string    `json:"nonce,omitempty"`
+	UserDataTransform string    `json:"user_data_transform,omitempty"`
+	// 是否已按 --verify 校验
+	Verified bool `json:"verified"`
+}
+
+// 请求证明文档，未指定子命令时的默认行为
+func attestCommand(fs *flag.FlagSet) func(args []string) {
+	userDataFlag := fs.String("userdata", "", "用户数据，为 - 时从标准输入读取任意字节")
+	userDataFileFlag := fs.String("userdata-file", "", "从文件读取任意字节作为用户数据")
+	userDataHashFlag := fs.String("userdata-hash", "", "用户数据超过 NSM 上限时改为证明其摘要 (sha256 或 sha384)")
+	var claimFlags stringList
+	fs.Var(&claimFlags, "claim", "以 key=value 声明构建确定性编码的用户数据 (如 app_version、git_sha、config_hash，exp 可为 RFC3339 时间、时长或 Unix 秒)，可重复指定")
+	claimsFormatFlag := fs.String("claims-format", attestation.ClaimsCBOR, "--claim 声明集的编码 (cbor 或 json)")
+	publicKeyFlag := fs.String("public-key", "", "公钥文件路径")
+	genKeyFlag := fs.String("gen-key", "", "在本地生成密钥对并证明其公钥 (rsa2048、rsa4096、p256 或 p384)")
+	keyOutFlag := fs.String("key-out", "attestation_key.pem", "--gen-key 生成的私钥保存路径 (PKCS#8 PEM)")
+	nonceFlag := fs.String("nonce", "", "随机数")
+	var nonceRandom randomNonceFlag
+	fs.Var(&nonceRandom, "nonce-random", "在本地生成 N 字节随机数 (默认 32) 作为 nonce，并校验返回文档中的 nonce 完全一致")
+	outputFlag := fs.String("output", "attestation_doc.bin", "输出文件路径")
+	formatFlag := fs.String("format", formatRaw, "证明文档保存格式 (raw、base64、pem、json 或 jws)")
+	muxFlag := fs.Bool("mux", false, "在单个 vsock 连接上使用 yamux 多路复用")
+	countFlag := fs.Int("count", 1, "并发请求的证明文档数量")
+	batchFlag := fs.Bool("batch", false, "以单个 attest-batch 请求发送 --count 个请求 (各自独立的 nonce)，不使用多路复用")
+	codecFlag := fs.String("codec", "json", "vsock 协议编码 (json、cbor 或 protobuf)")
+	timeoutFlag := fs.Duration("timeout", 0, "连接及等待全部响应的超时时间，0 表示不限制")
+	compressFlag := fs.String("compress", "", "响应压缩算法 (gzip 或 zstd)，为空时不压缩")
+	chunkSizeFlag := fs.Int("chunk-size", 0, "流式响应的分块大小 (字节)，0 表示不分块")
+	hmacKeyFlag := fs.String("hmac-key-file", "", "与 Enclave 共享的 HMAC 密钥文件")
+	noiseFlag := fs.String("noise", "", "建立 Noise 加密通道的握手模式 (NK 或 XX)")
+	noiseKeyFlag := fs.String("noise-key", "", "Noise XX 模式下客户端静态私钥文件 (十六进制)")
+	ratlsFlag := fs.Bool("ratls", false, "通过 RA-TLS 连接 (--port 需指向 Enclave 的 RA-TLS 端口)")
+	s3Flag := fs.String("s3", "", "将证明文档及校验报告归档到 S3，例如 s3://bucket/prefix/")
+	s3KMSKeyFlag := fs.String("s3-kms-key", "", "归档时使用 SSE-KMS 加密的 KMS 密钥 ID、ARN 或别名")
+	webhookFlag := fs.String("webhook", "", "将证明文档及校验结果 POST 到该地址")
+	webhookSecretFlag := fs.String("webhook-secret-file", "", "Webhook 签名密钥文件，设置后以 HMAC-SHA256 签名请求体 (X-Attestation-Signature 头)")
+	snsTopicFlag := fs.String("sns-topic", "", "将证明及校验成功/失败事件发布到该 SNS 主题 ARN")
+	var metricsFlags cloudWatchFlags
+	metricsFlags.register(fs)
+	var tracingFlags tracingFlags
+	tracingFlags.register(fs)
+	auditLogFlag := fs.String("audit-log", "", "将每个请求的输入摘要、文档摘要和结果追加到哈希链审计日志 (JSONL)")
+	freshFlag := fs.Bool("fresh", false, "要求 Enclave 生成新文档，不使用其缓存")
+	verifyFlag := fs.Bool("verify", false, "校验返回文档的签名、证书链及 --expect-public-key 等策略")
+	var policy verifyPolicy
+	policy.register(fs)
+	return func([]string) {
+
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
 		}
-		
-		// 处理 PEM 格式的公钥
-		pemContent := string(pkData)
-		if strings.Contains(pemContent, "-----BEGIN PUBLIC KEY-----") {
-			// 提取 PEM 中的 Base64 编码部分并解码为 DER 格式
-			pemBlock, _ := pem.Decode(pkData)
-			if pemBlock == nil {
-				log.Fatalf("解析 PEM 格式公钥失败")
+		if policy.expectPublicKey != "" {
+			*verifyFlag = true
+		}
+
+		switch *formatFlag {
+		case formatRaw, formatBase64, formatPEM, formatJSON, formatJWS:
+		default:
+			exitf(exitBadInput, "不支持的输出格式: %s (可选 raw、base64、pem、json、jws)", *formatFlag)
+		}
+		if nonceRandom > 0 && *nonceFlag != "" {
+			exitf(exitBadInput, "--nonce 和 --nonce-random 不能同时指定")
+		}
+
+		switch *userDataHashFlag {
+		case "", "sha256", "sha384":
+		default:
+			exitf(exitBadInput, "不支持的摘要算法: %s (可选 sha256、sha384)", *userDataHashFlag)
+		}
+
+		// 检查 CID 或连接地址
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		// 读取公钥文件（如果提供）
+		var publicKeyContent string
+		if *publicKeyFlag != "" {
+			pkData, err := os.ReadFile(*publicKeyFlag)
+			if err != nil {
+				exitf(exitBadInput, "读取公钥文件失败: %v", err)
 			}
+		
+			// 处理 PEM 格式的公钥
+			pemContent := string(pkData)
+			if strings.Contains(pemContent, "-----BEGIN PUBLIC KEY-----") {
+				// 提取 PEM 中的 Base64 编码部分并解码为 DER 格式
+				pemBlock, _ := pem.Decode(pkData)
+				if pemBlock == nil {
+					exitf(exitBadInput, "解析 PEM 格式公钥失败")
+				}
 			
-			// 重新编码为 Base64 以便传输
-			publicKeyContent = base64.StdEncoding.EncodeToString(pemBlock.Bytes)
-		} else {
-			// 如果不是 PEM 格式，假设是 DER 格式，直接进行 Base64 编码
-			publicKeyContent = base64.StdEncoding.EncodeToString(pkData)
+				// 重新编码为 Base64 以便传输
+				publicKeyContent = base64.StdEncoding.EncodeToString(pemBlock.Bytes)
+			} else {
+				// 如果不是 PEM 格式，假设是 DER 格式，直接进行 Base64 编码
+				publicKeyContent = base64.StdEncoding.EncodeToString(pkData)
+			}
 		}
-	}
 
-	// 准备参数
-	args := CommandArgs{
-		UserData:  *userDataFlag,
-		PublicKey: publicKeyContent,
-		Nonce:     *nonceFlag,
-	}
+		// 在本地生成密钥对，证明文档的 public_key 即为该公钥
+		var generatedPublicKey []byte
+		if *genKeyFlag != "" {
+			if *publicKeyFlag != "" {
+				exitf(exitBadInput, "--public-key 和 --gen-key 不能同时指定")
+			}
+			der, err := generateKeyFile(*genKeyFlag, *keyOutFlag)
+			if err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+			generatedPublicKey = der
+			publicKeyContent = base64.StdEncoding.EncodeToString(der)
+			log.Printf("已生成 %s 密钥，私钥保存到 %s\n", *genKeyFlag, *keyOutFlag)
+		}
 
-	// 序列化参数
-	argsJSON, err := json.Marshal(args)
-	if err != nil {
-		log.Fatalf("序列化参数失败: %v", err)
-	}
+		var archive *s3Archive
+		if *s3Flag != "" {
+			a, err := newS3Archive(context.Background(), *s3Flag, *s3KMSKeyFlag)
+			if err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+			archive = a
+		}
 
-	// 发送参数
-	if _, err := conn.Write(argsJSON); err != nil {
-		log.Fatalf("发送参数失败: %v", err)
-	}
+		var hook *webhook
+		if *webhookFlag != "" {
+			w, err := newWebhook(*webhookFlag, *webhookSecretFlag)
+			if err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+			hook = w
+		}
 
-	log.Println("已发送参数，等待响应...")
+		var notifier *snsNotifier
+		if *snsTopicFlag != "" {
+			n, err := newSNSNotifier(context.Background(), *snsTopicFlag, enclave.String())
+			if err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+			notifier = n
+		}
 
-	// 读取响应
-	buffer := make([]byte, 65536) // 64KB 缓冲区
-	n, err := conn.Read(buffer)
-	if err != nil && err != io.EOF {
-		log.Fatalf("读取响应失败: %v", err)
-	}
+		metrics, err := metricsFlags.open(context.Background(), enclave.label())
+		if err != nil {
+			exitWithError(err)
+		}
 
-	// 解析响应
-	var response Response
-	if err := json.Unmarshal(buffer[:n], &response); err != nil {
-		log.Fatalf("解析响应失败: %v", err)
-	}
+		tracer, err := tracingFlags.open(context.Background())
+		if err != nil {
+			exitWithError(err)
+		}
 
-	// 处理响应
-	if !response.Success {
-		log.Fatalf("Enclave 返回错误: %s", response.ErrorMessage)
-	}
+		var audit *auditLog
+		if *auditLogFlag != "" {
+			l, err := openAuditLog(*auditLogFlag)
+			if err != nil {
+				exitWithError(err)
+			}
+			audit = l
+		}
 
-	log.Println("成功接收到证明文档")
+		// 准备参数
+		args := client.CommandArgs{
+			PublicKey: publicKeyContent,
+			Nonce:     *nonceFlag,
+			Fresh:     *freshFlag,
+		}
 
-	// 保存证明文档
-	if *outputFlag != "" {
-		if err := saveAttestationDoc(response.Document, *outputFlag); err != nil {
-			log.Printf("保存证明文档失败: %v\n", err)
+		// 文件或标准输入中的用户数据按二进制处理，base64 编码后传输
+		var userData []byte
+		binaryUserData := true
+		switch {
+		case *userDataFileFlag != "" && *userDataFlag != "":
+			exitf(exitBadInput, "--userdata 和 --userdata-file 不能同时指定")
+		case len(claimFlags) > 0:
+			if *userDataFileFlag != "" || *userDataFlag != "" {
+				exitf(exitBadInput, "--claim 不能与 --userdata 或 --userdata-file 同时指定")
+			}
+			claims := make(attestation.UserDataClaims)
+			for _, claim := range claimFlags {
+				if err := claims.Set(claim, time.Now()); err != nil {
+					exitf(exitBadInput, "%v", err)
+				}
+			}
+			data, err := claims.Encode(*claimsFormatFlag)
+			if err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+			userData = data
+		case *userDataFileFlag != "":
+			data, err := os.ReadFile(*userDataFileFlag)
+			if err != nil {
+				exitf(exitBadInput, "读取用户数据文件失败: %v", err)
+			}
+			userData = data
+		case *userDataFlag == "-":
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				exitf(exitBadInput, "从标准输入读取用户数据失败: %v", err)
+			}
+			userData = data
+		default:
+			userData = []byte(*userDataFlag)
+			binaryUserData = false
+		}
+
+		// 超过 NSM 上限的用户数据可改为证明其摘要
+		var userDataTransform string
+		if len(userData) > maxUserDataSize {
+			if *userDataHashFlag == "" {
+				exitf(exitBadInput, "用户数据 %d 字节超过 NSM 上限 %d 字节，可使用 --userdata-hash sha256 或 sha384 改为证明其摘要", len(userData), maxUserDataSize)
+			}
+			digest, err := hashUserData(*userDataHashFlag, userData)
+			if err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+			userDataTransform = fmt.Sprintf("%s (原始 %d 字节)", *userDataHashFlag, len(userData))
+			log.Printf("用户数据超过 %d 字节，改为证明其 %s 摘要\n", maxUserDataSize, userDataTransform)
+			userData = digest
+			binaryUserData = true
+		}
+		if binaryUserData {
+			args.UserDataB64 = base64.StdEncoding.EncodeToString(userData)
 		} else {
-			log.Printf("证明文档已保存到 %s\n", *outputFlag)
+			args.UserData = string(userData)
+		}
+
+		count := *countFlag
+		if count < 1 {
+			exitf(exitBadInput, "--count 必须大于 0")
+		}
+		if *batchFlag && count > client.MaxBatchSize {
+			exitf(exitBadInput, "--batch 时 --count 不能超过 %d", client.MaxBatchSize)
+		}
+
+		opts := &client.Options{
+			Mux:       *muxFlag || (count > 1 && !*batchFlag),
+			Codec:     *codecFlag,
+			ChunkSize: *chunkSizeFlag,
+		}
+		if *compressFlag != "" {
+			opts.Compression = []string{*compressFlag}
+		}
+		if *hmacKeyFlag != "" {
+			key, err := os.ReadFile(*hmacKeyFlag)
+			if err != nil {
+				exitf(exitBadInput, "读取 HMAC 密钥文件失败: %v", err)
+			}
+			opts.HMACKey = client.ParseHMACKey(key)
+		}
+		opts.Noise = *noiseFlag
+		opts.RATLS = *ratlsFlag
+		opts.VerifyAttestation = policy.channelVerifier()
+		if *noiseKeyFlag != "" {
+			data, err := os.ReadFile(*noiseKeyFlag)
+			if err != nil {
+				exitf(exitBadInput, "读取 Noise 私钥文件失败: %v", err)
+			}
+			key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+			if err != nil {
+				exitf(exitBadInput, "解析 Noise 私钥失败: %v", err)
+			}
+			opts.NoiseClientKey = key
+		}
+
+		// 连接、请求、Enclave 内的处理及校验记录在同一个 trace 中
+		ctx, span := startHostSpan(context.Background(), "attest", attribute.String("enclave", enclave.label()), attribute.Int("count", count))
+		endTrace := func(err error) {
+			endHostSpan(span, err)
+			tracer.shutdown(context.Background())
+		}
+		defer endTrace(nil)
+		if *timeoutFlag > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, *timeoutFlag)
+			defer cancel()
+		}
+
+		// 连接到 Enclave，多个请求时在同一连接上多路复用
+		conn, err := enclave.dialContext(ctx, opts)
+		if err != nil {
+			notifier.publish(context.Background(), eventAttestationFailed, nil, err)
+			endTrace(err)
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		log.Printf("已连接到 Enclave (%s)\n", &enclave)
+		if opts.Noise != "" {
+			log.Printf("已建立 Noise %s 加密通道，Enclave 证明文档 %d 字节\n", opts.Noise, len(conn.Attestation()))
+		}
+		if opts.RATLS {
+			log.Printf("已建立 RA-TLS 连接，证书中的证明文档 %d 字节\n", len(conn.Attestation()))
+		}
+
+		// 每个请求使用独立的随机 nonce
+		nonces := make([][]byte, count)
+		if nonceRandom > 0 {
+			for i := range nonces {
+				nonces[i] = make([]byte, nonceRandom)
+				if _, err := rand.Read(nonces[i]); err != nil {
+					exitf(exitFailure, "生成随机 nonce 失败: %v", err)
+				}
+			}
+		}
+
+		responses := make([]*client.Response, count)
+		errs := make([]error, count)
+		latencies := make([]time.Duration, count)
+		inputsHashes := make([]string, count)
+		items := make([]client.CommandArgs, count)
+		for i := range items {
+			items[i] = args
+			if nonces[i] != nil {
+				items[i].NonceB64 = base64.StdEncoding.EncodeToString(nonces[i])
+			}
+			inputsHashes[i] = auditInputsHash(items[i])
+		}
+		if *batchFlag {
+			// 所有请求在同一个请求中发送，延迟为整个批量请求的耗时
+			start := time.Now()
+			batch, err := conn.AttestBatch(ctx, items)
+			for i := range items {
+				latencies[i] = time.Since(start)
+				if err != nil {
+					errs[i] = err
+				} else {
+					responses[i] = batch[i]
+				}
+			}
+		} else {
+			var wg sync.WaitGroup
+			for i := range items {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					start := time.Now()
+					responses[i], errs[i] = conn.Attest(ctx, items[i])
+					latencies[i] = time.Since(start)
+				}(i)
+			}
+			log.Println("已发送参数，等待响应...")
+			wg.Wait()
+		}
+
+		documents := make([]attestedDocument, 0, count)
+		for i := 0; i < count; i++ {
+			// 记录到审计日志，写入失败时终止，避免缺失记录
+			entry := auditEntry{Peer: enclave.address(), Method: client.MethodAttest, InputsHash: inputsHashes[i], Result: "ok"}
+			recordAudit := func(result string, err error) {
+				if result == "" {
+					result = "error"
+				}
+				if result != "ok" {
+					entry.Result = result
+					entry.Error = err.Error()
+				}
+				if err := audit.append(entry); err != nil {
+					exitWithError(err)
+				}
+			}
+
+			if errs[i] != nil {
+				recordAudit("error", errs[i])
+				metrics.recordAttestation(context.Background(), nil, latencies[i], errs[i])
+				notifier.publish(context.Background(), eventAttestationFailed, nil, errs[i])
+				endTrace(errs[i])
+				exitWithError(errs[i])
+			}
+
+			// 处理响应
+			response := responses[i]
+			tracer.exportEnclaveSpans(ctx, response.Trace)
+			if !response.Success {
+				attestErr := response.Err()
+				recordAudit(response.ErrorCode, attestErr)
+				metrics.recordAttestation(context.Background(), nil, latencies[i], attestErr)
+				notifier.publish(context.Background(), eventAttestationFailed, nil, attestErr)
+				endTrace(attestErr)
+				exitWithError(attestErr)
+			}
+
+			log.Println("成功接收到证明文档")
+
+			// 校验失败时同样归档，保留失败证据
+			raw := attestation.Decode([]byte(response.Document))
+			parsed, _ := attestation.Parse(raw)
+			documentHash := sha256.Sum256(raw)
+			entry.DocumentHash = hex.EncodeToString(documentHash[:])
+			metrics.recordAttestation(context.Background(), parsed, latencies[i], nil)
+			notifier.publish(context.Background(), eventAttestationSucceeded, parsed, nil)
+			var verifyErr error
+			var verified *attestation.SignedDocument
+			if *verifyFlag {
+				_, verifySpan := startHostSpan(ctx, "verify")
+				// 按 Enclave 返回的证据类型选择校验器，旧版 Enclave 不返回类型时按 Nitro 证明文档处理
+				var claims *attestation.Claims
+				claims, verifyErr = policy.verifyEvidence(attestation.EvidenceType(response.EvidenceType), raw)
+				if claims != nil {
+					verified, _ = claims.Evidence.(*attestation.SignedDocument)
+				}
+				endHostSpan(verifySpan, verifyErr)
+				metrics.recordVerification(context.Background(), parsed, verifyErr)
+				if verifyErr != nil {
+					notifier.publish(context.Background(), eventVerificationFailed, parsed, verifyErr)
+				} else {
+					notifier.publish(context.Background(), eventVerificationSucceeded, verified, nil)
+				}
+			}
+			if verifyErr != nil {
+				recordAudit(auditVerificationFailed, verifyErr)
+			} else {
+				recordAudit("ok", nil)
+			}
+			if archive != nil {
+				location, err := archive.archive(context.Background(), raw, verifyErr, *verifyFlag)
+				if err != nil {
+					exitf(exitFailure, "归档证明文档失败: %v", err)
+				}
+				log.Printf("证明文档及校验报告已归档到 %s\n", location)
+			}
+			if hook != nil {
+				if err := hook.deliver(context.Background(), raw, verifyErr, *verifyFlag); err != nil {
+					exitWithError(err)
+				}
+				log.Printf("证明文档已推送到 %s\n", hook.url)
+			}
+			if verifyErr != nil {
+				endTrace(verifyErr)
+				exitf(exitCode(verifyErr), "证明文档校验失败: %v", verifyErr)
+			}
+			if verified != nil {
+				log.Printf("证明文档校验通过: %s\n", verified.ModuleID)
+			}
+
+			// 确认文档中的 nonce 与本地生成的随机数、public_key 与本地生成的公钥完全一致
+			if nonces[i] != nil || generatedPublicKey != nil {
+				doc, err := attestation.Parse(attestation.Decode([]byte(response.Document)))
+				if err != nil {
+					exitf(exitVerification, "解析证明文档失败: %v", err)
+				}
+				if nonces[i] != nil {
+					if !bytes.Equal(doc.Nonce, nonces[i]) {
+						notifier.publish(context.Background(), eventVerificationFailed, doc, fmt.Errorf("nonce 不一致"))
+						exitf(exitPolicy, "证明文档中的 nonce (%x) 与发送的随机数 (%x) 不一致", doc.Nonce, nonces[i])
+					}
+					log.Printf("nonce 校验通过: %x\n", nonces[i])
+				}
+				if generatedPublicKey != nil && !bytes.Equal(doc.PublicKey, generatedPublicKey) {
+					notifier.publish(context.Background(), eventVerificationFailed, doc, fmt.Errorf("public_key 不一致"))
+					exitf(exitPolicy, "证明文档中的 public_key 与生成的公钥不一致")
+				}
+			}
+
+			result := attestedDocument{
+				Size:              len(raw),
+				DocumentSHA256:    entry.DocumentHash,
+				UserDataTransform: userDataTransform,
+				Verified:          verified != nil,
+			}
+			if parsed != nil {
+				result.ModuleID = parsed.ModuleID
+				result.Timestamp = parsed.Time()
+				result.Nonce = hex.EncodeToString(parsed.Nonce)
+			}
+
+			// 保存证明文档
+			if *outputFlag != "" {
+				filename := outputFilename(*outputFlag, i, count)
+				if err := saveAttestationDoc(response.Document, filename, *formatFlag); err != nil {
+					log.Printf("保存证明文档失败: %v\n", err)
+				} else {
+					log.Printf("证明文档已保存到 %s\n", filename)
+					result.Output = filename
+					result.Format = *formatFlag
+				}
+			}
+			documents = append(documents, result)
+			if jsonOutput {
+				continue
+			}
+
+			// 打印证明文档摘要
+			fmt.Println("\n证明文档已接收")
+			if userDataTransform != "" {
+				fmt.Printf("user_data 为用户数据的 %s 摘要\n", userDataTransform)
+			}
+			if len(response.Document) > 100 {
+				fmt.Printf("文档大小: %d 字节, 前100字节: %s...\n", len(response.Document), response.Document[:100])
+			} else {
+				fmt.Printf("文档大小: %d 字节, 内容: %s\n", len(response.Document), response.Document)
+			}
+		}
+		if jsonOutput {
+			printJSON(attestResult{Enclave: enclave.address(), Documents: documents})
 		}
 	}
+}
 
-	// 打印证明文档摘要
-	fmt.Println("\n证明文档已接收")
-	if len(response.Document) > 100 {
-		fmt.Printf("文档大小: %d 字节, 前100字节: %s...\n", len(response.Document), response.Document[:100])
-	} else {
-		fmt.Printf("文档大小: %d 字节, 内容: %s\n", len(response.Document), response.Document)
+// 多个请求时为每份文档生成独立的文件名
+func outputFilename(output string, index int, count int) string {
+	if count == 1 {
+		return output
 	}
+	ext := filepath.Ext(output)
+	return fmt.Sprintf("%s.%d%s", strings.TrimSuffix(output, ext), index, ext)
 }
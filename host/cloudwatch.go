@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatch/types"
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 作为维度的 PCR0 前缀长度 (十六进制字符)
+const pcr0PrefixLength = 16
+
+// CloudWatch 指标参数
+type cloudWatchFlags struct {
+	namespace string
+	emf       string
+}
+
+// 注册 --cloudwatch-namespace 和 --cloudwatch-emf 参数
+func (f *cloudWatchFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.namespace, "cloudwatch-namespace", "", "通过 PutMetricData 将请求数、校验结果和延迟发布到该 CloudWatch 命名空间")
+	fs.StringVar(&f.emf, "cloudwatch-emf", "", "改为以嵌入式指标格式 (EMF) 写入该文件 (- 为标准输出)，由 CloudWatch Agent 采集")
+}
+
+// 按参数创建指标发布器，未启用时返回 nil
+func (f *cloudWatchFlags) open(ctx context.Context, enclave string) (*cloudWatchMetrics, error) {
+	if f.emf == "" && f.namespace == "" {
+		return nil, nil
+	}
+
+	m := &cloudWatchMetrics{namespace: f.namespace, enclave: enclave}
+	if m.namespace == "" {
+		m.namespace = "EnclaveAttestation"
+	}
+
+	if f.emf != "" {
+		if f.emf == "-" {
+			m.emf = os.Stdout
+		} else {
+			file, err := os.OpenFile(f.emf, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+			if err != nil {
+				return nil, fmt.Errorf("打开 EMF 文件失败: %v", err)
+			}
+			m.emf = file
+		}
+		return m, nil
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("加载 AWS 配置失败: %v", err)
+	}
+	m.client = cloudwatch.NewFromConfig(cfg)
+	return m, nil
+}
+
+// 以 Enclave 名称和 PCR0 前缀为维度发布证明指标
+type cloudWatchMetrics struct {
+	namespace string
+	enclave   string
+
+	// 二者其一: PutMetricData 客户端或 EMF 输出
+	client *cloudwatch.Client
+	emf    io.Writer
+}
+
+// 一个指标数据点
+type metricDatum struct {
+	name  string
+	value float64
+	unit  types.StandardUnit
+}
+
+// 记录一次证明请求的结果和延迟
+func (m *cloudWatchMetrics) recordAttestation(ctx context.Context, doc *attestation.SignedDocument, latency time.Duration, attestErr error) {
+	if m == nil {
+		return
+	}
+	failures := 0.0
+	if attestErr != nil {
+		failures = 1
+	}
+	m.put(ctx, doc, []metricDatum{
+		{"AttestationRequests", 1, types.StandardUnitCount},
+		{"AttestationFailures", failures, types.StandardUnitCount},
+		{"AttestationLatency", float64(latency.Milliseconds()), types.StandardUnitMilliseconds},
+	})
+}
+
+// 记录一次文档校验的结果
+func (m *cloudWatchMetrics) recordVerification(ctx context.Context, doc *attestation.SignedDocument, verifyErr error) {
+	if m == nil {
+		return
+	}
+	success, failure := 1.0, 0.0
+	if verifyErr != nil {
+		success, failure = 0, 1
+	}
+	m.put(ctx, doc, []metricDatum{
+		{"VerificationSuccesses", success, types.StandardUnitCount},
+		{"VerificationFailures", failure, types.StandardUnitCount},
+	})
+}
+
+// 发布指标，失败只记录日志，不影响主流程
+func (m *cloudWatchMetrics) put(ctx context.Context, doc *attestation.SignedDocument, data []metricDatum) {
+	pcr0 := "unknown"
+	if doc != nil {
+		if prefix := hex.EncodeToString(doc.PCRs[0]); len(prefix) >= pcr0PrefixLength {
+			pcr0 = prefix[:pcr0PrefixLength]
+		}
+	}
+
+	if m.emf != nil {
+		if err := m.writeEMF(pcr0, data); err != nil {
+			log.Printf("写入 EMF 指标失败: %v\n", err)
+		}
+		return
+	}
+
+	now := time.Now()
+	dimensions := []types.Dimension{
+		{Name: aws.String("Enclave"), Value: aws.String(m.enclave)},
+		{Name: aws.String("PCR0Prefix"), Value: aws.String(pcr0)},
+	}
+	metricData := make([]types.MetricDatum, 0, len(data))
+	for _, d := range data {
+		metricData = append(metricData, types.MetricDatum{
+			MetricName: aws.String(d.name),
+			Value:      aws.Float64(d.value),
+			Unit:       d.unit,
+			Timestamp:  aws.Time(now),
+			Dimensions: dimensions,
+		})
+	}
+	_, err := m.client.PutMetricData(ctx, &cloudwatch.PutMetricDataInput{
+		Namespace:  aws.String(m.namespace),
+		MetricData: metricData,
+	})
+	if err != nil {
+		log.Printf("发布 CloudWatch 指标失败: %v\n", err)
+	}
+}
+
+// 写入一行嵌入式指标格式 (EMF) JSON
+func (m *cloudWatchMetrics) writeEMF(pcr0 string, data []metricDatum) error {
+	type metricDefinition struct {
+		Name string `json:"Name"`
+		Unit string `json:"Unit"`
+	}
+	definitions := make([]metricDefinition, 0, len(data))
+	record := map[string]interface{}{
+		"Enclave":    m.enclave,
+		"PCR0Prefix": pcr0,
+	}
+	for _, d := range data {
+		definitions = append(definitions, metricDefinition{Name: d.name, Unit: string(d.unit)})
+		record[d.name] = d.value
+	}
+	record["_aws"] = map[string]interface{}{
+		"Timestamp": time.Now().UnixMilli(),
+		"CloudWatchMetrics": []interface{}{map[string]interface{}{
+			"Namespace":  m.namespace,
+			"Dimensions": [][]string{{"Enclave", "PCR0Prefix"}},
+			"Metrics":    definitions,
+		}},
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = m.emf.Write(append(line, '\n'))
+	return err
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+	"github.com/yourusername/aws-enclave-attestation/oci"
+)
+
+// cosign 证明的存储约定: 镜像 sha256:<摘要> 的证明保存在同一仓库的 sha256-<摘要>.att 标签下，
+// 每个证明为一个 DSSE 信封层
+const (
+	dsseMediaType        = "application/vnd.dsse.envelope.v1+json"
+	inTotoPayloadType    = "application/vnd.in-toto+json"
+	cosignSignatureLabel = "dev.cosignproject.cosign/signature"
+	cosignTimeout        = 2 * time.Minute
+)
+
+// DSSE 信封
+type dsseEnvelope struct {
+	PayloadType string          `json:"payloadType"`
+	Payload     string          `json:"payload"`
+	Signatures  []dsseSignature `json:"signatures"`
+}
+
+type dsseSignature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+// DSSE 预认证编码: "DSSEv1" SP len(type) SP type SP len(payload) SP payload
+func dssePAE(payloadType string, payload []byte) []byte {
+	return []byte(fmt.Sprintf("DSSEv1 %d %s %d %s", len(payloadType), payloadType, len(payload), payload))
+}
+
+// 以 ECDSA 私钥签名生成 DSSE 信封，与 cosign attest --key 相同
+func signDSSE(payloadType string, payload []byte, key *ecdsa.PrivateKey) (*dsseEnvelope, error) {
+	digest := sha256.Sum256(dssePAE(payloadType, payload))
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return &dsseEnvelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures:  []dsseSignature{{Sig: base64.StdEncoding.EncodeToString(sig)}},
+	}, nil
+}
+
+// 校验证明文档，将校验结论和文档作为 cosign 证明 (in-toto Statement 的 DSSE 信封) 附加到部署的容器镜像，
+// 可用 cosign verify-attestation --key <公钥> --type urn:aws-enclave-attestation:runtime-attestation:1 校验
+func cosignAttachCommand(fs *flag.FlagSet) func(args []string) {
+	var policy verifyPolicy
+	policy.register(fs)
+	evidenceType := fs.String("evidence-type", string(attestation.EvidenceNitro), "证据类型，决定使用的校验器")
+	image := fs.String("image", "", "容器镜像引用 (registry/repository:tag 或 @sha256:...)")
+	keyPath := fs.String("key", "", "签名私钥 (PEM 格式 ECDSA，与 cosign 校验时使用的公钥对应)")
+	username := fs.String("registry-username", "", "镜像仓库用户名")
+	password := fs.String("registry-password", "", "镜像仓库密码或令牌 (建议通过环境变量 ATTEST_REGISTRY_PASSWORD 传入)")
+	plainHTTP := fs.Bool("plain-http", false, "以 HTTP 访问镜像仓库 (仅用于本地测试仓库)")
+	return func(args []string) {
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *image == "" || *keyPath == "" {
+			exitf(exitBadInput, "必须指定 --image 和 --key")
+		}
+		ref, err := oci.ParseReference(*image)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		key, err := jwks.LoadPrivateKey(*keyPath)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		raw := attestation.Decode(data)
+		// 只附加校验通过的证据
+		claims, err := policy.verifyEvidence(attestation.EvidenceType(*evidenceType), raw)
+		if err != nil {
+			exitf(exitCode(err), "校验失败: %v", err)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), cosignTimeout)
+		defer cancel()
+		registry := &oci.Client{Username: *username, Password: *password, PlainHTTP: *plainHTTP}
+		digest, err := registry.Resolve(ctx, ref)
+		if err != nil {
+			exitf(exitConnection, "%v", err)
+		}
+
+		// subject 为容器镜像，Enclave 镜像的 PCR0 在 predicate 的 measurements 中
+		statement := newInTotoStatement(claims, raw, nil)
+		statement.Subject = []inTotoSubject{{
+			Name:   ref.Registry + "/" + ref.Repository,
+			Digest: map[string]string{"sha256": strings.TrimPrefix(digest, "sha256:")},
+		}}
+		payload, err := json.Marshal(statement)
+		if err != nil {
+			exitf(exitFailure, "%v", err)
+		}
+		envelope, err := signDSSE(inTotoPayloadType, payload, key)
+		if err != nil {
+			exitf(exitFailure, "签名失败: %v", err)
+		}
+		layer, err := json.Marshal(envelope)
+		if err != nil {
+			exitf(exitFailure, "%v", err)
+		}
+
+		tag := strings.Replace(digest, ":", "-", 1) + ".att"
+		manifestDigest, err := attachLayer(ctx, registry, ref, tag, layer)
+		if err != nil {
+			exitf(exitConnection, "附加证明失败: %v", err)
+		}
+
+		location := fmt.Sprintf("%s/%s:%s@%s", ref.Registry, ref.Repository, tag, manifestDigest)
+		log.Printf("证明已附加到 %s@%s\n", ref.Registry+"/"+ref.Repository, digest)
+		if jsonOutput {
+			printJSON(map[string]string{"image": ref.Registry + "/" + ref.Repository + "@" + digest, "attestation": location, "module_id": claims.ModuleID})
+			return
+		}
+		fmt.Println(location)
+	}
+}
+
+// 将 DSSE 信封作为新层追加到 .att 标签的清单 (不存在时新建)，返回新清单的摘要
+func attachLayer(ctx context.Context, registry *oci.Client, ref oci.Reference, tag string, layer []byte) (string, error) {
+	manifest, err := registry.GetManifest(ctx, ref, tag)
+	if err != nil {
+		return "", err
+	}
+	if manifest == nil {
+		manifest = &oci.Manifest{SchemaVersion: 2, MediaType: oci.MediaTypeManifest}
+	}
+
+	desc, err := registry.PushBlob(ctx, ref, dsseMediaType, layer)
+	if err != nil {
+		return "", err
+	}
+	desc.Annotations = map[string]string{cosignSignatureLabel: "", "predicateType": runtimePredicateType}
+	manifest.Layers = append(manifest.Layers, desc)
+
+	// 镜像配置只列出各层摘要，与 cosign 生成的一致
+	diffIDs := make([]string, len(manifest.Layers))
+	for i, l := range manifest.Layers {
+		diffIDs[i] = l.Digest
+	}
+	config, err := json.Marshal(map[string]interface{}{
+		"architecture": "",
+		"os":           "",
+		"config":       map[string]interface{}{},
+		"rootfs":       map[string]interface{}{"type": "layers", "diff_ids": diffIDs},
+	})
+	if err != nil {
+		return "", err
+	}
+	if manifest.Config, err = registry.PushBlob(ctx, ref, oci.MediaTypeConfig, config); err != nil {
+		return "", err
+	}
+	return registry.PutManifest(ctx, ref, tag, manifest)
+}
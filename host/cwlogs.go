@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+)
+
+// PutLogEvents 单批最多的事件数
+const cloudWatchLogsBatch = 1000
+
+// 调用 CloudWatch Logs JSON API (PutLogEvents、CreateLogStream) 的最小客户端，
+// 请求以 SigV4 签名，凭证和区域来自 AWS 默认凭证链
+type cloudWatchLogs struct {
+	cfg    aws.Config
+	group  string
+	stream string
+	// 为空时为 https://logs.<region>.amazonaws.com
+	endpoint string
+}
+
+// 日志事件，timestamp 为 Unix 毫秒
+type cloudWatchLogEvent struct {
+	Timestamp int64  `json:"timestamp"`
+	Message   string `json:"message"`
+}
+
+func newCloudWatchLogs(ctx context.Context, group, stream string) (*cloudWatchLogs, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("加载 AWS 配置失败: %v", err)
+	}
+	if cfg.Region == "" {
+		return nil, fmt.Errorf("未配置 AWS 区域 (AWS_REGION)")
+	}
+	c := &cloudWatchLogs{cfg: cfg, group: group, stream: stream}
+	if err := c.createStream(ctx); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// 创建日志流，已存在时忽略
+func (c *cloudWatchLogs) createStream(ctx context.Context) error {
+	err := c.call(ctx, "CreateLogStream", map[string]string{"logGroupName": c.group, "logStreamName": c.stream})
+	if err != nil && !strings.Contains(err.Error(), "ResourceAlreadyExistsException") {
+		return err
+	}
+	return nil
+}
+
+// 按时间顺序分批写入日志事件
+func (c *cloudWatchLogs) put(ctx context.Context, events []cloudWatchLogEvent) error {
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Timestamp < events[j].Timestamp })
+	for len(events) > 0 {
+		n := len(events)
+		if n > cloudWatchLogsBatch {
+			n = cloudWatchLogsBatch
+		}
+		request := map[string]interface{}{"logGroupName": c.group, "logStreamName": c.stream, "logEvents": events[:n]}
+		if err := c.call(ctx, "PutLogEvents", request); err != nil {
+			return err
+		}
+		events = events[n:]
+	}
+	return nil
+}
+
+func (c *cloudWatchLogs) call(ctx context.Context, action string, request interface{}) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return err
+	}
+	endpoint := c.endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://logs.%s.amazonaws.com/", c.cfg.Region)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "Logs_20140328."+action)
+
+	credentials, err := c.cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return fmt.Errorf("获取 AWS 凭证失败: %v", err)
+	}
+	hash := sha256.Sum256(body)
+	if err := v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "logs", c.cfg.Region, time.Now()); err != nil {
+		return fmt.Errorf("签名 CloudWatch Logs 请求失败: %v", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 CloudWatch Logs 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("CloudWatch Logs %s 失败: %s %s", action, resp.Status, strings.TrimSpace(string(data)))
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 两个证明文档的一项差异，值为可读文本 (二进制为十六进制或可打印文本)
+type docDifference struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+// --json 时 diff 的输出
+type docDiff struct {
+	Identical bool `json:"identical"`
+	// 第二个文档的时间戳减去第一个的时间戳 (毫秒)
+	TimestampDeltaMS int64           `json:"timestamp_delta_ms"`
+	Differences      []docDifference `json:"differences"`
+}
+
+// 逐字段比较两个本地证明文档，用于排查此前通过的 Enclave 为何不再满足策略
+func diffCommand(fs *flag.FlagSet) func(args []string) {
+	includeTimestamp := fs.Bool("timestamp", false, "将时间戳也列为差异 (默认只输出时间差)")
+	return func(args []string) {
+		docs := make([]*attestation.SignedDocument, 2)
+		for i, path := range args {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				exitf(exitBadInput, "读取证明文档失败: %v", err)
+			}
+			if docs[i], err = attestation.Parse(attestation.Decode(data)); err != nil {
+				exitf(exitBadInput, "%s: %v", path, err)
+			}
+		}
+
+		result := compareDocuments(docs[0], docs[1], *includeTimestamp)
+		if jsonOutput {
+			printJSON(result)
+			return
+		}
+		fmt.Printf("时间差: %s\n", time.Duration(result.TimestampDeltaMS)*time.Millisecond)
+		if result.Identical {
+			fmt.Println("除时间戳外无差异")
+			return
+		}
+		for _, d := range result.Differences {
+			fmt.Printf("%s:\n  - %s\n  + %s\n", d.Field, d.Old, d.New)
+		}
+	}
+}
+
+func compareDocuments(a, b *attestation.SignedDocument, includeTimestamp bool) docDiff {
+	result := docDiff{TimestampDeltaMS: int64(b.Timestamp) - int64(a.Timestamp), Differences: []docDifference{}}
+	add := func(field, old, new string) {
+		if old != new {
+			result.Differences = append(result.Differences, docDifference{Field: field, Old: old, New: new})
+		}
+	}
+
+	add("module_id", a.ModuleID, b.ModuleID)
+	if includeTimestamp {
+		add("timestamp", a.Time().Format(time.RFC3339Nano), b.Time().Format(time.RFC3339Nano))
+	}
+	add("digest", a.Digest, b.Digest)
+	add("algorithm", a.AlgorithmName(), b.AlgorithmName())
+	add("debug", fmt.Sprint(a.IsDebug()), fmt.Sprint(b.IsDebug()))
+
+	// 两个文档中出现的所有 PCR，缺失的一方显示为 (无)
+	all := make(map[int][]byte)
+	for index := range a.PCRs {
+		all[index] = nil
+	}
+	for index := range b.PCRs {
+		all[index] = nil
+	}
+	for _, index := range sortedPCRIndexes(all) {
+		add(fmt.Sprintf("PCR%d", index), pcrText(a.PCRs, index), pcrText(b.PCRs, index))
+	}
+
+	add("user_data", bytesText(a.UserData), bytesText(b.UserData))
+	add("nonce", bytesText(a.Nonce), bytesText(b.Nonce))
+	add("public_key", bytesText(a.PublicKey), bytesText(b.PublicKey))
+
+	// 签名证书及 cabundle 按位置比较，指纹不同即为证书轮换
+	add("certificate", certificateText(a.Certificate), certificateText(b.Certificate))
+	for i := 0; i < len(a.CABundle) || i < len(b.CABundle); i++ {
+		add(fmt.Sprintf("cabundle[%d]", i), bundleText(a.CABundle, i), bundleText(b.CABundle, i))
+	}
+
+	result.Identical = len(result.Differences) == 0
+	return result
+}
+
+func pcrText(pcrs map[int][]byte, index int) string {
+	value, ok := pcrs[index]
+	if !ok {
+		return "(无)"
+	}
+	return hex.EncodeToString(value)
+}
+
+func bytesText(value []byte) string {
+	switch {
+	case len(value) == 0:
+		return "(空)"
+	case isPrintable(value):
+		return fmt.Sprintf("%q", value)
+	default:
+		return hex.EncodeToString(value)
+	}
+}
+
+func bundleText(bundle [][]byte, i int) string {
+	if i >= len(bundle) {
+		return "(无)"
+	}
+	return certificateText(bundle[i])
+}
+
+// 证书的主体、有效期和 SHA-256 指纹
+func certificateText(der []byte) string {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Sprintf("(无法解析: %v)", err)
+	}
+	sum := sha256.Sum256(der)
+	return fmt.Sprintf("%s 有效期至 %s sha256:%s", cert.Subject, cert.NotAfter.Format(time.RFC3339), hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// 未配置上游时使用的 Amazon Route 53 Resolver (VPC DNS)
+const defaultDNSUpstream = "169.254.169.253:53"
+
+// 单个查询转发到上游的超时时间
+const dnsUpstreamTimeout = 5 * time.Second
+
+// 配置文件中的 DNS 代理配置，命令行参数优先:
+//
+//	{"dns": {"listen": "vsock://8053", "upstream": "169.254.169.253:53", "allow": ["amazonaws.com", "example.com"]}}
+type dnsProxyConfig struct {
+	Listen   string   `json:"listen,omitempty"`
+	Upstream string   `json:"upstream,omitempty"`
+	Allow    []string `json:"allow,omitempty"`
+}
+
+// 允许解析的域名: 每项匹配该域名及其子域名，"*" 匹配所有域名
+type dnsAllowList []string
+
+func (l dnsAllowList) allows(name string) bool {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, entry := range l {
+		entry = strings.ToLower(strings.Trim(strings.TrimPrefix(entry, "*."), "."))
+		if entry == "*" || name == entry || strings.HasSuffix(name, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// 为 Enclave 转发 DNS 查询: Enclave 以 --dns-forward 通过 vsock 发送 DNS-over-TCP 格式的查询，
+// 只有查询的域名在允许列表中时才转发到上游解析器，其余返回 REFUSED
+func dnsProxyCommand(fs *flag.FlagSet) func(args []string) {
+	listen := fs.String("listen", "", "监听地址 (vsock://PORT，本地测试可用 tcp://HOST:PORT 或 unix:///PATH)，默认为配置文件中的 dns.listen 或 vsock://8053")
+	upstream := fs.String("upstream", "", "上游 DNS 解析器 (HOST:PORT)，默认为配置文件中的 dns.upstream 或 /etc/resolv.conf 中的第一个 nameserver")
+	var allow stringList
+	fs.Var(&allow, "allow-domain", "允许解析的域名 (含子域名，* 表示全部)，可重复指定，与配置文件中的 dns.allow 合并")
+	return func(args []string) {
+		var config dnsProxyConfig
+		if hostConfig, err := loadHostConfig(enclave.configPath); err == nil && hostConfig.DNS != nil {
+			config = *hostConfig.DNS
+		} else if err != nil && !os.IsNotExist(err) {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *listen != "" {
+			config.Listen = *listen
+		}
+		if config.Listen == "" {
+			config.Listen = "vsock://8053"
+		}
+		if *upstream != "" {
+			config.Upstream = *upstream
+		}
+		if config.Upstream == "" {
+			config.Upstream = systemNameserver()
+		}
+		allowed := dnsAllowList(append(config.Allow, allow...))
+		if len(allowed) == 0 {
+			exitf(exitBadInput, "必须通过 --allow-domain 或配置文件中的 dns.allow 指定允许解析的域名")
+		}
+
+		listener, err := listenRaw(config.Listen)
+		if err != nil {
+			exitf(exitFailure, "监听 %s 失败: %v", config.Listen, err)
+		}
+		log.Printf("DNS 代理已启动，监听 %s，上游 %s，允许 %s\n", config.Listen, config.Upstream, strings.Join(allowed, ","))
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Printf("接受连接失败: %v\n", err)
+				continue
+			}
+			go serveDNSConn(conn, config.Upstream, allowed)
+		}
+	}
+}
+
+// /etc/resolv.conf 中的第一个 nameserver，没有时为 defaultDNSUpstream
+func systemNameserver() string {
+	data, err := os.ReadFile("/etc/resolv.conf")
+	if err != nil {
+		return defaultDNSUpstream
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return defaultDNSUpstream
+}
+
+// 处理一条连接上的查询，每个消息以 2 字节大端长度开头 (RFC 1035 4.2.2)
+func serveDNSConn(conn net.Conn, upstream string, allowed dnsAllowList) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(time.Minute))
+		query, err := readDNSMessage(reader)
+		if err != nil {
+			if err != io.EOF {
+				log.Printf("读取 DNS 查询失败: %v\n", err)
+			}
+			return
+		}
+		response, err := resolveDNS(query, upstream, allowed)
+		if err != nil {
+			log.Printf("%v\n", err)
+			return
+		}
+		if err := writeDNSMessage(conn, response); err != nil {
+			log.Printf("发送 DNS 响应失败: %v\n", err)
+			return
+		}
+	}
+}
+
+// 检查查询的域名并转发到上游，不允许的域名或上游失败时返回 REFUSED 或 SERVFAIL
+func resolveDNS(query []byte, upstream string, allowed dnsAllowList) ([]byte, error) {
+	var parser dnsmessage.Parser
+	header, err := parser.Start(query)
+	if err != nil {
+		return nil, fmt.Errorf("解析 DNS 查询失败: %v", err)
+	}
+	questions, err := parser.AllQuestions()
+	if err != nil {
+		return nil, fmt.Errorf("解析 DNS 查询失败: %v", err)
+	}
+	if len(questions) != 1 {
+		return dnsErrorResponse(header, questions, dnsmessage.RCodeFormatError)
+	}
+
+	name := questions[0].Name.String()
+	if !allowed.allows(name) {
+		log.Printf("拒绝解析 %s (%s)\n", name, questions[0].Type)
+		return dnsErrorResponse(header, questions, dnsmessage.RCodeRefused)
+	}
+	response, err := exchangeDNS(query, upstream)
+	if err != nil {
+		log.Printf("解析 %s 失败: %v\n", name, err)
+		return dnsErrorResponse(header, questions, dnsmessage.RCodeServerFailure)
+	}
+	return response, nil
+}
+
+// 以 UDP 查询上游，响应被截断时改用 TCP
+func exchangeDNS(query []byte, upstream string) ([]byte, error) {
+	conn, err := net.DialTimeout("udp", upstream, dnsUpstreamTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(dnsUpstreamTimeout))
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// 忽略 ID 不符的响应
+		if n < 12 || buf[0] != query[0] || buf[1] != query[1] {
+			continue
+		}
+		if buf[2]&0x02 == 0 {
+			return buf[:n], nil
+		}
+		break
+	}
+
+	tcp, err := net.DialTimeout("tcp", upstream, dnsUpstreamTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer tcp.Close()
+	tcp.SetDeadline(time.Now().Add(dnsUpstreamTimeout))
+	if err := writeDNSMessage(tcp, query); err != nil {
+		return nil, err
+	}
+	return readDNSMessage(tcp)
+}
+
+// 只含问题部分的错误响应
+func dnsErrorResponse(header dnsmessage.Header, questions []dnsmessage.Question, rcode dnsmessage.RCode) ([]byte, error) {
+	message := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 header.ID,
+			Response:           true,
+			OpCode:             header.OpCode,
+			RecursionDesired:   header.RecursionDesired,
+			RecursionAvailable: true,
+			RCode:              rcode,
+		},
+		Questions: questions,
+	}
+	return message.Pack()
+}
+
+func readDNSMessage(r io.Reader) ([]byte, error) {
+	var length [2]byte
+	if _, err := io.ReadFull(r, length[:]); err != nil {
+		return nil, err
+	}
+	message := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(r, message); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+func writeDNSMessage(w io.Writer, message []byte) error {
+	buf := make([]byte, 2+len(message))
+	binary.BigEndian.PutUint16(buf, uint16(len(message)))
+	copy(buf[2:], message)
+	_, err := w.Write(buf)
+	return err
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 校验证明文档并转换为 EAT: cbor 为 UCCS (CWT 声明集)，json 为 JSON 声明集
+func eatCommand(fs *flag.FlagSet) func(args []string) {
+	var policy verifyPolicy
+	policy.register(fs)
+	evidenceType := fs.String("evidence-type", string(attestation.EvidenceNitro), "证据类型，决定使用的校验器")
+	format := fs.String("format", "json", "输出格式 (cbor 或 json)")
+	output := fs.String("output", "", "输出文件路径，为空时输出到标准输出 (cbor 格式必须指定)")
+	issuer := fs.String("issuer", "", "EAT 的 iss 声明")
+	includeEvidence := fs.Bool("include-evidence", false, "在 EAT 中附带原始证明文档，接收方可重新校验签名")
+	return func(args []string) {
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *format != "cbor" && *format != "json" {
+			exitf(exitBadInput, "不支持的输出格式: %s (可选 cbor、json)", *format)
+		}
+		if *format == "cbor" && *output == "" {
+			exitf(exitBadInput, "cbor 格式必须指定 --output")
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		raw := attestation.Decode(data)
+		// EAT 本身不签名，只转换校验通过的证据
+		claims, err := policy.verifyEvidence(attestation.EvidenceType(*evidenceType), raw)
+		if err != nil {
+			exitf(exitCode(err), "校验失败: %v", err)
+		}
+
+		opts := attestation.EATOptions{Issuer: *issuer}
+		if *includeEvidence {
+			opts.Evidence = raw
+		}
+		var eat []byte
+		if *format == "cbor" {
+			eat, err = claims.EAT(opts)
+		} else {
+			var compact []byte
+			if compact, err = claims.EATJSON(opts); err == nil {
+				var indented bytes.Buffer
+				err = json.Indent(&indented, compact, "", "  ")
+				indented.WriteByte('\n')
+				eat = indented.Bytes()
+			}
+		}
+		if err != nil {
+			exitf(exitFailure, "生成 EAT 失败: %v", err)
+		}
+
+		if *output == "" {
+			os.Stdout.Write(eat)
+			return
+		}
+		if err := os.WriteFile(*output, eat, 0644); err != nil {
+			exitf(exitFailure, "写入 EAT 失败: %v", err)
+		}
+		log.Printf("EAT 已保存到 %s (%d 字节)\n", *output, len(eat))
+		if jsonOutput {
+			printJSON(map[string]interface{}{"output": *output, "format": *format, "size": len(eat)})
+			return
+		}
+		fmt.Println(*output)
+	}
+}
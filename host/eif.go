@@ -0,0 +1,24 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 在本地由 EIF 计算期望的 PCR，结果与 nitro-cli build-enclave 的输出相同，可直接用于生成策略
+func measureEIFCommand(fs *flag.FlagSet) func(args []string) {
+	format := fs.String("format", "table", "输出格式 (json、env 或 table)")
+	return func(args []string) {
+		if jsonOutput {
+			*format = "json"
+		}
+		measurements, err := attestation.MeasureEIFFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if err := printPCRs(*format, measurements.PCRs, sortedPCRIndexes(measurements.PCRs)); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+	}
+}
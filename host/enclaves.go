@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+)
+
+// 默认的多 Enclave 配置文件
+const defaultEnclavesConfig = "enclaves.json"
+
+// 配置文件中的一个 Enclave，address 非空时优先于 cid/port
+type enclaveEntry struct {
+	CID     uint   `json:"cid,omitempty"`
+	Port    uint   `json:"port,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+// 多 Enclave 配置文件格式:
+//
+//	{"enclaves": {"payments": {"cid": 16, "port": 5000}, "dev": {"address": "unix:///tmp/attest.sock"}}}
+//
+// 同一文件中还可包含主机为 Enclave 提供的代理 (dns-proxy、tcp-proxy、imds-proxy) 的配置
+type enclavesConfig struct {
+	Enclaves map[string]enclaveEntry `json:"enclaves"`
+	DNS      *dnsProxyConfig         `json:"dns,omitempty"`
+	Proxies  []proxyRoute            `json:"proxies,omitempty"`
+	IMDS     *imdsProxyConfig        `json:"imds,omitempty"`
+}
+
+// 加载配置文件，文件不存在时返回的错误满足 os.IsNotExist
+func loadHostConfig(path string) (*enclavesConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("读取 Enclave 配置失败: %v", err)
+	}
+
+	var config enclavesConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("解析 Enclave 配置失败: %v", err)
+	}
+	return &config, nil
+}
+
+// 加载多 Enclave 配置
+func loadEnclaves(path string) (map[string]enclaveEntry, error) {
+	config, err := loadHostConfig(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取 Enclave 配置失败: %v", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(config.Enclaves) == 0 {
+		return nil, fmt.Errorf("Enclave 配置 %s 中没有 Enclave", path)
+	}
+	for name, entry := range config.Enclaves {
+		if entry.Address == "" && entry.CID == 0 {
+			return nil, fmt.Errorf("Enclave %s 必须指定 cid 或 address", name)
+		}
+	}
+	return config.Enclaves, nil
+}
+
+// 由配置项构造连接地址
+func (e enclaveEntry) endpoint(name string) endpoint {
+	ep := endpoint{name: name, cid: e.CID, port: e.Port, connect: e.Address}
+	if ep.port == 0 {
+		ep.port = 5000
+	}
+	return ep
+}
+
+// 探测配置中每个 Enclave 的可用性和版本
+func listCommand(fs *flag.FlagSet) func(args []string) {
+	timeout := fs.Duration("timeout", 5*time.Second, "每个 Enclave 的探测超时时间")
+	return func(args []string) {
+		enclaves, err := loadEnclaves(enclave.configPath)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		names := make([]string, 0, len(enclaves))
+		for name := range enclaves {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		// 并发探测，单个 Enclave 无响应不影响其他
+		type probe struct {
+			version string
+			latency time.Duration
+			err     error
+		}
+		results := make([]chan probe, len(names))
+		for i, name := range names {
+			results[i] = make(chan probe, 1)
+			go func(ep endpoint, result chan<- probe) {
+				start := time.Now()
+				version, err := ep.health(*timeout)
+				result <- probe{version: version, latency: time.Since(start), err: err}
+			}(enclaves[name].endpoint(name), results[i])
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tADDRESS\tSTATUS\tVERSION\tLATENCY")
+		statuses := make([]healthResult, 0, len(names))
+		for i, name := range names {
+			ep := enclaves[name].endpoint(name)
+			var p probe
+			select {
+			case p = <-results[i]:
+			case <-time.After(*timeout):
+				p = probe{err: fmt.Errorf("超时"), latency: *timeout}
+			}
+			if p.err != nil {
+				statuses = append(statuses, healthResult{Name: name, Address: ep.address(), Status: "down", Error: p.err.Error()})
+				fmt.Fprintf(w, "%s\t%s\tdown (%v)\t-\t-\n", name, ep.address(), p.err)
+				continue
+			}
+			latency := p.latency.Round(time.Millisecond)
+			statuses = append(statuses, healthResult{Name: name, Address: ep.address(), Status: "ok", Version: p.version, LatencyMS: latency.Milliseconds()})
+			fmt.Fprintf(w, "%s\t%s\tok\t%s\t%s\n", name, ep.address(), p.version, latency)
+		}
+		if jsonOutput {
+			printJSON(struct {
+				Enclaves []healthResult `json:"enclaves"`
+			}{statuses})
+			return
+		}
+		w.Flush()
+	}
+}
+
+// 连接 Enclave 并调用 health 方法，返回服务器版本
+func (e *endpoint) health(timeout time.Duration) (string, error) {
+	conn, err := e.dial(nil)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	response, err := conn.Health(ctx)
+	if err != nil {
+		return "", err
+	}
+	if !response.Success {
+		return "", response.Err()
+	}
+	return response.Version, nil
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// 校验证明文档后以其中的公钥加密数据，只有该 Enclave 能解密
+func encryptCommand(fs *flag.FlagSet) func(args []string) {
+	var policy verifyPolicy
+	policy.register(fs)
+	docPath := fs.String("doc", "", "接收方 Enclave 的证明文档 (decrypt-key 子命令的输出)")
+	input := fs.String("in", "-", "要加密的文件，- 表示标准输入")
+	output := fs.String("out", "", "加密消息的输出文件")
+	return func(args []string) {
+		if *docPath == "" || *output == "" {
+			exitf(exitBadInput, "必须指定 --doc 和 --out")
+		}
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		data, err := os.ReadFile(*docPath)
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		claims, err := policy.verifyEvidence(attestation.EvidenceNitro, attestation.Decode(data))
+		if err != nil {
+			exitf(exitCode(err), "校验失败: %v", err)
+		}
+		if len(claims.PublicKey) == 0 {
+			exitf(exitBadInput, "证明文档中没有 public_key")
+		}
+
+		var plaintext []byte
+		if *input == "-" {
+			plaintext, err = io.ReadAll(os.Stdin)
+		} else {
+			plaintext, err = os.ReadFile(*input)
+		}
+		if err != nil {
+			exitf(exitBadInput, "读取明文失败: %v", err)
+		}
+		message, err := client.Seal(claims.PublicKey, plaintext)
+		if err != nil {
+			exitf(exitBadInput, "加密失败: %v", err)
+		}
+		sealed, err := json.Marshal(message)
+		if err != nil {
+			exitf(exitFailure, "%v", err)
+		}
+		if err := os.WriteFile(*output, append(sealed, '\n'), 0644); err != nil {
+			exitf(exitFailure, "写入加密消息失败: %v", err)
+		}
+		if jsonOutput {
+			printJSON(map[string]string{"alg": message.Algorithm, "kid": message.KeyID, "module_id": claims.ModuleID})
+			return
+		}
+		log.Printf("已以 %s 的公钥 %s (%s) 加密到 %s\n", claims.ModuleID, message.KeyID, message.Algorithm, *output)
+	}
+}
+
+// 获取 Enclave 解密公钥的证明文档
+func decryptKeyCommand(fs *flag.FlagSet) func(args []string) {
+	nonce := fs.String("nonce", "", "写入证明文档的 nonce")
+	output := fs.String("output", "decrypt-key.bin", "证明文档的输出文件")
+	format := fs.String("format", formatRaw, "证明文档保存格式 (raw、base64、pem、json 或 jws)")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.DecryptKey(context.Background(), *nonce)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		if err := saveAttestationDoc(response.Document, *output, *format); err != nil {
+			exitf(exitFailure, "保存证明文档失败: %v", err)
+		}
+		log.Printf("解密公钥的证明文档已保存到 %s\n", *output)
+	}
+}
+
+// 将 encrypt 子命令的加密消息发送给 Enclave 解密，明文按句柄保存在 Enclave 内
+func decryptCommand(fs *flag.FlagSet) func(args []string) {
+	handle := fs.String("handle", "", "Enclave 内保存明文的句柄 (本地 HTTP 接口的 GET /secrets/<handle>)")
+	return func(args []string) {
+		if *handle == "" {
+			exitf(exitBadInput, "必须指定 --handle")
+		}
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		sealed, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取加密消息失败: %v", err)
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.Decrypt(context.Background(), *handle, sealed)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		if response.Secret == nil {
+			exitf(exitFailure, "Enclave 响应中没有 secret")
+		}
+		if jsonOutput {
+			printJSON(response.Secret)
+			return
+		}
+		fmt.Printf("句柄: %s\n", response.Secret.Handle)
+		fmt.Printf("大小: %d 字节\n", response.Secret.Size)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/spf13/pflag"
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// Enclave 连接地址: 默认按 --cid/--port 通过 vsock 连接，--connect 指定时使用该地址
+// --enclave 指定时从多 Enclave 配置中按名称选择
+type endpoint struct {
+	cid     uint
+	port    uint
+	connect string
+
+	name       string
+	configPath string
+
+	// 连接后建立加密会话，之后的请求都经会话加密发送
+	session bool
+
+	// 校验会话证明文档的策略，由带校验策略的子命令设置，为空时以内置的 AWS 根证书校验签名和证书链
+	verify func(document []byte) error
+
+	// 在命令行或环境变量中指定的 cid、port 和 connect，优先于配置文件
+	explicit map[string]bool
+}
+
+// 注册 --cid、--port、--connect、--enclave、--enclaves-config 和 --session 参数
+func (e *endpoint) register(fs *flag.FlagSet) {
+	fs.UintVar(&e.cid, "cid", 16, "Enclave 的 CID")
+	fs.UintVar(&e.port, "port", 5000, "vsock 端口")
+	fs.StringVar(&e.connect, "connect", "", "连接地址 (tcp://HOST:PORT 或 unix:///PATH，用于没有 vsock 的本地测试)，指定时忽略 --cid 和 --port")
+	fs.StringVar(&e.name, "enclave", "", "按名称从 --enclaves-config 中选择 Enclave，命令行或环境变量中的 --cid、--port 和 --connect 优先于配置")
+	fs.StringVar(&e.configPath, "enclaves-config", defaultEnclavesConfig, "多 Enclave 配置文件")
+	fs.BoolVar(&e.session, "session", false, "连接后以 session-open 建立加密会话 (Enclave 会话公钥由证明文档证明)，请求和响应在传输层上都是密文")
+}
+
+// 记录在命令行或环境变量中指定的连接参数
+func (e *endpoint) markExplicit(flags *pflag.FlagSet) {
+	e.explicit = make(map[string]bool)
+	for _, name := range []string{"cid", "port", "connect"} {
+		e.explicit[name] = flags.Changed(name)
+	}
+}
+
+// 检查连接参数，指定 --enclave 时解析为配置中的地址
+func (e *endpoint) validate() error {
+	if e.name != "" {
+		enclaves, err := loadEnclaves(e.configPath)
+		if err != nil {
+			return err
+		}
+		entry, ok := enclaves[e.name]
+		if !ok {
+			return fmt.Errorf("Enclave 配置 %s 中没有名为 %s 的 Enclave", e.configPath, e.name)
+		}
+		// 命令行和环境变量中的参数优先，配置中的地址只在未指定任何连接参数时使用
+		resolved := entry.endpoint(e.name)
+		if !e.explicit["cid"] && !e.explicit["port"] && !e.explicit["connect"] {
+			e.connect = resolved.connect
+		}
+		if !e.explicit["cid"] {
+			e.cid = resolved.cid
+		}
+		if !e.explicit["port"] {
+			e.port = resolved.port
+		}
+	}
+
+	if e.connect != "" {
+		_, _, err := client.ParseAddress(e.connect)
+		return err
+	}
+	if e.cid == 0 {
+		return fmt.Errorf("必须指定 Enclave 的 CID")
+	}
+	return nil
+}
+
+// 连接地址，vsock 地址以 vsock://CID:PORT 表示
+func (e *endpoint) address() string {
+	if e.connect != "" {
+		return e.connect
+	}
+	return fmt.Sprintf("vsock://%d:%d", e.cid, e.port)
+}
+
+// 用于指标维度的 Enclave 标识: 配置中的名称或连接地址
+func (e *endpoint) label() string {
+	if e.name != "" {
+		return e.name
+	}
+	return e.address()
+}
+
+func (e *endpoint) String() string {
+	if e.name != "" {
+		return fmt.Sprintf("%s, %s", e.name, e.address())
+	}
+	if e.connect != "" {
+		return e.connect
+	}
+	return fmt.Sprintf("CID: %d", e.cid)
+}
+
+// 连接到 Enclave 并完成握手
+func (e *endpoint) dial(opts *client.Options) (*client.Client, error) {
+	return e.dialContext(context.Background(), opts)
+}
+
+// 同 dial，连接记录为 ctx 中 span 的子 span
+func (e *endpoint) dialContext(ctx context.Context, opts *client.Options) (*client.Client, error) {
+	if e.session {
+		withSession := client.Options{}
+		if opts != nil {
+			withSession = *opts
+		}
+		withSession.Session = true
+		if withSession.VerifyAttestation == nil {
+			withSession.VerifyAttestation = e.verify
+		}
+		opts = &withSession
+	}
+	if e.connect != "" {
+		return client.DialAddressContext(ctx, e.connect, opts)
+	}
+	return client.DialContext(ctx, uint32(e.cid), uint32(e.port), opts)
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// 参数对应的环境变量前缀: --cid 对应 ATTEST_CID，--enclaves-config 对应 ATTEST_ENCLAVES_CONFIG
+const envPrefix = "ATTEST_"
+
+// 参数对应的环境变量名
+func envName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// 命令行未指定的参数从对应的环境变量读取，优先级为: 命令行参数 > 环境变量 > 配置文件 (enclaves.json)
+func applyEnv(flags *pflag.FlagSet) error {
+	var err error
+	flags.VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed {
+			return
+		}
+		name := envName(f.Name)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = fmt.Errorf("环境变量 %s 无效: %v", name, setErr)
+		}
+	})
+	return err
+}
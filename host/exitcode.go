@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// 退出码按失败类别划分，所有子命令一致，便于脚本和 CI 据此分支
+const (
+	exitFailure      = 1 // 其他错误
+	exitBadInput     = 2 // 参数无效或无法读取、解析输入文件
+	exitConnection   = 3 // 无法连接 Enclave 或通信失败
+	exitVerification = 4 // 证明文档的签名或证书链校验失败
+	exitPolicy       = 5 // 证明文档与策略不符 (PCR、public_key、nonce、调试模式、时效等)
+)
+
+// Enclave 返回错误时的退出码，按错误码细分，便于脚本区分失败原因
+const exitEnclaveError = 10
+
+var enclaveExitCodes = []struct {
+	err  error
+	code int
+}{
+	{client.ErrBadRequest, 11},
+	{client.ErrParse, 12},
+	{client.ErrInvalidPublicKey, 13},
+	{client.ErrUnauthorized, 14},
+	{client.ErrRequestTooLarge, 15},
+	{client.ErrUnsupportedMethod, 16},
+	{client.ErrNSMUnavailable, 17},
+	{client.ErrNSM, 18},
+	{client.ErrTimeout, 19},
+	{client.ErrInternal, 20},
+	{client.ErrUnsupportedSchema, 21},
+}
+
+// 标记了失败类别的错误，错误信息不变
+type classifiedError struct {
+	code int
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() error {
+	return e.err
+}
+
+// 以退出码 code 标记错误的失败类别
+func classify(code int, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{code: code, err: err}
+}
+
+// 证明文档签名或证书链校验失败
+func verificationError(err error) error {
+	return classify(exitVerification, err)
+}
+
+// 证明文档与策略不符
+func policyErrorf(format string, args ...interface{}) error {
+	return classify(exitPolicy, fmt.Errorf(format, args...))
+}
+
+// 错误对应的退出码
+func exitCode(err error) int {
+	for _, e := range enclaveExitCodes {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	var enclaveErr *client.EnclaveError
+	if errors.As(err, &enclaveErr) {
+		return exitEnclaveError
+	}
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return classified.code
+	}
+	if errors.Is(err, client.ErrConnection) {
+		return exitConnection
+	}
+	return exitFailure
+}
+
+// 记录错误并以对应的退出码退出
+func exitWithError(err error) {
+	exit(exitCode(err), err)
+}
+
+// 记录错误并以退出码 code 退出
+func exitf(code int, format string, args ...interface{}) {
+	exit(code, fmt.Errorf(format, args...))
+}
+
+// --json 时同时将错误以 JSON 对象输出到标准输出
+func exit(code int, err error) {
+	log.Print(err)
+	if jsonOutput {
+		result := errorResult{Error: err.Error(), Class: exitClass(code), ExitCode: code}
+		var enclaveErr *client.EnclaveError
+		if errors.As(err, &enclaveErr) {
+			result.ErrorCode = enclaveErr.Code
+		}
+		printJSON(result)
+	}
+	os.Exit(code)
+}
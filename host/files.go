@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// --json 时 push-file、pull-file 的输出
+type fileTransferResult struct {
+	Local   string `json:"local"`
+	Enclave string `json:"enclave"`
+	Size    int64  `json:"size"`
+	SHA256  string `json:"sha256"`
+}
+
+func printFileTransfer(result fileTransferResult, verb string) {
+	if jsonOutput {
+		printJSON(result)
+		return
+	}
+	fmt.Printf("已%s %s (%d 字节, sha256 %s)\n", verb, result.Enclave, result.Size, result.SHA256)
+}
+
+// SHA-256 校验失败按校验失败 (退出码 4) 处理
+func fileTransferError(err error) error {
+	if errors.Is(err, client.ErrFileDigest) {
+		return verificationError(err)
+	}
+	return err
+}
+
+// 将本地文件分块上传到 Enclave 的 --file-root 下，Enclave 校验 SHA-256 后替换目标文件
+func pushFileCommand(fs *flag.FlagSet) func(args []string) {
+	chunkSize := fs.Int("chunk-size", client.DefaultPushChunkSize, "每个请求携带的字节数 (base64 编码后需小于 Enclave 的 --max-request-size)")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		file, err := os.Open(args[0])
+		if err != nil {
+			exitf(exitBadInput, "打开文件失败: %v", err)
+		}
+		defer file.Close()
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		result, err := conn.PushFile(context.Background(), args[1], file, *chunkSize)
+		if err != nil {
+			exitWithError(fileTransferError(err))
+		}
+		printFileTransfer(fileTransferResult{Local: args[0], Enclave: result.Path, Size: result.Size, SHA256: result.SHA256}, "上传")
+	}
+}
+
+// 从 Enclave 的 --file-root 下分块下载文件，SHA-256 校验通过后才重命名到目标路径
+func pullFileCommand(fs *flag.FlagSet) func(args []string) {
+	chunkSize := fs.Int("chunk-size", client.DefaultPullChunkSize, "每个请求读取的字节数")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		temp, err := createPrivateTemp(filepath.Dir(args[1]), ".pull-file-*")
+		if err != nil {
+			exitf(exitFailure, "创建临时文件失败: %v", err)
+		}
+		defer temp.close()
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			temp.close()
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		result, err := conn.PullFile(context.Background(), args[0], temp, *chunkSize)
+		if err != nil {
+			temp.close()
+			exitWithError(fileTransferError(err))
+		}
+		if err := temp.rename(args[1]); err != nil {
+			temp.close()
+			exitf(exitFailure, "保存文件失败: %v", err)
+		}
+		printFileTransfer(fileTransferResult{Local: args[1], Enclave: result.Path, Size: result.Size, SHA256: result.SHA256}, "下载")
+	}
+}
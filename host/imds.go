@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 默认的 IMDS 地址
+const defaultIMDSEndpoint = "http://169.254.169.254"
+
+// 主机 IMDSv2 令牌的有效期 (秒)，到期前一分钟刷新
+const imdsTokenTTL = 21600
+
+// 默认允许 Enclave 访问的 IMDS 路径前缀: IAM 角色凭证、区域及实例身份文档，
+// 足以让 Enclave 内的 AWS SDK 使用默认凭证链
+var defaultIMDSPaths = []string{
+	"/latest/meta-data/iam/security-credentials/",
+	"/latest/meta-data/iam/info",
+	"/latest/meta-data/placement/region",
+	"/latest/meta-data/placement/availability-zone",
+	"/latest/dynamic/instance-identity/",
+}
+
+// 配置文件中的 IMDS 代理配置，命令行参数优先:
+//
+//	{"imds": {"listen": "vsock://8002", "allow": ["/latest/meta-data/instance-id"]}}
+type imdsProxyConfig struct {
+	Listen   string   `json:"listen,omitempty"`
+	Endpoint string   `json:"endpoint,omitempty"`
+	Allow    []string `json:"allow,omitempty"`
+}
+
+// 将 Enclave 的 IMDS 请求转发到主机的 IMDS，由主机获取并刷新 IMDSv2 令牌
+type imdsProxy struct {
+	endpoint string
+	allow    []string
+	client   *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// 为 Enclave 代理允许的 IMDS 路径，Enclave 以 --imds-forward 连接
+func imdsProxyCommand(fs *flag.FlagSet) func(args []string) {
+	listen := fs.String("listen", "", "监听地址 (vsock://PORT，本地测试可用 tcp://HOST:PORT 或 unix:///PATH)，默认为配置文件中的 imds.listen 或 vsock://8002")
+	endpoint := fs.String("endpoint", "", "IMDS 地址，默认为配置文件中的 imds.endpoint 或 "+defaultIMDSEndpoint)
+	var allow stringList
+	fs.Var(&allow, "allow-path", "在默认路径 (凭证、区域、实例身份文档) 之外允许的 IMDS 路径前缀，可重复指定")
+	return func(args []string) {
+		var config imdsProxyConfig
+		if hostConfig, err := loadHostConfig(enclave.configPath); err == nil && hostConfig.IMDS != nil {
+			config = *hostConfig.IMDS
+		} else if err != nil && !os.IsNotExist(err) {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *listen != "" {
+			config.Listen = *listen
+		}
+		if config.Listen == "" {
+			config.Listen = "vsock://8002"
+		}
+		if *endpoint != "" {
+			config.Endpoint = *endpoint
+		}
+		if config.Endpoint == "" {
+			config.Endpoint = defaultIMDSEndpoint
+		}
+
+		proxy := &imdsProxy{
+			endpoint: strings.TrimSuffix(config.Endpoint, "/"),
+			allow:    append(append(append([]string(nil), defaultIMDSPaths...), config.Allow...), allow...),
+			client:   &http.Client{Timeout: 5 * time.Second},
+		}
+		listener, err := listenRaw(config.Listen)
+		if err != nil {
+			exitf(exitFailure, "监听 %s 失败: %v", config.Listen, err)
+		}
+		log.Printf("IMDS 代理已启动，监听 %s，转发到 %s\n", config.Listen, proxy.endpoint)
+		server := &http.Server{Handler: proxy, ReadHeaderTimeout: 10 * time.Second}
+		exitf(exitFailure, "IMDS 代理退出: %v", server.Serve(listener))
+	}
+}
+
+func (p *imdsProxy) allows(path string) bool {
+	if strings.Contains(path, "..") {
+		return false
+	}
+	for _, prefix := range p.allow {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *imdsProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "只支持 GET", http.StatusMethodNotAllowed)
+		return
+	}
+	if !p.allows(r.URL.Path) {
+		log.Printf("拒绝 IMDS 请求 %s\n", r.URL.Path)
+		http.Error(w, "路径不在允许列表中", http.StatusForbidden)
+		return
+	}
+
+	resp, err := p.get(r.Context(), r.URL.Path)
+	if err != nil {
+		log.Printf("请求 IMDS %s 失败: %v\n", r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// 以主机的 IMDSv2 令牌请求 path，令牌失效 (401) 时刷新并重试一次
+func (p *imdsProxy) get(ctx context.Context, path string) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		token, err := p.sessionToken(ctx, attempt > 0)
+		if err != nil {
+			return nil, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.endpoint+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", token)
+		resp, err := p.client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, nil
+		}
+		resp.Body.Close()
+	}
+}
+
+// 主机的 IMDSv2 令牌，过期前一分钟或 refresh 时重新获取
+func (p *imdsProxy) sessionToken(ctx context.Context, refresh bool) (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !refresh && p.token != "" && time.Until(p.tokenExpiry) > time.Minute {
+		return p.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.endpoint+"/latest/api/token", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", strconv.Itoa(imdsTokenTTL))
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("获取 IMDSv2 令牌失败: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("获取 IMDSv2 令牌失败: %s", resp.Status)
+	}
+	p.token = strings.TrimSpace(string(body))
+	p.tokenExpiry = time.Now().Add(imdsTokenTTL * time.Second)
+	return p.token, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 解析本地保存的证明文档并打印其内容，不需要连接 Enclave
+func inspectCommand(fs *flag.FlagSet) func(args []string) {
+	return func(args []string) {
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		doc, err := attestation.Parse(attestation.Decode(data))
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		if jsonOutput {
+			printJSON(doc)
+			return
+		}
+		printDocument(doc)
+	}
+}
+
+// 以可读格式打印证明文档
+func printDocument(doc *attestation.SignedDocument) {
+	fmt.Printf("Module ID:   %s\n", doc.ModuleID)
+	fmt.Printf("Timestamp:   %s (%d)\n", doc.Time().Format(time.RFC3339Nano), doc.Timestamp)
+	fmt.Printf("Digest:      %s\n", doc.Digest)
+	fmt.Printf("Signature:   %s\n", doc.AlgorithmName())
+	if doc.IsDebug() {
+		fmt.Println("Debug Mode:  是 (PCR0/1/2 全为零，不应在生产环境中信任)")
+	}
+
+	fmt.Println("\nPCRs:")
+	for _, index := range sortedPCRIndexes(doc.PCRs) {
+		fmt.Printf("  PCR%-2d  %s\n", index, hex.EncodeToString(doc.PCRs[index]))
+	}
+
+	fmt.Println()
+	printBytesField("User Data:", doc.UserData)
+	printBytesField("Nonce:", doc.Nonce)
+	printBytesField("Public Key:", doc.PublicKey)
+
+	fmt.Println("\nCertificates:")
+	printCertificate("certificate", doc.Certificate)
+	for i, der := range doc.CABundle {
+		printCertificate(fmt.Sprintf("cabundle[%d]", i), der)
+	}
+}
+
+// 可打印的 UTF-8 文本原样输出，否则输出十六进制
+func printBytesField(name string, value []byte) {
+	switch {
+	case len(value) == 0:
+		fmt.Printf("%-12s (空)\n", name)
+	case isPrintable(value):
+		fmt.Printf("%-12s %q\n", name, value)
+	default:
+		fmt.Printf("%-12s %s (%d 字节)\n", name, hex.EncodeToString(value), len(value))
+	}
+}
+
+func isPrintable(value []byte) bool {
+	if !utf8.Valid(value) {
+		return false
+	}
+	for _, r := range string(value) {
+		if r < 0x20 && r != '\n' && r != '\t' {
+			return false
+		}
+	}
+	return true
+}
+
+func printCertificate(name string, der []byte) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		fmt.Printf("  %-13s 解析失败: %v\n", name, err)
+		return
+	}
+	fmt.Printf("  %-13s %s\n", name, cert.Subject)
+	fmt.Printf("  %-13s 有效期 %s ~ %s\n", "", cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339))
+}
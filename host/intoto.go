@@ -0,0 +1,148 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// in-toto Statement v1 及本工具的运行时证明 predicate 类型
+const (
+	inTotoStatementType  = "https://in-toto.io/Statement/v1"
+	runtimePredicateType = "urn:aws-enclave-attestation:runtime-attestation:1"
+)
+
+// in-toto Statement: subject 为 Enclave 镜像 (以 PCR0 标识)，predicate 为校验结论及度量值
+type inTotoStatement struct {
+	Type          string             `json:"_type"`
+	Subject       []inTotoSubject    `json:"subject"`
+	PredicateType string             `json:"predicateType"`
+	Predicate     runtimeAttestation `json:"predicate"`
+}
+
+type inTotoSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// 运行时证明 predicate
+type runtimeAttestation struct {
+	Verifier     inTotoVerifier    `json:"verifier"`
+	TimeVerified time.Time         `json:"timeVerified"`
+	Result       string            `json:"verificationResult"`
+	Error        string            `json:"error,omitempty"`
+	EvidenceType string            `json:"evidenceType"`
+	ModuleID     string            `json:"moduleId"`
+	Timestamp    time.Time         `json:"timestamp"`
+	Debug        bool              `json:"debug"`
+	Measurements map[string]string `json:"measurements"`
+	UserData     string            `json:"userData,omitempty"`
+	Nonce        string            `json:"nonce,omitempty"`
+	PublicKey    string            `json:"publicKey,omitempty"`
+	Evidence     inTotoEvidence    `json:"evidence"`
+}
+
+type inTotoVerifier struct {
+	ID string `json:"id"`
+}
+
+// 原始证明文档，接收方可据此重新校验
+type inTotoEvidence struct {
+	MediaType string            `json:"mediaType"`
+	Digest    map[string]string `json:"digest"`
+	Content   []byte            `json:"content"`
+}
+
+// 以 in-toto Statement 输出证明文档的校验结论和 PCR，校验失败时同样输出 (verificationResult 为 FAILED) 并以对应退出码退出
+func inTotoCommand(fs *flag.FlagSet) func(args []string) {
+	var policy verifyPolicy
+	policy.register(fs)
+	evidenceType := fs.String("evidence-type", string(attestation.EvidenceNitro), "证据类型，决定使用的校验器")
+	subjectName := fs.String("subject-name", "", "subject 名称，为空时使用文档的 module_id")
+	output := fs.String("output", "", "Statement 保存路径，为空时输出到标准输出")
+	return func(args []string) {
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		raw := attestation.Decode(data)
+		claims, err := attestation.ParseEvidence(attestation.EvidenceType(*evidenceType), raw)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		_, verifyErr := policy.verifyEvidence(claims.Type, raw)
+
+		statement := newInTotoStatement(claims, raw, verifyErr)
+		if *subjectName != "" {
+			statement.Subject[0].Name = *subjectName
+		}
+		if *output != "" {
+			file, err := os.Create(*output)
+			if err != nil {
+				exitf(exitFailure, "创建 %s 失败: %v", *output, err)
+			}
+			writeJSON(file, statement)
+			if err := file.Close(); err != nil {
+				exitf(exitFailure, "写入 %s 失败: %v", *output, err)
+			}
+			log.Printf("in-toto Statement 已保存到 %s\n", *output)
+		} else {
+			printJSON(statement)
+		}
+		if verifyErr != nil {
+			exitf(exitCode(verifyErr), "校验失败: %v", verifyErr)
+		}
+	}
+}
+
+func newInTotoStatement(claims *attestation.Claims, raw []byte, verifyErr error) inTotoStatement {
+	measurements := make(map[string]string, len(claims.Measurements))
+	for index, value := range claims.Measurements {
+		measurements[fmt.Sprintf("PCR%d", index)] = hex.EncodeToString(value)
+	}
+
+	// PCR0 为 Enclave 镜像的 SHA-384 度量值
+	subject := inTotoSubject{Name: claims.ModuleID, Digest: map[string]string{}}
+	if pcr0, ok := claims.Measurements[0]; ok {
+		subject.Digest["sha384"] = hex.EncodeToString(pcr0)
+	}
+
+	documentHash := sha256.Sum256(raw)
+	predicate := runtimeAttestation{
+		Verifier:     inTotoVerifier{ID: "attestation-client"},
+		TimeVerified: time.Now().UTC(),
+		Result:       "PASSED",
+		EvidenceType: string(claims.Type),
+		ModuleID:     claims.ModuleID,
+		Timestamp:    claims.Time,
+		Debug:        claims.Debug,
+		Measurements: measurements,
+		UserData:     hex.EncodeToString(claims.UserData),
+		Nonce:        hex.EncodeToString(claims.Nonce),
+		PublicKey:    hex.EncodeToString(claims.PublicKey),
+		Evidence: inTotoEvidence{
+			MediaType: "application/cose; cose-type=\"cose-sign1\"",
+			Digest:    map[string]string{"sha256": hex.EncodeToString(documentHash[:])},
+			Content:   raw,
+		},
+	}
+	if verifyErr != nil {
+		predicate.Result = "FAILED"
+		predicate.Error = verifyErr.Error()
+	}
+	return inTotoStatement{
+		Type:          inTotoStatementType,
+		Subject:       []inTotoSubject{subject},
+		PredicateType: runtimePredicateType,
+		Predicate:     predicate,
+	}
+}
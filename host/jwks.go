@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+)
+
+// 从 Enclave 获取 JWT 签名公钥并通过 HTTP 发布的网关
+type jwksGateway struct {
+	enclave endpoint
+	refresh time.Duration
+
+	mu        sync.Mutex
+	cached    *jwks.Set
+	fetchedAt time.Time
+}
+
+// 请求 Enclave 证明签名公钥，返回携带证明文档的 JWK
+func (g *jwksGateway) fetchKey(ctx context.Context, nonce string) (jwks.Key, error) {
+	conn, err := g.enclave.dial(nil)
+	if err != nil {
+		return jwks.Key{}, err
+	}
+	defer conn.Close()
+
+	response, err := conn.SigningKey(ctx, nonce)
+	if err != nil {
+		return jwks.Key{}, err
+	}
+	if !response.Success {
+		return jwks.Key{}, response.Err()
+	}
+
+	raw := attestation.Decode([]byte(response.Document))
+	doc, err := attestation.Parse(raw)
+	if err != nil {
+		return jwks.Key{}, err
+	}
+	public, err := x509.ParsePKIXPublicKey(doc.PublicKey)
+	if err != nil {
+		return jwks.Key{}, fmt.Errorf("解析签名公钥失败: %v", err)
+	}
+	ecKey, ok := public.(*ecdsa.PublicKey)
+	if !ok {
+		return jwks.Key{}, fmt.Errorf("签名公钥不是 ECDSA")
+	}
+
+	key, err := jwks.FromECDSA(ecKey)
+	if err != nil {
+		return jwks.Key{}, err
+	}
+	key.Attestation = base64.StdEncoding.EncodeToString(raw)
+	return key, nil
+}
+
+// 返回缓存的 JWK Set，超过刷新间隔后重新获取证明文档
+func (g *jwksGateway) keySet(ctx context.Context) (*jwks.Set, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.cached != nil && time.Since(g.fetchedAt) < g.refresh {
+		return g.cached, nil
+	}
+
+	key, err := g.fetchKey(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	g.cached = &jwks.Set{Keys: []jwks.Key{key}}
+	g.fetchedAt = time.Now()
+	log.Printf("已刷新 JWKS，kid: %s\n", key.Kid)
+	return g.cached, nil
+}
+
+func (g *jwksGateway) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	set, err := g.keySet(r.Context())
+	if err != nil {
+		log.Printf("获取签名公钥失败: %v\n", err)
+		http.Error(w, "获取签名公钥失败", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(g.refresh.Seconds())))
+	json.NewEncoder(w).Encode(set)
+}
+
+// 携带调用方随机数的最新证明文档，供依赖方确认签名密钥仍由运行中的 Enclave 持有
+func (g *jwksGateway) handleAttestation(w http.ResponseWriter, r *http.Request) {
+	key, err := g.fetchKey(r.Context(), r.URL.Query().Get("nonce"))
+	if err != nil {
+		log.Printf("获取签名公钥失败: %v\n", err)
+		http.Error(w, "获取签名公钥失败", http.StatusBadGateway)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(key)
+}
+
+// 启动 JWKS 网关
+func jwksGatewayCommand(fs *flag.FlagSet) func(args []string) {
+	listen := fs.String("listen", ":8081", "HTTP 监听地址")
+	refresh := fs.Duration("refresh", 5*time.Minute, "JWKS 中证明文档的刷新间隔")
+	var profiling pprofFlags
+	profiling.register(fs)
+	return func(args []string) {
+		profiling.start()
+
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		g := &jwksGateway{enclave: enclave, refresh: *refresh}
+
+		mux := http.NewServeMux()
+		mux.HandleFunc("/.well-known/jwks.json", g.handleJWKS)
+		mux.HandleFunc("/attestation", g.handleAttestation)
+
+		server := &http.Server{
+			Addr:              *listen,
+			Handler:           mux,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		log.Printf("JWKS 网关监听 %s (Enclave %s)\n", *listen, &enclave)
+		exitf(exitFailure, "JWKS 网关退出: %v", server.ListenAndServe())
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+)
+
+// 离线校验证明文档并转换为由本地密钥签名的 JWT
+func jwtCommand(fs *flag.FlagSet) func(args []string) {
+	var policy verifyPolicy
+	policy.register(fs)
+	evidenceType := fs.String("evidence-type", string(attestation.EvidenceNitro), "证据类型，决定使用的校验器")
+	keyPath := fs.String("key", "", "JWT 签名私钥 (PEM 格式 ECDSA)，接收方以对应公钥 (或 JWKS) 校验")
+	issuer := fs.String("issuer", "", "JWT 的 iss 声明")
+	audience := fs.String("audience", "", "JWT 的 aud 声明")
+	ttl := fs.Duration("ttl", 15*time.Minute, "JWT 有效期，0 表示不设置 exp")
+	output := fs.String("output", "", "输出文件路径，为空时输出到标准输出")
+	jwksPath := fs.String("jwks", "", "同时将签名公钥写入该 JWK Set 文件，供接收方校验 JWT")
+	return func(args []string) {
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *keyPath == "" {
+			exitf(exitBadInput, "必须指定 --key")
+		}
+		key, err := jwks.LoadPrivateKey(*keyPath)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		jwk, err := jwks.FromECDSA(&key.PublicKey)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		raw := attestation.Decode(data)
+		// JWT 由校验方签名，只转换校验通过的证据
+		claims, err := policy.verifyEvidence(attestation.EvidenceType(*evidenceType), raw)
+		if err != nil {
+			exitf(exitCode(err), "校验失败: %v", err)
+		}
+
+		token, err := claims.JWT(key, attestation.JWTOptions{
+			Issuer:   *issuer,
+			Audience: *audience,
+			TTL:      *ttl,
+			KeyID:    jwk.Kid,
+		})
+		if err != nil {
+			exitf(exitFailure, "生成 JWT 失败: %v", err)
+		}
+
+		if *jwksPath != "" {
+			set, _ := json.MarshalIndent(jwks.Set{Keys: []jwks.Key{jwk}}, "", "  ")
+			if err := os.WriteFile(*jwksPath, append(set, '\n'), 0644); err != nil {
+				exitf(exitFailure, "写入 JWK Set 失败: %v", err)
+			}
+		}
+
+		if *output == "" {
+			fmt.Println(token)
+			return
+		}
+		if err := os.WriteFile(*output, []byte(token+"\n"), 0600); err != nil {
+			exitf(exitFailure, "写入 JWT 失败: %v", err)
+		}
+		log.Printf("JWT 已保存到 %s (kid %s)\n", *output, jwk.Kid)
+		if jsonOutput {
+			printJSON(map[string]interface{}{"output": *output, "kid": jwk.Kid, "module_id": claims.ModuleID})
+			return
+		}
+		fmt.Println(*output)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+)
+
+// 按类型生成密钥对: rsa2048、rsa4096、p256 或 p384
+func generateKeyPair(kind string) (crypto.Signer, error) {
+	switch kind {
+	case "rsa2048":
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case "rsa4096":
+		return rsa.GenerateKey(rand.Reader, 4096)
+	case "p256":
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case "p384":
+		return ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型: %s (可选 rsa2048、rsa4096、p256、p384)", kind)
+	}
+}
+
+// 生成密钥对并将私钥以 PKCS#8 PEM 格式保存，返回 DER 格式的公钥
+func generateKeyFile(kind string, keyOut string) ([]byte, error) {
+	key, err := generateKeyPair(kind)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, fmt.Errorf("编码私钥失败: %v", err)
+	}
+	// 私钥文件仅允许当前用户读写，已存在时不覆盖
+	f, err := os.OpenFile(keyOut, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("创建私钥文件失败: %v", err)
+	}
+	if err := pem.Encode(f, &pem.Block{Type: "PRIVATE KEY", Bytes: der}); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("写入私钥文件失败: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, fmt.Errorf("写入私钥文件失败: %v", err)
+	}
+
+	public, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("编码公钥失败: %v", err)
+	}
+	return public, nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// 让 Enclave 以 KMS 非对称密钥签名，并保存绑定签名的证明文档
+func kmsSignCommand(fs *flag.FlagSet) func(args []string) {
+	keyID := fs.String("key-id", "", "KMS 签名密钥 (须在 Enclave 的 --kms-sign-key 中)，Enclave 只配置了一个密钥时可省略")
+	algorithm := fs.String("algorithm", "ECDSA_SHA_256", "KMS 签名算法 (如 ECDSA_SHA_256、RSASSA_PSS_SHA_256)")
+	digest := fs.Bool("digest", false, "先在主机上按签名算法计算消息摘要，只将摘要发送给 Enclave (消息超过 4096 字节时需要)")
+	region := fs.String("region", "", "KMS 的区域，为空时使用 Enclave 的默认区域")
+	nonce := fs.String("nonce", "", "写入证明文档的 nonce")
+	signatureOutput := fs.String("output", "", "保存签名 (DER 或原始字节) 的文件路径，为空时输出 base64")
+	documentOutput := fs.String("document-output", "", "保存绑定签名的证明文档的文件路径")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		message, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取消息文件失败: %v", err)
+		}
+		if *digest {
+			message, err = signingDigest(*algorithm, message)
+			if err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		request := client.CommandArgs{KeyID: *keyID, Region: *region, SigningAlgorithm: *algorithm, Digest: *digest, Nonce: *nonce}
+		response, err := conn.KMSSign(context.Background(), request, message)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		if response.Signature == nil {
+			exitf(exitFailure, "Enclave 响应中没有 signature")
+		}
+
+		if *documentOutput != "" {
+			if err := saveAttestationDoc(response.Document, *documentOutput, formatRaw); err != nil {
+				exitf(exitFailure, "保存证明文档失败: %v", err)
+			}
+			log.Printf("绑定签名的证明文档已保存到 %s\n", *documentOutput)
+		}
+		if *signatureOutput != "" {
+			if err := os.WriteFile(*signatureOutput, response.Signature.Signature, 0644); err != nil {
+				exitf(exitFailure, "保存签名失败: %v", err)
+			}
+		}
+		if jsonOutput {
+			printJSON(response.Signature)
+			return
+		}
+		fmt.Printf("KMS 密钥: %s\n", response.Signature.KeyID)
+		fmt.Printf("算法: %s\n", response.Signature.SigningAlgorithm)
+		if *signatureOutput == "" {
+			fmt.Printf("签名: %s\n", base64.StdEncoding.EncodeToString(response.Signature.Signature))
+		}
+	}
+}
+
+// 按 KMS 签名算法名称末尾的哈希算法计算消息摘要
+func signingDigest(algorithm string, message []byte) ([]byte, error) {
+	switch {
+	case strings.HasSuffix(algorithm, "_SHA_256"):
+		sum := sha256.Sum256(message)
+		return sum[:], nil
+	case strings.HasSuffix(algorithm, "_SHA_384"):
+		sum := sha512.Sum384(message)
+		return sum[:], nil
+	case strings.HasSuffix(algorithm, "_SHA_512"):
+		sum := sha512.Sum512(message)
+		return sum[:], nil
+	default:
+		return nil, fmt.Errorf("无法由签名算法 %s 确定摘要算法", algorithm)
+	}
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// journald 原生协议套接字
+const journaldSocket = "/run/systemd/journal/socket"
+
+// Enclave 转发的一条日志 - 与 enclave 端匹配，peer 为主机记录的对端地址
+type logRecord struct {
+	Time    time.Time `json:"time"`
+	Source  string    `json:"source"`
+	Message string    `json:"message"`
+	Peer    string    `json:"peer,omitempty"`
+}
+
+// 接收 Enclave 以 --log-forward 转发的日志，写入文件、journald 和/或 CloudWatch Logs
+func logReceiverCommand(fs *flag.FlagSet) func(args []string) {
+	listen := fs.String("listen", "vsock://9000", "监听地址 (vsock://PORT，本地测试可用 tcp://HOST:PORT 或 unix:///PATH)")
+	output := fs.String("output", "-", "以 JSONL 追加写入的文件 (- 为标准输出，为空时不写入)")
+	journald := fs.Bool("journald", false, "同时写入 systemd journal (SYSLOG_IDENTIFIER=aws-enclave-attestation)")
+	logGroup := fs.String("cloudwatch-log-group", "", "同时写入该 CloudWatch Logs 日志组 (需已存在)")
+	logStream := fs.String("cloudwatch-log-stream", "", "CloudWatch Logs 日志流，不存在时创建 (默认为主机名)")
+	flushInterval := fs.Duration("flush-interval", 5*time.Second, "批量写入 CloudWatch Logs 的间隔")
+	return func(args []string) {
+		var sinks []logSink
+		if *output != "" {
+			var w io.Writer = os.Stdout
+			if *output != "-" {
+				file, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+				if err != nil {
+					exitf(exitBadInput, "打开日志文件失败: %v", err)
+				}
+				w = file
+			}
+			sinks = append(sinks, &jsonlSink{w: w})
+		}
+		if *journald {
+			conn, err := net.Dial("unixgram", journaldSocket)
+			if err != nil {
+				exitf(exitFailure, "连接 journald 失败: %v", err)
+			}
+			sinks = append(sinks, &journaldSink{conn: conn})
+		}
+		if *logGroup != "" {
+			stream := *logStream
+			if stream == "" {
+				stream, _ = os.Hostname()
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			client, err := newCloudWatchLogs(ctx, *logGroup, stream)
+			cancel()
+			if err != nil {
+				exitf(exitConnection, "%v", err)
+			}
+			sinks = append(sinks, &cloudWatchLogsSink{client: client})
+		}
+		if len(sinks) == 0 {
+			exitf(exitBadInput, "必须指定 --output、--journald 或 --cloudwatch-log-group 中的至少一个")
+		}
+
+		listener, err := listenRaw(*listen)
+		if err != nil {
+			exitf(exitFailure, "监听 %s 失败: %v", *listen, err)
+		}
+		log.Printf("日志接收端已启动，监听 %s\n", *listen)
+
+		records := make(chan logRecord, 1024)
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					log.Printf("接受连接失败: %v\n", err)
+					continue
+				}
+				go receiveLogs(conn, records)
+			}
+		}()
+
+		// 单个 goroutine 写入各目标，CloudWatch Logs 按间隔批量写入
+		ticker := time.NewTicker(*flushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case record := <-records:
+				for _, sink := range sinks {
+					if err := sink.write(record); err != nil {
+						log.Printf("写入日志失败: %v\n", err)
+					}
+				}
+			case <-ticker.C:
+				for _, sink := range sinks {
+					if err := sink.flush(); err != nil {
+						log.Printf("写入日志失败: %v\n", err)
+					}
+				}
+			}
+		}
+	}
+}
+
+// 逐行读取一个 Enclave 连接转发的日志，无法解析的行按原文记录
+func receiveLogs(conn net.Conn, records chan<- logRecord) {
+	defer conn.Close()
+	peer := conn.RemoteAddr().String()
+	log.Printf("Enclave %s 开始转发日志\n", peer)
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		var record logRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			record = logRecord{Time: time.Now().UTC(), Source: "enclave", Message: scanner.Text()}
+		}
+		record.Peer = peer
+		records <- record
+	}
+	if err := scanner.Err(); err != nil {
+		log.Printf("读取 Enclave %s 的日志失败: %v\n", peer, err)
+	}
+	log.Printf("Enclave %s 的日志连接已断开\n", peer)
+}
+
+// 日志写入目标，flush 定期调用
+type logSink interface {
+	write(record logRecord) error
+	flush() error
+}
+
+// 每条一行 JSON
+type jsonlSink struct {
+	w io.Writer
+}
+
+func (s *jsonlSink) write(record logRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = s.w.Write(append(data, '\n'))
+	return err
+}
+
+func (s *jsonlSink) flush() error { return nil }
+
+// systemd journal 原生协议: 每个字段一行 KEY=VALUE，值含换行时为 KEY\n<64 位小端长度><值>\n
+type journaldSink struct {
+	conn net.Conn
+}
+
+func (s *journaldSink) write(record logRecord) error {
+	var buf bytes.Buffer
+	field := func(key, value string) {
+		if !strings.Contains(value, "\n") {
+			fmt.Fprintf(&buf, "%s=%s\n", key, value)
+			return
+		}
+		buf.WriteString(key + "\n")
+		binary.Write(&buf, binary.LittleEndian, uint64(len(value)))
+		buf.WriteString(value + "\n")
+	}
+	field("MESSAGE", record.Message)
+	field("SYSLOG_IDENTIFIER", "aws-enclave-attestation")
+	field("PRIORITY", "6")
+	field("ENCLAVE_SOURCE", record.Source)
+	field("ENCLAVE_PEER", record.Peer)
+	field("ENCLAVE_TIMESTAMP", record.Time.Format(time.RFC3339Nano))
+	_, err := s.conn.Write(buf.Bytes())
+	return err
+}
+
+func (s *journaldSink) flush() error { return nil }
+
+// 缓存到下一次 flush 时批量写入 CloudWatch Logs
+type cloudWatchLogsSink struct {
+	client  *cloudWatchLogs
+	pending []cloudWatchLogEvent
+}
+
+func (s *cloudWatchLogsSink) write(record logRecord) error {
+	message := record.Message
+	if record.Peer != "" {
+		message = "[" + record.Peer + "] " + message
+	}
+	s.pending = append(s.pending, cloudWatchLogEvent{Timestamp: record.Time.UnixMilli(), Message: message})
+	return nil
+}
+
+func (s *cloudWatchLogsSink) flush() error {
+	if len(s.pending) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := s.client.put(ctx, s.pending); err != nil {
+		// 保留未写入的事件到下次重试，积压过多时丢弃最早的
+		if len(s.pending) > 10*cloudWatchLogsBatch {
+			s.pending = s.pending[len(s.pending)-10*cloudWatchLogsBatch:]
+		}
+		return err
+	}
+	s.pending = nil
+	return nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// 通过 vsock 查询 Enclave 中 NSM 的描述，以 JSON 输出
+func describeNSMCommand(fs *flag.FlagSet) func(args []string) {
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.DescribeNSM(context.Background())
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+
+		output, err := json.MarshalIndent(response.NSM, "", "  ")
+		if err != nil {
+			exitWithError(err)
+		}
+		fmt.Println(string(output))
+	}
+}
+
+// 通过 vsock 从 Enclave 的 NSM 获取随机数，用于在主机上收集 Enclave 熵
+func getRandomCommand(fs *flag.FlagSet) func(args []string) {
+	length := fs.Int("length", 256, "随机字节数")
+	format := fs.String("format", "hex", "输出格式 (hex、base64 或 raw)")
+	output := fs.String("output", "", "写入该文件，为空时输出到标准输出")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *length <= 0 {
+			exitf(exitBadInput, "--length 必须大于 0")
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.GetRandom(context.Background(), *length)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		if len(response.Random) != *length {
+			exitf(exitFailure, "Enclave 返回 %d 字节随机数，请求 %d 字节", len(response.Random), *length)
+		}
+
+		var data []byte
+		switch *format {
+		case "hex":
+			data = []byte(hex.EncodeToString(response.Random) + "\n")
+		case "base64":
+			data = []byte(base64.StdEncoding.EncodeToString(response.Random) + "\n")
+		case "raw":
+			data = response.Random
+		default:
+			exitf(exitBadInput, "不支持的输出格式: %s (可选 hex、base64、raw)", *format)
+		}
+
+		if *output != "" {
+			if err := os.WriteFile(*output, data, 0600); err != nil {
+				exitf(exitFailure, "写入随机数失败: %v", err)
+			}
+			if jsonOutput {
+				printJSON(randomResult{Length: len(response.Random), Output: *output})
+			}
+			return
+		}
+		if jsonOutput {
+			printJSON(randomResult{Length: len(response.Random), Hex: hex.EncodeToString(response.Random)})
+			return
+		}
+		os.Stdout.Write(data)
+	}
+}
+
+// --json 时 get-random 的输出，指定 --output 时不含随机数
+type randomResult struct {
+	Length int    `json:"length"`
+	Hex    string `json:"hex,omitempty"`
+	Output string `json:"output,omitempty"`
+}
+
+// 解析 PCR 索引列表，如 0、0,1,2,8 或 16-19
+func parsePCRIndices(text string) ([]uint16, error) {
+	var indices []uint16
+	for _, part := range strings.Split(text, ",") {
+		part = strings.TrimSpace(part)
+		first, last, isRange := strings.Cut(part, "-")
+		start, err := strconv.ParseUint(first, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 PCR 索引: %s", part)
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.ParseUint(last, 10, 16); err != nil || end < start {
+				return nil, fmt.Errorf("无效的 PCR 索引范围: %s", part)
+			}
+		}
+		for i := start; i <= end; i++ {
+			indices = append(indices, uint16(i))
+		}
+	}
+	return indices, nil
+}
+
+// 按索引顺序输出 {"索引": {"locked": ..., "value": ...}} 形式的 JSON
+func printPCRStates(pcrs map[uint16]client.PCRState) {
+	indices := make([]int, 0, len(pcrs))
+	for index := range pcrs {
+		indices = append(indices, int(index))
+	}
+	sort.Ints(indices)
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, index := range indices {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		state, _ := json.Marshal(pcrs[uint16(index)])
+		fmt.Fprintf(&buf, "%q:%s", strconv.Itoa(index), state)
+	}
+	buf.WriteByte('}')
+
+	var indented bytes.Buffer
+	json.Indent(&indented, buf.Bytes(), "", "  ")
+	fmt.Println(indented.String())
+}
+
+// 通过 vsock 读取 Enclave 的 PCR 锁定状态和值
+func describePCRCommand(fs *flag.FlagSet) func(args []string) {
+	indexList := fs.String("index", "", "PCR 索引，如 0、0,1,2,8 或 16-19，为空时读取全部 PCR")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		var indices []uint16
+		if *indexList != "" {
+			var err error
+			if indices, err = parsePCRIndices(*indexList); err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.DescribePCR(context.Background(), indices)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		printPCRStates(response.PCRs)
+	}
+}
+
+// 通过 vsock 扩展 Enclave 的用户 PCR，输出扩展后的值
+func extendPCRCommand(fs *flag.FlagSet) func(args []string) {
+	index := fs.Uint("index", 0, "PCR 索引 (16 及以上)")
+	data := fs.String("data", "", "扩展数据")
+	file := fs.String("file", "", "以该文件的 SHA-384 摘要扩展 PCR")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *index < 16 || *index > 0xffff {
+			exitf(exitBadInput, "只能扩展 PCR16 及以上的用户 PCR")
+		}
+		measurement := []byte(*data)
+		if *file != "" {
+			content, err := os.ReadFile(*file)
+			if err != nil {
+				exitf(exitBadInput, "读取文件失败: %v", err)
+			}
+			sum := sha512.Sum384(content)
+			measurement = sum[:]
+		}
+		if len(measurement) == 0 {
+			exitf(exitBadInput, "必须指定 --data 或 --file")
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.ExtendPCR(context.Background(), uint16(*index), measurement)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		printPCRStates(response.PCRs)
+	}
+}
+
+// 通过 vsock 锁定 Enclave 的一个 PCR，例如在写入应用测量值后冻结用户 PCR
+func lockPCRCommand(fs *flag.FlagSet) func(args []string) {
+	index := fs.Uint("index", 0, "PCR 索引")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *index > 0xffff {
+			exitf(exitBadInput, "无效的 PCR 索引: %d", *index)
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.LockPCR(context.Background(), uint16(*index))
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		printPCRStates(response.PCRs)
+	}
+}
+
+// 通过 vsock 锁定 Enclave 的 PCR0 到 PCR(range-1)
+func lockPCRsCommand(fs *flag.FlagSet) func(args []string) {
+	pcrRange := fs.Uint("range", 0, "锁定 PCR0 到 PCR(range-1)")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *pcrRange == 0 || *pcrRange > 0xffff {
+			exitf(exitBadInput, "--range 必须在 1 到 65535 之间")
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.LockPCRs(context.Background(), uint16(*pcrRange))
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		printPCRStates(response.PCRs)
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// 让 Enclave 解密处理 S3 加密客户端 v2 加密的对象，只输出由明文导出的结果
+func decryptObjectCommand(fs *flag.FlagSet) func(args []string) {
+	viaHost := fs.Bool("via-host", false, "由主机以默认凭证链读取对象并分块上传密文 (Enclave 没有 S3 的 --egress 规则时使用)，否则由 Enclave 直接读取")
+	region := fs.String("region", "", "KMS (及 Enclave 读取时 S3) 的区域，为空时使用 Enclave 的默认区域")
+	chunkSize := fs.Int("chunk-size", client.DefaultPushChunkSize, "--via-host 时每块上传的字节数")
+	output := fs.String("output", "", "保存 Enclave --object-processor 输出的文件路径，为空时输出到标准输出")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		u, err := url.Parse(args[0])
+		if err != nil || u.Scheme != "s3" || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+			exitf(exitBadInput, "无效的 S3 地址: %s (格式为 s3://BUCKET/KEY)", args[0])
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		ctx := context.Background()
+		request := client.CommandArgs{Region: *region}
+		var response *client.Response
+		if *viaHost {
+			cfg, err := awsconfig.LoadDefaultConfig(ctx)
+			if err != nil {
+				exitf(exitFailure, "加载 AWS 配置失败: %v", err)
+			}
+			object, err := s3.NewFromConfig(cfg).GetObject(ctx, &s3.GetObjectInput{
+				Bucket: aws.String(u.Host),
+				Key:    aws.String(strings.TrimPrefix(u.Path, "/")),
+			})
+			if err != nil {
+				exitf(exitFailure, "读取 %s 失败: %v", args[0], err)
+			}
+			defer object.Body.Close()
+			// SDK 返回的元数据键不含 x-amz-meta- 前缀
+			response, err = conn.DecryptObjectStream(ctx, request, object.Metadata, object.Body, *chunkSize)
+			if err != nil {
+				exitWithError(err)
+			}
+		} else {
+			request.S3URI = args[0]
+			response, err = conn.DecryptObject(ctx, request)
+			if err != nil {
+				exitWithError(err)
+			}
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		if response.Object == nil {
+			exitf(exitFailure, "Enclave 响应中没有 object")
+		}
+
+		if *output != "" {
+			if err := os.WriteFile(*output, response.Object.Output, 0644); err != nil {
+				exitf(exitFailure, "保存处理结果失败: %v", err)
+			}
+			log.Printf("处理结果已保存到 %s\n", *output)
+		}
+		if jsonOutput {
+			printJSON(response.Object)
+			return
+		}
+		fmt.Printf("KMS 密钥: %s\n", response.Object.KeyID)
+		fmt.Printf("明文大小: %d 字节, %d 行\n", response.Object.Size, response.Object.Lines)
+		fmt.Printf("明文 SHA-256: %s\n", response.Object.SHA256)
+		if *output == "" && len(response.Object.Output) > 0 {
+			os.Stdout.Write(response.Object.Output)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// 离线构建中可用的子命令: 只处理本地文件，不连接网络或 Enclave
+var offlineCommands = map[string]bool{
+	"verify":        true,
+	"inspect":       true,
+	"eat":           true,
+	"intoto":        true,
+	"jwt":           true,
+	"pcrs":          true,
+	"diff":          true,
+	"instance-pcrs": true,
+	"measure-eif":   true,
+	"audit-verify":  true,
+}
+
+// 离线模式下拒绝任何出站连接的拨号函数
+func refuseDial(ctx context.Context, network, address string) (net.Conn, error) {
+	return nil, fmt.Errorf("离线模式禁止网络连接: %s %s", network, address)
+}
+
+// 离线模式: 替换默认的 HTTP 传输层，使任何经 net/http 的出站连接都失败，
+// 而不是在隔离网络中静默超时；返回供吊销检查等使用的 HTTP 客户端
+func enforceOffline() *http.Client {
+	transport := &http.Transport{DialContext: refuseDial, Proxy: nil}
+	http.DefaultTransport = transport
+	http.DefaultClient = &http.Client{Transport: transport}
+	return http.DefaultClient
+}
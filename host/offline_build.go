@@ -0,0 +1,6 @@
+//go:build offline
+
+package main
+
+// 以 -tags offline 构建的版本始终处于离线模式，--offline 无法关闭，用于隔离网络中的审计环境
+const offlineBuild = true
@@ -0,0 +1,6 @@
+//go:build !offline
+
+package main
+
+// 默认构建允许联网，由 --offline 开启离线模式
+const offlineBuild = false
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestEnforceOffline(t *testing.T) {
+	transport, client := http.DefaultTransport, http.DefaultClient
+	defer func() { http.DefaultTransport, http.DefaultClient = transport, client }()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	offline := enforceOffline()
+	for name, c := range map[string]*http.Client{"返回的客户端": offline, "http.DefaultClient": http.DefaultClient, "新建的客户端": {}} {
+		if _, err := c.Get(server.URL); err == nil || !strings.Contains(err.Error(), "离线模式禁止网络连接") {
+			t.Fatalf("离线模式下%s的连接应失败: %v", name, err)
+		}
+	}
+}
+
+// 以 -tags offline 构建时只注册处理本地文件的子命令
+func TestOfflineBuildCommands(t *testing.T) {
+	root := setupCLI()
+	for _, cmd := range root.Commands() {
+		if offlineBuild && !offlineCommands[cmd.Name()] {
+			t.Errorf("离线构建不应包含子命令 %s", cmd.Name())
+		}
+	}
+	if offlineBuild && root.Run != nil {
+		t.Errorf("离线构建未指定子命令时不应按 attest 处理")
+	}
+	if !offlineBuild && len(root.Commands()) != len(subcommands) {
+		t.Errorf("默认构建应包含全部 %d 个子命令，实际 %d 个", len(subcommands), len(root.Commands()))
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/client"
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+	"github.com/yourusername/aws-enclave-attestation/oidc"
+)
+
+// 启动以证明文档换取 OIDC ID Token 的 Broker
+func oidcBrokerCommand(fs *flag.FlagSet) func(args []string) {
+	listen := fs.String("listen", ":8443", "HTTP 监听地址")
+	issuer := fs.String("issuer", "", "issuer URL，需与依赖方可访问的 Broker 地址一致")
+	subject := fs.String("subject", oidc.SubjectPCR0, "sub 声明取自的证明文档字段 (module_id 或 pcrN)")
+	signingKey := fs.String("signing-key", "", "ID Token 签名私钥 (PEM 格式 ECDSA P-256)")
+	tokenTTL := fs.Duration("token-ttl", 15*time.Minute, "ID Token 有效期")
+	tlsCert := fs.String("tls-cert", "", "HTTPS 证书文件，为空时使用 HTTP")
+	tlsKey := fs.String("tls-key", "", "HTTPS 私钥文件")
+	allowDebug := fs.Bool("allow-debug", false, "接受调试模式 Enclave (PCR0/1/2 全为零) 的文档，仅用于测试")
+	var rootCerts stringList
+	fs.Var(&rootCerts, "root-cert", "信任的根证书 PEM 文件，替代内置的 AWS 根证书，可重复指定")
+	var rootPins stringList
+	fs.Var(&rootPins, "root-fingerprint", "固定根证书的 SHA-256 指纹 (sha256:HEX)，可重复指定")
+	var audiences, expectPCRs stringList
+	fs.Var(&audiences, "audience", "允许的 audience，可重复指定")
+	fs.Var(&expectPCRs, "expect-pcr", "签发前要求匹配的 PCR，格式为 INDEX=HEX，可重复指定")
+	var profiling pprofFlags
+	profiling.register(fs)
+	return func(args []string) {
+		profiling.start()
+
+		if *issuer == "" || *signingKey == "" {
+			exitf(exitBadInput, "必须指定 --issuer 和 --signing-key")
+		}
+
+		key, err := jwks.LoadPrivateKey(*signingKey)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		expected := make(map[int]string)
+		for _, item := range expectPCRs {
+			indexStr, value, ok := strings.Cut(item, "=")
+			index, err := strconv.Atoi(indexStr)
+			if !ok || err != nil {
+				exitf(exitBadInput, "无效的 --expect-pcr: %q", item)
+			}
+			expected[index] = value
+		}
+
+		var verify attestation.VerifyOptions
+		if len(rootCerts) > 0 {
+			if verify.Roots, err = attestation.LoadRoots(rootCerts...); err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+		}
+		if verify.RootFingerprints, err = parseFingerprints(rootPins); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		broker, err := oidc.New(oidc.Config{
+			Issuer:       *issuer,
+			Audiences:    audiences,
+			Subject:      *subject,
+			SigningKey:   key,
+			TokenTTL:     *tokenTTL,
+			ExpectedPCRs: expected,
+			Verify:       verify,
+			AllowDebug:   *allowDebug,
+		})
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		server := &http.Server{
+			Addr:              *listen,
+			Handler:           broker,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		log.Printf("OIDC Broker 监听 %s (issuer: %s)\n", *listen, *issuer)
+		if *tlsCert != "" {
+			err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		exitf(exitFailure, "OIDC Broker 退出: %v", err)
+	}
+}
+
+// --json 时 oidc-token 的输出，指定 --output 时不含 ID Token
+type idTokenResult struct {
+	IDToken   string `json:"id_token,omitempty"`
+	Output    string `json:"output,omitempty"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// 使用证明文档经 Broker 换取 ID Token
+func oidcTokenCommand(fs *flag.FlagSet) func(args []string) {
+	brokerURL := fs.String("broker", "", "OIDC Broker 地址")
+	audience := fs.String("audience", "", "请求的 audience，为空时使用 Broker 的默认值")
+	output := fs.String("output", "", "保存 ID Token 的文件路径 (可用作 AWS_WEB_IDENTITY_TOKEN_FILE)，为空时输出到标准输出")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		if *brokerURL == "" {
+			exitf(exitBadInput, "必须指定 --broker")
+		}
+
+		ctx := context.Background()
+		nonce, err := oidc.RequestNonce(ctx, *brokerURL)
+		if err != nil {
+			exitf(exitFailure, "获取随机数失败: %v", err)
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		response, err := conn.Attest(ctx, client.CommandArgs{Nonce: nonce})
+		conn.Close()
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+
+		token, err := oidc.Exchange(ctx, *brokerURL, response.Document, *audience)
+		if err != nil {
+			exitf(exitFailure, "换取 ID Token 失败: %v", err)
+		}
+		log.Printf("已获取 ID Token，有效期 %ds\n", token.ExpiresIn)
+
+		result := idTokenResult{ExpiresIn: token.ExpiresIn}
+		if *output == "" {
+			if jsonOutput {
+				result.IDToken = token.IDToken
+				printJSON(result)
+				return
+			}
+			fmt.Println(token.IDToken)
+			return
+		}
+		if err := os.WriteFile(*output, []byte(token.IDToken), 0600); err != nil {
+			exitf(exitFailure, "写入 ID Token 失败: %v", err)
+		}
+		log.Printf("ID Token 已保存到 %s\n", *output)
+		if jsonOutput {
+			result.Output = *output
+			printJSON(result)
+		}
+	}
+}
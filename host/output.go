@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+)
+
+// --json: 结果、校验结论和错误以单个 JSON 对象输出到标准输出，日志仍输出到标准错误
+var jsonOutput bool
+
+// 将结果以 JSON 对象输出到标准输出
+func printJSON(v interface{}) {
+	writeJSON(os.Stdout, v)
+}
+
+// 将结果以缩进的 JSON 对象写入 w
+func writeJSON(w io.Writer, v interface{}) {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		log.Fatalf("输出 JSON 失败: %v", err)
+	}
+}
+
+// --json 时输出的错误
+type errorResult struct {
+	Error    string `json:"error"`
+	Class    string `json:"class"`
+	ExitCode int    `json:"exit_code"`
+	// Enclave 返回的错误码
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// 退出码对应的失败类别
+func exitClass(code int) string {
+	switch {
+	case code == exitBadInput:
+		return "bad_input"
+	case code == exitConnection:
+		return "connection"
+	case code == exitVerification:
+		return "verification"
+	case code == exitPolicy:
+		return "policy"
+	case code >= exitEnclaveError:
+		return "enclave"
+	default:
+		return "failure"
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 按索引升序排列的 PCR 索引
+func sortedPCRIndexes(pcrs map[int][]byte) []int {
+	indexes := make([]int, 0, len(pcrs))
+	for index := range pcrs {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// 从本地证明文档中导出 PCR，用于 KMS 密钥策略、Terraform 变量或 CI 流水线
+func pcrsCommand(fs *flag.FlagSet) func(args []string) {
+	format := fs.String("format", "table", "输出格式 (json、env 或 table)")
+	indexList := fs.String("index", "", "只导出指定的 PCR，以逗号分隔，例如 0,1,2,8")
+	return func(args []string) {
+		// --json 等同于 --format json
+		if jsonOutput {
+			*format = "json"
+		}
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		doc, err := attestation.Parse(attestation.Decode(data))
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		indexes := sortedPCRIndexes(doc.PCRs)
+		if *indexList != "" {
+			indexes = nil
+			for _, item := range strings.Split(*indexList, ",") {
+				index, err := strconv.Atoi(strings.TrimSpace(item))
+				if err != nil {
+					exitf(exitBadInput, "无效的 PCR 索引: %q", item)
+				}
+				if _, ok := doc.PCRs[index]; !ok {
+					exitf(exitBadInput, "证明文档中没有 PCR%d", index)
+				}
+				indexes = append(indexes, index)
+			}
+		}
+
+		if err := printPCRs(*format, doc.PCRs, indexes); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+	}
+}
+
+// 按 json、env 或 table 格式输出指定的 PCR
+func printPCRs(format string, pcrs map[int][]byte, indexes []int) error {
+	switch format {
+	case "json":
+		values := make(map[string]string, len(indexes))
+		for _, index := range indexes {
+			values[fmt.Sprintf("PCR%d", index)] = hex.EncodeToString(pcrs[index])
+		}
+		out, _ := json.MarshalIndent(values, "", "  ")
+		fmt.Println(string(out))
+	case "env":
+		for _, index := range indexes {
+			fmt.Printf("PCR%d=%s\n", index, hex.EncodeToString(pcrs[index]))
+		}
+	case "table":
+		for _, index := range indexes {
+			fmt.Printf("PCR%-2d  %s\n", index, hex.EncodeToString(pcrs[index]))
+		}
+	default:
+		return fmt.Errorf("不支持的输出格式: %s (可选 json、env、table)", format)
+	}
+	return nil
+}
+
+// 由父实例的 IAM 角色 ARN 和实例 ID 计算期望的 PCR3/PCR4，用于策略或与文档比对
+func instancePCRsCommand(fs *flag.FlagSet) func(args []string) {
+	roleARN := fs.String("role-arn", "", "父实例的 IAM 角色 ARN (PCR3)")
+	instanceID := fs.String("instance-id", "", "父实例 ID (PCR4)")
+	format := fs.String("format", "table", "输出格式 (json、env 或 table)")
+	return func(args []string) {
+		if jsonOutput {
+			*format = "json"
+		}
+		if *roleARN == "" && *instanceID == "" {
+			exitf(exitBadInput, "需要指定 --role-arn 或 --instance-id")
+		}
+		pcrs := make(map[int][]byte)
+		if *roleARN != "" {
+			pcrs[attestation.PCRParentRole] = attestation.ParentRolePCR(*roleARN)
+		}
+		if *instanceID != "" {
+			pcrs[attestation.PCRInstanceID] = attestation.InstanceIDPCR(*instanceID)
+		}
+		if err := printPCRs(*format, pcrs, sortedPCRIndexes(pcrs)); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mdlayher/vsock"
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// pprof 管理接口参数
+type pprofFlags struct {
+	listen string
+}
+
+// 注册 --pprof-listen 参数
+func (f *pprofFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.listen, "pprof-listen", "", "在该地址提供 pprof 调试接口 (如 127.0.0.1:6060)，应只监听回环或管理网络，为空时不启用")
+}
+
+// 在独立的 ServeMux 上启动 pprof，不暴露在业务监听地址上
+func (f *pprofFlags) start() {
+	if f.listen == "" {
+		return
+	}
+	host, _, err := net.SplitHostPort(f.listen)
+	if err != nil {
+		exitf(exitBadInput, "无效的 pprof 监听地址: %s", f.listen)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		log.Printf("警告: pprof 接口监听在非回环地址 %s，请确保只有管理网络可以访问\n", f.listen)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	server := &http.Server{Addr: f.listen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		exitf(exitFailure, "pprof 服务退出: %v", server.ListenAndServe())
+	}()
+	log.Printf("pprof 服务监听 %s/debug/pprof/\n", f.listen)
+}
+
+// 将本地 TCP 端口转发到 Enclave 的 pprof 监听端口 (--pprof-listen vsock://PORT)，
+// 以便在主机上运行 go tool pprof
+func pprofProxyCommand(fs *flag.FlagSet) func(args []string) {
+	cid := fs.Uint("cid", 16, "Enclave 的 CID")
+	port := fs.Uint("port", 6060, "Enclave 的 pprof vsock 端口")
+	connect := fs.String("connect", "", "Enclave pprof 地址 (tcp://HOST:PORT 或 unix:///PATH)，指定时忽略 --cid 和 --port")
+	listen := fs.String("listen", "127.0.0.1:6060", "本地监听地址")
+	return func(args []string) {
+		target := *connect
+		if target == "" {
+			target = fmt.Sprintf("vsock://%d:%d", *cid, *port)
+		}
+		network, addr, err := client.ParseAddress(target)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		listener, err := net.Listen("tcp", *listen)
+		if err != nil {
+			exitf(exitFailure, "监听 %s 失败: %v", *listen, err)
+		}
+		log.Printf("转发 %s 到 Enclave pprof (%s)，例如: go tool pprof http://%s/debug/pprof/heap\n", *listen, target, *listen)
+
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Printf("接受连接失败: %v\n", err)
+				continue
+			}
+			go func() {
+				defer conn.Close()
+				upstream, err := dialRaw(network, addr)
+				if err != nil {
+					log.Printf("连接到 Enclave pprof 失败: %v\n", err)
+					return
+				}
+				defer upstream.Close()
+
+				done := make(chan struct{}, 2)
+				go func() {
+					io.Copy(upstream, conn)
+					done <- struct{}{}
+				}()
+				go func() {
+					io.Copy(conn, upstream)
+					done <- struct{}{}
+				}()
+				<-done
+			}()
+		}
+	}
+}
+
+// 不经过协议握手，直接建立到 Enclave 的连接
+func dialRaw(network string, addr string) (net.Conn, error) {
+	if network != "vsock" {
+		return net.Dial(network, addr)
+	}
+	cidText, portText, ok := strings.Cut(addr, ":")
+	if !ok {
+		return nil, fmt.Errorf("无效的 vsock 地址: %s (格式为 CID:PORT)", addr)
+	}
+	cid, err := strconv.ParseUint(cidText, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 vsock CID: %s", cidText)
+	}
+	port, err := strconv.ParseUint(portText, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 vsock 端口: %s", portText)
+	}
+	return vsock.Dial(uint32(cid), uint32(port), nil)
+}
+
+// 监听 Enclave 主动发起的连接: vsock://PORT 监听主机的 vsock 端口，tcp:// 和 unix:// 用于本地测试
+func listenRaw(address string) (net.Listener, error) {
+	scheme, addr, ok := strings.Cut(address, "://")
+	if !ok || addr == "" {
+		return nil, fmt.Errorf("无效的监听地址: %s (格式为 vsock://PORT、tcp://HOST:PORT 或 unix:///PATH)", address)
+	}
+	switch scheme {
+	case "vsock":
+		port, err := strconv.ParseUint(strings.TrimPrefix(addr, ":"), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 vsock 端口: %s", addr)
+		}
+		return vsock.Listen(uint32(port), nil)
+	case "tcp":
+		return net.Listen("tcp", addr)
+	case "unix":
+		// 清理上次运行遗留的套接字文件
+		if info, err := os.Stat(addr); err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(addr)
+		}
+		return net.Listen("unix", addr)
+	default:
+		return nil, fmt.Errorf("不支持的监听地址类型: %s (可选 vsock、tcp、unix)", scheme)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 配置文件中的一条转发规则: listen 上 Enclave 发起的连接转发到固定的 target (HOST:PORT)，
+// Enclave 只能访问配置中列出的目标，与 vsock-proxy 的允许列表相同:
+//
+//	{"proxies": [{"listen": "vsock://8000", "target": "kms.us-east-1.amazonaws.com:443"}]}
+type proxyRoute struct {
+	Listen string `json:"listen"`
+	Target string `json:"target"`
+}
+
+// 解析 --route LISTEN=HOST:PORT
+func parseProxyRoute(value string) (proxyRoute, error) {
+	listen, target, ok := strings.Cut(value, "=")
+	if !ok || listen == "" || target == "" {
+		return proxyRoute{}, fmt.Errorf("无效的转发规则 %q (格式为 vsock://PORT=HOST:PORT)", value)
+	}
+	return proxyRoute{Listen: listen, Target: target}, nil
+}
+
+// 为 Enclave 转发 TCP 连接，功能与 vsock-proxy 相同，规则来自配置文件的 proxies 和 --route
+func tcpProxyCommand(fs *flag.FlagSet) func(args []string) {
+	var routeFlags stringList
+	fs.Var(&routeFlags, "route", "转发规则 vsock://PORT=HOST:PORT (本地测试可用 tcp:// 或 unix:// 监听)，可重复指定，与配置文件中的 proxies 合并")
+	connectTimeout := fs.Duration("connect-timeout", 10*time.Second, "连接目标的超时时间")
+	return func(args []string) {
+		var routes []proxyRoute
+		if hostConfig, err := loadHostConfig(enclave.configPath); err == nil {
+			routes = append(routes, hostConfig.Proxies...)
+		} else if !os.IsNotExist(err) {
+			exitf(exitBadInput, "%v", err)
+		}
+		for _, value := range routeFlags {
+			route, err := parseProxyRoute(value)
+			if err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+			routes = append(routes, route)
+		}
+		if len(routes) == 0 {
+			exitf(exitBadInput, "必须通过 --route 或配置文件中的 proxies 指定转发规则")
+		}
+
+		listeners := make([]net.Listener, len(routes))
+		for i, route := range routes {
+			if _, _, err := net.SplitHostPort(route.Target); err != nil {
+				exitf(exitBadInput, "无效的转发目标 %q: %v", route.Target, err)
+			}
+			listener, err := listenRaw(route.Listen)
+			if err != nil {
+				exitf(exitFailure, "监听 %s 失败: %v", route.Listen, err)
+			}
+			listeners[i] = listener
+			log.Printf("转发 %s -> %s\n", route.Listen, route.Target)
+		}
+
+		var wg sync.WaitGroup
+		for i, route := range routes {
+			wg.Add(1)
+			go func(listener net.Listener, route proxyRoute) {
+				defer wg.Done()
+				serveProxyRoute(listener, route, *connectTimeout)
+			}(listeners[i], route)
+		}
+		wg.Wait()
+	}
+}
+
+func serveProxyRoute(listener net.Listener, route proxyRoute, connectTimeout time.Duration) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Printf("接受 %s 的连接失败: %v\n", route.Listen, err)
+			continue
+		}
+		go proxyConn(conn, route, connectTimeout)
+	}
+}
+
+// 连接目标并双向复制数据，一个方向结束时半关闭另一方向的写端
+func proxyConn(conn net.Conn, route proxyRoute, connectTimeout time.Duration) {
+	defer conn.Close()
+	peer := conn.RemoteAddr().String()
+	target, err := net.DialTimeout("tcp", route.Target, connectTimeout)
+	if err != nil {
+		log.Printf("%s 连接 %s 失败: %v\n", peer, route.Target, err)
+		return
+	}
+	defer target.Close()
+
+	start := time.Now()
+	var sent, received int64
+	done := make(chan struct{})
+	go func() {
+		received, _ = io.Copy(conn, target)
+		closeWrite(conn)
+		close(done)
+	}()
+	sent, _ = io.Copy(target, conn)
+	closeWrite(target)
+	<-done
+	log.Printf("%s -> %s 已关闭 (发送 %d 字节, 接收 %d 字节, %s)\n", peer, route.Target, sent, received, time.Since(start).Round(time.Millisecond))
+}
+
+// 半关闭连接的写端，不支持时直接关闭
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		cw.CloseWrite()
+		return
+	}
+	conn.Close()
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// Rego 策略评估的超时时间
+const regoTimeout = 30 * time.Second
+
+// Rego 策略的输入 (input)，二进制字段为十六进制
+type regoInput struct {
+	EvidenceType string            `json:"evidence_type"`
+	ModuleID     string            `json:"module_id"`
+	Timestamp    int64             `json:"timestamp"`
+	Time         time.Time         `json:"time"`
+	Debug        bool              `json:"debug"`
+	PCRs         map[string]string `json:"pcrs"`
+	UserData     string            `json:"user_data,omitempty"`
+	// user_data 为 JSON 时解析后的内容，策略可直接检查其中的声明
+	UserDataClaims interface{}       `json:"user_data_claims,omitempty"`
+	Nonce          string            `json:"nonce,omitempty"`
+	PublicKey      string            `json:"public_key,omitempty"`
+	Certificates   []regoCertificate `json:"certificates,omitempty"`
+}
+
+// 签名证书及 CA 链中的证书，签名证书在前
+type regoCertificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// Rego 策略的评估结论
+type regoDecision struct {
+	Query       string          `json:"query"`
+	Allow       bool            `json:"allow"`
+	Deny        []string        `json:"deny,omitempty"`
+	Explanation json.RawMessage `json:"explanation,omitempty"`
+}
+
+// 由声明生成策略输入
+func newRegoInput(claims *attestation.Claims) regoInput {
+	input := regoInput{
+		EvidenceType: string(claims.Type),
+		ModuleID:     claims.ModuleID,
+		Timestamp:    claims.Time.UnixMilli(),
+		Time:         claims.Time.UTC(),
+		Debug:        claims.Debug,
+		PCRs:         make(map[string]string, len(claims.Measurements)),
+		UserData:     hex.EncodeToString(claims.UserData),
+		Nonce:        hex.EncodeToString(claims.Nonce),
+		PublicKey:    hex.EncodeToString(claims.PublicKey),
+	}
+	for index, value := range claims.Measurements {
+		input.PCRs[strconv.Itoa(index)] = hex.EncodeToString(value)
+	}
+	// attest --claim 生成的 CBOR/JSON 声明集，或其他 JSON 用户数据
+	if userDataClaims, err := attestation.DecodeUserDataClaims(claims.UserData); err == nil {
+		input.UserDataClaims = userDataClaims
+	} else {
+		var userDataJSON interface{}
+		if json.Unmarshal(claims.UserData, &userDataJSON) == nil {
+			input.UserDataClaims = userDataJSON
+		}
+	}
+	if doc, ok := claims.Evidence.(*attestation.SignedDocument); ok {
+		for _, der := range append([][]byte{doc.Certificate}, doc.CABundle...) {
+			cert, err := x509.ParseCertificate(der)
+			if err != nil {
+				continue
+			}
+			input.Certificates = append(input.Certificates, regoCertificate{
+				Subject:   cert.Subject.String(),
+				Issuer:    cert.Issuer.String(),
+				Serial:    cert.SerialNumber.Text(16),
+				NotBefore: cert.NotBefore.UTC(),
+				NotAfter:  cert.NotAfter.UTC(),
+			})
+		}
+	}
+	return input
+}
+
+// 按 --rego 或 --opa-url 评估策略，查询结果为 true 或 allow 为 true 的对象时放行，
+// 对象中的 deny (字符串数组) 作为拒绝原因
+func (p *verifyPolicy) evaluateRego(claims *attestation.Claims) (*regoDecision, error) {
+	input := newRegoInput(claims)
+	ctx, cancel := context.WithTimeout(context.Background(), regoTimeout)
+	defer cancel()
+
+	var (
+		result      interface{}
+		defined     bool
+		explanation json.RawMessage
+		err         error
+	)
+	if p.opaURL != "" {
+		result, defined, explanation, err = p.queryOPAServer(ctx, input)
+	} else {
+		result, defined, explanation, err = p.evalRegoFiles(ctx, input)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &regoDecision{Query: p.regoQuery, Explanation: explanation}
+	switch value := result.(type) {
+	case bool:
+		decision.Allow = value
+	case map[string]interface{}:
+		decision.Allow, _ = value["allow"].(bool)
+		if reasons, ok := value["deny"].([]interface{}); ok {
+			for _, reason := range reasons {
+				decision.Deny = append(decision.Deny, fmt.Sprint(reason))
+			}
+		}
+	default:
+		if defined {
+			return nil, fmt.Errorf("Rego 查询 %s 的结果应为布尔值或含 allow 的对象", p.regoQuery)
+		}
+	}
+	if !defined {
+		decision.Deny = append(decision.Deny, "查询结果未定义")
+	}
+	return decision, nil
+}
+
+// opa eval --format json 的输出
+type opaEvalOutput struct {
+	Result []struct {
+		Expressions []struct {
+			Value interface{} `json:"value"`
+		} `json:"expressions"`
+	} `json:"result"`
+	Explanation json.RawMessage `json:"explanation"`
+}
+
+// 以 opa eval 评估本地的 Rego 文件
+func (p *verifyPolicy) evalRegoFiles(ctx context.Context, input regoInput) (interface{}, bool, json.RawMessage, error) {
+	args := []string{"eval", "--format", "json", "--stdin-input"}
+	for _, file := range p.regoFiles {
+		args = append(args, "--data", file)
+	}
+	if p.regoExplain != "" {
+		args = append(args, "--explain", p.regoExplain)
+	}
+	args = append(args, p.regoQuery)
+
+	body, err := json.Marshal(input)
+	if err != nil {
+		return nil, false, nil, err
+	}
+	cmd := exec.CommandContext(ctx, p.opaBinary, args...)
+	cmd.Stdin = bytes.NewReader(body)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, false, nil, fmt.Errorf("执行 %s eval 失败: %v %s", p.opaBinary, err, strings.TrimSpace(stderr.String()))
+	}
+
+	var output opaEvalOutput
+	if err := json.Unmarshal(out, &output); err != nil {
+		return nil, false, nil, fmt.Errorf("解析 opa eval 输出失败: %v", err)
+	}
+	if len(output.Result) == 0 || len(output.Result[0].Expressions) == 0 {
+		return nil, false, output.Explanation, nil
+	}
+	return output.Result[0].Expressions[0].Value, true, output.Explanation, nil
+}
+
+// OPA Data API 的响应
+type opaDataResponse struct {
+	Result      *json.RawMessage `json:"result"`
+	Explanation json.RawMessage  `json:"explanation"`
+}
+
+// 通过 OPA 服务器的 Data API (POST /v1/data/<路径>) 评估策略，查询 data.a.b 对应路径 a/b
+func (p *verifyPolicy) queryOPAServer(ctx context.Context, input regoInput) (interface{}, bool, json.RawMessage, error) {
+	url := strings.TrimSuffix(p.opaURL, "/") + "/v1/data/" + strings.ReplaceAll(strings.TrimPrefix(p.regoQuery, "data."), ".", "/")
+	if p.regoExplain != "" {
+		url += "?explain=" + p.regoExplain
+	}
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return nil, false, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, false, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, false, nil, fmt.Errorf("请求 OPA 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+	if err != nil {
+		return nil, false, nil, fmt.Errorf("读取 OPA 响应失败: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, nil, fmt.Errorf("OPA 返回 %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+
+	var response opaDataResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, false, nil, fmt.Errorf("解析 OPA 响应失败: %v", err)
+	}
+	if response.Result == nil {
+		return nil, false, response.Explanation, nil
+	}
+	var result interface{}
+	if err := json.Unmarshal(*response.Result, &result); err != nil {
+		return nil, false, nil, fmt.Errorf("解析 OPA 结果失败: %v", err)
+	}
+	return result, true, response.Explanation, nil
+}
+
+// OPA 追踪事件中用于输出的字段
+type opaTraceEvent struct {
+	Op       string `json:"op"`
+	Type     string `json:"type"`
+	Message  string `json:"message"`
+	Location *struct {
+		File string `json:"file"`
+		Row  int    `json:"row"`
+	} `json:"location"`
+}
+
+// 逐行输出规则追踪 (--rego-explain)
+func logRegoTrace(explanation json.RawMessage) {
+	var events []opaTraceEvent
+	if err := json.Unmarshal(explanation, &events); err != nil {
+		return
+	}
+	for _, event := range events {
+		location := ""
+		if event.Location != nil {
+			location = fmt.Sprintf("%s:%d", event.Location.File, event.Location.Row)
+		}
+		line := strings.TrimSpace(strings.Join([]string{event.Op, event.Type, location, event.Message}, " "))
+		log.Printf("rego: %s\n", line)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+	"github.com/yourusername/aws-enclave-attestation/rekor"
+)
+
+// Rekor 请求超时时间
+const rekorTimeout = 30 * time.Second
+
+// Rekor 透明日志参数: 校验通过后副署上传，或要求文档已在日志中
+type rekorFlags struct {
+	url       string
+	upload    bool
+	key       string
+	entry     string
+	require   bool
+	publicKey string
+
+	signingKey *ecdsa.PrivateKey
+	logKey     *ecdsa.PublicKey
+}
+
+// 注册 --rekor-* 参数
+func (f *rekorFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.url, "rekor-url", rekor.DefaultURL, "Rekor 透明日志地址")
+	fs.BoolVar(&f.upload, "rekor-upload", false, "校验通过后以 --rekor-key 副署文档摘要并上传到 Rekor")
+	fs.StringVar(&f.key, "rekor-key", "", "副署私钥 (PEM 格式 ECDSA)")
+	fs.StringVar(&f.entry, "rekor-entry", "", "Rekor 条目文件: 上传时保存到该文件 (默认 <文档>.rekor.json)，--require-rekor 时从该文件读取，为空时按文档摘要在线查询")
+	fs.BoolVar(&f.require, "require-rekor", false, "要求文档已记录在 Rekor 中，并校验条目的包含证明")
+	fs.StringVar(&f.publicKey, "rekor-public-key", "", "Rekor 日志公钥 (PEM)，指定时同时校验检查点和 SET 的签名")
+}
+
+// 加载参数引用的密钥
+func (f *rekorFlags) load() error {
+	if f.upload {
+		if f.key == "" {
+			return fmt.Errorf("--rekor-upload 需要指定 --rekor-key")
+		}
+		key, err := jwks.LoadPrivateKey(f.key)
+		if err != nil {
+			return err
+		}
+		f.signingKey = key
+	}
+	if f.publicKey != "" {
+		key, err := rekor.LoadPublicKey(f.publicKey)
+		if err != nil {
+			return err
+		}
+		f.logKey = key
+	}
+	return nil
+}
+
+func (f *rekorFlags) client() *rekor.Client {
+	return &rekor.Client{URL: f.url}
+}
+
+// 确认文档已记录在 Rekor 中: 读取保存的条目或在线查询，再校验摘要、副署签名和包含证明
+func (f *rekorFlags) verify(raw []byte) (*rekor.Entry, error) {
+	var (
+		entry *rekor.Entry
+		err   error
+	)
+	if f.entry != "" {
+		entry, err = rekor.LoadEntry(f.entry)
+		if err != nil {
+			return nil, classify(exitBadInput, err)
+		}
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), rekorTimeout)
+		defer cancel()
+		if entry, err = f.client().Lookup(ctx, raw); err != nil {
+			return nil, classify(exitConnection, err)
+		}
+	}
+	if f.logKey == nil {
+		log.Printf("警告: 未指定 --rekor-public-key，不校验检查点和 SET 的签名\n")
+	}
+	if err := entry.Verify(raw, f.logKey); err != nil {
+		return nil, verificationError(fmt.Errorf("Rekor 条目校验失败: %v", err))
+	}
+	return entry, nil
+}
+
+// 副署并上传文档摘要，保存返回的条目
+func (f *rekorFlags) countersign(raw []byte, documentPath string) (*rekor.Entry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), rekorTimeout)
+	defer cancel()
+	entry, err := f.client().Upload(ctx, raw, f.signingKey)
+	if err != nil {
+		return nil, classify(exitConnection, err)
+	}
+	if err := entry.Verify(raw, f.logKey); err != nil {
+		return nil, verificationError(fmt.Errorf("Rekor 返回的条目校验失败: %v", err))
+	}
+	path := f.entry
+	if path == "" {
+		path = documentPath + ".rekor.json"
+	}
+	if err := entry.Save(path); err != nil {
+		return nil, fmt.Errorf("保存 Rekor 条目失败: %v", err)
+	}
+	log.Printf("已上传到 Rekor: 索引 %d，条目已保存到 %s\n", entry.LogIndex, path)
+	return entry, nil
+}
+
+// --json 时输出的 Rekor 条目摘要
+type rekorResult struct {
+	UUID           string `json:"uuid"`
+	LogIndex       int64  `json:"log_index"`
+	IntegratedTime int64  `json:"integrated_time"`
+}
+
+func newRekorResult(entry *rekor.Entry) *rekorResult {
+	if entry == nil {
+		return nil
+	}
+	return &rekorResult{UUID: entry.UUID, LogIndex: entry.LogIndex, IntegratedTime: entry.IntegratedTime}
+}
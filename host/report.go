@@ -0,0 +1,28 @@
+package main
+
+import (
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 校验报告，随文档归档到 S3 或推送到 Webhook
+type verificationReport struct {
+	ReceivedAt time.Time `json:"received_at"`
+	Verified   bool      `json:"verified"`
+	// 未校验或校验通过时为空
+	Error    string                      `json:"error,omitempty"`
+	Document *attestation.SignedDocument `json:"document,omitempty"`
+}
+
+// 生成校验报告，verified 表示是否执行了校验，无法解析的文档不含摘要
+func newVerificationReport(raw []byte, verifyErr error, verified bool) verificationReport {
+	report := verificationReport{ReceivedAt: time.Now().UTC(), Verified: verified && verifyErr == nil}
+	if verifyErr != nil {
+		report.Error = verifyErr.Error()
+	}
+	if doc, err := attestation.Parse(raw); err == nil {
+		report.Document = doc
+	}
+	return report
+}
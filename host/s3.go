@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// 将证明文档及其校验报告归档到 S3，作为合规证据保存
+type s3Archive struct {
+	client *s3.Client
+	bucket string
+	prefix string
+	// 非空时使用 SSE-KMS 加密，可为密钥 ID、ARN 或别名
+	kmsKeyID string
+}
+
+// 解析 s3://bucket/prefix/ 并使用默认凭证链创建客户端
+func newS3Archive(ctx context.Context, location string, kmsKeyID string) (*s3Archive, error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return nil, fmt.Errorf("无效的 S3 地址: %s (格式为 s3://bucket/prefix/)", location)
+	}
+
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("加载 AWS 配置失败: %v", err)
+	}
+	return &s3Archive{
+		client:   s3.NewFromConfig(cfg),
+		bucket:   u.Host,
+		prefix:   strings.TrimPrefix(u.Path, "/"),
+		kmsKeyID: kmsKeyID,
+	}, nil
+}
+
+func (a *s3Archive) put(ctx context.Context, key string, body []byte, contentType string) error {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(a.bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	}
+	if a.kmsKeyID != "" {
+		input.ServerSideEncryption = types.ServerSideEncryptionAwsKms
+		input.SSEKMSKeyId = aws.String(a.kmsKeyID)
+	}
+	if _, err := a.client.PutObject(ctx, input); err != nil {
+		return fmt.Errorf("上传 s3://%s/%s 失败: %v", a.bucket, key, err)
+	}
+	return nil
+}
+
+// 归档一份文档: <prefix>/<时间>-<module_id>/document.bin 和 report.json，返回归档目录
+func (a *s3Archive) archive(ctx context.Context, raw []byte, verifyErr error, verified bool) (string, error) {
+	report := newVerificationReport(raw, verifyErr, verified)
+	name := report.ReceivedAt.Format("20060102T150405.000000000Z")
+	if report.Document != nil {
+		name += "-" + report.Document.ModuleID
+	}
+	dir := path.Join(a.prefix, name)
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := a.put(ctx, dir+"/document.bin", raw, "application/cbor"); err != nil {
+		return "", err
+	}
+	if err := a.put(ctx, dir+"/report.json", data, "application/json"); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("s3://%s/%s/", a.bucket, dir), nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// 让 Enclave 从 Secrets Manager 读取并以证明文档经 KMS 解密密钥，主机只得到句柄
+func getSecretCommand(fs *flag.FlagSet) func(args []string) {
+	return secretCommand(fs, "密钥 ID", func(conn *client.Client, args client.CommandArgs, name string) (*client.Response, error) {
+		args.SecretID = name
+		return conn.GetSecret(context.Background(), args)
+	})
+}
+
+// 让 Enclave 读取 SSM SecureString 参数并以证明文档经 KMS 解密，主机只得到句柄
+func getParameterCommand(fs *flag.FlagSet) func(args []string) {
+	return secretCommand(fs, "参数名称", func(conn *client.Client, args client.CommandArgs, name string) (*client.Response, error) {
+		args.Parameter = name
+		return conn.GetParameter(context.Background(), args)
+	})
+}
+
+// get-secret、get-parameter 的公共参数和输出，call 以位置参数 name 发送请求
+func secretCommand(fs *flag.FlagSet, nameLabel string, call func(conn *client.Client, args client.CommandArgs, name string) (*client.Response, error)) func(args []string) {
+	keyID := fs.String("key-id", "", "要求密文属于该 KMS 密钥 (ID、ARN 或别名)，为空时由 KMS 按密文确定")
+	region := fs.String("region", "", "AWS 服务的区域，为空时使用 Enclave 的默认区域")
+	handle := fs.String("handle", "", "Enclave 内保存明文的句柄，默认为"+nameLabel)
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := call(conn, client.CommandArgs{KeyID: *keyID, Region: *region, Handle: *handle}, args[0])
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+		if response.Secret == nil {
+			exitf(exitFailure, "Enclave 响应中没有 secret")
+		}
+		if jsonOutput {
+			printJSON(response.Secret)
+			return
+		}
+		fmt.Printf("句柄: %s\n", response.Secret.Handle)
+		fmt.Printf("ARN: %s\n", response.Secret.ARN)
+		fmt.Printf("版本: %s\n", response.Secret.VersionID)
+		fmt.Printf("KMS 密钥: %s\n", response.Secret.KeyID)
+		fmt.Printf("大小: %d 字节\n", response.Secret.Size)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sns"
+	"github.com/aws/aws-sdk-go-v2/service/sns/types"
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// 证明及校验事件类型，作为 SNS 消息属性 event 便于订阅过滤
+const (
+	eventAttestationSucceeded  = "attestation.succeeded"
+	eventAttestationFailed     = "attestation.failed"
+	eventVerificationSucceeded = "verification.succeeded"
+	eventVerificationFailed    = "verification.failed"
+)
+
+// 发布到 SNS 的事件
+type attestationEvent struct {
+	Event    string    `json:"event"`
+	Time     time.Time `json:"time"`
+	Enclave  string    `json:"enclave"`
+	ModuleID string    `json:"module_id,omitempty"`
+	PCR0     string    `json:"pcr0,omitempty"`
+	Error    string    `json:"error,omitempty"`
+}
+
+// 将证明及校验事件发布到 SNS 主题
+type snsNotifier struct {
+	client   *sns.Client
+	topicARN string
+	enclave  string
+}
+
+// 使用默认凭证链创建 SNS 客户端
+func newSNSNotifier(ctx context.Context, topicARN string, enclave string) (*snsNotifier, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("加载 AWS 配置失败: %v", err)
+	}
+	return &snsNotifier{client: sns.NewFromConfig(cfg), topicARN: topicARN, enclave: enclave}, nil
+}
+
+// 发布事件，doc 和 eventErr 可为空；发布失败只记录日志，不影响主流程
+func (n *snsNotifier) publish(ctx context.Context, event string, doc *attestation.SignedDocument, eventErr error) {
+	if n == nil {
+		return
+	}
+
+	e := attestationEvent{Event: event, Time: time.Now().UTC(), Enclave: n.enclave}
+	if doc != nil {
+		e.ModuleID = doc.ModuleID
+		e.PCR0 = hex.EncodeToString(doc.PCRs[0])
+	}
+	if eventErr != nil {
+		e.Error = eventErr.Error()
+	}
+
+	message, err := json.Marshal(e)
+	if err != nil {
+		log.Printf("编码 SNS 事件失败: %v\n", err)
+		return
+	}
+	_, err = n.client.Publish(ctx, &sns.PublishInput{
+		TopicArn: aws.String(n.topicARN),
+		Subject:  aws.String("Enclave " + event),
+		Message:  aws.String(string(message)),
+		MessageAttributes: map[string]types.MessageAttributeValue{
+			"event": {DataType: aws.String("String"), StringValue: aws.String(event)},
+		},
+	})
+	if err != nil {
+		log.Printf("发布 SNS 事件 %s 失败: %v\n", event, err)
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// 私有目录 (0700) 中的临时文件 (0600)，close 时覆盖已写入的内容并删除
+// 目录建在目标所在目录下，保证之后可以原子地重命名到目标路径
+type privateTempFile struct {
+	dir  string
+	file *os.File
+	size int64
+}
+
+// 在 parent 下创建私有目录及其中的临时文件
+func createPrivateTemp(parent string, pattern string) (*privateTempFile, error) {
+	dir, err := os.MkdirTemp(parent, pattern)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(dir, 0700); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	file, err := os.OpenFile(filepath.Join(dir, "data"), os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return &privateTempFile{dir: dir, file: file}, nil
+}
+
+func (t *privateTempFile) Write(p []byte) (int, error) {
+	n, err := t.file.Write(p)
+	t.size += int64(n)
+	return n, err
+}
+
+// 临时文件路径
+func (t *privateTempFile) Name() string {
+	return t.file.Name()
+}
+
+// 将临时文件重命名到 path，成功后 close 不再覆盖其内容
+func (t *privateTempFile) rename(path string) error {
+	if err := t.file.Sync(); err != nil {
+		return err
+	}
+	if err := os.Rename(t.file.Name(), path); err != nil {
+		return err
+	}
+	t.size = 0
+	return nil
+}
+
+// 以零覆盖尚未移走的内容，关闭并删除临时文件及私有目录；可重复调用
+func (t *privateTempFile) close() {
+	if t.size > 0 {
+		t.file.Seek(0, 0)
+		t.file.Write(make([]byte, t.size))
+		t.file.Sync()
+		t.size = 0
+	}
+	t.file.Close()
+	os.RemoveAll(t.dir)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+)
+
+// NTP 时间戳的纪元 (1900-01-01) 与 Unix 纪元之间的秒数
+const ntpEpochOffset = 2208988800
+
+// 一次 SNTP 查询的结果
+type ntpResult struct {
+	// 按 offset 修正后的当前时间
+	Time   time.Time
+	Offset time.Duration
+	RTT    time.Duration
+}
+
+// 以 SNTP (RFC 4330) 查询 server 的时间，server 未指定端口时使用 123
+// 请求的发送时间戳同时作为随机数，响应的 origin 字段必须与之相同
+func queryNTP(server string, timeout time.Duration) (*ntpResult, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "123")
+	}
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	request := make([]byte, 48)
+	request[0] = 0x23 // LI=0, VN=4, Mode=3 (client)
+	t0 := time.Now()
+	binary.BigEndian.PutUint64(request[40:], toNTPTime(t0))
+	if _, err := conn.Write(request); err != nil {
+		return nil, err
+	}
+	response := make([]byte, 48)
+	n, err := conn.Read(response)
+	t3 := time.Now()
+	if err != nil {
+		return nil, err
+	}
+	if n < 48 {
+		return nil, fmt.Errorf("NTP 响应过短: %d 字节", n)
+	}
+	if response[0]&0x07 != 4 {
+		return nil, fmt.Errorf("NTP 响应的模式无效: %d", response[0]&0x07)
+	}
+	if response[1] == 0 {
+		return nil, fmt.Errorf("NTP 服务器拒绝服务 (kiss code %q)", response[12:16])
+	}
+	if !bytes.Equal(response[24:32], request[40:48]) {
+		return nil, errors.New("NTP 响应的 origin 时间戳与请求不符")
+	}
+
+	t1 := fromNTPTime(binary.BigEndian.Uint64(response[32:]))
+	t2 := fromNTPTime(binary.BigEndian.Uint64(response[40:]))
+	offset := (t1.Sub(t0) + t2.Sub(t3)) / 2
+	return &ntpResult{
+		Time:   time.Now().Add(offset),
+		Offset: offset,
+		RTT:    t3.Sub(t0) - t2.Sub(t1),
+	}, nil
+}
+
+func toNTPTime(t time.Time) uint64 {
+	seconds := uint64(t.Unix() + ntpEpochOffset)
+	fraction := uint64(t.Nanosecond()) << 32 / 1e9
+	return seconds<<32 | fraction
+}
+
+func fromNTPTime(v uint64) time.Time {
+	seconds := int64(v>>32) - ntpEpochOffset
+	nanos := int64((v & 0xffffffff) * 1e9 >> 32)
+	return time.Unix(seconds, nanos)
+}
+
+// 时间证明 (JWT) 的声明 - 与 enclave 端匹配
+type timeProofClaims struct {
+	TimeUnixNano int64  `json:"time_unix_nano"`
+	NTPServer    string `json:"ntp_server,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// 以时间机构私钥签名时间证明
+func signTimeProof(key *ecdsa.PrivateKey, t time.Time, ntpServer string) (string, error) {
+	var method jwt.SigningMethod
+	switch key.Curve.Params().BitSize {
+	case 256:
+		method = jwt.SigningMethodES256
+	case 384:
+		method = jwt.SigningMethodES384
+	case 521:
+		method = jwt.SigningMethodES512
+	default:
+		return "", fmt.Errorf("不支持的曲线: %s", key.Curve.Params().Name)
+	}
+	claims := timeProofClaims{
+		TimeUnixNano:     t.UnixNano(),
+		NTPServer:        ntpServer,
+		RegisteredClaims: jwt.RegisteredClaims{IssuedAt: jwt.NewNumericDate(t)},
+	}
+	return jwt.NewWithClaims(method, claims).SignedString(key)
+}
+
+// --json 时 time-sync 的输出
+type timeSyncResult struct {
+	Time          time.Time `json:"time"`
+	OffsetMS      float64   `json:"offset_ms"`
+	Authenticated bool      `json:"authenticated"`
+	NTPServer     string    `json:"ntp_server,omitempty"`
+	NTPOffsetMS   float64   `json:"ntp_offset_ms,omitempty"`
+}
+
+// 以主机时间 (或 NTP 服务器的时间) 同步 Enclave 的时钟，--key 时附带时间机构签名的证明
+func timeSyncCommand(fs *flag.FlagSet) func(args []string) {
+	ntpServer := fs.String("ntp-server", "", "从该 NTP 服务器获取时间 (如 Amazon Time Sync Service 169.254.169.123)，为空时使用主机时钟")
+	ntpTimeout := fs.Duration("ntp-timeout", 5*time.Second, "NTP 查询超时时间")
+	keyPath := fs.String("key", "", "时间机构的 ECDSA 私钥 (PEM)，指定时以其签名时间证明，Enclave 以 --time-authority-key 校验")
+	interval := fs.Duration("interval", 0, "按该间隔持续同步，0 表示只同步一次")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		var key *ecdsa.PrivateKey
+		if *keyPath != "" {
+			var err error
+			if key, err = jwks.LoadPrivateKey(*keyPath); err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+		}
+
+		syncOnce := func() (*timeSyncResult, error) {
+			// 先建立连接，使发送的时间尽量接近 Enclave 收到的时间
+			conn, err := enclave.dial(nil)
+			if err != nil {
+				return nil, err
+			}
+			defer conn.Close()
+
+			result := &timeSyncResult{NTPServer: *ntpServer}
+			now := time.Now()
+			if *ntpServer != "" {
+				ntp, err := queryNTP(*ntpServer, *ntpTimeout)
+				if err != nil {
+					return nil, fmt.Errorf("查询 NTP 服务器 %s 失败: %v", *ntpServer, err)
+				}
+				now = ntp.Time
+				result.NTPOffsetMS = float64(ntp.Offset) / float64(time.Millisecond)
+			}
+			var proof string
+			if key != nil {
+				var err error
+				if proof, err = signTimeProof(key, now, *ntpServer); err != nil {
+					return nil, fmt.Errorf("签名时间证明失败: %v", err)
+				}
+			}
+			response, err := conn.SetTime(context.Background(), now, proof)
+			if err != nil {
+				return nil, err
+			}
+			if err := response.Err(); err != nil {
+				return nil, err
+			}
+			if response.Time == nil {
+				return nil, errors.New("set-time 响应中没有 time")
+			}
+			result.Time = time.Unix(0, response.Time.TimeUnixNano).UTC()
+			result.OffsetMS = float64(response.Time.OffsetNS) / float64(time.Millisecond)
+			result.Authenticated = response.Time.Authenticated
+			return result, nil
+		}
+
+		for {
+			result, err := syncOnce()
+			if err != nil && *interval == 0 {
+				exitWithError(err)
+			}
+			switch {
+			case err != nil:
+				log.Printf("同步 Enclave 时钟失败: %v\n", err)
+			case jsonOutput:
+				printJSON(result)
+			default:
+				fmt.Printf("已同步 Enclave 时钟: %s (修正 %.3f ms, 已认证 %t)\n", result.Time.Format(time.RFC3339Nano), result.OffsetMS, result.Authenticated)
+			}
+			if *interval == 0 {
+				return
+			}
+			time.Sleep(*interval)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+)
+
+// 请求 Enclave 签发以证明过的密钥签名的 JWT
+func tokenCommand(fs *flag.FlagSet) func(args []string) {
+	audience := fs.String("audience", "", "JWT 的 audience")
+	ttl := fs.Duration("ttl", 0, "JWT 有效期，0 表示使用 Enclave 默认值")
+	documentOutput := fs.String("document-output", "", "保存签名公钥证明文档的文件路径")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		if *audience == "" {
+			exitf(exitBadInput, "必须指定 --audience")
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		defer conn.Close()
+
+		response, err := conn.Token(context.Background(), *audience, *ttl)
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+
+		if *documentOutput != "" {
+			if err := saveAttestationDoc(response.Document, *documentOutput, formatRaw); err != nil {
+				exitf(exitFailure, "保存证明文档失败: %v", err)
+			}
+			log.Printf("签名公钥的证明文档已保存到 %s\n", *documentOutput)
+		}
+		if jsonOutput {
+			printJSON(struct {
+				Token string `json:"token"`
+			}{response.Token})
+			return
+		}
+		fmt.Println(response.Token)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/client"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.24.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 主机 span 的 instrumentation 名称
+const hostTracerName = "github.com/yourusername/aws-enclave-attestation/host"
+
+// OTLP 追踪参数
+type tracingFlags struct {
+	endpoint string
+}
+
+// 注册 --otlp-endpoint 参数
+func (f *tracingFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.endpoint, "otlp-endpoint", "", "以 OTLP/HTTP 导出追踪 (连接、请求、NSM 调用及校验) 的地址，如 http://localhost:4318，也可通过 OTEL_EXPORTER_OTLP_ENDPOINT 设置")
+}
+
+// 按参数或 OTEL_EXPORTER_OTLP_* 环境变量创建追踪导出，未启用时返回 nil
+func (f *tracingFlags) open(ctx context.Context) (*tracing, error) {
+	var opts []otlptracehttp.Option
+	switch {
+	case f.endpoint != "":
+		opts = append(opts, otlptracehttp.WithEndpointURL(f.endpoint))
+	case os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") == "" && os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") == "":
+		return nil, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("创建 OTLP 导出器失败: %v", err)
+	}
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName("aws-enclave-attestation")))
+	if err != nil {
+		return nil, err
+	}
+
+	// 命令行进程可能随时退出，span 结束即同步导出
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	return &tracing{provider: provider, exporter: exporter, resource: res}, nil
+}
+
+// 主机追踪: 本地 span 由 TracerProvider 导出，Enclave 返回的 span 通过同一导出器补充
+type tracing struct {
+	provider *sdktrace.TracerProvider
+	exporter sdktrace.SpanExporter
+	resource *resource.Resource
+}
+
+// 开始一个主机 span，未启用追踪时为空操作
+func startHostSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(hostTracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// 结束主机 span，err 非空时记录为错误
+func endHostSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// 导出 Enclave 随响应返回的 span，与 ctx 中的 span 同属一个 trace
+func (t *tracing) exportEnclaveSpans(ctx context.Context, spans []client.TraceSpan) {
+	if t == nil || len(spans) == 0 {
+		return
+	}
+	traceID := trace.SpanContextFromContext(ctx).TraceID()
+	if !traceID.IsValid() {
+		return
+	}
+
+	// 父 span 不在 Enclave 内的为 Enclave 处理请求的服务端 span
+	local := make(map[string]bool, len(spans))
+	for _, s := range spans {
+		local[s.SpanID] = true
+	}
+
+	stubs := make(tracetest.SpanStubs, 0, len(spans))
+	for _, s := range spans {
+		spanID, err1 := parseSpanID(s.SpanID)
+		parentID, err2 := parseSpanID(s.ParentSpanID)
+		if err1 != nil || err2 != nil {
+			log.Printf("忽略无效的 Enclave span %s\n", s.Name)
+			continue
+		}
+		stub := tracetest.SpanStub{
+			Name:        s.Name,
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: spanID, TraceFlags: trace.FlagsSampled}),
+			Parent:      trace.NewSpanContext(trace.SpanContextConfig{TraceID: traceID, SpanID: parentID, TraceFlags: trace.FlagsSampled, Remote: true}),
+			SpanKind:    trace.SpanKindInternal,
+			StartTime:   time.Unix(0, s.Start),
+			EndTime:     time.Unix(0, s.End),
+			Resource:    t.resource,
+		}
+		if !local[s.ParentSpanID] {
+			stub.SpanKind = trace.SpanKindServer
+		}
+		stub.Attributes = append(stub.Attributes, attribute.Bool("enclave", true))
+		for k, v := range s.Attributes {
+			stub.Attributes = append(stub.Attributes, attribute.String(k, v))
+		}
+		if s.Error != "" {
+			stub.Status = sdktrace.Status{Code: codes.Error, Description: s.Error}
+		}
+		stubs = append(stubs, stub)
+	}
+
+	if err := t.exporter.ExportSpans(ctx, stubs.Snapshots()); err != nil {
+		log.Printf("导出 Enclave span 失败: %v\n", err)
+	}
+}
+
+func parseSpanID(text string) (trace.SpanID, error) {
+	var id trace.SpanID
+	data, err := hex.DecodeString(text)
+	if err != nil || len(data) != len(id) {
+		return id, fmt.Errorf("无效的 span ID: %s", text)
+	}
+	copy(id[:], data)
+	return id, nil
+}
+
+// 导出剩余的 span
+func (t *tracing) shutdown(ctx context.Context) {
+	if t == nil {
+		return
+	}
+	if err := t.provider.Shutdown(ctx); err != nil {
+		log.Printf("关闭追踪导出失败: %v\n", err)
+	}
+}
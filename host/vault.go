@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/client"
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+	"github.com/yourusername/aws-enclave-attestation/vault"
+)
+
+// 启动校验证明文档并签发 Vault JWT 的 Bridge 服务
+func vaultBridgeCommand(fs *flag.FlagSet) func(args []string) {
+	listen := fs.String("listen", ":8080", "HTTP 监听地址")
+	issuer := fs.String("issuer", "", "JWT issuer，需与 Vault 的 bound_issuer 一致")
+	audience := fs.String("audience", "vault", "JWT audience，需与 Vault 角色的 bound_audiences 一致")
+	signingKey := fs.String("signing-key", "", "JWT 签名私钥 (PEM 格式 ECDSA P-256)")
+	rolesFile := fs.String("roles", "", "PCR 到 Vault 角色的映射文件 (JSON)")
+	tokenTTL := fs.Duration("token-ttl", 5*time.Minute, "JWT 有效期")
+	tlsCert := fs.String("tls-cert", "", "HTTPS 证书文件，为空时使用 HTTP")
+	tlsKey := fs.String("tls-key", "", "HTTPS 私钥文件")
+	allowDebug := fs.Bool("allow-debug", false, "接受调试模式 Enclave (PCR0/1/2 全为零) 的文档，仅用于测试")
+	var rootCerts stringList
+	fs.Var(&rootCerts, "root-cert", "信任的根证书 PEM 文件，替代内置的 AWS 根证书，可重复指定")
+	var rootPins stringList
+	fs.Var(&rootPins, "root-fingerprint", "固定根证书的 SHA-256 指纹 (sha256:HEX)，可重复指定")
+	var profiling pprofFlags
+	profiling.register(fs)
+	return func(args []string) {
+		profiling.start()
+
+		if *signingKey == "" || *rolesFile == "" || *issuer == "" {
+			exitf(exitBadInput, "必须指定 --signing-key、--roles 和 --issuer")
+		}
+
+		key, err := jwks.LoadPrivateKey(*signingKey)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		roles, err := vault.LoadRoles(*rolesFile)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		var verify attestation.VerifyOptions
+		if len(rootCerts) > 0 {
+			if verify.Roots, err = attestation.LoadRoots(rootCerts...); err != nil {
+				exitf(exitBadInput, "%v", err)
+			}
+		}
+		if verify.RootFingerprints, err = parseFingerprints(rootPins); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		bridge, err := vault.NewBridge(vault.BridgeConfig{
+			Issuer:     *issuer,
+			Audience:   *audience,
+			SigningKey: key,
+			TokenTTL:   *tokenTTL,
+			Roles:      roles,
+			Verify:     verify,
+			AllowDebug: *allowDebug,
+		})
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		server := &http.Server{
+			Addr:              *listen,
+			Handler:           bridge,
+			ReadHeaderTimeout: 10 * time.Second,
+		}
+		log.Printf("Vault Bridge 监听 %s，已加载 %d 个角色\n", *listen, len(roles))
+		if *tlsCert != "" {
+			err = server.ListenAndServeTLS(*tlsCert, *tlsKey)
+		} else {
+			err = server.ListenAndServe()
+		}
+		exitf(exitFailure, "Vault Bridge 退出: %v", err)
+	}
+}
+
+// 配置 Vault 的 JWT 认证方法并写入 PCR 到策略的角色映射
+func vaultSetupCommand(fs *flag.FlagSet) func(args []string) {
+	vaultAddr := fs.String("vault-addr", os.Getenv("VAULT_ADDR"), "Vault 地址")
+	vaultToken := fs.String("vault-token", os.Getenv("VAULT_TOKEN"), "具有管理权限的 Vault 令牌")
+	namespace := fs.String("namespace", os.Getenv("VAULT_NAMESPACE"), "Vault 命名空间")
+	mount := fs.String("mount", "jwt", "JWT 认证方法的挂载路径")
+	rolesFile := fs.String("roles", "", "PCR 到 Vault 角色的映射文件 (JSON)")
+	issuer := fs.String("issuer", "", "Bridge 的 JWT issuer")
+	audience := fs.String("audience", "vault", "Bridge 的 JWT audience")
+	jwksURL := fs.String("jwks-url", "", "Bridge 的 JWKS 地址，例如 https://bridge:8080/.well-known/jwks.json")
+	return func(args []string) {
+		if *vaultAddr == "" || *rolesFile == "" {
+			exitf(exitBadInput, "必须指定 --vault-addr 和 --roles")
+		}
+
+		roles, err := vault.LoadRoles(*rolesFile)
+		if err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		ctx := context.Background()
+		vc := &vault.Client{Address: *vaultAddr, Namespace: *namespace, Token: *vaultToken}
+		if *jwksURL != "" {
+			if err := vc.ConfigureJWTAuth(ctx, *mount, *jwksURL, *issuer); err != nil {
+				exitf(exitFailure, "配置 JWT 认证方法失败: %v", err)
+			}
+			log.Printf("已配置 auth/%s 信任 %s\n", *mount, *jwksURL)
+		}
+		for _, role := range roles {
+			if err := vc.WriteRole(ctx, *mount, role, *audience); err != nil {
+				exitf(exitFailure, "写入角色 %s 失败: %v", role.Name, err)
+			}
+			log.Printf("已写入角色 %s (策略: %v)\n", role.Name, role.Policies)
+		}
+	}
+}
+
+// 使用证明文档经 Bridge 登录 Vault，可选读取一个密钥
+func vaultLoginCommand(fs *flag.FlagSet) func(args []string) {
+	bridgeURL := fs.String("bridge", "", "Vault Bridge 地址，例如 https://bridge:8080")
+	vaultAddr := fs.String("vault-addr", os.Getenv("VAULT_ADDR"), "Vault 地址")
+	namespace := fs.String("namespace", os.Getenv("VAULT_NAMESPACE"), "Vault 命名空间")
+	mount := fs.String("mount", "jwt", "JWT 认证方法的挂载路径")
+	secret := fs.String("secret", "", "登录后读取的密钥 API 路径，例如 secret/data/payments")
+	return func(args []string) {
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		if *bridgeURL == "" || *vaultAddr == "" {
+			exitf(exitBadInput, "必须指定 --bridge 和 --vault-addr")
+		}
+
+		ctx := context.Background()
+		nonce, err := vault.RequestNonce(ctx, *bridgeURL)
+		if err != nil {
+			exitf(exitFailure, "获取随机数失败: %v", err)
+		}
+
+		conn, err := enclave.dial(nil)
+		if err != nil {
+			exitWithError(err)
+		}
+		response, err := conn.Attest(ctx, client.CommandArgs{Nonce: nonce})
+		conn.Close()
+		if err != nil {
+			exitWithError(err)
+		}
+		if !response.Success {
+			exitWithError(response.Err())
+		}
+
+		token, err := vault.ExchangeDocument(ctx, *bridgeURL, response.Document)
+		if err != nil {
+			exitf(exitFailure, "换取 JWT 失败: %v", err)
+		}
+		log.Printf("Bridge 已签发角色 %s 的 JWT\n", token.Role)
+
+		vc := &vault.Client{Address: *vaultAddr, Namespace: *namespace}
+		auth, err := vc.Login(ctx, *mount, token.Role, token.Token)
+		if err != nil {
+			exitf(exitFailure, "登录 Vault 失败: %v", err)
+		}
+		log.Printf("已登录 Vault (策略: %v, 有效期: %ds)\n", auth.Policies, auth.LeaseDuration)
+
+		if *secret == "" {
+			if jsonOutput {
+				printJSON(struct {
+					ClientToken string `json:"client_token"`
+				}{auth.ClientToken})
+				return
+			}
+			fmt.Println(auth.ClientToken)
+			return
+		}
+
+		data, err := vc.Read(ctx, *secret)
+		if err != nil {
+			exitf(exitFailure, "读取密钥失败: %v", err)
+		}
+		out, _ := json.MarshalIndent(data, "", "  ")
+		fmt.Println(string(out))
+	}
+}
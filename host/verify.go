@@ -0,0 +1,460 @@
+package main
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/rekor"
+)
+
+// 校验证明文档时的附加策略，由 verify 子命令和 attest --verify 共用
+type verifyPolicy struct {
+	expectPublicKey string
+	rejectDebug     bool
+	maxAge          time.Duration
+	clockSkew       time.Duration
+	atTime          string
+	rootCerts       stringList
+	rootPins        stringList
+	revocation      string
+	offline         bool
+	crlFiles        stringList
+	expectPCRs      stringList
+	referenceFiles  stringList
+	eifPath         string
+	moduleIDs       stringList
+	roleARNs        stringList
+	instanceIDs     stringList
+	expectClaims    stringList
+	requireClaims   bool
+	regoFiles       stringList
+	regoQuery       string
+	regoExplain     string
+	opaURL          string
+	opaBinary       string
+
+	// --expect-pcr 和 --reference-values 合并得到的 PCR 参考值
+	references attestation.ReferenceValues
+
+	// --expect-claim 按键合并的期望值
+	claims map[string][]string
+
+	// 由 --eif 计算出的期望 PCR
+	eifPCRs attestation.ReferenceValues
+
+	// 最近一次 Rego 策略评估的结论
+	decision *regoDecision
+
+	// 自定义根证书，为空时使用内置的 AWS Nitro Enclaves 根证书
+	roots *x509.CertPool
+
+	// 固定的根证书指纹
+	fingerprints [][]byte
+
+	// 吊销检查选项
+	revocationOpts attestation.RevocationOptions
+
+	// 解析后的期望公钥 (DER 格式的 SubjectPublicKeyInfo)
+	publicKey []byte
+}
+
+// 在 FlagSet 上注册策略参数
+func (p *verifyPolicy) register(fs *flag.FlagSet) {
+	fs.BoolVar(&p.rejectDebug, "reject-debug", true, "拒绝调试模式 Enclave (PCR0/1/2 全为零) 生成的文档")
+	fs.Var(&p.rootCerts, "root-cert", "信任的根证书 PEM 文件，替代内置的 AWS 根证书，可重复指定")
+	fs.Var(&p.rootPins, "root-fingerprint", "固定根证书的 SHA-256 指纹 (sha256:HEX)，校验所用的根证书必须与之一致，可重复指定")
+	fs.StringVar(&p.revocation, "revocation", "off", "证书链吊销检查 (off、soft 或 hard)：soft 在无法获取吊销状态时放行，hard 时拒绝")
+	fs.Var(&p.crlFiles, "crl", "本地 CRL 文件 (PEM 或 DER)，优先于在线查询 OCSP 和 CRL 分发点，可重复指定")
+	fs.BoolVar(&p.offline, "offline", offlineBuild, "离线校验: 不在线查询吊销状态、不使用 --opa-url，任何出站连接都会失败 (以 -tags offline 构建时始终开启)")
+	fs.StringVar(&p.atTime, "at-time", "", "按指定时间 (RFC3339) 校验证书有效期和文档新鲜度，为 document 时使用文档自身的时间戳 (不能与 --max-age 同时使用)")
+	fs.DurationVar(&p.maxAge, "max-age", 0, "文档时间戳距今超过该时长时拒绝，0 表示不检查")
+	fs.DurationVar(&p.clockSkew, "clock-skew", time.Minute, "允许文档时间戳晚于当前时间的最大偏差")
+	fs.StringVar(&p.expectPublicKey, "expect-public-key", "", "要求证明文档的 public_key 与该公钥一致 (PEM/DER 格式的公钥、私钥或证书)")
+	fs.Var(&p.expectPCRs, "expect-pcr", "要求 PCR 等于指定值 (格式 INDEX=HEX)，可重复指定，同一 PCR 的多个值匹配任一即可")
+	fs.Var(&p.referenceFiles, "reference-values", "从 CoRIM 文件加载 PCR 参考值 (如镜像构建工具生成的参考值)，可重复指定")
+	fs.StringVar(&p.eifPath, "eif", "", "要求 PCR0/1/2 (及签名镜像的 PCR8) 与该 EIF 计算出的值一致，用于确认运行的是发布的镜像")
+	fs.Var(&p.moduleIDs, "expect-module-id", "要求 module_id 匹配该通配符模式 (如 i-0abc*-enc*)，可重复指定，匹配任一即可")
+	fs.Var(&p.roleARNs, "expect-role-arn", "要求 PCR3 与父实例的 IAM 角色 ARN 对应，可重复指定")
+	fs.Var(&p.instanceIDs, "expect-instance-id", "要求 PCR4 与父实例 ID 对应，可重复指定")
+	fs.Var(&p.expectClaims, "expect-claim", "要求 user_data 声明集 (attest --claim 生成) 中的声明等于指定值 (格式 key=value)，可重复指定，同一键的多个值匹配任一即可")
+	fs.BoolVar(&p.requireClaims, "require-claims", false, "要求 user_data 为声明集且未过期 (指定 --expect-claim 时隐含)")
+	fs.Var(&p.regoFiles, "rego", "以 opa eval 评估的 Rego 策略文件或目录，可重复指定")
+	fs.StringVar(&p.regoQuery, "rego-query", "data.attestation.allow", "Rego 查询，结果为 true 或 allow 为 true 的对象时放行")
+	fs.StringVar(&p.regoExplain, "rego-explain", "", "输出规则追踪 (notes、fails 或 full)")
+	fs.StringVar(&p.opaURL, "opa-url", "", "通过 OPA 服务器的 Data API 评估策略 (如 http://127.0.0.1:8181)，替代 --rego")
+	fs.StringVar(&p.opaBinary, "opa-path", "opa", "评估 --rego 策略所用的 opa 可执行文件")
+}
+
+// 加载策略参数引用的文件
+func (p *verifyPolicy) load() error {
+	if p.atTime != "" && p.atTime != "document" {
+		if _, err := time.Parse(time.RFC3339, p.atTime); err != nil {
+			return fmt.Errorf("无效的 --at-time: %v", err)
+		}
+	}
+	// 以文档自身的时间戳为准时文档年龄恒为 0，--max-age 将形同虚设
+	if p.atTime == "document" && p.maxAge != 0 {
+		return fmt.Errorf("--at-time document 不能与 --max-age 同时使用")
+	}
+	if len(p.rootCerts) > 0 {
+		roots, err := attestation.LoadRoots(p.rootCerts...)
+		if err != nil {
+			return err
+		}
+		p.roots = roots
+	}
+	fingerprints, err := parseFingerprints(p.rootPins)
+	if err != nil {
+		return err
+	}
+	p.fingerprints = fingerprints
+	switch p.revocation {
+	case "off":
+		p.revocationOpts.Mode = attestation.RevocationOff
+	case "soft":
+		p.revocationOpts.Mode = attestation.RevocationSoftFail
+	case "hard":
+		p.revocationOpts.Mode = attestation.RevocationHardFail
+	default:
+		return fmt.Errorf("无效的 --revocation %q (可选 off、soft、hard)", p.revocation)
+	}
+	if offlineBuild && !p.offline {
+		return fmt.Errorf("该版本以 offline 标签构建，不能关闭 --offline")
+	}
+	if p.offline {
+		if p.opaURL != "" {
+			return fmt.Errorf("离线模式不能使用 --opa-url，请改用 --rego")
+		}
+		p.revocationOpts.Offline = true
+		p.revocationOpts.HTTPClient = enforceOffline()
+	}
+	if len(p.crlFiles) > 0 {
+		if p.revocationOpts.Mode == attestation.RevocationOff {
+			return fmt.Errorf("--crl 需要同时指定 --revocation soft 或 hard")
+		}
+		crls, err := attestation.LoadCRLs(p.crlFiles...)
+		if err != nil {
+			return err
+		}
+		p.revocationOpts.CRLs = crls
+	}
+	if p.expectPublicKey != "" {
+		der, err := loadPublicKeyDER(p.expectPublicKey)
+		if err != nil {
+			return err
+		}
+		p.publicKey = der
+	}
+	if p.eifPath != "" {
+		measurements, err := attestation.MeasureEIFFile(p.eifPath)
+		if err != nil {
+			return err
+		}
+		p.eifPCRs = attestation.ReferenceValues{}
+		for index, value := range measurements.PCRs {
+			p.eifPCRs.Add(index, value)
+		}
+	}
+	if _, err := attestation.MatchModuleID("", p.moduleIDs...); err != nil {
+		return err
+	}
+	if len(p.expectPCRs) > 0 || len(p.referenceFiles) > 0 || len(p.roleARNs) > 0 || len(p.instanceIDs) > 0 {
+		p.references = attestation.ReferenceValues{}
+	}
+	for _, roleARN := range p.roleARNs {
+		p.references.Add(attestation.PCRParentRole, attestation.ParentRolePCR(roleARN))
+	}
+	for _, instanceID := range p.instanceIDs {
+		p.references.Add(attestation.PCRInstanceID, attestation.InstanceIDPCR(instanceID))
+	}
+	for _, expect := range p.expectPCRs {
+		index, value, err := parseExpectPCR(expect)
+		if err != nil {
+			return err
+		}
+		p.references.Add(index, value)
+	}
+	for _, path := range p.referenceFiles {
+		refs, err := attestation.LoadCoRIM(path)
+		if err != nil {
+			return err
+		}
+		p.references.Merge(refs)
+	}
+	for _, expect := range p.expectClaims {
+		key, value, ok := strings.Cut(expect, "=")
+		if !ok || key == "" {
+			return fmt.Errorf("无效的 --expect-claim %q: 格式应为 key=value", expect)
+		}
+		if p.claims == nil {
+			p.claims = make(map[string][]string)
+		}
+		p.claims[key] = append(p.claims[key], value)
+	}
+	if len(p.regoFiles) > 0 && p.opaURL != "" {
+		return fmt.Errorf("--rego 和 --opa-url 不能同时指定")
+	}
+	if len(p.regoFiles) > 0 || p.opaURL != "" {
+		if !strings.HasPrefix(p.regoQuery, "data.") {
+			return fmt.Errorf("无效的 --rego-query %q: 应以 data. 开头", p.regoQuery)
+		}
+		switch p.regoExplain {
+		case "", "notes", "fails", "full":
+		default:
+			return fmt.Errorf("无效的 --rego-explain %q (可选 notes、fails、full)", p.regoExplain)
+		}
+	}
+	return nil
+}
+
+// 解析 --root-fingerprint 指定的指纹
+func parseFingerprints(values []string) ([][]byte, error) {
+	var fingerprints [][]byte
+	for _, value := range values {
+		fingerprint, err := attestation.ParseFingerprint(value)
+		if err != nil {
+			return nil, err
+		}
+		fingerprints = append(fingerprints, fingerprint)
+	}
+	return fingerprints, nil
+}
+
+// 解析 INDEX=HEX 形式的 PCR 期望值
+func parseExpectPCR(expect string) (int, []byte, error) {
+	indexText, valueText, ok := strings.Cut(expect, "=")
+	if !ok {
+		return 0, nil, fmt.Errorf("无效的 --expect-pcr %q: 格式应为 INDEX=HEX", expect)
+	}
+	index, err := strconv.Atoi(strings.TrimPrefix(strings.ToUpper(indexText), "PCR"))
+	if err != nil || index < 0 || index >= 32 {
+		return 0, nil, fmt.Errorf("无效的 --expect-pcr %q: PCR 索引应为 0-31", expect)
+	}
+	value, err := hex.DecodeString(valueText)
+	if err != nil || len(value) == 0 {
+		return 0, nil, fmt.Errorf("无效的 --expect-pcr %q: PCR 值应为十六进制", expect)
+	}
+	return index, value, nil
+}
+
+// 校验 Nitro 证明文档的签名和证书链，再按策略检查文档内容
+func (p *verifyPolicy) verify(raw []byte) (*attestation.SignedDocument, error) {
+	claims, err := p.verifyEvidence(attestation.EvidenceNitro, raw)
+	if err != nil {
+		return nil, err
+	}
+	return claims.Evidence.(*attestation.SignedDocument), nil
+}
+
+// 校验 Noise 握手、RA-TLS 证书或加密会话中的证明文档: 其 public_key 为通道或会话公钥，
+// 不按 --expect-public-key 和 user_data 声明检查，其余策略照常适用；须在 load 之后调用
+func (p *verifyPolicy) channelVerifier() func(document []byte) error {
+	channel := *p
+	channel.publicKey = nil
+	channel.requireClaims = false
+	channel.claims = nil
+	return func(document []byte) error {
+		_, err := channel.verify(document)
+		return err
+	}
+}
+
+// 以证据类型对应的校验器校验证据，再按策略检查其中的声明，类型为空时按 Nitro 证明文档处理
+func (p *verifyPolicy) verifyEvidence(evidenceType attestation.EvidenceType, raw []byte) (*attestation.Claims, error) {
+	verifier, err := attestation.LookupVerifier(evidenceType)
+	if err != nil {
+		return nil, classify(exitBadInput, err)
+	}
+
+	now, err := p.verificationTime(verifier, raw)
+	if err != nil {
+		return nil, verificationError(err)
+	}
+
+	claims, err := verifier.Verify(raw, attestation.VerifyOptions{Roots: p.roots, Time: now, RootFingerprints: p.fingerprints, Revocation: p.revocationOpts})
+	if err != nil {
+		return nil, verificationError(err)
+	}
+
+	if err := claims.CheckAge(now, p.maxAge, p.clockSkew); err != nil {
+		return nil, classify(exitPolicy, err)
+	}
+	if p.rejectDebug && claims.Debug {
+		return nil, policyErrorf("证明文档来自调试模式的 Enclave (PCR0/1/2 全为零)，可使用 --reject-debug=false 放行")
+	}
+	if p.publicKey != nil && !bytes.Equal(normalizePublicKey(claims.PublicKey), p.publicKey) {
+		return nil, policyErrorf("证明文档中的 public_key 与 %s 不一致", p.expectPublicKey)
+	}
+	if len(p.moduleIDs) > 0 {
+		if matched, _ := attestation.MatchModuleID(claims.ModuleID, p.moduleIDs...); !matched {
+			return nil, policyErrorf("module_id %s 不匹配 %s", claims.ModuleID, strings.Join(p.moduleIDs, "、"))
+		}
+	}
+	if p.requireClaims || p.claims != nil {
+		userDataClaims, err := attestation.DecodeUserDataClaims(claims.UserData)
+		if err != nil {
+			return nil, classify(exitPolicy, err)
+		}
+		if err := userDataClaims.Check(p.claims, now); err != nil {
+			return nil, classify(exitPolicy, err)
+		}
+	}
+	if p.eifPCRs != nil {
+		if err := p.eifPCRs.Check(claims.Measurements); err != nil {
+			return nil, policyErrorf("证明文档与 EIF %s 不符: %v", p.eifPath, err)
+		}
+	}
+	if p.references != nil {
+		if err := p.references.Check(claims.Measurements); err != nil {
+			return nil, classify(exitPolicy, err)
+		}
+	}
+	if len(p.regoFiles) > 0 || p.opaURL != "" {
+		decision, err := p.evaluateRego(claims)
+		if err != nil {
+			return nil, err
+		}
+		p.decision = decision
+		if p.regoExplain != "" {
+			logRegoTrace(decision.Explanation)
+		}
+		if !decision.Allow {
+			if len(decision.Deny) > 0 {
+				return nil, policyErrorf("Rego 策略 %s 拒绝: %s", p.regoQuery, strings.Join(decision.Deny, "; "))
+			}
+			return nil, policyErrorf("Rego 策略 %s 拒绝", p.regoQuery)
+		}
+	}
+	return claims, nil
+}
+
+// 校验所用的时间: 当前时间、--at-time 指定的时间或证据自身的时间戳
+func (p *verifyPolicy) verificationTime(verifier attestation.Verifier, raw []byte) (time.Time, error) {
+	switch p.atTime {
+	case "":
+		return time.Now(), nil
+	case "document":
+		claims, err := verifier.Parse(raw)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return claims.Time, nil
+	default:
+		return time.Parse(time.RFC3339, p.atTime)
+	}
+}
+
+// 从公钥、私钥或证书文件中取出 DER 格式的 SubjectPublicKeyInfo
+func loadPublicKeyDER(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取公钥文件失败: %v", err)
+	}
+
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		der = block.Bytes
+	}
+
+	var public interface{}
+	if key, err := x509.ParsePKIXPublicKey(der); err == nil {
+		public = key
+	} else if cert, err := x509.ParseCertificate(der); err == nil {
+		public = cert.PublicKey
+	} else if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		public = key.(interface{ Public() crypto.PublicKey }).Public()
+	} else if key, err := x509.ParseECPrivateKey(der); err == nil {
+		public = key.Public()
+	} else if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		public = key.Public()
+	} else if key, err := x509.ParsePKCS1PublicKey(der); err == nil {
+		public = key
+	} else {
+		return nil, fmt.Errorf("无法从 %s 解析公钥", path)
+	}
+
+	return x509.MarshalPKIXPublicKey(public)
+}
+
+// 可解析的公钥统一为重新编码的 DER，其他内容原样比较
+func normalizePublicKey(der []byte) []byte {
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return der
+	}
+	normalized, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return der
+	}
+	return normalized
+}
+
+// --json 时 verify 输出的校验结论，校验失败时输出错误对象
+type verifyResult struct {
+	Valid        bool      `json:"valid"`
+	EvidenceType string    `json:"evidence_type"`
+	ModuleID     string    `json:"module_id"`
+	Timestamp    time.Time `json:"timestamp"`
+	Debug        bool      `json:"debug"`
+
+	// 指定 Rego 策略时的评估结论
+	Policy *regoDecision `json:"policy,omitempty"`
+
+	// --require-rekor 或 --rekor-upload 时的透明日志条目
+	Rekor *rekorResult `json:"rekor,omitempty"`
+}
+
+// 离线校验已保存的证明文档
+func verifyCommand(fs *flag.FlagSet) func(args []string) {
+	var policy verifyPolicy
+	policy.register(fs)
+	evidenceType := fs.String("evidence-type", string(attestation.EvidenceNitro), "证据类型，决定使用的校验器")
+	var transparency rekorFlags
+	transparency.register(fs)
+	return func(args []string) {
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if err := transparency.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+
+		data, err := os.ReadFile(args[0])
+		if err != nil {
+			exitf(exitBadInput, "读取证明文档失败: %v", err)
+		}
+		raw := attestation.Decode(data)
+		claims, err := policy.verifyEvidence(attestation.EvidenceType(*evidenceType), raw)
+		if err != nil {
+			exitf(exitCode(err), "校验失败: %v", err)
+		}
+
+		var entry *rekor.Entry
+		if transparency.require {
+			if entry, err = transparency.verify(raw); err != nil {
+				exitf(exitCode(err), "校验失败: %v", err)
+			}
+		}
+		if transparency.upload {
+			if entry, err = transparency.countersign(raw, args[0]); err != nil {
+				exitf(exitCode(err), "上传到 Rekor 失败: %v", err)
+			}
+		}
+
+		if jsonOutput {
+			printJSON(verifyResult{Valid: true, EvidenceType: string(claims.Type), ModuleID: claims.ModuleID, Timestamp: claims.Time, Debug: claims.Debug, Policy: policy.decision, Rekor: newRekorResult(entry)})
+			return
+		}
+		fmt.Printf("校验通过: %s (%s)\n", claims.ModuleID, claims.Time.Format(time.RFC3339))
+	}
+}
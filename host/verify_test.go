@@ -0,0 +1,15 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// 按文档自身时间戳校验时 --max-age 无法生效，应在加载策略时拒绝
+func TestVerifyPolicyRejectsDocumentTimeWithMaxAge(t *testing.T) {
+	p := &verifyPolicy{atTime: "document", maxAge: 5 * time.Minute}
+	if err := p.load(); err == nil || !strings.Contains(err.Error(), "--max-age") {
+		t.Fatalf("--at-time document 与 --max-age 同时使用应报错: %v", err)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/client"
+)
+
+// 原子写入文件: 先写入同目录下私有目录中的临时文件再重命名，读取方不会看到写了一半的内容
+// 任何失败路径都会覆盖并删除临时文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := createPrivateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer tmp.close()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return tmp.rename(path)
+}
+
+// watch 模式的刷新状态，用于导出指标
+type watchState struct {
+	mu          sync.Mutex
+	lastSuccess time.Time
+	documentAt  time.Time
+	refreshes   int
+	failures    int
+}
+
+// 以 Prometheus 文本格式导出文档新鲜度指标
+func (s *watchState) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	age := -1.0
+	if !s.documentAt.IsZero() {
+		age = time.Since(s.documentAt).Seconds()
+	}
+	fmt.Fprintln(w, "# HELP attestation_document_age_seconds 磁盘上证明文档的生成时间距今秒数，尚无文档时为 -1")
+	fmt.Fprintln(w, "# TYPE attestation_document_age_seconds gauge")
+	fmt.Fprintf(w, "attestation_document_age_seconds %g\n", age)
+	fmt.Fprintln(w, "# HELP attestation_last_refresh_timestamp_seconds 最近一次成功刷新的 Unix 时间")
+	fmt.Fprintln(w, "# TYPE attestation_last_refresh_timestamp_seconds gauge")
+	fmt.Fprintf(w, "attestation_last_refresh_timestamp_seconds %d\n", s.lastSuccess.Unix())
+	fmt.Fprintln(w, "# HELP attestation_refreshes_total 成功刷新次数")
+	fmt.Fprintln(w, "# TYPE attestation_refreshes_total counter")
+	fmt.Fprintf(w, "attestation_refreshes_total %d\n", s.refreshes)
+	fmt.Fprintln(w, "# HELP attestation_refresh_failures_total 刷新失败次数")
+	fmt.Fprintln(w, "# TYPE attestation_refresh_failures_total counter")
+	fmt.Fprintf(w, "attestation_refresh_failures_total %d\n", s.failures)
+}
+
+// 请求一份新的证明文档并原子替换输出文件，返回解析后的文档
+func refreshDocument(enclave *endpoint, args client.CommandArgs, nonceSize int, policy *verifyPolicy, verify bool, metrics *cloudWatchMetrics, output string, format string) (*attestation.SignedDocument, error) {
+	if nonceSize > 0 {
+		nonce := make([]byte, nonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("生成随机 nonce 失败: %v", err)
+		}
+		args.NonceB64 = base64.StdEncoding.EncodeToString(nonce)
+	}
+
+	conn, err := enclave.dial(nil)
+	if err != nil {
+		metrics.recordAttestation(context.Background(), nil, 0, err)
+		return nil, err
+	}
+	defer conn.Close()
+
+	start := time.Now()
+	response, err := conn.Attest(context.Background(), args)
+	if err == nil && !response.Success {
+		err = response.Err()
+	}
+	if err != nil {
+		metrics.recordAttestation(context.Background(), nil, time.Since(start), err)
+		return nil, err
+	}
+
+	raw := attestation.Decode([]byte(response.Document))
+	doc, err := attestation.Parse(raw)
+	metrics.recordAttestation(context.Background(), doc, time.Since(start), err)
+	if err != nil {
+		return nil, err
+	}
+	if verify {
+		_, err := policy.verify(raw)
+		metrics.recordVerification(context.Background(), doc, err)
+		if err != nil {
+			return nil, fmt.Errorf("证明文档校验失败: %v", err)
+		}
+	}
+
+	data, err := encodeDocument(raw, format)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFileAtomic(output, data, 0644); err != nil {
+		return nil, fmt.Errorf("写入证明文档失败: %v", err)
+	}
+	return doc, nil
+}
+
+// 定期刷新磁盘上的证明文档，供主机上的其他进程读取
+func watchCommand(fs *flag.FlagSet) func(args []string) {
+	interval := fs.Duration("interval", 5*time.Minute, "刷新间隔")
+	output := fs.String("output", "", "证明文档保存路径，每次刷新时原子替换")
+	format := fs.String("format", formatRaw, "证明文档保存格式 (raw、base64、pem、json 或 jws)")
+	userData := fs.String("userdata", "", "用户数据")
+	var nonceRandom randomNonceFlag
+	fs.Var(&nonceRandom, "nonce-random", "每次刷新在本地生成 N 字节随机数 (默认 32) 作为 nonce")
+	verify := fs.Bool("verify", false, "写入前校验文档的签名、证书链及策略，校验失败时保留旧文档")
+	metricsListen := fs.String("metrics-listen", "", "导出 Prometheus 指标的 HTTP 监听地址 (如 :9102)，为空时不导出")
+	var policy verifyPolicy
+	policy.register(fs)
+	var metricsFlags cloudWatchFlags
+	metricsFlags.register(fs)
+	var profiling pprofFlags
+	profiling.register(fs)
+	return func(args []string) {
+		profiling.start()
+
+		if err := enclave.validate(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if *output == "" {
+			exitf(exitBadInput, "必须指定 --output")
+		}
+		if *interval <= 0 {
+			exitf(exitBadInput, "--interval 必须大于 0")
+		}
+		switch *format {
+		case formatRaw, formatBase64, formatPEM, formatJSON, formatJWS:
+		default:
+			exitf(exitBadInput, "不支持的输出格式: %s (可选 raw、base64、pem、json、jws)", *format)
+		}
+		if err := policy.load(); err != nil {
+			exitf(exitBadInput, "%v", err)
+		}
+		if policy.expectPublicKey != "" {
+			*verify = true
+		}
+		enclave.verify = policy.channelVerifier()
+
+		metrics, err := metricsFlags.open(context.Background(), enclave.label())
+		if err != nil {
+			exitWithError(err)
+		}
+
+		state := &watchState{}
+		if *metricsListen != "" {
+			mux := http.NewServeMux()
+			mux.Handle("/metrics", state)
+			server := &http.Server{Addr: *metricsListen, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+			go func() {
+				exitf(exitFailure, "指标服务退出: %v", server.ListenAndServe())
+			}()
+			log.Printf("指标服务监听 %s/metrics\n", *metricsListen)
+		}
+
+		// 每次刷新都要求新文档，不使用 Enclave 缓存
+		request := client.CommandArgs{UserData: *userData, Fresh: true}
+
+		log.Printf("每 %s 刷新证明文档到 %s (Enclave %s)\n", *interval, *output, &enclave)
+		ticker := time.NewTicker(*interval)
+		defer ticker.Stop()
+		for {
+			doc, err := refreshDocument(&enclave, request, int(nonceRandom), &policy, *verify, metrics, *output, *format)
+
+			state.mu.Lock()
+			if err != nil {
+				state.failures++
+				log.Printf("刷新证明文档失败 (保留旧文档): %v\n", err)
+			} else {
+				state.refreshes++
+				state.lastSuccess = time.Now()
+				state.documentAt = doc.Time()
+				log.Printf("证明文档已刷新: %s (生成于 %s)\n", *output, state.documentAt.Format(time.RFC3339))
+			}
+			state.mu.Unlock()
+
+			<-ticker.C
+		}
+	}
+}
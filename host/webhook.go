@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Webhook 签名头，值为 sha256=<请求体 HMAC-SHA256 十六进制>
+const webhookSignatureHeader = "X-Attestation-Signature"
+
+// 将证明文档及校验结果推送到外部 HTTP 服务
+type webhook struct {
+	url    string
+	secret []byte
+	client *http.Client
+}
+
+// 推送的请求体
+type webhookPayload struct {
+	verificationReport
+	// base64 编码的原始证明文档 (COSE_Sign1)
+	RawDocument string `json:"raw_document"`
+}
+
+// 创建 Webhook，secretFile 为空时不签名
+func newWebhook(url string, secretFile string) (*webhook, error) {
+	if !strings.HasPrefix(url, "https://") && !strings.HasPrefix(url, "http://") {
+		return nil, fmt.Errorf("无效的 Webhook 地址: %s", url)
+	}
+	w := &webhook{url: url, client: &http.Client{Timeout: 10 * time.Second}}
+	if secretFile != "" {
+		secret, err := os.ReadFile(secretFile)
+		if err != nil {
+			return nil, fmt.Errorf("读取 Webhook 密钥文件失败: %v", err)
+		}
+		w.secret = []byte(strings.TrimRight(string(secret), "\r\n"))
+	}
+	return w, nil
+}
+
+// 请求体的 HMAC-SHA256 签名
+func signWebhookBody(secret []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// 推送一份文档，失败时最多重试 3 次
+func (w *webhook) deliver(ctx context.Context, raw []byte, verifyErr error, verified bool) error {
+	body, err := json.Marshal(webhookPayload{
+		verificationReport: newVerificationReport(raw, verifyErr, verified),
+		RawDocument:        base64.StdEncoding.EncodeToString(raw),
+	})
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < 3; attempt++ {
+		if attempt > 0 {
+			time.Sleep(time.Duration(attempt) * time.Second)
+		}
+		if lastErr = w.post(ctx, body); lastErr == nil {
+			return nil
+		}
+	}
+	return lastErr
+}
+
+func (w *webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.secret != nil {
+		req.Header.Set(webhookSignatureHeader, signWebhookBody(w.secret, body))
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("推送到 Webhook 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16))
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Webhook 返回 %s", resp.Status)
+	}
+	return nil
+}
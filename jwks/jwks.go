@@ -0,0 +1,168 @@
+// Package jwks 实现发布 ECDSA 签名公钥所需的 JWK / JWK Set 编码 (RFC 7517, RFC 7638)。
+package jwks
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+)
+
+// JWK Set
+type Set struct {
+	Keys []Key `json:"keys"`
+}
+
+// EC 公钥的 JWK
+type Key struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	Kid string `json:"kid,omitempty"`
+	Use string `json:"use,omitempty"`
+	Alg string `json:"alg,omitempty"`
+
+	// base64 编码的证明文档，其 public_key 为该公钥 (非标准成员)
+	Attestation string `json:"attestation,omitempty"`
+}
+
+// 曲线名、JWS 算法及坐标长度
+var curves = map[elliptic.Curve]struct {
+	name string
+	alg  string
+	size int
+}{
+	elliptic.P256(): {"P-256", "ES256", 32},
+	elliptic.P384(): {"P-384", "ES384", 48},
+	elliptic.P521(): {"P-521", "ES512", 66},
+}
+
+// 将 ECDSA 公钥编码为 JWK，kid 为 RFC 7638 指纹
+func FromECDSA(key *ecdsa.PublicKey) (Key, error) {
+	curve, ok := curves[key.Curve]
+	if !ok {
+		return Key{}, fmt.Errorf("不支持的曲线: %s", key.Curve.Params().Name)
+	}
+
+	x := make([]byte, curve.size)
+	y := make([]byte, curve.size)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	k := Key{
+		Kty: "EC",
+		Crv: curve.name,
+		X:   base64.RawURLEncoding.EncodeToString(x),
+		Y:   base64.RawURLEncoding.EncodeToString(y),
+		Use: "sig",
+		Alg: curve.alg,
+	}
+	k.Kid = k.Thumbprint()
+	return k, nil
+}
+
+// RFC 7638 JWK 指纹
+func (k Key) Thumbprint() string {
+	canonical := fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, k.Crv, k.Kty, k.X, k.Y)
+	sum := sha256.Sum256([]byte(canonical))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// 解码为 ECDSA 公钥
+func (k Key) PublicKey() (*ecdsa.PublicKey, error) {
+	if k.Kty != "EC" {
+		return nil, fmt.Errorf("不支持的密钥类型: %s", k.Kty)
+	}
+	for curve, info := range curves {
+		if info.name != k.Crv {
+			continue
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("解码 x 坐标失败: %v", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("解码 y 坐标失败: %v", err)
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("公钥不在曲线 %s 上", k.Crv)
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("不支持的曲线: %s", k.Crv)
+}
+
+// 按 kid 查找密钥
+func (s Set) Lookup(kid string) (Key, bool) {
+	for _, k := range s.Keys {
+		if k.Kid == kid {
+			return k, true
+		}
+	}
+	return Key{}, false
+}
+
+// 从 PEM 文件加载 ECDSA 私钥 (SEC1 或 PKCS#8)
+func LoadPrivateKey(path string) (*ecdsa.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取签名密钥失败: %v", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("解析 PEM 格式签名密钥失败")
+	}
+
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析签名密钥失败: %v", err)
+	}
+	key, ok := parsed.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("签名密钥不是 ECDSA 私钥")
+	}
+	return key, nil
+}
+
+// 校验 attestation 成员中的证明文档，并确认其 public_key 与该 JWK 一致
+func (k Key) VerifyAttestation(opts attestation.VerifyOptions) (*attestation.SignedDocument, error) {
+	if k.Attestation == "" {
+		return nil, fmt.Errorf("密钥 %s 未携带证明文档", k.Kid)
+	}
+	raw, err := base64.StdEncoding.DecodeString(k.Attestation)
+	if err != nil {
+		return nil, fmt.Errorf("解码证明文档失败: %v", err)
+	}
+
+	doc, err := attestation.Verify(raw, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	attested, err := x509.ParsePKIXPublicKey(doc.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("解析证明文档中的 public_key 失败: %v", err)
+	}
+	public, err := k.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	if !public.Equal(attested) {
+		return nil, fmt.Errorf("证明文档中的 public_key 与密钥 %s 不一致", k.Kid)
+	}
+	return doc, nil
+}
@@ -0,0 +1,121 @@
+// Package nitro 供编译进 Enclave 的 Go 应用直接通过 /dev/nsm 获取证明文档，不经过本地的证明服务。
+package nitro
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+)
+
+// NSM 对各输入的长度上限 (字节)
+const (
+	MaxUserDataSize  = 512
+	MaxNonceSize     = 512
+	MaxPublicKeySize = 1024
+)
+
+// 无法打开或调用 NSM 设备 (不在 Nitro Enclave 中运行或非 Linux 系统)
+var ErrUnavailable = errors.New("NSM 不可用")
+
+// NSM 返回的错误码，如 InvalidArgument、InputTooLarge
+type Error string
+
+func (e Error) Error() string {
+	return "NSM 返回错误: " + string(e)
+}
+
+// 证明文档的输入，空的输入不包含在文档中
+type AttestOptions struct {
+	UserData []byte
+	Nonce    []byte
+	// DER 格式的 SubjectPublicKeyInfo 或其他公钥编码
+	PublicKey []byte
+}
+
+func (o AttestOptions) validate() error {
+	if len(o.UserData) > MaxUserDataSize {
+		return fmt.Errorf("user_data 超过 NSM 上限 %d 字节", MaxUserDataSize)
+	}
+	if len(o.Nonce) > MaxNonceSize {
+		return fmt.Errorf("nonce 超过 NSM 上限 %d 字节", MaxNonceSize)
+	}
+	if len(o.PublicKey) > MaxPublicKeySize {
+		return fmt.Errorf("public_key 超过 NSM 上限 %d 字节", MaxPublicKeySize)
+	}
+	return nil
+}
+
+// 请求 NSM 生成证明文档，返回 COSE_Sign1 原始字节，可用 attestation.Parse / attestation.Verify 解析校验。
+// ctx 取消时立即返回，已发出的 ioctl 在后台完成
+func Attest(ctx context.Context, opts AttestOptions) ([]byte, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	optional := func(b []byte) []byte {
+		if len(b) == 0 {
+			return nil
+		}
+		return b
+	}
+	request := map[string]interface{}{"Attestation": map[string][]byte{
+		"user_data":  optional(opts.UserData),
+		"nonce":      optional(opts.Nonce),
+		"public_key": optional(opts.PublicKey),
+	}}
+
+	type result struct {
+		document []byte
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		var response struct {
+			Document []byte `cbor:"document"`
+		}
+		err := call(request, "Attestation", &response)
+		done <- result{response.Document, err}
+	}()
+	select {
+	case r := <-done:
+		return r.document, r.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 向 NSM 发送一个 CBOR 编码的请求，将响应中 name 对应的结果解码到 out，
+// 请求格式与 aws-nitro-enclaves-nsm-api 相同
+func call(request interface{}, name string, out interface{}) error {
+	payload, err := cbor.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("编码 NSM 请求失败: %v", err)
+	}
+	response, err := send(payload)
+	if err != nil {
+		return err
+	}
+
+	var decoded map[string]cbor.RawMessage
+	if err := cbor.Unmarshal(response, &decoded); err != nil {
+		return fmt.Errorf("解析 NSM 响应失败: %v", err)
+	}
+	if raw, ok := decoded["Error"]; ok {
+		var code string
+		cbor.Unmarshal(raw, &code)
+		return Error(code)
+	}
+	raw, ok := decoded[name]
+	if !ok {
+		return errors.New("NSM 响应中没有 " + name)
+	}
+	if err := cbor.Unmarshal(raw, out); err != nil {
+		return fmt.Errorf("解析 NSM %s 响应失败: %v", name, err)
+	}
+	return nil
+}
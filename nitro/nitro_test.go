@@ -0,0 +1,29 @@
+package nitro
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+// 超过 NSM 上限的输入在调用设备前被拒绝，没有 /dev/nsm 时返回 ErrUnavailable
+func TestAttestWithoutNSM(t *testing.T) {
+	ctx := context.Background()
+	if _, err := Attest(ctx, AttestOptions{UserData: make([]byte, MaxUserDataSize+1)}); err == nil || errors.Is(err, ErrUnavailable) {
+		t.Fatalf("超长 user_data 未在调用设备前拒绝: %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := Attest(canceled, AttestOptions{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ctx 已取消时应返回 context.Canceled: %v", err)
+	}
+
+	if _, err := os.Stat("/dev/nsm"); err == nil {
+		t.Skip("存在 /dev/nsm")
+	}
+	if _, err := Attest(ctx, AttestOptions{Nonce: []byte("nonce")}); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("没有 /dev/nsm 时应返回 ErrUnavailable: %v", err)
+	}
+}
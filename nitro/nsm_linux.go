@@ -0,0 +1,45 @@
+//go:build linux
+
+package nitro
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// NSM 驱动设备及请求 ioctl: _IOWR(0x0A, 0, struct nsm_message)
+const (
+	devicePath   = "/dev/nsm"
+	ioctlRequest = 0xC0200A00
+
+	// NSM 响应的最大长度
+	maxResponseSize = 0x3000
+)
+
+// ioctl 参数: 请求和响应缓冲区
+type message struct {
+	request  syscall.Iovec
+	response syscall.Iovec
+}
+
+// 通过 /dev/nsm 的 ioctl 发送请求并返回 CBOR 响应
+func send(payload []byte) ([]byte, error) {
+	device, err := os.OpenFile(devicePath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 打开 %s 失败: %v", ErrUnavailable, devicePath, err)
+	}
+	defer device.Close()
+
+	response := make([]byte, maxResponseSize)
+	var msg message
+	msg.request.Base = &payload[0]
+	msg.request.SetLen(len(payload))
+	msg.response.Base = &response[0]
+	msg.response.SetLen(len(response))
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, device.Fd(), ioctlRequest, uintptr(unsafe.Pointer(&msg))); errno != 0 {
+		return nil, fmt.Errorf("%w: ioctl 失败: %v", ErrUnavailable, errno)
+	}
+	return response[:msg.response.Len], nil
+}
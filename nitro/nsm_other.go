@@ -0,0 +1,10 @@
+//go:build !linux
+
+package nitro
+
+import "fmt"
+
+// Nitro Enclave 只运行 Linux
+func send(payload []byte) ([]byte, error) {
+	return nil, fmt.Errorf("%w: 仅支持 Linux", ErrUnavailable)
+}
@@ -0,0 +1,345 @@
+package oci
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OCI 媒体类型
+const (
+	MediaTypeManifest = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeConfig   = "application/vnd.oci.image.config.v1+json"
+	// Docker 镜像清单，解析镜像引用时一并接受
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeIndex          = "application/vnd.oci.image.index.v1+json"
+)
+
+// Docker Hub 的镜像仓库地址
+const dockerHub = "registry-1.docker.io"
+
+// 镜像引用: registry/repository[:tag][@digest]
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// 解析镜像引用，省略镜像仓库时为 Docker Hub，省略标签和摘要时为 latest
+func ParseReference(s string) (Reference, error) {
+	var ref Reference
+	rest := s
+	if name, digest, ok := strings.Cut(rest, "@"); ok {
+		if !strings.HasPrefix(digest, "sha256:") || len(digest) != len("sha256:")+64 {
+			return ref, fmt.Errorf("无效的镜像摘要: %s", digest)
+		}
+		rest, ref.Digest = name, digest
+	}
+	if slash := strings.LastIndex(rest, "/"); strings.LastIndex(rest, ":") > slash {
+		colon := strings.LastIndex(rest, ":")
+		rest, ref.Tag = rest[:colon], rest[colon+1:]
+	}
+	first, remainder, ok := strings.Cut(rest, "/")
+	if ok && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		ref.Registry, ref.Repository = first, remainder
+	} else {
+		ref.Registry, ref.Repository = dockerHub, rest
+		if !strings.Contains(rest, "/") {
+			ref.Repository = "library/" + rest
+		}
+	}
+	if ref.Repository == "" {
+		return ref, fmt.Errorf("无效的镜像引用: %s", s)
+	}
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = "latest"
+	}
+	return ref, nil
+}
+
+func (r Reference) String() string {
+	s := r.Registry + "/" + r.Repository
+	if r.Tag != "" {
+		s += ":" + r.Tag
+	}
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+	return s
+}
+
+// 镜像清单中的内容描述
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// OCI 镜像清单
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// OCI Distribution API 客户端，支持匿名、Basic 和 Bearer 令牌认证
+type Client struct {
+	Username string
+	Password string
+
+	// 使用 HTTP 而不是 HTTPS 访问镜像仓库 (本地测试仓库)
+	PlainHTTP bool
+
+	HTTPClient *http.Client
+
+	// 按 realm/service/scope 缓存的 Bearer 令牌
+	tokens map[string]string
+}
+
+// 内容的 sha256 摘要
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func (c *Client) baseURL(ref Reference) string {
+	scheme := "https"
+	if c.PlainHTTP {
+		scheme = "http"
+	}
+	return fmt.Sprintf("%s://%s/v2/%s", scheme, ref.Registry, ref.Repository)
+}
+
+// 解析镜像引用对应的清单摘要，引用已含摘要时直接返回
+func (c *Client) Resolve(ctx context.Context, ref Reference) (string, error) {
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	accept := strings.Join([]string{MediaTypeManifest, mediaTypeIndex, mediaTypeDockerManifest, mediaTypeDockerList}, ", ")
+	resp, body, err := c.do(ctx, ref, http.MethodGet, c.baseURL(ref)+"/manifests/"+ref.Tag, nil, map[string]string{"Accept": accept})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError("获取镜像清单", resp, body)
+	}
+	if digest := resp.Header.Get("Docker-Content-Digest"); digest != "" {
+		return digest, nil
+	}
+	return Digest(body), nil
+}
+
+// 读取标签对应的 OCI 清单，不存在时返回 nil
+func (c *Client) GetManifest(ctx context.Context, ref Reference, tag string) (*Manifest, error) {
+	resp, body, err := c.do(ctx, ref, http.MethodGet, c.baseURL(ref)+"/manifests/"+tag, nil, map[string]string{"Accept": MediaTypeManifest})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError("获取清单", resp, body)
+	}
+	var manifest Manifest
+	if err := json.Unmarshal(body, &manifest); err != nil {
+		return nil, fmt.Errorf("解析清单失败: %v", err)
+	}
+	return &manifest, nil
+}
+
+// 上传 blob (已存在时跳过)，返回其描述
+func (c *Client) PushBlob(ctx context.Context, ref Reference, mediaType string, data []byte) (Descriptor, error) {
+	desc := Descriptor{MediaType: mediaType, Digest: Digest(data), Size: int64(len(data))}
+	resp, body, err := c.do(ctx, ref, http.MethodHead, c.baseURL(ref)+"/blobs/"+desc.Digest, nil, nil)
+	if err != nil {
+		return desc, err
+	}
+	if resp.StatusCode == http.StatusOK {
+		return desc, nil
+	}
+
+	resp, body, err = c.do(ctx, ref, http.MethodPost, c.baseURL(ref)+"/blobs/uploads/", nil, nil)
+	if err != nil {
+		return desc, err
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return desc, statusError("开始上传 blob", resp, body)
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return desc, fmt.Errorf("无效的上传地址: %v", err)
+	}
+	query := location.Query()
+	query.Set("digest", desc.Digest)
+	location.RawQuery = query.Encode()
+
+	resp, body, err = c.do(ctx, ref, http.MethodPut, location.String(), data, map[string]string{"Content-Type": "application/octet-stream"})
+	if err != nil {
+		return desc, err
+	}
+	if resp.StatusCode != http.StatusCreated {
+		return desc, statusError("上传 blob", resp, body)
+	}
+	return desc, nil
+}
+
+// 以标签上传清单，返回清单摘要
+func (c *Client) PutManifest(ctx context.Context, ref Reference, tag string, manifest *Manifest) (string, error) {
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+	resp, body, err := c.do(ctx, ref, http.MethodPut, c.baseURL(ref)+"/manifests/"+tag, data, map[string]string{"Content-Type": manifest.MediaType})
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", statusError("上传清单", resp, body)
+	}
+	return Digest(data), nil
+}
+
+// 发送请求，收到 401 时按 WWW-Authenticate 认证后重试一次
+func (c *Client) do(ctx context.Context, ref Reference, method, target string, body []byte, headers map[string]string) (*http.Response, []byte, error) {
+	authorization := ""
+	for attempt := 0; ; attempt++ {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, reader)
+		if err != nil {
+			return nil, nil, err
+		}
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp, err := c.httpClient().Do(req)
+		if err != nil {
+			return nil, nil, fmt.Errorf("请求镜像仓库失败: %v", err)
+		}
+		data, err := io.ReadAll(io.LimitReader(resp.Body, 16<<20))
+		resp.Body.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取镜像仓库响应失败: %v", err)
+		}
+		if resp.StatusCode != http.StatusUnauthorized || attempt > 0 {
+			return resp, data, nil
+		}
+		if authorization, err = c.authorize(ctx, ref, resp.Header.Get("WWW-Authenticate")); err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// 按认证质询生成 Authorization 头
+func (c *Client) authorize(ctx context.Context, ref Reference, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if c.Username == "" {
+			return "", fmt.Errorf("镜像仓库要求认证，请指定用户名和密码")
+		}
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(c.Username, c.Password)
+		return req.Header.Get("Authorization"), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("不支持的认证方式: %q", challenge)
+	}
+
+	scope := params["scope"]
+	if scope == "" {
+		scope = "repository:" + ref.Repository + ":pull,push"
+	}
+	key := params["realm"] + " " + params["service"] + " " + scope
+	if token, ok := c.tokens[key]; ok {
+		return "Bearer " + token, nil
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("无效的认证 realm: %q", params["realm"])
+	}
+	query := realm.Query()
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if c.Username != "" {
+		req.SetBasicAuth(c.Username, c.Password)
+	}
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", fmt.Errorf("获取镜像仓库令牌失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if resp.StatusCode != http.StatusOK {
+		return "", statusError("获取镜像仓库令牌", resp, data)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(data, &token); err != nil {
+		return "", fmt.Errorf("解析镜像仓库令牌失败: %v", err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if c.tokens == nil {
+		c.tokens = make(map[string]string)
+	}
+	c.tokens[key] = token.Token
+	return "Bearer " + token.Token, nil
+}
+
+// 解析 WWW-Authenticate: Bearer realm="...",service="...",scope="..."
+func parseChallenge(header string) (string, map[string]string) {
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(header), " ")
+	params := make(map[string]string)
+	for rest != "" {
+		var name, value string
+		name, rest, _ = strings.Cut(strings.TrimLeft(rest, " ,"), "=")
+		if strings.HasPrefix(rest, `"`) {
+			value, rest, _ = strings.Cut(rest[1:], `"`)
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+		}
+		params[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return scheme, params
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func statusError(action string, resp *http.Response, body []byte) error {
+	return fmt.Errorf("%s失败: %s %s", action, resp.Status, strings.TrimSpace(string(body)))
+}
@@ -0,0 +1,306 @@
+// Package oidc 实现以证明文档为凭据换取 OIDC ID Token 的 Broker。
+//
+// 不理解 Nitro 证明文档的系统 (AWS IAM OIDC 身份提供商、GCP Workload Identity
+// Federation 等) 只需信任 Broker 的 issuer，即可通过 sub 和 PCR 声明识别 Enclave。
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+)
+
+const (
+	// 随机数有效期
+	nonceTTL = 5 * time.Minute
+
+	// 请求体最大长度
+	maxRequestBody = 64 * 1024
+
+	// 发现文档和 JWKS 的路径
+	discoveryPath = "/.well-known/openid-configuration"
+	jwksPath      = "/.well-known/jwks.json"
+)
+
+// 可用作 sub 的证明文档字段
+const (
+	SubjectModuleID = "module_id"
+	SubjectPCR0     = "pcr0"
+)
+
+// Broker 配置
+type Config struct {
+	// issuer URL，必须是依赖方可通过 HTTPS 访问的 Broker 地址
+	Issuer string
+
+	// 允许请求的 audience，例如 sts.amazonaws.com 或 GCP WIF 提供商的资源名
+	Audiences []string
+
+	// sub 声明取自证明文档的字段: module_id 或 pcrN
+	Subject string
+
+	// ID Token 签名密钥 (ECDSA P-256)
+	SigningKey *ecdsa.PrivateKey
+
+	// ID Token 有效期
+	TokenTTL time.Duration
+
+	// 签发前要求匹配的 PCR，键为 PCR 索引，值为十六进制，为空时不限制
+	ExpectedPCRs map[int]string
+
+	// 证明文档校验选项
+	Verify attestation.VerifyOptions
+
+	// 是否接受调试模式 Enclave (PCR0/1/2 全为零) 的文档
+	AllowDebug bool
+}
+
+// 校验证明文档并签发 OIDC ID Token 的 HTTP 服务
+//
+//	GET  /.well-known/openid-configuration  OIDC 发现文档
+//	GET  /.well-known/jwks.json             ID Token 签名公钥
+//	POST /v1/nonce                          获取一次性随机数
+//	POST /v1/token                          提交携带随机数的证明文档，换取 ID Token
+type Broker struct {
+	config Config
+	key    jwks.Key
+	mux    *http.ServeMux
+	nonces *attestation.NonceStore
+}
+
+// 创建 Broker
+func New(config Config) (*Broker, error) {
+	if config.SigningKey == nil {
+		return nil, fmt.Errorf("未指定 ID Token 签名密钥")
+	}
+	if !strings.HasPrefix(config.Issuer, "https://") && !strings.HasPrefix(config.Issuer, "http://") {
+		return nil, fmt.Errorf("issuer 必须是 URL: %q", config.Issuer)
+	}
+	config.Issuer = strings.TrimRight(config.Issuer, "/")
+	if len(config.Audiences) == 0 {
+		return nil, fmt.Errorf("未配置允许的 audience")
+	}
+	if config.Subject == "" {
+		config.Subject = SubjectPCR0
+	}
+	if _, err := subjectPCR(config.Subject); config.Subject != SubjectModuleID && err != nil {
+		return nil, err
+	}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = 15 * time.Minute
+	}
+
+	key, err := jwks.FromECDSA(&config.SigningKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	if key.Alg != "ES256" {
+		return nil, fmt.Errorf("ID Token 签名密钥必须是 P-256 曲线")
+	}
+
+	b := &Broker{
+		config: config,
+		key:    key,
+		mux:    http.NewServeMux(),
+		nonces: &attestation.NonceStore{TTL: nonceTTL},
+	}
+	b.mux.HandleFunc(discoveryPath, b.handleDiscovery)
+	b.mux.HandleFunc(jwksPath, b.handleJWKS)
+	b.mux.HandleFunc("/v1/nonce", b.handleNonce)
+	b.mux.HandleFunc("/v1/token", b.handleToken)
+	return b, nil
+}
+
+func (b *Broker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// 获取随机数的响应
+type NonceResponse struct {
+	Nonce string `json:"nonce"`
+}
+
+// 换取 ID Token 的请求
+type TokenRequest struct {
+	// base64 编码的证明文档
+	Document string `json:"document"`
+	// 请求的 audience，为空时使用配置的第一个
+	Audience string `json:"audience,omitempty"`
+}
+
+// 换取 ID Token 的响应
+type TokenResponse struct {
+	IDToken   string `json:"id_token"`
+	TokenType string `json:"token_type"`
+	ExpiresIn int    `json:"expires_in"`
+}
+
+// OIDC 发现文档
+type discovery struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	ResponseTypesSupported           []string `json:"response_types_supported"`
+	SubjectTypesSupported            []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported"`
+	ClaimsSupported                  []string `json:"claims_supported"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (b *Broker) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, discovery{
+		Issuer:                           b.config.Issuer,
+		JWKSURI:                          b.config.Issuer + jwksPath,
+		ResponseTypesSupported:           []string{"id_token"},
+		SubjectTypesSupported:            []string{"public"},
+		IDTokenSigningAlgValuesSupported: []string{"ES256"},
+		ClaimsSupported:                  []string{"iss", "sub", "aud", "iat", "exp", "module_id", "pcr0", "pcr1", "pcr2", "pcr3", "pcr4", "pcr8"},
+	})
+}
+
+func (b *Broker) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jwks.Set{Keys: []jwks.Key{b.key}})
+}
+
+func (b *Broker) handleNonce(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+		return
+	}
+
+	nonce, err := b.nonces.Issue()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "生成随机数失败")
+		return
+	}
+	writeJSON(w, http.StatusOK, NonceResponse{Nonce: nonce})
+}
+
+func (b *Broker) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+		return
+	}
+
+	var req TokenRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+
+	audience := req.Audience
+	if audience == "" {
+		audience = b.config.Audiences[0]
+	}
+	if !b.allowsAudience(audience) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("不允许的 audience: %s", audience))
+		return
+	}
+
+	doc, err := attestation.Verify(attestation.Decode([]byte(req.Document)), b.config.Verify)
+	if err != nil {
+		log.Printf("拒绝证明文档: %v\n", err)
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if doc.IsDebug() && !b.config.AllowDebug {
+		log.Printf("拒绝调试模式 Enclave 的证明文档: %s\n", doc.ModuleID)
+		writeError(w, http.StatusForbidden, "不接受调试模式 Enclave 的证明文档")
+		return
+	}
+	if !b.nonces.Consume(string(doc.Nonce)) {
+		writeError(w, http.StatusUnauthorized, "证明文档中的随机数无效或已过期")
+		return
+	}
+	for index, want := range b.config.ExpectedPCRs {
+		if hex.EncodeToString(doc.PCRs[index]) != strings.ToLower(want) {
+			log.Printf("模块 %s 的 PCR%d 不匹配\n", doc.ModuleID, index)
+			writeError(w, http.StatusForbidden, fmt.Sprintf("PCR%d 不匹配", index))
+			return
+		}
+	}
+
+	token, err := b.issue(doc, audience)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("签发 ID Token 失败: %v", err))
+		return
+	}
+
+	log.Printf("已为模块 %s 签发 audience 为 %s 的 ID Token\n", doc.ModuleID, audience)
+	writeJSON(w, http.StatusOK, TokenResponse{
+		IDToken:   token,
+		TokenType: "N_A",
+		ExpiresIn: int(b.config.TokenTTL / time.Second),
+	})
+}
+
+func (b *Broker) allowsAudience(audience string) bool {
+	for _, a := range b.config.Audiences {
+		if a == audience {
+			return true
+		}
+	}
+	return false
+}
+
+// 签发 ID Token，sub 取自配置的证明文档字段
+func (b *Broker) issue(doc *attestation.SignedDocument, audience string) (string, error) {
+	subject := doc.ModuleID
+	if b.config.Subject != SubjectModuleID {
+		index, _ := subjectPCR(b.config.Subject)
+		value, ok := doc.PCRs[index]
+		if !ok {
+			return "", fmt.Errorf("证明文档缺少 PCR%d", index)
+		}
+		subject = hex.EncodeToString(value)
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       b.config.Issuer,
+		"sub":       subject,
+		"aud":       audience,
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"exp":       now.Add(b.config.TokenTTL).Unix(),
+		"module_id": doc.ModuleID,
+	}
+	for index, value := range doc.PCRs {
+		claims["pcr"+strconv.Itoa(index)] = hex.EncodeToString(value)
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = b.key.Kid
+	return token.SignedString(b.config.SigningKey)
+}
+
+// 解析 pcrN 形式的 sub 配置
+func subjectPCR(subject string) (int, error) {
+	index, err := strconv.Atoi(strings.TrimPrefix(subject, "pcr"))
+	if !strings.HasPrefix(subject, "pcr") || err != nil || index < 0 || index >= 32 {
+		return 0, fmt.Errorf("无效的 sub 字段: %q (应为 module_id 或 pcrN)", subject)
+	}
+	return index, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
@@ -0,0 +1,56 @@
+package oidc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// 向 Broker 获取一次性随机数
+func RequestNonce(ctx context.Context, brokerURL string) (string, error) {
+	var resp NonceResponse
+	if err := post(ctx, brokerURL, "/v1/nonce", struct{}{}, &resp); err != nil {
+		return "", err
+	}
+	return resp.Nonce, nil
+}
+
+// 向 Broker 提交证明文档换取 ID Token
+func Exchange(ctx context.Context, brokerURL, document, audience string) (*TokenResponse, error) {
+	var resp TokenResponse
+	req := TokenRequest{Document: document, Audience: audience}
+	if err := post(ctx, brokerURL, "/v1/token", req, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func post(ctx context.Context, brokerURL, path string, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimRight(brokerURL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 Broker 失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		json.NewDecoder(io.LimitReader(resp.Body, maxRequestBody)).Decode(&apiErr)
+		return fmt.Errorf("Broker 返回 %d: %s", resp.StatusCode, apiErr.Error)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxRequestBody)).Decode(out)
+}
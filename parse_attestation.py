@@ -10,12 +10,31 @@ from cryptography.hazmat.primitives import hashes
 from cryptography.hazmat.primitives.asymmetric import padding, ec
 from cryptography.exceptions import InvalidSignature
 
+PEM_BEGIN = b"-----BEGIN ATTESTATION DOCUMENT-----"
+PEM_END = b"-----END ATTESTATION DOCUMENT-----"
+
+def decode_pem(content):
+    """如果内容包含 PEM 格式的证明文档，返回其中的原始字节"""
+    start = content.find(PEM_BEGIN)
+    if start < 0:
+        return content
+    end = content.find(PEM_END, start)
+    if end < 0:
+        return content
+    body = content[start + len(PEM_BEGIN):end]
+    # 跳过可能存在的 PEM 头部字段 (Key: Value)
+    lines = [line for line in body.splitlines() if line.strip() and b":" not in line]
+    return base64.b64decode(b"".join(lines))
+
 def parse_attestation_doc(file_path):
     """解析 AWS Nitro 证明文档"""
     try:
         with open(file_path, 'rb') as f:
             content = f.read()
         
+        # PEM 格式 (-----BEGIN ATTESTATION DOCUMENT-----) 先去掉封装并解码 base64
+        content = decode_pem(content)
+        
         # 解析 CBOR 格式的 COSE_Sign1 结构
         try:
             cose_sign1 = cbor2.loads(content)
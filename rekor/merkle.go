@@ -0,0 +1,94 @@
+package rekor
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// RFC 6962 叶子哈希: SHA-256(0x00 || 条目内容)
+func leafHash(data []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// RFC 6962 内部节点哈希: SHA-256(0x01 || 左 || 右)
+func nodeHash(left, right []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
+
+// 按 RFC 9162 2.1.3.2 由叶子哈希和审计路径计算根哈希并与 root 比较
+func verifyInclusion(leaf []byte, index, size int64, proof [][]byte, root []byte) error {
+	if index < 0 || index >= size {
+		return fmt.Errorf("包含证明无效: 索引 %d 超出树大小 %d", index, size)
+	}
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("包含证明无效: 审计路径过长")
+		}
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+		fn >>= 1
+		sn >>= 1
+	}
+	if sn != 0 {
+		return fmt.Errorf("包含证明无效: 审计路径过短")
+	}
+	if !bytes.Equal(r, root) {
+		return fmt.Errorf("包含证明无效: 计算出的根哈希与日志不一致")
+	}
+	return nil
+}
+
+// 检查点为签名的 note: 正文依次为来源、树大小、base64 根哈希，空行后为 "— <名称> <base64(4 字节密钥标识 || 签名)>"
+func verifyCheckpoint(checkpoint string, size int64, root []byte, key *ecdsa.PublicKey) error {
+	text, signatures, ok := strings.Cut(checkpoint, "\n\n")
+	if !ok {
+		return fmt.Errorf("无效的检查点")
+	}
+	lines := strings.Split(text, "\n")
+	if len(lines) < 3 {
+		return fmt.Errorf("无效的检查点")
+	}
+	if n, err := strconv.ParseInt(lines[1], 10, 64); err != nil || n != size {
+		return fmt.Errorf("检查点的树大小与包含证明不一致")
+	}
+	if hash, err := base64.StdEncoding.DecodeString(lines[2]); err != nil || !bytes.Equal(hash, root) {
+		return fmt.Errorf("检查点的根哈希与包含证明不一致")
+	}
+
+	digest := sha256.Sum256([]byte(text + "\n"))
+	for _, line := range strings.Split(signatures, "\n") {
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) != 2 {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sig) <= 4 {
+			continue
+		}
+		if ecdsa.VerifyASN1(key, digest[:], sig[4:]) {
+			return nil
+		}
+	}
+	return fmt.Errorf("检查点签名校验失败")
+}
@@ -0,0 +1,59 @@
+package rekor
+
+import (
+	"fmt"
+	"testing"
+)
+
+// RFC 6962 2.1 的递归定义，作为对照
+func largestPowerOfTwoBelow(n int) int {
+	k := 1
+	for k*2 < n {
+		k *= 2
+	}
+	return k
+}
+
+func merkleRoot(leaves [][]byte) []byte {
+	if len(leaves) == 1 {
+		return leaves[0]
+	}
+	k := largestPowerOfTwoBelow(len(leaves))
+	return nodeHash(merkleRoot(leaves[:k]), merkleRoot(leaves[k:]))
+}
+
+func auditPath(m int, leaves [][]byte) [][]byte {
+	if len(leaves) == 1 {
+		return nil
+	}
+	k := largestPowerOfTwoBelow(len(leaves))
+	if m < k {
+		return append(auditPath(m, leaves[:k]), merkleRoot(leaves[k:]))
+	}
+	return append(auditPath(m-k, leaves[k:]), merkleRoot(leaves[:k]))
+}
+
+// 各种树大小和位置的包含证明都能校验，篡改后失败
+func TestVerifyInclusion(t *testing.T) {
+	var leaves [][]byte
+	for size := 1; size <= 33; size++ {
+		leaves = append(leaves, leafHash([]byte(fmt.Sprint(size))))
+		root := merkleRoot(leaves)
+		for index := range leaves {
+			proof := auditPath(index, leaves)
+			if err := verifyInclusion(leaves[index], int64(index), int64(size), proof, root); err != nil {
+				t.Fatalf("size=%d index=%d: %v", size, index, err)
+			}
+			if size > 1 {
+				if err := verifyInclusion(leaves[(index+1)%size], int64(index), int64(size), proof, root); err == nil {
+					t.Fatalf("size=%d index=%d: 错误的叶子通过了校验", size, index)
+				}
+			}
+			if len(proof) > 0 {
+				if err := verifyInclusion(leaves[index], int64(index), int64(size), proof[:len(proof)-1], root); err == nil {
+					t.Fatalf("size=%d index=%d: 截断的审计路径通过了校验", size, index)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package rekor
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// 公共 Rekor 实例
+const DefaultURL = "https://rekor.sigstore.dev"
+
+// 透明日志条目，与 Rekor API 的 LogEntry 相同，另记录其 UUID
+type Entry struct {
+	UUID           string       `json:"uuid"`
+	Body           string       `json:"body"`
+	IntegratedTime int64        `json:"integratedTime"`
+	LogID          string       `json:"logID"`
+	LogIndex       int64        `json:"logIndex"`
+	Verification   Verification `json:"verification"`
+}
+
+// 条目的包含证明及签名时间戳
+type Verification struct {
+	InclusionProof       *InclusionProof `json:"inclusionProof,omitempty"`
+	SignedEntryTimestamp string          `json:"signedEntryTimestamp"`
+}
+
+// RFC 6962 Merkle 包含证明，LogIndex 为条目在当前分片树中的位置
+type InclusionProof struct {
+	Checkpoint string   `json:"checkpoint"`
+	Hashes     []string `json:"hashes"`
+	LogIndex   int64    `json:"logIndex"`
+	RootHash   string   `json:"rootHash"`
+	TreeSize   int64    `json:"treeSize"`
+}
+
+// hashedrekord 条目: 对文档 SHA-256 摘要的签名及签名公钥
+type hashedRekord struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Signature struct {
+			Content   string `json:"content"`
+			PublicKey struct {
+				Content string `json:"content"`
+			} `json:"publicKey"`
+		} `json:"signature"`
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+	} `json:"spec"`
+}
+
+// Rekor 客户端
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+// 以 key 对文档的 SHA-256 摘要签名 (副署)，作为 hashedrekord 条目上传
+func (c *Client) Upload(ctx context.Context, document []byte, key *ecdsa.PrivateKey) (*Entry, error) {
+	digest := sha256.Sum256(document)
+	signature, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("副署签名失败: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	publicKey := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	var entry hashedRekord
+	entry.APIVersion = "0.0.1"
+	entry.Kind = "hashedrekord"
+	entry.Spec.Signature.Content = base64.StdEncoding.EncodeToString(signature)
+	entry.Spec.Signature.PublicKey.Content = base64.StdEncoding.EncodeToString(publicKey)
+	entry.Spec.Data.Hash.Algorithm = "sha256"
+	entry.Spec.Data.Hash.Value = hex.EncodeToString(digest[:])
+
+	var entries map[string]Entry
+	if err := c.do(ctx, http.MethodPost, "/api/v1/log/entries", entry, &entries); err != nil {
+		return nil, err
+	}
+	return single(entries)
+}
+
+// 按文档的 SHA-256 摘要查找日志条目，有多个时返回第一个
+func (c *Client) Lookup(ctx context.Context, document []byte) (*Entry, error) {
+	digest := sha256.Sum256(document)
+	var uuids []string
+	query := map[string]string{"hash": "sha256:" + hex.EncodeToString(digest[:])}
+	if err := c.do(ctx, http.MethodPost, "/api/v1/index/retrieve", query, &uuids); err != nil {
+		return nil, err
+	}
+	if len(uuids) == 0 {
+		return nil, fmt.Errorf("透明日志中没有该文档的条目")
+	}
+	var entries map[string]Entry
+	if err := c.do(ctx, http.MethodGet, "/api/v1/log/entries/"+uuids[0], nil, &entries); err != nil {
+		return nil, err
+	}
+	return single(entries)
+}
+
+func single(entries map[string]Entry) (*Entry, error) {
+	for uuid, entry := range entries {
+		entry.UUID = uuid
+		return &entry, nil
+	}
+	return nil, fmt.Errorf("Rekor 未返回条目")
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	url := strings.TrimRight(c.URL, "/") + path
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 Rekor 失败: %v", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return fmt.Errorf("读取 Rekor 响应失败: %v", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Rekor 返回 %s: %s", resp.Status, strings.TrimSpace(string(data)))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析 Rekor 响应失败: %v", err)
+	}
+	return nil
+}
+
+// 保存条目，供之后离线校验
+func (e *Entry) Save(path string) error {
+	data, err := json.MarshalIndent(e, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// 加载保存的条目
+func LoadEntry(path string) (*Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Rekor 条目失败: %v", err)
+	}
+	var entry Entry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, fmt.Errorf("解析 Rekor 条目失败: %v", err)
+	}
+	return &entry, nil
+}
+
+// 从 PEM 文件加载 Rekor 的 ECDSA 公钥 (GET /api/v1/log/publicKey)
+func LoadPublicKey(path string) (*ecdsa.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 Rekor 公钥失败: %v", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("解析 PEM 格式 Rekor 公钥失败")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("解析 Rekor 公钥失败: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("Rekor 公钥不是 ECDSA 公钥")
+	}
+	return ecKey, nil
+}
+
+// 校验条目记录的是该文档: hashedrekord 的摘要与文档一致且副署签名有效；
+// 包含证明能推出检查点中的根哈希；logPublicKey 非空时校验检查点和 SET 的签名
+func (e *Entry) Verify(document []byte, logPublicKey *ecdsa.PublicKey) error {
+	body, err := base64.StdEncoding.DecodeString(e.Body)
+	if err != nil {
+		return fmt.Errorf("解析条目内容失败: %v", err)
+	}
+	var record hashedRekord
+	if err := json.Unmarshal(body, &record); err != nil {
+		return fmt.Errorf("解析条目内容失败: %v", err)
+	}
+	if record.Kind != "hashedrekord" || record.Spec.Data.Hash.Algorithm != "sha256" {
+		return fmt.Errorf("不支持的条目类型: %s", record.Kind)
+	}
+	digest := sha256.Sum256(document)
+	if !strings.EqualFold(record.Spec.Data.Hash.Value, hex.EncodeToString(digest[:])) {
+		return fmt.Errorf("条目中的摘要与文档不一致")
+	}
+	if err := verifyCountersignature(record, digest[:]); err != nil {
+		return err
+	}
+
+	proof := e.Verification.InclusionProof
+	if proof == nil {
+		return fmt.Errorf("条目没有包含证明")
+	}
+	root, err := hex.DecodeString(proof.RootHash)
+	if err != nil {
+		return fmt.Errorf("无效的根哈希: %v", err)
+	}
+	hashes := make([][]byte, len(proof.Hashes))
+	for i, h := range proof.Hashes {
+		if hashes[i], err = hex.DecodeString(h); err != nil {
+			return fmt.Errorf("无效的包含证明: %v", err)
+		}
+	}
+	if err := verifyInclusion(leafHash(body), proof.LogIndex, proof.TreeSize, hashes, root); err != nil {
+		return err
+	}
+
+	if logPublicKey == nil {
+		return nil
+	}
+	if err := verifyCheckpoint(proof.Checkpoint, proof.TreeSize, root, logPublicKey); err != nil {
+		return err
+	}
+	return e.verifySET(logPublicKey)
+}
+
+func verifyCountersignature(record hashedRekord, digest []byte) error {
+	publicKeyPEM, err := base64.StdEncoding.DecodeString(record.Spec.Signature.PublicKey.Content)
+	if err != nil {
+		return fmt.Errorf("解析副署公钥失败: %v", err)
+	}
+	block, _ := pem.Decode(publicKeyPEM)
+	if block == nil {
+		return fmt.Errorf("解析副署公钥失败")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("解析副署公钥失败: %v", err)
+	}
+	ecKey, ok := key.(*ecdsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("副署公钥不是 ECDSA 公钥")
+	}
+	signature, err := base64.StdEncoding.DecodeString(record.Spec.Signature.Content)
+	if err != nil || !ecdsa.VerifyASN1(ecKey, digest, signature) {
+		return fmt.Errorf("副署签名校验失败")
+	}
+	return nil
+}
+
+// SET 为 Rekor 对 {body, integratedTime, logID, logIndex} 规范 JSON 的签名
+func (e *Entry) verifySET(key *ecdsa.PublicKey) error {
+	// map 按键排序编码，无多余空白，即规范 JSON
+	payload, err := json.Marshal(map[string]interface{}{
+		"body":           e.Body,
+		"integratedTime": e.IntegratedTime,
+		"logID":          e.LogID,
+		"logIndex":       e.LogIndex,
+	})
+	if err != nil {
+		return err
+	}
+	signature, err := base64.StdEncoding.DecodeString(e.Verification.SignedEntryTimestamp)
+	if err != nil {
+		return fmt.Errorf("解析 SET 失败: %v", err)
+	}
+	digest := sha256.Sum256(payload)
+	if !ecdsa.VerifyASN1(key, digest[:], signature) {
+		return fmt.Errorf("SET 签名校验失败")
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package vault
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yourusername/aws-enclave-attestation/attestation"
+	"github.com/yourusername/aws-enclave-attestation/jwks"
+)
+
+const (
+	// 随机数有效期，证明文档必须在此时间内携带随机数提交
+	nonceTTL = 5 * time.Minute
+
+	// 请求体最大长度
+	maxRequestBody = 64 * 1024
+)
+
+// Bridge 配置
+type BridgeConfig struct {
+	// JWT 的 iss 和 aud，需与 Vault JWT 认证方法的 bound_issuer 和角色的 bound_audiences 一致
+	Issuer   string
+	Audience string
+
+	// JWT 签名密钥 (ECDSA P-256)
+	SigningKey *ecdsa.PrivateKey
+
+	// JWT 有效期
+	TokenTTL time.Duration
+
+	// PCR 到 Vault 角色的映射
+	Roles []Role
+
+	// 证明文档校验选项
+	Verify attestation.VerifyOptions
+
+	// 是否接受调试模式 Enclave (PCR0/1/2 全为零) 的文档
+	AllowDebug bool
+}
+
+// 校验证明文档并签发 JWT 的 HTTP 服务
+//
+//	POST /v1/nonce                 获取一次性随机数
+//	POST /v1/token                 提交携带随机数的证明文档，换取 JWT
+//	GET  /.well-known/jwks.json    JWT 签名公钥，供 Vault 的 jwks_url 使用
+type Bridge struct {
+	config BridgeConfig
+	key    jwks.Key
+	mux    *http.ServeMux
+	nonces *attestation.NonceStore
+}
+
+// 创建 Bridge
+func NewBridge(config BridgeConfig) (*Bridge, error) {
+	if config.SigningKey == nil {
+		return nil, fmt.Errorf("未指定 JWT 签名密钥")
+	}
+	if config.SigningKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("JWT 签名密钥必须是 P-256 曲线")
+	}
+	if config.Issuer == "" || config.Audience == "" {
+		return nil, fmt.Errorf("必须指定 JWT 的 issuer 和 audience")
+	}
+	if len(config.Roles) == 0 {
+		return nil, fmt.Errorf("未配置任何角色映射")
+	}
+	if config.TokenTTL <= 0 {
+		config.TokenTTL = 5 * time.Minute
+	}
+
+	key, err := jwks.FromECDSA(&config.SigningKey.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	b := &Bridge{
+		config: config,
+		key:    key,
+		mux:    http.NewServeMux(),
+		nonces: &attestation.NonceStore{TTL: nonceTTL},
+	}
+	b.mux.HandleFunc("/v1/nonce", b.handleNonce)
+	b.mux.HandleFunc("/v1/token", b.handleToken)
+	b.mux.HandleFunc("/.well-known/jwks.json", b.handleJWKS)
+	return b, nil
+}
+
+func (b *Bridge) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mux.ServeHTTP(w, r)
+}
+
+// 获取随机数的响应
+type NonceResponse struct {
+	Nonce string `json:"nonce"`
+}
+
+// 换取 JWT 的请求
+type TokenRequest struct {
+	// base64 编码的证明文档
+	Document string `json:"document"`
+}
+
+// 换取 JWT 的响应
+type TokenResponse struct {
+	Token string `json:"token"`
+	Role  string `json:"role"`
+}
+
+// 错误响应
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+func (b *Bridge) handleNonce(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+		return
+	}
+
+	nonce, err := b.nonces.Issue()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "生成随机数失败")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, NonceResponse{Nonce: nonce})
+}
+
+func (b *Bridge) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "仅支持 POST")
+		return
+	}
+
+	var req TokenRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("解析请求失败: %v", err))
+		return
+	}
+
+	doc, err := attestation.Verify(attestation.Decode([]byte(req.Document)), b.config.Verify)
+	if err != nil {
+		log.Printf("拒绝证明文档: %v\n", err)
+		writeError(w, http.StatusUnauthorized, err.Error())
+		return
+	}
+	if doc.IsDebug() && !b.config.AllowDebug {
+		log.Printf("拒绝调试模式 Enclave 的证明文档: %s\n", doc.ModuleID)
+		writeError(w, http.StatusForbidden, "不接受调试模式 Enclave 的证明文档")
+		return
+	}
+	if !b.nonces.Consume(string(doc.Nonce)) {
+		writeError(w, http.StatusUnauthorized, "证明文档中的随机数无效或已过期")
+		return
+	}
+
+	role := matchRole(b.config.Roles, doc.PCRs)
+	if role == nil {
+		log.Printf("模块 %s 的 PCR 不匹配任何角色\n", doc.ModuleID)
+		writeError(w, http.StatusForbidden, "PCR 不匹配任何角色")
+		return
+	}
+
+	token, err := b.issue(doc, role)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("签发 JWT 失败: %v", err))
+		return
+	}
+
+	log.Printf("已为模块 %s 签发角色 %s 的 JWT\n", doc.ModuleID, role.Name)
+	writeJSON(w, http.StatusOK, TokenResponse{Token: token, Role: role.Name})
+}
+
+// 签发包含 PCR 声明的 JWT
+func (b *Bridge) issue(doc *attestation.SignedDocument, role *Role) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":       b.config.Issuer,
+		"aud":       b.config.Audience,
+		"sub":       doc.ModuleID,
+		"iat":       now.Unix(),
+		"nbf":       now.Unix(),
+		"exp":       now.Add(b.config.TokenTTL).Unix(),
+		"role":      role.Name,
+		"module_id": doc.ModuleID,
+	}
+	for name, value := range pcrClaims(doc.PCRs) {
+		claims[name] = value
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	token.Header["kid"] = b.key.Kid
+	return token.SignedString(b.config.SigningKey)
+}
+
+func (b *Bridge) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, jwks.Set{Keys: []jwks.Key{b.key}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, errorResponse{Error: message})
+}
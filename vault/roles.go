@@ -0,0 +1,126 @@
+// Package vault 将 Nitro Enclave 证明文档桥接为 HashiCorp Vault JWT 认证方法可接受的令牌。
+//
+// Bridge 校验证明文档后签发包含 PCR 声明的 JWT，Vault 端的 JWT 认证角色通过
+// bound_claims 将 PCR 值映射到策略，只有度量值匹配的 Enclave 才能换取 Vault 令牌。
+package vault
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PCR 到 Vault 策略的映射
+type Role struct {
+	// Vault JWT 认证角色名
+	Name string `json:"name"`
+	// 期望的 PCR 值，键为 PCR 索引，值为十六进制
+	PCRs map[string]string `json:"pcrs"`
+	// 登录成功后授予的 Vault 策略
+	Policies []string `json:"policies"`
+	// Vault 令牌有效期，例如 "15m"
+	TTL string `json:"ttl,omitempty"`
+}
+
+// 角色映射配置文件
+type RoleConfig struct {
+	Roles []Role `json:"roles"`
+}
+
+// 从 JSON 文件加载角色映射
+func LoadRoles(path string) ([]Role, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取角色映射文件失败: %v", err)
+	}
+
+	var config RoleConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("解析角色映射文件失败: %v", err)
+	}
+	for i := range config.Roles {
+		if err := config.Roles[i].normalize(); err != nil {
+			return nil, err
+		}
+	}
+	return config.Roles, nil
+}
+
+// 检查角色定义并将 PCR 值统一为小写十六进制
+func (r *Role) normalize() error {
+	if r.Name == "" {
+		return fmt.Errorf("角色缺少 name")
+	}
+	if len(r.PCRs) == 0 {
+		return fmt.Errorf("角色 %s 未指定任何 PCR", r.Name)
+	}
+	if r.TTL != "" {
+		if _, err := time.ParseDuration(r.TTL); err != nil {
+			return fmt.Errorf("角色 %s 的 ttl 无效: %v", r.Name, err)
+		}
+	}
+
+	pcrs := make(map[string]string, len(r.PCRs))
+	for index, value := range r.PCRs {
+		i, err := strconv.Atoi(index)
+		if err != nil || i < 0 || i >= 32 {
+			return fmt.Errorf("角色 %s 的 PCR 索引 %q 无效", r.Name, index)
+		}
+		value = strings.ToLower(strings.TrimSpace(value))
+		if _, err := hex.DecodeString(value); err != nil {
+			return fmt.Errorf("角色 %s 的 PCR%d 不是十六进制: %v", r.Name, i, err)
+		}
+		pcrs[strconv.Itoa(i)] = value
+	}
+	r.PCRs = pcrs
+	return nil
+}
+
+// 角色要求的 PCR 是否全部与证明文档一致
+func (r *Role) matches(pcrs map[int][]byte) bool {
+	for index, want := range r.PCRs {
+		i, _ := strconv.Atoi(index)
+		got, ok := pcrs[i]
+		if !ok || hex.EncodeToString(got) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// Vault 角色中绑定的声明，键与 Bridge 签发的 JWT 声明一致
+func (r *Role) boundClaims() map[string]string {
+	claims := make(map[string]string, len(r.PCRs))
+	for index, value := range r.PCRs {
+		claims[pcrClaim(index)] = value
+	}
+	return claims
+}
+
+// 返回第一个与证明文档 PCR 匹配的角色
+func matchRole(roles []Role, pcrs map[int][]byte) *Role {
+	for i := range roles {
+		if roles[i].matches(pcrs) {
+			return &roles[i]
+		}
+	}
+	return nil
+}
+
+// PCR 声明名，例如 pcr0
+func pcrClaim(index string) string {
+	return "pcr" + index
+}
+
+// 证明文档中全部 PCR 对应的声明
+func pcrClaims(pcrs map[int][]byte) map[string]string {
+	claims := make(map[string]string, len(pcrs))
+	for i, value := range pcrs {
+		claims[pcrClaim(strconv.Itoa(i))] = hex.EncodeToString(value)
+	}
+	return claims
+}
@@ -0,0 +1,182 @@
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Vault HTTP API 客户端
+type Client struct {
+	// Vault 地址，例如 https://vault.example.com:8200
+	Address string
+	// Vault 企业版命名空间，可为空
+	Namespace string
+	// 已登录的 Vault 令牌
+	Token string
+
+	HTTPClient *http.Client
+}
+
+// Vault 登录返回的认证信息
+type Auth struct {
+	ClientToken   string   `json:"client_token"`
+	Accessor      string   `json:"accessor"`
+	Policies      []string `json:"policies"`
+	LeaseDuration int      `json:"lease_duration"`
+	Renewable     bool     `json:"renewable"`
+}
+
+// Vault API 通用响应
+type secretResponse struct {
+	Data   map[string]interface{} `json:"data"`
+	Auth   *Auth                  `json:"auth"`
+	Errors []string               `json:"errors"`
+}
+
+// 使用 Bridge 签发的 JWT 登录 Vault 的 JWT 认证方法
+func (c *Client) Login(ctx context.Context, mount, role, token string) (*Auth, error) {
+	body := map[string]string{"role": role, "jwt": token}
+
+	var resp secretResponse
+	if err := c.do(ctx, http.MethodPost, "auth/"+mount+"/login", body, &resp); err != nil {
+		return nil, err
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return nil, fmt.Errorf("Vault 登录响应缺少 client_token")
+	}
+
+	c.Token = resp.Auth.ClientToken
+	return resp.Auth, nil
+}
+
+// 读取密钥，path 为 API 路径，例如 secret/data/payments
+func (c *Client) Read(ctx context.Context, path string) (map[string]interface{}, error) {
+	var resp secretResponse
+	if err := c.do(ctx, http.MethodGet, path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Data, nil
+}
+
+// 在 Vault 中创建或更新 JWT 认证角色，将 PCR 声明绑定到策略
+func (c *Client) WriteRole(ctx context.Context, mount string, role Role, audience string) error {
+	body := map[string]interface{}{
+		"role_type":       "jwt",
+		"user_claim":      "sub",
+		"bound_audiences": []string{audience},
+		"bound_claims":    role.boundClaims(),
+		"token_policies":  role.Policies,
+	}
+	if role.TTL != "" {
+		body["token_ttl"] = role.TTL
+	}
+	return c.do(ctx, http.MethodPost, "auth/"+mount+"/role/"+role.Name, body, nil)
+}
+
+// 配置 JWT 认证方法信任 Bridge 的签名公钥
+func (c *Client) ConfigureJWTAuth(ctx context.Context, mount, jwksURL, issuer string) error {
+	body := map[string]string{
+		"jwks_url":     jwksURL,
+		"bound_issuer": issuer,
+	}
+	return c.do(ctx, http.MethodPost, "auth/"+mount+"/config", body, nil)
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	url := strings.TrimRight(c.Address, "/") + "/v1/" + strings.TrimLeft(path, "/")
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.Token != "" {
+		req.Header.Set("X-Vault-Token", c.Token)
+	}
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 Vault 失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("读取 Vault 响应失败: %v", err)
+	}
+	if resp.StatusCode >= 300 {
+		var apiErr secretResponse
+		if json.Unmarshal(data, &apiErr) == nil && len(apiErr.Errors) > 0 {
+			return fmt.Errorf("Vault 返回 %d: %s", resp.StatusCode, strings.Join(apiErr.Errors, "; "))
+		}
+		return fmt.Errorf("Vault 返回 %d", resp.StatusCode)
+	}
+
+	if out == nil || len(data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, out)
+}
+
+// 向 Bridge 获取一次性随机数
+func RequestNonce(ctx context.Context, bridgeURL string) (string, error) {
+	var resp NonceResponse
+	if err := postBridge(ctx, bridgeURL+"/v1/nonce", nil, &resp); err != nil {
+		return "", err
+	}
+	return resp.Nonce, nil
+}
+
+// 向 Bridge 提交证明文档换取 JWT
+func ExchangeDocument(ctx context.Context, bridgeURL, document string) (*TokenResponse, error) {
+	var resp TokenResponse
+	if err := postBridge(ctx, bridgeURL+"/v1/token", TokenRequest{Document: document}, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func postBridge(ctx context.Context, url string, body interface{}, out interface{}) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求 Bridge 失败: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var apiErr errorResponse
+		json.NewDecoder(io.LimitReader(resp.Body, maxRequestBody)).Decode(&apiErr)
+		return fmt.Errorf("Bridge 返回 %d: %s", resp.StatusCode, apiErr.Error)
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxRequestBody)).Decode(out)
+}